@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"aletterahead-api/isa"
+)
+
+// defaultJuniorISAAllowancePence is the Junior ISA subscription limit (£9,000 a tax year)
+const defaultJuniorISAAllowancePence = 900000
+
+// InitAllowance reads the Junior ISA allowance enforced on donations
+func InitAllowance() isa.Config {
+	cfg := isa.Config{
+		AllowancePence: defaultJuniorISAAllowancePence,
+		Mode:           getEnv("JUNIOR_ISA_LIMIT_MODE", isa.ModeReject),
+	}
+
+	if value := getEnv("JUNIOR_ISA_ALLOWANCE_PENCE", ""); value != "" {
+		pence, err := strconv.Atoi(value)
+		if err != nil || pence <= 0 {
+			log.Printf("Invalid JUNIOR_ISA_ALLOWANCE_PENCE %q, using %d", value, defaultJuniorISAAllowancePence)
+		} else {
+			cfg.AllowancePence = pence
+		}
+	}
+
+	if cfg.Mode != isa.ModeReject && cfg.Mode != isa.ModeWarn {
+		log.Printf("Invalid JUNIOR_ISA_LIMIT_MODE %q, using %q", cfg.Mode, isa.ModeReject)
+		cfg.Mode = isa.ModeReject
+	}
+
+	return cfg
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"aletterahead-api/auth"
+)
+
+func InitAuth() (*auth.Verifier, error) {
+	// Build issuer and JWKS location from the Auth0 tenant domain
+	domain := getEnv("AUTH0_DOMAIN", "")
+	audience := getEnv("AUTH0_AUDIENCE", "")
+	if domain == "" || audience == "" {
+		return nil, fmt.Errorf("AUTH0_DOMAIN and AUTH0_AUDIENCE must be set")
+	}
+
+	issuer := fmt.Sprintf("https://%s/", domain)
+
+	// AUTH0_JWKS_FILE lets tests and offline setups load signing keys from disk
+	jwksSource := getEnv("AUTH0_JWKS_FILE", issuer+".well-known/jwks.json")
+
+	// Anyone can sign tokens with the test keys, so only test setups may load them
+	testKeys, err := strconv.ParseBool(getEnv("AUTH0_TEST_KEYS", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH0_TEST_KEYS: %w", err)
+	}
+
+	verifier, err := auth.NewVerifier(issuer, audience, jwksSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Auth0 signing keys: %w", err)
+	}
+
+	if !testKeys && (strings.Contains(jwksSource, "tests/auth") || verifier.TrustsKey(auth.TestKeyID)) {
+		return nil, fmt.Errorf("AUTH0_JWKS_FILE %s holds the test signing keys from tests/auth; unset it, or set AUTH0_TEST_KEYS=true in a test setup", jwksSource)
+	}
+
+	return verifier, nil
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// subjectKey is the gin context key holding the authenticated Auth0 user ID
+const subjectKey = "auth0_sub"
+
+// Verifier validates Auth0 RS256 access tokens
+type Verifier struct {
+	issuer   string
+	audience string
+	keys     *keySet
+}
+
+// NewVerifier creates a Verifier for tokens issued by issuer for audience.
+// jwksSource is either the tenant's JWKS URL or a path to a local JWKS file.
+func NewVerifier(issuer, audience, jwksSource string) (*Verifier, error) {
+	keys, err := newKeySet(jwksSource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{
+		issuer:   issuer,
+		audience: audience,
+		keys:     keys,
+	}, nil
+}
+
+// TrustsKey reports whether tokens signed with the key kid are accepted
+func (v *Verifier) TrustsKey(kid string) bool {
+	v.keys.mu.Lock()
+	defer v.keys.mu.Unlock()
+	_, ok := v.keys.keys[kid]
+	return ok
+}
+
+// Verify parses and validates a raw access token, returning its subject
+func (v *Verifier) Verify(rawToken string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if claims.Subject == "" {
+		return "", fmt.Errorf("token has no subject")
+	}
+
+	return claims.Subject, nil
+}
+
+// Middleware rejects requests without a valid bearer token and stores the
+// caller's Auth0 user ID for handlers to read with Subject
+func (v *Verifier) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		rawToken, found := strings.CutPrefix(header, "Bearer ")
+		if !found || rawToken == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Missing bearer token",
+			})
+			return
+		}
+
+		subject, err := v.Verify(rawToken)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "Invalid access token",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.Set(subjectKey, subject)
+		c.Next()
+	}
+}
+
+// Subject returns the authenticated Auth0 user ID (the token's "sub" claim)
+func Subject(c *gin.Context) string {
+	return c.GetString(subjectKey)
+}
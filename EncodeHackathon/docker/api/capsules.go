@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"aletterahead-api/jobs"
+	"aletterahead-api/media"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runCapsules handles the `capsules [-dry-run] [-child 12] [-resend] [-today 2035-07-15]`
+// subcommand: one pass of time capsule delivery, reporting each child. Capsules
+// that have been sent are skipped, so it is safe to run again.
+func runCapsules(db *pgxpool.Pool, args []string) error {
+	flags := flag.NewFlagSet("capsules", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be sent")
+	childID := flags.Int("child", 0, "only deliver to this child")
+	resend := flags.Bool("resend", false, "email a fresh link for capsules that have been sent")
+	today := flags.String("today", time.Now().Format("2006-01-02"), "deliver capsules due by this date")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dueBy, err := time.Parse("2006-01-02", *today)
+	if err != nil {
+		return fmt.Errorf("today must be a date like 2035-07-15")
+	}
+	if *resend && *childID == 0 {
+		return fmt.Errorf("resend needs a child")
+	}
+
+	links := media.LinkSigner{
+		Secret: []byte(getEnv("VIDEO_LINK_SECRET", "")),
+		TTL:    getDurationEnv("CAPSULE_LINK_TTL", 365*24*time.Hour),
+	}
+	mail := InitMailer()
+	deliverer := newCapsuleDeliverer(db, mail, links)
+	deliverer.DryRun = *dryRun
+
+	// A link signed with a throwaway key would never open
+	if !deliverer.DryRun {
+		if len(links.Secret) == 0 {
+			return fmt.Errorf("VIDEO_LINK_SECRET must be set to sign capsule links")
+		}
+		if mail == nil {
+			return fmt.Errorf("SMTP_HOST must be set to send capsules")
+		}
+		if deliverer.LinkBaseURL == "" {
+			return fmt.Errorf("PUBLIC_BASE_URL or CAPSULE_LINK_BASE_URL must be set to link to capsules")
+		}
+	}
+
+	outcomes, err := deliverer.Deliver(context.Background(), jobs.DeliverOptions{
+		Today:   dueBy,
+		ChildID: *childID,
+		Resend:  *resend,
+	})
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, o := range outcomes {
+		detail := ""
+		if o.Err != nil {
+			detail = o.Err.Error()
+		} else if o.Delivery.ID != 0 {
+			detail = fmt.Sprintf("%d gifts, %d messages, %d videos", o.Delivery.ItemCount, o.Delivery.MessageCount, o.Delivery.VideoCount)
+		}
+		fmt.Printf("%-8d %-20s %-35s %s  %-8s %s\n",
+			o.Due.ChildID, o.Due.ChildName, o.Due.Email, o.Due.DeliverOn.Format("2006-01-02"), o.Result, detail)
+		counts[o.Result]++
+	}
+
+	if deliverer.DryRun {
+		log.Printf("Dry run: %d time capsules are due", len(outcomes))
+	} else {
+		log.Printf("Sent %d of %d due time capsules (%d empty, %d failed, %d skipped)",
+			counts["sent"], len(outcomes), counts["empty"], counts["failed"], counts["skipped"])
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+// Package capsules keeps track of time capsules: the messages and videos of a
+// child's approved donations, delivered to the child on their 18th birthday (or
+// the date their parent chose). Each child has one delivery, which is claimed
+// before its email is sent, so a pass can be re-run - or run on several API
+// hosts at once - without anyone being emailed twice.
+package capsules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Status is where a delivery is
+type Status string
+
+const (
+	// Sending deliveries have been claimed and their email is on its way
+	Sending Status = "sending"
+	// Sent deliveries have been emailed to the child
+	Sent Status = "sent"
+	// Failed deliveries couldn't be emailed; they are tried again until MaxAttempts
+	Failed Status = "failed"
+	// Empty deliveries had no approved donations yet; they are looked at again
+	// once a donation is approved after they were last claimed
+	Empty Status = "empty"
+)
+
+// StaleAfter is how long a delivery can be Sending before it is assumed the
+// host sending it died, and it is claimed again
+const StaleAfter = time.Hour
+
+// ErrNotFound is returned when the delivery doesn't exist
+var ErrNotFound = errors.New("capsule delivery not found")
+
+// deliverOnSQL is the date a child's capsule is due
+const deliverOnSQL = `COALESCE(c.delivery_date, (c.DOB + INTERVAL '18 years')::DATE)`
+
+// approvedSinceSQL holds when a donation to the child was approved after the
+// delivery was last claimed, so an empty delivery has something to send
+func approvedSinceSQL(delivery string) string {
+	return `EXISTS (
+		SELECT 1
+		FROM donations dn
+		JOIN events e ON e.event_id = dn.event_id
+		WHERE e.child_id = ` + delivery + `.child_id
+		AND dn.approved = true
+		AND dn.approved_at > ` + delivery + `.claimed_at
+	)`
+}
+
+// Due is a child whose capsule should be delivered
+type Due struct {
+	ChildID   int
+	ChildName string
+	Email     string
+	DeliverOn time.Time
+	// DeliveryID and Status are set when a delivery has been started before
+	DeliveryID *int
+	Status     *Status
+}
+
+// DueOptions narrows FindDue
+type DueOptions struct {
+	// Today is the date deliveries are due by
+	Today time.Time
+	// ChildID only looks at one child (0 for every child)
+	ChildID int
+	// Resend includes deliveries that have already been sent
+	Resend bool
+	// MaxAttempts leaves out deliveries that have failed this many times
+	MaxAttempts int
+}
+
+// Delivery is the record of a child's time capsule
+type Delivery struct {
+	ID           int
+	ChildID      int
+	ChildName    string
+	Status       Status
+	ScheduledFor time.Time
+	Email        string
+	ItemCount    int // Approved donations in the capsule
+	MessageCount int
+	VideoCount   int
+	Attempts     int
+	LastError    string
+	SentAt       *time.Time // First time the email went
+	LastSentAt   *time.Time
+	SendCount    int
+	OpenedAt     *time.Time // First time the link was opened
+}
+
+const deliveryColumns = `
+	d.delivery_id, d.child_id, c.child_name, d.status, d.scheduled_for, d.email,
+	d.item_count, d.message_count, d.video_count, d.attempts, COALESCE(d.last_error, ''),
+	d.sent_at, d.last_sent_at, d.send_count, d.opened_at
+`
+
+func scanDelivery(row pgx.Row) (Delivery, error) {
+	var d Delivery
+	err := row.Scan(
+		&d.ID,
+		&d.ChildID,
+		&d.ChildName,
+		&d.Status,
+		&d.ScheduledFor,
+		&d.Email,
+		&d.ItemCount,
+		&d.MessageCount,
+		&d.VideoCount,
+		&d.Attempts,
+		&d.LastError,
+		&d.SentAt,
+		&d.LastSentAt,
+		&d.SendCount,
+		&d.OpenedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Delivery{}, ErrNotFound
+	}
+	return d, err
+}
+
+// Resource names a delivery in its signed link
+func Resource(deliveryID int) string {
+	return "capsules/" + strconv.Itoa(deliveryID)
+}
+
+// FindDue lists the children whose capsule is due by opts.Today and hasn't been
+// delivered (or is being delivered by another host right now)
+func FindDue(ctx context.Context, db *pgxpool.Pool, opts DueOptions) ([]Due, error) {
+	query := `
+		SELECT c.child_id, c.child_name, c.email, ` + deliverOnSQL + `, d.delivery_id, d.status
+		FROM children c
+		LEFT JOIN capsule_deliveries d ON d.child_id = c.child_id
+		WHERE ` + deliverOnSQL + ` <= $1
+		AND ($2 = 0 OR c.child_id = $2)
+		AND (
+			d.delivery_id IS NULL
+			OR (d.status = 'empty' AND ` + approvedSinceSQL("d") + `)
+			OR (d.status = 'failed' AND d.attempts < $3)
+			OR (d.status = 'sending' AND d.claimed_at < $4)
+			OR ($5 AND d.status IN ('sent', 'failed'))
+		)
+		ORDER BY 4, c.child_id
+	`
+
+	rows, err := db.Query(ctx, query, opts.Today, opts.ChildID, opts.MaxAttempts, time.Now().Add(-StaleAfter), opts.Resend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due capsules: %w", err)
+	}
+	defer rows.Close()
+
+	var due []Due
+	for rows.Next() {
+		var d Due
+		if err := rows.Scan(&d.ChildID, &d.ChildName, &d.Email, &d.DeliverOn, &d.DeliveryID, &d.Status); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// Claim starts (or restarts) the delivery for a due child, marking it Sending.
+// It returns false if another pass got there first or it has been sent since
+// (unless resend is set).
+func Claim(ctx context.Context, db *pgxpool.Pool, due Due, resend bool) (Delivery, bool, error) {
+	claimQuery := `
+		INSERT INTO capsule_deliveries (child_id, scheduled_for, email, status, claimed_at)
+		VALUES ($1, $2, $3, 'sending', NOW())
+		ON CONFLICT (child_id) DO UPDATE
+		SET status = 'sending',
+			claimed_at = NOW(),
+			scheduled_for = EXCLUDED.scheduled_for,
+			email = EXCLUDED.email,
+			updated_at = NOW()
+		WHERE capsule_deliveries.status = 'failed'
+		OR (capsule_deliveries.status = 'empty' AND ` + approvedSinceSQL("capsule_deliveries") + `)
+		OR (capsule_deliveries.status = 'sending' AND capsule_deliveries.claimed_at < $4)
+		OR ($5 AND capsule_deliveries.status = 'sent')
+		RETURNING delivery_id
+	`
+
+	var deliveryID int
+	err := db.QueryRow(ctx, claimQuery, due.ChildID, due.DeliverOn, due.Email, time.Now().Add(-StaleAfter), resend).Scan(&deliveryID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Delivery{}, false, nil
+	}
+	if err != nil {
+		return Delivery{}, false, fmt.Errorf("failed to claim capsule for child %d: %w", due.ChildID, err)
+	}
+
+	delivery, err := Get(ctx, db, deliveryID)
+	return delivery, err == nil, err
+}
+
+// Compile adds every donation the parent approved to the child's events to the delivery
+// (ones already in it are kept) and counts what it holds
+func Compile(ctx context.Context, db *pgxpool.Pool, deliveryID int) (Delivery, error) {
+	itemsQuery := `
+		INSERT INTO capsule_items (delivery_id, donation_id)
+		SELECT cd.delivery_id, d.id
+		FROM capsule_deliveries cd
+		JOIN events e ON e.child_id = cd.child_id
+		JOIN donations d ON d.event_id = e.event_id
+		WHERE cd.delivery_id = $1
+		AND d.approved = true
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := db.Exec(ctx, itemsQuery, deliveryID); err != nil {
+		return Delivery{}, fmt.Errorf("failed to compile capsule %d: %w", deliveryID, err)
+	}
+
+	countQuery := `
+		UPDATE capsule_deliveries cd
+		SET item_count = counts.items,
+			message_count = counts.messages,
+			video_count = counts.videos,
+			updated_at = NOW()
+		FROM (
+			SELECT
+				COUNT(*) AS items,
+				COUNT(*) FILTER (WHERE COALESCE(d.message, '') <> '' OR d.sealed) AS messages,
+				COUNT(*) FILTER (WHERE d.video_id IS NOT NULL OR d.video_address IS NOT NULL) AS videos
+			FROM capsule_items i
+			JOIN donations d ON d.id = i.donation_id
+			WHERE i.delivery_id = $1
+		) counts
+		WHERE cd.delivery_id = $1
+	`
+	if _, err := db.Exec(ctx, countQuery, deliveryID); err != nil {
+		return Delivery{}, fmt.Errorf("failed to count capsule %d: %w", deliveryID, err)
+	}
+
+	return Get(ctx, db, deliveryID)
+}
+
+// MarkSent records that the delivery's email went to email
+func MarkSent(ctx context.Context, db *pgxpool.Pool, deliveryID int, email string) error {
+	updateQuery := `
+		UPDATE capsule_deliveries
+		SET status = 'sent',
+			email = $2,
+			attempts = attempts + 1,
+			last_error = NULL,
+			sent_at = COALESCE(sent_at, NOW()),
+			last_sent_at = NOW(),
+			send_count = send_count + 1,
+			updated_at = NOW()
+		WHERE delivery_id = $1
+	`
+	_, err := db.Exec(ctx, updateQuery, deliveryID, email)
+	return err
+}
+
+// MarkFailed records a failed attempt to send the delivery. A delivery that had
+// already been sent stays Sent (a failed resend doesn't undo the first email).
+func MarkFailed(ctx context.Context, db *pgxpool.Pool, deliveryID int, sendErr error) error {
+	updateQuery := `
+		UPDATE capsule_deliveries
+		SET status = CASE WHEN sent_at IS NULL THEN 'failed' ELSE 'sent' END,
+			attempts = attempts + 1,
+			last_error = $2,
+			updated_at = NOW()
+		WHERE delivery_id = $1
+	`
+	_, err := db.Exec(ctx, updateQuery, deliveryID, sendErr.Error())
+	return err
+}
+
+// MarkEmpty records that there was nothing to deliver yet. Its claimed_at marks when it
+// was checked, so it is only claimed again for a donation approved after that
+func MarkEmpty(ctx context.Context, db *pgxpool.Pool, deliveryID int) error {
+	updateQuery := `
+		UPDATE capsule_deliveries
+		SET status = CASE WHEN sent_at IS NULL THEN 'empty' ELSE 'sent' END,
+			updated_at = NOW()
+		WHERE delivery_id = $1
+	`
+	_, err := db.Exec(ctx, updateQuery, deliveryID)
+	return err
+}
+
+// MarkOpened records the first time the child opened their capsule
+func MarkOpened(ctx context.Context, db *pgxpool.Pool, deliveryID int) error {
+	_, err := db.Exec(ctx,
+		`UPDATE capsule_deliveries SET opened_at = NOW() WHERE delivery_id = $1 AND opened_at IS NULL`,
+		deliveryID,
+	)
+	return err
+}
+
+// Get loads a delivery
+func Get(ctx context.Context, db *pgxpool.Pool, deliveryID int) (Delivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM capsule_deliveries d
+		JOIN children c ON c.child_id = d.child_id
+		WHERE d.delivery_id = $1
+	`
+	return scanDelivery(db.QueryRow(ctx, query, deliveryID))
+}
+
+// Item is one donation in a capsule
+type Item struct {
+	DonationID  int
+	DonorName   string
+	Message     *string
+	AmountPence int
+	EventName   string
+	CreatedAt   time.Time
+	// VideoAddress is the path of an uploaded video (links to it are built on
+	// PUBLIC_BASE_URL when they are signed), or a link given before videos were uploaded
+	VideoAddress *string
+	// Sealed items have no Message; SealedMessage is opened with the child's
+	// key (see the sealed package)
+	Sealed        bool
+	SealedMessage []byte
+}
+
+// Items lists what a delivery holds, oldest first
+func Items(ctx context.Context, db *pgxpool.Pool, deliveryID int) ([]Item, error) {
+	query := `
+		SELECT d.id, d.donor_name, d.message, d.amount_pence, e.event_name, d.created_at,
+			COALESCE('/api/videos/' || v.filename, d.video_address),
+			d.sealed, d.sealed_message
+		FROM capsule_items i
+		JOIN donations d ON d.id = i.donation_id
+		JOIN events e ON e.event_id = d.event_id
+		LEFT JOIN videos v ON v.video_id = d.video_id
+		WHERE i.delivery_id = $1
+		ORDER BY d.created_at, d.id
+	`
+
+	rows, err := db.Query(ctx, query, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load capsule %d: %w", deliveryID, err)
+	}
+	defer rows.Close()
+
+	var items []Item
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(
+			&item.DonationID,
+			&item.DonorName,
+			&item.Message,
+			&item.AmountPence,
+			&item.EventName,
+			&item.CreatedAt,
+			&item.VideoAddress,
+			&item.Sealed,
+			&item.SealedMessage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// DeliverOn is the date the child's capsule is due
+func DeliverOn(ctx context.Context, db *pgxpool.Pool, childID int) (time.Time, error) {
+	var deliverOn time.Time
+	err := db.QueryRow(ctx, `SELECT `+deliverOnSQL+` FROM children c WHERE c.child_id = $1`, childID).Scan(&deliverOn)
+	return deliverOn, err
+}
+
+// Delivered reports whether the child's capsule has been sent
+func Delivered(ctx context.Context, db *pgxpool.Pool, childID int) (bool, error) {
+	var delivered bool
+	err := db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM capsule_deliveries WHERE child_id = $1 AND sent_at IS NOT NULL)`,
+		childID,
+	).Scan(&delivered)
+	return delivered, err
+}
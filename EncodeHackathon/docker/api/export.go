@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"aletterahead-api/keepsake"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runExport handles the `export -child 12 | -event 34 [-o keepsake.zip]`
+// subcommand: writes a child's (or one event's) keepsake ZIP, as
+// /children/export downloads it, to a file or stdout ("-o -")
+func runExport(db *pgxpool.Pool, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	childID := flags.Int("child", 0, "export all of this child's events")
+	eventID := flags.Int("event", 0, "export one event")
+	output := flags.String("o", "", "file to write (default <name>-keepsake.zip, - for stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*childID == 0) == (*eventID == 0) {
+		return fmt.Errorf("give either -child or -event")
+	}
+
+	store, err := InitStorage()
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	ctx := context.Background()
+	k, err := keepsake.Load(ctx, db, keepsake.Scope{ChildID: *childID, EventID: *eventID})
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		w := bufio.NewWriter(os.Stdout)
+		if err := keepsake.Write(ctx, w, store, k); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	path := *output
+	if path == "" {
+		path = k.Name() + ".zip"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := keepsake.Write(ctx, f, store, k); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %s: %d donations, %d bytes", path, len(k.Donations), stat.Size())
+	return nil
+}
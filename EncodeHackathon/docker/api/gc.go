@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"aletterahead-api/media"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runGC handles the `gc [-dry-run] [-mode delete|quarantine] [-grace 72h]`
+// subcommand: one pass of the orphaned video collector, reporting each video
+func runGC(db *pgxpool.Pool, args []string) error {
+	store, err := InitStorage()
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	collector := newVideoCollector(db, store)
+
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	flags.BoolVar(&collector.DryRun, "dry-run", collector.DryRun, "only report what would be removed")
+	mode := flags.String("mode", string(collector.Mode), "delete or quarantine orphaned videos")
+	flags.DurationVar(&collector.GracePeriod, "grace", collector.GracePeriod, "how long a video must have been unneeded")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	collector.Mode = media.RemoveMode(*mode)
+	if collector.Mode != media.RemoveDelete && collector.Mode != media.RemoveQuarantine {
+		return fmt.Errorf("mode must be delete or quarantine")
+	}
+	if collector.GracePeriod < 0 {
+		return fmt.Errorf("grace must not be negative")
+	}
+
+	result, err := collector.Collect(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, o := range result.Orphans {
+		videoID := "-"
+		if o.VideoID != nil {
+			videoID = fmt.Sprint(*o.VideoID)
+		}
+		fmt.Printf("%-8s %-45s %-18s %-20s %10d bytes  %d files\n",
+			videoID, o.Filename, o.Reason, o.Since.Format("2006-01-02 15:04:05"), o.SizeBytes, len(o.Keys))
+		total += o.SizeBytes
+	}
+
+	if collector.DryRun {
+		log.Printf("Dry run: %d orphaned videos (%d bytes) would be removed (%s)", len(result.Orphans), total, collector.Mode)
+	} else {
+		log.Printf("Removed %d of %d orphaned videos (%d bytes, %s)", result.Removed, len(result.Orphans), result.RemovedBytes, collector.Mode)
+	}
+	return nil
+}
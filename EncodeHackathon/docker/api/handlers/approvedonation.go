@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"aletterahead-api/lifecycle"
+	"aletterahead-api/payments"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76/client"
 )
 
 // ApproveDonationRequest represents the request structure for approving/rejecting donations
@@ -16,14 +21,21 @@ type ApproveDonationRequest struct {
 
 // ApproveDonationResponse represents the response after approval action
 type ApproveDonationResponse struct {
-	DonationID int    `json:"donation_id"`
-	Approved   bool   `json:"approved"`
-	DonorName  string `json:"donor_name"`
-	Message    string `json:"message"`
+	DonationID          int     `json:"donation_id"`
+	Approved            bool    `json:"approved"`
+	DonorName           string  `json:"donor_name"`
+	Status              string  `json:"status"`
+	PaymentStatus       string  `json:"payment_status"`
+	RefundID            *string `json:"refund_id,omitempty"`
+	RefundedAmountPence *int    `json:"refunded_amount_pence,omitempty"`
+	Message             string  `json:"message"`
 }
 
-// ApproveDonation approves or rejects a donation
-func ApproveDonation(db *pgxpool.Pool) gin.HandlerFunc {
+// ApproveDonation approves or rejects a donation.
+// Donations are only authorised when they are made: a donation can be approved once
+// its payment is held, which captures it; rejecting one releases the authorisation (or refunds a captured payment).
+// Rejection is final - once the donor's money is released it can't be taken again.
+func ApproveDonation(db *pgxpool.Pool, sc *client.API) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req ApproveDonationRequest
 
@@ -36,15 +48,26 @@ func ApproveDonation(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
 		// First, verify the donation exists and get its details
 		verifyQuery := `
 			SELECT 
 				d.id,
 				d.donor_name,
+				d.status,
 				d.approved,
 				d.event_id,
 				e.event_name,
-				c.child_name
+				c.child_name,
+				c.parent_id,
+				d.amount_pence,
+				d.payment_intent_id,
+				d.payment_status
 			FROM donations d
 			JOIN events e ON d.event_id = e.event_id
 			JOIN children c ON e.child_id = c.child_id
@@ -53,18 +76,28 @@ func ApproveDonation(db *pgxpool.Pool) gin.HandlerFunc {
 
 		var donationID int
 		var donorName string
-		var currentApproval bool
+		var status lifecycle.Status
+		var approved bool
 		var eventID int
 		var eventName string
 		var childName string
+		var ownerID int
+		var amountPence int
+		var paymentIntentID *string
+		var paymentStatus string
 
 		err := db.QueryRow(context.Background(), verifyQuery, req.DonationID).Scan(
 			&donationID,
 			&donorName,
-			&currentApproval,
+			&status,
+			&approved,
 			&eventID,
 			&eventName,
 			&childName,
+			&ownerID,
+			&amountPence,
+			&paymentIntentID,
+			&paymentStatus,
 		)
 		if err != nil {
 			if err.Error() == "no rows in result set" {
@@ -79,28 +112,125 @@ func ApproveDonation(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		// Check if the approval status is already what was requested
-		if currentApproval == req.Approved {
-			status := "approved"
-			if !req.Approved {
-				status = "rejected"
-			}
-			c.JSON(http.StatusOK, gin.H{
-				"message":     "Donation is already " + status,
-				"donation_id": req.DonationID,
-				"approved":    currentApproval,
-			})
+		// Only the event's parent may moderate its donations
+		if ownerID != callerID {
+			forbidResource(c, "donation")
 			return
 		}
 
-		// Update the approval status (remove updated_at since column doesn't exist)
+		// Work out what the parent's decision means for this donation
+		target := lifecycle.Approved
+		if req.Approved {
+			if approved {
+				c.JSON(http.StatusOK, gin.H{
+					"message":     "Donation is already approved",
+					"donation_id": req.DonationID,
+					"approved":    true,
+					"status":      status,
+				})
+				return
+			}
+			if status.AwaitingDecision(approved) && !status.Approvable() {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":  "Donation hasn't been paid for yet and cannot be approved",
+					"status": status,
+				})
+				return
+			}
+			if !status.Approvable() {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":  "Donation has been " + string(status) + " and cannot be approved",
+					"status": status,
+				})
+				return
+			}
+		} else {
+			switch status {
+			case lifecycle.Rejected, lifecycle.Refunded:
+				c.JSON(http.StatusOK, gin.H{
+					"message":     "Donation is already " + string(status),
+					"donation_id": req.DonationID,
+					"approved":    false,
+					"status":      status,
+				})
+				return
+			case lifecycle.Disputed:
+				c.JSON(http.StatusConflict, gin.H{
+					"error":  "Donation is disputed and cannot be rejected",
+					"status": status,
+				})
+				return
+			}
+
+			target = lifecycle.Rejected
+			if status == lifecycle.Captured {
+				target = lifecycle.Refunded
+			}
+		}
+
+		// Rejecting must release the donor's money
+		needsRefund := !req.Approved && paymentIntentID != nil &&
+			paymentStatus != "refunded" && paymentStatus != "canceled"
+
+		// Approving takes the authorised money, unless it has already been captured
+		needsCapture := req.Approved && paymentIntentID != nil &&
+			(paymentStatus == "pending" || paymentStatus == "authorized")
+
+		// Give the donor their money back before recording the rejection
+		var refundID *string
+		var refundedAmount *int
+		if needsRefund {
+			release, err := payments.ReleaseDonation(sc, req.DonationID, *paymentIntentID)
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{
+					"error":   "Failed to refund donation",
+					"details": err.Error(),
+				})
+				return
+			}
+			paymentStatus = release.Status
+			refundID = release.RefundID
+			if release.Status == "refunded" {
+				refundedAmount = &amountPence
+			}
+		}
+
+		// Capture the payment before recording the approval
+		if needsCapture {
+			captured, err := payments.CaptureDonation(sc, req.DonationID, *paymentIntentID)
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{
+					"error":   "Failed to capture donation",
+					"details": err.Error(),
+				})
+				return
+			}
+			// Not yet authorised - the sweeper captures it once the donor has paid
+			if captured {
+				paymentStatus = "succeeded"
+			}
+		}
+		// A donation captured by the event's policy is approved where it is
+		if req.Approved && (paymentStatus == "succeeded" || status == lifecycle.Captured) {
+			target = lifecycle.Captured
+		}
+
+		// Record the payment outcome (and refund details, if any)
 		updateQuery := `
 			UPDATE donations 
-			SET approved = $1
-			WHERE id = $2
+			SET payment_status = $1,
+				refund_id = COALESCE($2, refund_id),
+				refunded_amount_pence = COALESCE($3, refunded_amount_pence),
+				refunded_at = CASE WHEN $3::INTEGER IS NOT NULL THEN NOW() ELSE refunded_at END
+			WHERE id = $4
 		`
 
-		_, err = db.Exec(context.Background(), updateQuery, req.Approved, req.DonationID)
+		_, err = db.Exec(context.Background(), updateQuery,
+			paymentStatus,
+			refundID,
+			refundedAmount,
+			req.DonationID,
+		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to update donation status",
@@ -108,6 +238,31 @@ func ApproveDonation(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Move the donation to its new status
+		reason := "approved by parent"
+		if !req.Approved {
+			reason = "rejected by parent"
+		}
+		if req.Approved {
+			_, err = lifecycle.Approve(context.Background(), db, req.DonationID, target, callerID, reason)
+		} else {
+			_, err = lifecycle.Transition(context.Background(), db, req.DonationID, target, lifecycle.ParentActor(callerID), reason)
+		}
+		if err != nil {
+			var invalid *lifecycle.InvalidTransitionError
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":  "Donation status changed, please try again",
+					"status": invalid.From,
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update donation status",
+			})
+			return
+		}
+
 		// Determine response message
 		action := "approved"
 		if !req.Approved {
@@ -115,11 +270,20 @@ func ApproveDonation(db *pgxpool.Pool) gin.HandlerFunc {
 		}
 
 		// Return success response
+		message := "Donation " + action + " successfully"
+		if refundedAmount != nil {
+			message += " and the donor has been refunded"
+		}
+
 		response := ApproveDonationResponse{
-			DonationID: req.DonationID,
-			Approved:   req.Approved,
-			DonorName:  donorName,
-			Message:    "Donation " + action + " successfully",
+			DonationID:          req.DonationID,
+			Approved:            req.Approved,
+			DonorName:           donorName,
+			Status:              string(target),
+			PaymentStatus:       paymentStatus,
+			RefundID:            refundID,
+			RefundedAmountPence: refundedAmount,
+			Message:             message,
 		}
 
 		c.JSON(http.StatusOK, response)
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"aletterahead-api/lifecycle"
+	"aletterahead-api/payments"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+// CancelEventRequest represents the request structure for cancelling an event
+type CancelEventRequest struct {
+	EventID int     `json:"event_id" binding:"required"`
+	Reason  *string `json:"reason"`
+}
+
+// CancelEventResponse represents the response after cancelling an event
+type CancelEventResponse struct {
+	EventID           int       `json:"event_id"`
+	EventName         string    `json:"event_name"`
+	CancelledAt       time.Time `json:"cancelled_at"`
+	RejectedDonations int       `json:"rejected_donations"`
+	KeptDonations     int       `json:"kept_donations"`
+	Message           string    `json:"message"`
+}
+
+// pendingDonation is a donation the parent hadn't decided on when its event was cancelled
+type pendingDonation struct {
+	id              int
+	amountPence     int
+	paymentIntentID *string
+	paymentStatus   string
+}
+
+// CancelEvent cancels an event. It stays in the parent's list but takes no more
+// donations or videos. Donations still waiting for a decision are rejected and
+// the donors' card holds released, as if the parent had rejected each one;
+// approved donations are kept (and captured as usual). Cancelling again retries
+// any donation whose payment couldn't be released.
+func CancelEvent(db *pgxpool.Pool, sc *client.API) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CancelEventRequest
+
+		// Bind JSON request body
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
+		// Verify the event exists and belongs to the caller
+		eventQuery := `
+			SELECT 
+				e.event_name,
+				c.parent_id
+			FROM events e
+			JOIN children c ON e.child_id = c.child_id
+			WHERE e.event_id = $1
+		`
+
+		var eventName string
+		var parentID int
+
+		err := db.QueryRow(context.Background(), eventQuery, req.EventID).Scan(&eventName, &parentID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Event not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		// Parents can only cancel their own children's events
+		if parentID != callerID {
+			forbidResource(c, "event")
+			return
+		}
+
+		// Close the event first, so no new donations arrive while the pending ones are rejected.
+		// The update locks the event row, so it waits for donations being saved (which hold it,
+		// see saveDonation) and they are all found below. An event that is already cancelled
+		// keeps its original time and reason.
+		cancelQuery := `
+			UPDATE events
+			SET cancelled_at = COALESCE(cancelled_at, NOW()),
+				cancel_reason = CASE WHEN cancelled_at IS NULL THEN $2 ELSE cancel_reason END
+			WHERE event_id = $1
+			RETURNING cancelled_at
+		`
+
+		var cancelledAt time.Time
+		err = db.QueryRow(context.Background(), cancelQuery, req.EventID, req.Reason).Scan(&cancelledAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to cancel event",
+			})
+			return
+		}
+
+		// Find the donations still waiting for the parent's decision
+		pendingQuery := `
+			SELECT id, amount_pence, payment_intent_id, payment_status
+			FROM donations
+			WHERE event_id = $1
+			AND status IN ($2, $3, $4)
+			ORDER BY id
+		`
+
+		rows, err := db.Query(context.Background(), pendingQuery, req.EventID,
+			lifecycle.AwaitingPayment, lifecycle.PendingReview, lifecycle.PaymentFailed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+		pending, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pendingDonation, error) {
+			var d pendingDonation
+			err := row.Scan(&d.id, &d.amountPence, &d.paymentIntentID, &d.paymentStatus)
+			return d, err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		// One donation failing to release shouldn't stop the rest
+		actor := lifecycle.ParentActor(callerID)
+		rejected := 0
+		var failed []int
+		for _, d := range pending {
+			if err := rejectPendingDonation(context.Background(), db, sc, d, actor); err != nil {
+				log.Printf("Cancelling event %d: donation %d: %v", req.EventID, d.id, err)
+				failed = append(failed, d.id)
+				continue
+			}
+			rejected++
+		}
+
+		var kept int
+		err = db.QueryRow(context.Background(),
+			`SELECT COUNT(*) FROM donations WHERE event_id = $1 AND status IN ($2, $3, $4)`,
+			req.EventID, lifecycle.Approved, lifecycle.Captured, lifecycle.Disputed,
+		).Scan(&kept)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		if len(failed) > 0 {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":               "Event cancelled, but some pending donations could not be released. Cancel it again to retry",
+				"event_id":            req.EventID,
+				"cancelled_at":        cancelledAt,
+				"rejected_donations":  rejected,
+				"failed_donation_ids": failed,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, CancelEventResponse{
+			EventID:           req.EventID,
+			EventName:         eventName,
+			CancelledAt:       cancelledAt,
+			RejectedDonations: rejected,
+			KeptDonations:     kept,
+			Message:           "Event cancelled successfully",
+		})
+	}
+}
+
+// rejectPendingDonation releases a pending donation's payment and records it as
+// rejected, the same way ApproveDonation rejects one
+func rejectPendingDonation(ctx context.Context, db *pgxpool.Pool, sc *client.API, d pendingDonation, actor string) error {
+	// A donation still being created has no payment yet. Reject it first, then release
+	// a payment CreateDonation recorded before the rejection; one recorded after it is
+	// released by CreateDonation itself.
+	if d.paymentIntentID == nil {
+		if _, err := lifecycle.Transition(ctx, db, d.id, lifecycle.Rejected, actor, "event cancelled by parent"); err != nil {
+			return err
+		}
+		err := db.QueryRow(ctx, `SELECT payment_intent_id, payment_status FROM donations WHERE id = $1`, d.id).
+			Scan(&d.paymentIntentID, &d.paymentStatus)
+		if err != nil {
+			return fmt.Errorf("failed to read donation payment: %w", err)
+		}
+		if d.paymentIntentID == nil {
+			return nil
+		}
+	}
+
+	var refundID *string
+	var refundedAmount *int
+	if d.paymentIntentID != nil && d.paymentStatus != "refunded" && d.paymentStatus != "canceled" {
+		release, err := payments.ReleaseDonation(sc, d.id, *d.paymentIntentID)
+		if err != nil {
+			return err
+		}
+		d.paymentStatus = release.Status
+		refundID = release.RefundID
+		if release.Status == "refunded" {
+			refundedAmount = &d.amountPence
+		}
+	}
+
+	updateQuery := `
+		UPDATE donations 
+		SET payment_status = $1,
+			refund_id = COALESCE($2, refund_id),
+			refunded_amount_pence = COALESCE($3, refunded_amount_pence),
+			refunded_at = CASE WHEN $3::INTEGER IS NOT NULL THEN NOW() ELSE refunded_at END
+		WHERE id = $4
+	`
+
+	if _, err := db.Exec(ctx, updateQuery, d.paymentStatus, refundID, refundedAmount, d.id); err != nil {
+		return fmt.Errorf("failed to record released payment: %w", err)
+	}
+
+	_, err := lifecycle.Transition(ctx, db, d.id, lifecycle.Rejected, actor, "event cancelled by parent")
+	return err
+}
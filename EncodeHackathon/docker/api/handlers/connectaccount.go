@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+
+	"aletterahead-api/payments"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76"
+)
+
+// saveAccountStatus copies a Connect account's capabilities from Stripe onto its
+// payment_accounts row. onboarding_complete is only ever set from here.
+func saveAccountStatus(db *pgxpool.Pool, account *stripe.Account) error {
+	updateQuery := `
+		UPDATE payment_accounts
+		SET onboarding_complete = $1,
+			charges_enabled = $2,
+			payouts_enabled = $3,
+			details_submitted = $4,
+			updated_at = NOW()
+		WHERE stripe_connect_account_id = $5
+	`
+
+	_, err := db.Exec(context.Background(), updateQuery,
+		payments.OnboardingComplete(account),
+		account.ChargesEnabled,
+		account.PayoutsEnabled,
+		account.DetailsSubmitted,
+		account.ID,
+	)
+	return err
+}
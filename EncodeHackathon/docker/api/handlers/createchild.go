@@ -11,10 +11,12 @@ import (
 
 // CreateChildRequest represents the request structure for creating a child
 type CreateChildRequest struct {
-	ParentID  int    `json:"parent_id" binding:"required"`
+	ParentID  int    `json:"parent_id"` // Optional, must match the authenticated parent
 	ChildName string `json:"child_name" binding:"required"`
 	DOB       string `json:"dob" binding:"required"` // Format: "2017-07-15"
 	Email     string `json:"email" binding:"required,email"`
+	// DeliveryDate is when their time capsule is emailed to them (defaults to their 18th birthday)
+	DeliveryDate *string `json:"delivery_date"`
 }
 
 // CreateChildResponse represents the response after creating a child
@@ -24,7 +26,7 @@ type CreateChildResponse struct {
 	Message   string `json:"message"`
 }
 
-// CreateChild adds a new child to a parent's account
+// CreateChild adds a new child to the authenticated parent's account
 func CreateChild(db *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CreateChildRequest
@@ -67,20 +69,21 @@ func CreateChild(db *pgxpool.Pool) gin.HandlerFunc {
 		// Calculate ISA expiry (18th birthday)
 		isaExpiry := dob.AddDate(18, 0, 0)
 
-		// Verify parent exists
-		parentCheckQuery := `SELECT parent_id FROM parents WHERE parent_id = $1`
-		var parentExists int
-		err = db.QueryRow(context.Background(), parentCheckQuery, req.ParentID).Scan(&parentExists)
-		if err != nil {
-			if err.Error() == "no rows in result set" {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error": "Parent not found",
-				})
+		var deliveryDate *time.Time
+		if req.DeliveryDate != nil {
+			date, ok := parseDeliveryDate(c, *req.DeliveryDate)
+			if !ok {
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Database query failed",
-			})
+			deliveryDate = &date
+		}
+
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+		if !requireSameParent(c, parentID, req.ParentID) {
 			return
 		}
 
@@ -97,8 +100,8 @@ func CreateChild(db *pgxpool.Pool) gin.HandlerFunc {
 
 		// Insert new child
 		insertQuery := `
-			INSERT INTO children (DOB, parent_id, email, isa_expiry, child_name)
-			VALUES ($1, $2, $3, $4, $5)
+			INSERT INTO children (DOB, parent_id, email, isa_expiry, child_name, delivery_date)
+			VALUES ($1, $2, $3, $4, $5, $6)
 			RETURNING child_id, created_at
 		`
 
@@ -107,10 +110,11 @@ func CreateChild(db *pgxpool.Pool) gin.HandlerFunc {
 
 		err = db.QueryRow(context.Background(), insertQuery,
 			dob,
-			req.ParentID,
+			parentID,
 			req.Email,
 			isaExpiry,
 			req.ChildName,
+			deliveryDate,
 		).Scan(&childID, &createdAt)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
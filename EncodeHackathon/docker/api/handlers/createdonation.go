@@ -2,11 +2,25 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"aletterahead-api/isa"
+	"aletterahead-api/lifecycle"
+	"aletterahead-api/media"
+	"aletterahead-api/payments"
+	"aletterahead-api/sealed"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
 )
 
 // Donation represents the donation data structure
@@ -18,7 +32,13 @@ type Donation struct {
 	Approved     bool      `json:"approved"`
 	EventID      int       `json:"event_id"`
 	CreatedAt    time.Time `json:"created_at"`
+	VideoID      *int      `json:"video_id"`
 	VideoAddress *string   `json:"video_address"`
+	ThumbnailURL *string   `json:"thumbnail_url"` // Poster frame of the video, once it has been transcoded
+	Status       string    `json:"status"`        // See the lifecycle package
+	// Stripe payment tracking
+	PaymentIntentID *string `json:"payment_intent_id"`
+	PaymentStatus   string  `json:"payment_status"`
 }
 
 // CreateDonationRequest represents the request structure for creating donations
@@ -27,18 +47,27 @@ type CreateDonationRequest struct {
 	DonorName    string  `json:"donor_name" binding:"required"`
 	AmountPence  int     `json:"amount_pence" binding:"required,min=100"` // Minimum £1.00
 	Message      *string `json:"message"`
-	VideoAddress *string `json:"video_address"`
+	Sealed       bool    `json:"sealed"`        // Encrypt the message so only the child reads it, at 18 (see the sealed package)
+	VideoID      *int    `json:"video_id"`      // From UploadVideo, for this event and not used by another donation
+	VideoAddress *string `json:"video_address"` // No longer accepted, see VideoID
 }
 
 // CreateDonationResponse represents the response after creating a donation
 type CreateDonationResponse struct {
-	DonationID int    `json:"donation_id"`
-	Status     string `json:"status"`
-	Message    string `json:"message"`
+	DonationID      int    `json:"donation_id"`
+	Status          string `json:"status"`
+	PaymentIntentID string `json:"payment_intent_id"`
+	ClientSecret    string `json:"client_secret"`
+	Warning         string `json:"warning,omitempty"`
+	Message         string `json:"message"`
 }
 
-// CreateDonation processes a new donation
-func CreateDonation(db *pgxpool.Pool) gin.HandlerFunc {
+// CreateDonation processes a new donation and authorises a Stripe PaymentIntent for it.
+// Donations that would take the child over their Junior ISA allowance for the tax year
+// are rejected or accepted with a warning, depending on allowance.Mode. A video must
+// have been uploaded for the same event, and each video can go with one donation.
+// Sealed messages are encrypted with the child's key before they are stored.
+func CreateDonation(db *pgxpool.Pool, sc *client.API, allowance isa.Config, keys sealed.Keyring) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CreateDonationRequest
 
@@ -51,14 +80,40 @@ func CreateDonation(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Videos are referenced by the ID UploadVideo returned, never by address
+		if req.VideoAddress != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "video_address is no longer accepted, send the video_id returned by the upload",
+			})
+			return
+		}
+
+		if req.Sealed {
+			if req.Message == nil || strings.TrimSpace(*req.Message) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "A sealed donation needs a message",
+				})
+				return
+			}
+			if !keys.Enabled() {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error": "Sealed messages are not available",
+				})
+				return
+			}
+		}
+
 		// Verify event exists and is not expired
 		eventQuery := `
 			SELECT 
 				e.event_id,
 				e.expires_at,
 				e.videos_enabled,
+				c.child_id,
+				c.child_name,
 				pa.stripe_connect_account_id,
-				pa.onboarding_complete
+				pa.onboarding_complete,
+				e.cancelled_at
 			FROM events e
 			JOIN children c ON e.child_id = c.child_id
 			JOIN parents p ON c.parent_id = p.parent_id
@@ -69,15 +124,21 @@ func CreateDonation(db *pgxpool.Pool) gin.HandlerFunc {
 		var eventID int
 		var expiresAt time.Time
 		var videosEnabled bool
+		var childID int
+		var childName string
 		var stripeAccountID *string
 		var onboardingComplete bool
+		var cancelledAt *time.Time
 
 		err := db.QueryRow(context.Background(), eventQuery, req.EventID).Scan(
 			&eventID,
 			&expiresAt,
 			&videosEnabled,
+			&childID,
+			&childName,
 			&stripeAccountID,
 			&onboardingComplete,
+			&cancelledAt,
 		)
 		if err != nil {
 			if err.Error() == "no rows in result set" {
@@ -92,6 +153,14 @@ func CreateDonation(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		if cancelledAt != nil {
+			c.JSON(http.StatusGone, gin.H{
+				"error":        "This event has been cancelled",
+				"cancelled_at": cancelledAt,
+			})
+			return
+		}
+
 		// Check if event has expired
 		if time.Now().After(expiresAt) {
 			c.JSON(http.StatusGone, gin.H{
@@ -102,7 +171,7 @@ func CreateDonation(db *pgxpool.Pool) gin.HandlerFunc {
 		}
 
 		// Check if payment account is ready
-		if !onboardingComplete {
+		if !onboardingComplete || stripeAccountID == nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{
 				"error": "Payment processing not yet available for this event",
 			})
@@ -110,52 +179,258 @@ func CreateDonation(db *pgxpool.Pool) gin.HandlerFunc {
 		}
 
 		// Validate video upload if provided
-		if req.VideoAddress != nil && !videosEnabled {
+		if req.VideoID != nil && !videosEnabled {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "Video uploads are not enabled for this event",
 			})
 			return
 		}
 
-		// Insert donation into database
+		// Seal the message before anything is stored, so its text never is
+		message := req.Message
+		var sealedMessage []byte
+		var sealedLength *int
+		if req.Sealed {
+			sealedMessage, err = keys.Seal(context.Background(), db, childID, *req.Message)
+			if err != nil {
+				log.Printf("Failed to seal message for child %d: %v", childID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to seal message",
+				})
+				return
+			}
+			length := utf8.RuneCountInString(*req.Message)
+			message, sealedLength = nil, &length
+		}
+
+		// Claim the video so no other donation can use it. Only its video_id is kept;
+		// links to it are built from PUBLIC_BASE_URL when they are handed out
+		if req.VideoID != nil {
+			_, err := media.Attach(context.Background(), db, *req.VideoID, req.EventID)
+			if err != nil {
+				switch {
+				case errors.Is(err, media.ErrNotFound), errors.Is(err, media.ErrWrongEvent):
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error": "Video was not uploaded for this event",
+					})
+				case errors.Is(err, media.ErrAlreadyAttached):
+					c.JSON(http.StatusConflict, gin.H{
+						"error": "Video is already attached to another donation",
+					})
+				default:
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "Database query failed",
+					})
+				}
+				return
+			}
+		}
+
+		// Insert donation into database, if it fits in the child's Junior ISA allowance for this tax year
+		taxYear := isa.TaxYearFor(time.Now())
 		insertQuery := `
-			INSERT INTO donations (message, donor_name, amount_pence, approved, event_id, video_address)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			RETURNING id, created_at
+			INSERT INTO donations (message, donor_name, amount_pence, approved, event_id, video_id, sealed, sealed_message, sealed_length)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
 		`
 
-		var donationID int
-		var createdAt time.Time
-
-		err = db.QueryRow(context.Background(), insertQuery,
-			req.Message,
+		saved, err := saveDonation(context.Background(), db, req.EventID, childID, req.AmountPence, allowance, taxYear, insertQuery,
+			message,
 			req.DonorName,
 			req.AmountPence,
 			false, // Donations start as unapproved for moderation
 			req.EventID,
-			req.VideoAddress,
-		).Scan(&donationID, &createdAt)
-		if err != nil {
+			req.VideoID,
+			req.Sealed,
+			sealedMessage,
+			sealedLength,
+		)
+		rejected := saved.overAllowance && allowance.Mode == isa.ModeReject
+		if err != nil || saved.cancelledAt != nil || rejected {
+			if req.VideoID != nil {
+				media.Release(context.Background(), db, *req.VideoID)
+			}
+			switch {
+			case err != nil:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to create donation",
+				})
+			case saved.cancelledAt != nil:
+				c.JSON(http.StatusGone, gin.H{
+					"error":        "This event has been cancelled",
+					"cancelled_at": saved.cancelledAt,
+				})
+			default:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":           fmt.Sprintf("This donation would exceed %s's Junior ISA allowance for the %s tax year", childName, taxYear.Label()),
+					"tax_year":        taxYear.Label(),
+					"remaining_pence": allowance.Remaining(saved.usage),
+				})
+			}
+			return
+		}
+		donationID := saved.id
+
+		var warning string
+		if saved.overAllowance {
+			warning = fmt.Sprintf("This donation takes %s over their Junior ISA allowance for the %s tax year (£%.2f remaining)",
+				childName, taxYear.Label(), float64(allowance.Remaining(saved.usage))/100)
+		}
+
+		if err := lifecycle.Created(context.Background(), db, donationID, lifecycle.DonorActor); err != nil {
+			abandonDonation(db, donationID, req.VideoID, "failed to record donation: "+err.Error())
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to create donation",
 			})
 			return
 		}
 
-		// TODO: Process Stripe payment here
-		// This is where you'd integrate with Stripe to:
-		// 1. Create payment intent
-		// 2. Charge the donor
-		// 3. Transfer to stripeAccountID
-		// 4. Update donation status
+		// Create a PaymentIntent as a destination charge to the parent's Connect account.
+		// It is only authorised here - the parent's approval captures it (see ApproveDonation).
+		params := &stripe.PaymentIntentParams{
+			Amount:        stripe.Int64(int64(req.AmountPence)),
+			Currency:      stripe.String(string(stripe.CurrencyGBP)),
+			CaptureMethod: stripe.String(string(stripe.PaymentIntentCaptureMethodManual)),
+			AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+				Enabled: stripe.Bool(true),
+			},
+			TransferData: &stripe.PaymentIntentTransferDataParams{
+				Destination: stripeAccountID,
+			},
+			Description: stripe.String(fmt.Sprintf("Donation from %s", req.DonorName)),
+		}
+		params.AddMetadata("donation_id", strconv.Itoa(donationID))
+		params.AddMetadata("event_id", strconv.Itoa(eventID))
+		// Retrying the same donation must never create a second charge
+		params.SetIdempotencyKey(fmt.Sprintf("donation-%d", donationID))
 
-		// For now, return success response
+		pi, err := sc.PaymentIntents.New(params)
+		if err != nil {
+			abandonDonation(db, donationID, req.VideoID, "failed to create PaymentIntent: "+err.Error())
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":       "Failed to create payment",
+				"donation_id": donationID,
+			})
+			return
+		}
+
+		// Record the PaymentIntent against the donation
+		updateQuery := `
+			UPDATE donations
+			SET payment_intent_id = $1, payment_status = $2
+			WHERE id = $3
+			RETURNING status
+		`
+
+		var status lifecycle.Status
+		err = db.QueryRow(context.Background(), updateQuery, pi.ID, "pending", donationID).Scan(&status)
+		if err != nil {
+			abandonDonation(db, donationID, req.VideoID, "failed to record PaymentIntent "+pi.ID+": "+err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to record payment",
+			})
+			return
+		}
+
+		// The event was cancelled while the payment was being set up, and the donation
+		// rejected before it had a payment to release: release it here instead
+		if status == lifecycle.Rejected {
+			release, err := payments.ReleaseDonation(sc, donationID, pi.ID)
+			if err != nil {
+				log.Printf("Failed to release PaymentIntent %s of donation %d on a cancelled event: %v", pi.ID, donationID, err)
+			} else {
+				db.Exec(context.Background(), `UPDATE donations SET payment_status = $1 WHERE id = $2`, release.Status, donationID)
+			}
+			c.JSON(http.StatusGone, gin.H{
+				"error": "This event has been cancelled",
+			})
+			return
+		}
+
+		// Return the client secret so the donations page can confirm the payment
 		response := CreateDonationResponse{
-			DonationID: donationID,
-			Status:     "pending_payment",
-			Message:    "Donation created successfully. Payment processing will be implemented next.",
+			DonationID:      donationID,
+			Status:          string(lifecycle.AwaitingPayment),
+			PaymentIntentID: pi.ID,
+			ClientSecret:    pi.ClientSecret,
+			Warning:         warning,
+			Message:         "Donation created successfully. Confirm the payment to complete your donation - you will only be charged once the parent approves it.",
 		}
 
 		c.JSON(http.StatusCreated, response)
 	}
 }
+
+// abandonDonation ends a donation that couldn't be set up for payment. It expires,
+// so it can never be approved or captured; the row is kept so the failed attempt is
+// visible, but its video is given back, so the donor can send it with a new donation.
+func abandonDonation(db *pgxpool.Pool, donationID int, videoID *int, reason string) {
+	ctx := context.Background()
+
+	updateQuery := `
+		UPDATE donations
+		SET payment_status = $1, video_id = NULL, video_address = NULL
+		WHERE id = $2
+	`
+	if _, err := db.Exec(ctx, updateQuery, "failed", donationID); err != nil {
+		log.Printf("Failed to mark donation %d failed: %v", donationID, err)
+	}
+
+	_, err := lifecycle.Transition(ctx, db, donationID, lifecycle.Expired, lifecycle.SystemActor("create_donation"), reason)
+	if err != nil {
+		log.Printf("Failed to move donation %d to %s: %v", donationID, lifecycle.Expired, err)
+	}
+
+	if videoID != nil {
+		if err := media.Release(ctx, db, *videoID); err != nil {
+			log.Printf("Failed to release video %d of donation %d: %v", *videoID, donationID, err)
+		}
+	}
+}
+
+// savedDonation is what saveDonation did
+type savedDonation struct {
+	id int
+	// cancelledAt is set when the event was cancelled first; nothing was saved
+	cancelledAt *time.Time
+	// usage is the child's ISA usage before this donation, and overAllowance whether
+	// it takes them over. In reject mode such a donation isn't saved
+	usage         isa.Usage
+	overAllowance bool
+}
+
+// saveDonation inserts a donation while holding its event, so it can't race CancelEvent:
+// the cancellation's update waits for the lock, then finds this donation to reject. If
+// the event was cancelled first nothing is saved. The child's row is locked too, so
+// donations to any of their events are checked against the ISA allowance one at a
+// time; in reject mode one that would go over it isn't saved.
+func saveDonation(ctx context.Context, db *pgxpool.Pool, eventID, childID, amountPence int, allowance isa.Config, taxYear isa.TaxYear, insertQuery string, args ...any) (savedDonation, error) {
+	var saved savedDonation
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return saved, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `SELECT cancelled_at FROM events WHERE event_id = $1 FOR SHARE`, eventID).Scan(&saved.cancelledAt)
+	if err != nil || saved.cancelledAt != nil {
+		return saved, err
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT child_id FROM children WHERE child_id = $1 FOR UPDATE`, childID); err != nil {
+		return saved, err
+	}
+	saved.usage, err = isa.ChildUsage(ctx, tx, childID, taxYear)
+	if err != nil {
+		return saved, err
+	}
+	saved.overAllowance = allowance.WouldExceed(saved.usage, amountPence)
+	if saved.overAllowance && allowance.Mode == isa.ModeReject {
+		return saved, nil
+	}
+
+	if err := tx.QueryRow(ctx, insertQuery, args...).Scan(&saved.id); err != nil {
+		return saved, err
+	}
+	return saved, tx.Commit(ctx)
+}
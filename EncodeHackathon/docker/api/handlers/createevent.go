@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,6 +19,9 @@ type CreateEventRequest struct {
 	EventMessage  *string `json:"event_message"`
 	VideosEnabled bool    `json:"videos_enabled"`
 	PhotoAddress  *string `json:"photo_address"`
+	// What to do with unapproved donations whose card hold is about to lapse:
+	// "notify" (default) asks the parent to decide, "capture" takes the money anyway
+	ExpiringAuthorizationPolicy string `json:"expiring_authorization_policy"`
 }
 
 // CreateEventResponse represents the response after creating an event
@@ -41,29 +46,22 @@ func CreateEvent(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		// Parse expiry date
-		expiresAt, err := time.Parse("2006-01-02", req.ExpiresAt)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid date format. Use YYYY-MM-DD (e.g., 2025-07-15)",
-			})
+		expiresAt, ok := parseEventExpiry(c, req.ExpiresAt)
+		if !ok {
 			return
 		}
 
-		// Validate expiry date is in the future
-		if expiresAt.Before(time.Now()) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Expiry date must be in the future",
-			})
+		// Validate the expiring authorisation policy
+		if req.ExpiringAuthorizationPolicy == "" {
+			req.ExpiringAuthorizationPolicy = "notify"
+		}
+		if !validAuthorizationPolicy(c, req.ExpiringAuthorizationPolicy) {
 			return
 		}
 
-		// Validate expiry date is not too far in the future (max 2 years)
-		twoYearsFromNow := time.Now().AddDate(2, 0, 0)
-		if expiresAt.After(twoYearsFromNow) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Expiry date cannot be more than 2 years in the future",
-			})
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
 			return
 		}
 
@@ -81,7 +79,7 @@ func CreateEvent(db *pgxpool.Pool) gin.HandlerFunc {
 		var childName string
 		var parentID int
 
-		err = db.QueryRow(context.Background(), childQuery, req.ChildID).Scan(
+		err := db.QueryRow(context.Background(), childQuery, req.ChildID).Scan(
 			&childID,
 			&childName,
 			&parentID,
@@ -99,42 +97,53 @@ func CreateEvent(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		// Check if child already has an active event with the same name
-		duplicateCheckQuery := `
-			SELECT event_id 
-			FROM events 
-			WHERE child_id = $1 
-			AND event_name = $2 
-			AND expires_at > NOW()
-		`
+		// Parents can only create events for their own children
+		if parentID != callerID {
+			forbidResource(c, "child")
+			return
+		}
 
-		var existingEventID int
-		err = db.QueryRow(context.Background(), duplicateCheckQuery, req.ChildID, req.EventName).Scan(&existingEventID)
-		if err == nil {
-			c.JSON(http.StatusConflict, gin.H{
-				"error": "An active event with this name already exists for this child",
+		// An uploaded photo must be one of the caller's
+		if req.PhotoAddress != nil && !requireOwnPhoto(c, db, callerID, *req.PhotoAddress) {
+			return
+		}
+
+		tx, err := db.Begin(context.Background())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to create event",
 			})
 			return
 		}
+		defer tx.Rollback(context.Background())
+
+		// Check if child already has an active event with the same name
+		if !requireUniqueEventName(c, tx, req.ChildID, req.EventName, 0) {
+			return
+		}
 
 		// Insert new event
 		insertQuery := `
-			INSERT INTO events (child_id, event_name, expires_at, event_message, videos_enabled, photo_address)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO events (child_id, event_name, expires_at, event_message, videos_enabled, photo_address, expiring_authorization_policy)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
 			RETURNING event_id, created_at
 		`
 
 		var eventID int
 		var createdAt time.Time
 
-		err = db.QueryRow(context.Background(), insertQuery,
+		err = tx.QueryRow(context.Background(), insertQuery,
 			req.ChildID,
 			req.EventName,
 			expiresAt,
 			req.EventMessage,
 			req.VideosEnabled,
 			req.PhotoAddress,
+			req.ExpiringAuthorizationPolicy,
 		).Scan(&eventID, &createdAt)
+		if err == nil {
+			err = tx.Commit(context.Background())
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to create event",
@@ -153,3 +162,85 @@ func CreateEvent(db *pgxpool.Pool) gin.HandlerFunc {
 		c.JSON(http.StatusCreated, response)
 	}
 }
+
+// parseEventExpiry parses an event's YYYY-MM-DD expiry date, which must be in
+// the future and no more than 2 years away, responding if it isn't
+func parseEventExpiry(c *gin.Context, value string) (time.Time, bool) {
+	expiresAt, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid date format. Use YYYY-MM-DD (e.g., 2025-07-15)",
+		})
+		return time.Time{}, false
+	}
+
+	// Validate expiry date is in the future
+	if expiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Expiry date must be in the future",
+		})
+		return time.Time{}, false
+	}
+
+	// Validate expiry date is not too far in the future (max 2 years)
+	twoYearsFromNow := time.Now().AddDate(2, 0, 0)
+	if expiresAt.After(twoYearsFromNow) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Expiry date cannot be more than 2 years in the future",
+		})
+		return time.Time{}, false
+	}
+
+	return expiresAt, true
+}
+
+// validAuthorizationPolicy checks an expiring authorisation policy, responding if it isn't one
+func validAuthorizationPolicy(c *gin.Context, policy string) bool {
+	if policy != "notify" && policy != "capture" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "expiring_authorization_policy must be 'notify' or 'capture'",
+		})
+		return false
+	}
+	return true
+}
+
+// requireUniqueEventName checks the child has no other active event called
+// name, responding with a conflict if they do. exceptEventID is the event
+// being renamed (0 when creating one). The event must be saved in tx.
+func requireUniqueEventName(c *gin.Context, tx pgx.Tx, childID int, name string, exceptEventID int) bool {
+	// Hold the child until the caller's transaction ends, so two requests can't
+	// both find the name free
+	if _, err := tx.Exec(context.Background(), `SELECT child_id FROM children WHERE child_id = $1 FOR UPDATE`, childID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database query failed",
+		})
+		return false
+	}
+
+	duplicateCheckQuery := `
+		SELECT event_id 
+		FROM events 
+		WHERE child_id = $1 
+		AND event_name = $2 
+		AND expires_at > NOW()
+		AND cancelled_at IS NULL
+		AND event_id <> $3
+	`
+
+	var existingEventID int
+	err := tx.QueryRow(context.Background(), duplicateCheckQuery, childID, name, exceptEventID).Scan(&existingEventID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "An active event with this name already exists for this child",
+		})
+		return false
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database query failed",
+		})
+		return false
+	}
+	return true
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"aletterahead-api/auth"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -47,6 +49,14 @@ func CreateParent(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// The token's subject is the only Auth0 ID the caller may use
+		if req.Auth0ID != auth.Subject(c) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "auth0_id does not match the authenticated user",
+			})
+			return
+		}
+
 		// Check if parent with same auth0_id already exists
 		auth0CheckQuery := `SELECT parent_id FROM parents WHERE auth0_id = $1`
 		var existingParentID int
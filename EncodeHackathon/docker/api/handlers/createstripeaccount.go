@@ -2,36 +2,43 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
-	"strings"
 	"time"
 
+	"aletterahead-api/payments"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76/client"
 )
 
-// SaveStripeAccountRequest represents the request structure for saving a Stripe account ID
-type SaveStripeAccountRequest struct {
-	ParentID               int    `json:"parent_id" binding:"required"`
-	StripeConnectAccountID string `json:"stripe_connect_account_id" binding:"required"`
+// CreateStripeAccountRequest represents the request structure for creating a Stripe account
+type CreateStripeAccountRequest struct {
+	ParentID int `json:"parent_id"` // Optional, must match the authenticated parent
 }
 
-// SaveStripeAccountResponse represents the response after saving a Stripe account
-type SaveStripeAccountResponse struct {
-	AccountID              int    `json:"account_id"`
-	ParentID               int    `json:"parent_id"`
-	StripeConnectAccountID string `json:"stripe_connect_account_id"`
-	OnboardingComplete     bool   `json:"onboarding_complete"`
-	Message                string `json:"message"`
+// CreateStripeAccountResponse represents the response after creating a Stripe account
+type CreateStripeAccountResponse struct {
+	AccountID              int        `json:"account_id"`
+	ParentID               int        `json:"parent_id"`
+	StripeConnectAccountID string     `json:"stripe_connect_account_id"`
+	OnboardingComplete     bool       `json:"onboarding_complete"`
+	OnboardingURL          string     `json:"onboarding_url,omitempty"`
+	OnboardingURLExpiresAt *time.Time `json:"onboarding_url_expires_at,omitempty"`
+	Message                string     `json:"message"`
 }
 
-// SaveStripeAccount saves a Stripe Connect account ID that was created client-side
-func SaveStripeAccount(db *pgxpool.Pool) gin.HandlerFunc {
+// CreateStripeAccount creates a Stripe Connect account for the authenticated parent
+// and returns a link to Stripe's hosted onboarding
+func CreateStripeAccount(db *pgxpool.Pool, sc *client.API, cfg payments.ConnectConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req SaveStripeAccountRequest
+		var req CreateStripeAccountRequest
 
-		// Bind JSON request body
-		if err := c.ShouldBindJSON(&req); err != nil {
+		// Bind JSON request body (may be empty, the caller comes from the token)
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid request format",
 				"details": err.Error(),
@@ -39,68 +46,79 @@ func SaveStripeAccount(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		// Validate stripe_connect_account_id format (should start with "acct_")
-		if !strings.HasPrefix(req.StripeConnectAccountID, "acct_") {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid Stripe account ID format. Should start with 'acct_'",
-			})
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
 			return
 		}
-
-		// Verify parent exists
-		parentCheckQuery := `SELECT parent_id FROM parents WHERE parent_id = $1`
-		var parentExists int
-		err := db.QueryRow(context.Background(), parentCheckQuery, req.ParentID).Scan(&parentExists)
-		if err != nil {
-			if err.Error() == "no rows in result set" {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error": "Parent not found",
-				})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Database query failed",
-			})
+		if !requireSameParent(c, parentID, req.ParentID) {
 			return
 		}
 
 		// Check if parent already has a Stripe account
 		existingAccountQuery := `SELECT account_id FROM payment_accounts WHERE parent_id = $1`
 		var existingAccountID int
-		err = db.QueryRow(context.Background(), existingAccountQuery, req.ParentID).Scan(&existingAccountID)
+		err := db.QueryRow(context.Background(), existingAccountQuery, parentID).Scan(&existingAccountID)
 		if err == nil {
 			c.JSON(http.StatusConflict, gin.H{
-				"error": "Parent already has a Stripe account",
+				"error": "Parent already has a Stripe account. Use /api/payments/onboarding-link to resume onboarding",
+			})
+			return
+		}
+		// Any other failure must not go on to create a second account
+		if !errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
 			})
 			return
 		}
 
-		// Check if this Stripe account ID is already used by another parent
-		duplicateStripeQuery := `SELECT account_id FROM payment_accounts WHERE stripe_connect_account_id = $1`
-		var duplicateAccountID int
-		err = db.QueryRow(context.Background(), duplicateStripeQuery, req.StripeConnectAccountID).Scan(&duplicateAccountID)
-		if err == nil {
-			c.JSON(http.StatusConflict, gin.H{
-				"error": "This Stripe account is already linked to another parent",
+		// The account is created with the parent's email so Stripe can prefill onboarding
+		var email string
+		err = db.QueryRow(context.Background(), `SELECT parent_email FROM parents WHERE parent_id = $1`, parentID).Scan(&email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		account, err := payments.CreateConnectAccount(sc, cfg, parentID, email)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":   "Failed to create Stripe account",
+				"details": err.Error(),
 			})
 			return
 		}
 
-		// Insert payment account record
+		// Insert payment account record. A parent has one account: a request that raced
+		// this one got the same Stripe account (the idempotency key is per parent) and saved it.
 		insertQuery := `
-			INSERT INTO payment_accounts (parent_id, stripe_connect_account_id, onboarding_complete)
-			VALUES ($1, $2, $3)
-			RETURNING account_id, created_at
+			INSERT INTO payment_accounts (parent_id, stripe_connect_account_id, onboarding_complete,
+				charges_enabled, payouts_enabled, details_submitted)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (parent_id) DO NOTHING
+			RETURNING account_id
 		`
 
 		var accountID int
-		var createdAt time.Time
+		onboardingComplete := payments.OnboardingComplete(account)
 
 		err = db.QueryRow(context.Background(), insertQuery,
-			req.ParentID,
-			req.StripeConnectAccountID,
-			false, // onboarding starts as incomplete - frontend will update via webhook
-		).Scan(&accountID, &createdAt)
+			parentID,
+			account.ID,
+			onboardingComplete,
+			account.ChargesEnabled,
+			account.PayoutsEnabled,
+			account.DetailsSubmitted,
+		).Scan(&accountID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Parent already has a Stripe account. Use /api/payments/onboarding-link to resume onboarding",
+			})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to save payment account",
@@ -108,13 +126,22 @@ func SaveStripeAccount(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		// Return success response
-		response := SaveStripeAccountResponse{
+		response := CreateStripeAccountResponse{
 			AccountID:              accountID,
-			ParentID:               req.ParentID,
-			StripeConnectAccountID: req.StripeConnectAccountID,
-			OnboardingComplete:     false,
-			Message:                "Stripe account linked successfully. Complete onboarding to start receiving payments.",
+			ParentID:               parentID,
+			StripeConnectAccountID: account.ID,
+			OnboardingComplete:     onboardingComplete,
+			Message:                "Stripe account created. Complete onboarding to start receiving payments.",
+		}
+
+		// Send the parent straight into onboarding; if this fails they can resume later
+		link, err := payments.OnboardingLink(sc, cfg, account.ID)
+		if err != nil {
+			response.Message = "Stripe account created, but the onboarding link could not be generated. Use /api/payments/onboarding-link to try again."
+		} else {
+			expiresAt := time.Unix(link.ExpiresAt, 0)
+			response.OnboardingURL = link.URL
+			response.OnboardingURLExpiresAt = &expiresAt
 		}
 
 		c.JSON(http.StatusCreated, response)
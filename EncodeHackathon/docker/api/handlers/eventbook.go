@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"aletterahead-api/keepsake"
+	"aletterahead-api/media"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EventBookRequest names the event to print
+type EventBookRequest struct {
+	EventID int `json:"event_id" binding:"required"`
+}
+
+// EventBook downloads one of the caller's events as a printable PDF book: the
+// event's name, photo and message, then every approved donation's message with
+// the donor's name and date. Videos are printed as their thumbnail and a QR
+// code. A printed page can't be given fresh links, so the codes are signed by
+// links, which last far longer than the usual video links; without a configured
+// secret no book is made, since its codes would stop working when the API restarts.
+func EventBook(db *pgxpool.Pool, videos VideoConfig, links media.LinkSigner) gin.HandlerFunc {
+	bookVideos := videos
+	bookVideos.Links = links
+
+	return func(c *gin.Context) {
+		if len(links.Secret) == 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Video link secret not configured",
+			})
+			return
+		}
+
+		var req EventBookRequest
+
+		// Bind JSON request body
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
+		k, err := keepsake.Load(c.Request.Context(), db, keepsake.Scope{EventID: req.EventID})
+		if err != nil {
+			if errors.Is(err, keepsake.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Event not found",
+				})
+				return
+			}
+			log.Printf("Failed to load event %d for its book: %v", req.EventID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		// Parents can only print their own events
+		if k.ParentID != callerID {
+			forbidResource(c, "event")
+			return
+		}
+
+		opts := keepsake.BookOptions{
+			VideoLink: func(d keepsake.Donation) string {
+				if d.Video != nil {
+					return bookVideos.signedVideoURL(d.Video.Filename)
+				}
+				if d.VideoAddress != nil {
+					return bookVideos.signVideoAddress(*d.VideoAddress, "")
+				}
+				return ""
+			},
+		}
+		// Only uploaded photos can be printed; other addresses aren't fetched
+		if k.Event.PhotoAddress != nil {
+			if filename, ok := photoFilenameFromAddress(*k.Event.PhotoAddress); ok {
+				opts.PhotoKey = media.PhotoKey(filename)
+			}
+		}
+		if links.TTL > 0 {
+			opts.LinksExpire = time.Now().Add(links.TTL)
+		}
+
+		var book bytes.Buffer
+		if err := keepsake.WriteBook(c.Request.Context(), &book, videos.Store, k, opts); err != nil {
+			log.Printf("Failed to make the book for event %d: %v", req.EventID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to make the book",
+			})
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="`+k.BookName()+`.pdf"`)
+		c.Header("Cache-Control", "no-store")
+		c.Header("Content-Length", strconv.Itoa(book.Len()))
+		c.Data(http.StatusOK, "application/pdf", book.Bytes())
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"aletterahead-api/keepsake"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ExportKeepsakeRequest picks what to download: all of a child's events, or one event
+type ExportKeepsakeRequest struct {
+	ChildID int `json:"child_id"`
+	EventID int `json:"event_id"`
+}
+
+// ExportKeepsake downloads everything collected for one of the caller's
+// children (or one of their events) as a ZIP: each approved donation's message,
+// donor, amount, date and video, with a manifest.json and an index.html. The
+// archive is streamed as it is built, so videos are never held in memory.
+func ExportKeepsake(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ExportKeepsakeRequest
+
+		// Bind JSON request body
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+		if (req.ChildID == 0) == (req.EventID == 0) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Send either child_id or event_id",
+			})
+			return
+		}
+
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
+		k, err := keepsake.Load(c.Request.Context(), db, keepsake.Scope{ChildID: req.ChildID, EventID: req.EventID})
+		if err != nil {
+			if errors.Is(err, keepsake.ErrNotFound) {
+				what := "Child"
+				if req.EventID != 0 {
+					what = "Event"
+				}
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": what + " not found",
+				})
+				return
+			}
+			log.Printf("Failed to load keepsake: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		// Parents can only download their own children's keepsakes
+		if k.ParentID != callerID {
+			if req.EventID != 0 {
+				forbidResource(c, "event")
+			} else {
+				forbidResource(c, "child")
+			}
+			return
+		}
+
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", `attachment; filename="`+k.Name()+`.zip"`)
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+
+		if err := keepsake.Write(c.Request.Context(), c.Writer, videos.Store, k); err != nil {
+			log.Printf("Keepsake for child %d failed part way: %v", k.ChildID, err)
+			abortStream(c)
+		}
+	}
+}
+
+// abortStream drops the connection of a response that failed after its body
+// was started, so the client sees a broken download rather than one that
+// looks complete
+func abortStream(c *gin.Context) {
+	c.Abort()
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		return
+	}
+	conn.Close()
+}
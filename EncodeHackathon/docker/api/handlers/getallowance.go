@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"aletterahead-api/isa"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ChildAllowance represents a child's Junior ISA allowance for the current tax year
+type ChildAllowance struct {
+	ChildID        int       `json:"child_id"`
+	ChildName      string    `json:"child_name"`
+	TaxYear        string    `json:"tax_year"`
+	TaxYearStart   time.Time `json:"tax_year_start"`
+	TaxYearEnd     time.Time `json:"tax_year_end"` // last day (5 April)
+	AllowancePence int       `json:"allowance_pence"`
+	CapturedPence  int       `json:"captured_pence"`
+	PendingPence   int       `json:"pending_pence"`
+	RemainingPence int       `json:"remaining_pence"`
+}
+
+// GetAllowanceRequest represents the request structure for getting ISA allowances
+type GetAllowanceRequest struct {
+	ParentID int `json:"parent_id"` // Optional, must match the authenticated parent
+	ChildID  int `json:"child_id"`  // Optional, defaults to all of the parent's children
+}
+
+// GetAllowanceResponse represents the response with each child's allowance
+type GetAllowanceResponse struct {
+	Children []ChildAllowance `json:"children"`
+	Count    int              `json:"count"`
+}
+
+// GetAllowance returns how much Junior ISA allowance each of the parent's children has left this tax year
+func GetAllowance(db *pgxpool.Pool, allowance isa.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GetAllowanceRequest
+
+		// Bind JSON request body (may be empty, the caller comes from the token)
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+		if !requireSameParent(c, parentID, req.ParentID) {
+			return
+		}
+
+		// Query the parent's children (or just the one asked for)
+		query := `
+			SELECT child_id, child_name, parent_id
+			FROM children
+			WHERE ($2 = 0 AND parent_id = $1) OR child_id = $2
+			ORDER BY child_name ASC
+		`
+
+		rows, err := db.Query(context.Background(), query, parentID, req.ChildID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		type childRow struct {
+			id, parentID int
+			name         string
+		}
+		var found []childRow
+		for rows.Next() {
+			var row childRow
+			if err := rows.Scan(&row.id, &row.name, &row.parentID); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to scan child data",
+				})
+				return
+			}
+			found = append(found, row)
+		}
+		rows.Close()
+
+		// Check for errors from iterating over rows
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Error processing child data",
+			})
+			return
+		}
+
+		if req.ChildID != 0 {
+			if len(found) == 0 {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Child not found",
+				})
+				return
+			}
+			// Parents can only see their own children's allowance
+			if found[0].parentID != parentID {
+				forbidResource(c, "child")
+				return
+			}
+		}
+
+		taxYear := isa.TaxYearFor(time.Now())
+		children := []ChildAllowance{}
+
+		for _, child := range found {
+			usage, err := isa.ChildUsage(context.Background(), db, child.id, taxYear)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to total donations",
+				})
+				return
+			}
+
+			children = append(children, ChildAllowance{
+				ChildID:        child.id,
+				ChildName:      child.name,
+				TaxYear:        taxYear.Label(),
+				TaxYearStart:   taxYear.Start,
+				TaxYearEnd:     taxYear.LastDay(),
+				AllowancePence: allowance.AllowancePence,
+				CapturedPence:  usage.CapturedPence,
+				PendingPence:   usage.PendingPence,
+				RemainingPence: allowance.Remaining(usage),
+			})
+		}
+
+		c.JSON(http.StatusOK, GetAllowanceResponse{
+			Children: children,
+			Count:    len(children),
+		})
+	}
+}
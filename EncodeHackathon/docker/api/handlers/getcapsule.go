@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"aletterahead-api/capsules"
+	"aletterahead-api/media"
+	"aletterahead-api/sealed"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CapsuleItem is one gift in a time capsule
+type CapsuleItem struct {
+	DonationID   int       `json:"donation_id"`
+	DonorName    string    `json:"donor_name"`
+	Message      *string   `json:"message"`
+	AmountPence  int       `json:"amount_pence"`
+	EventName    string    `json:"event_name"`
+	CreatedAt    time.Time `json:"created_at"`
+	VideoAddress *string   `json:"video_address"`
+	ThumbnailURL *string   `json:"thumbnail_url"`
+	Sealed       bool      `json:"sealed"`                 // A private letter for the child
+	SealedUntil  *string   `json:"sealed_until,omitempty"` // YYYY-MM-DD: the child's 18th birthday, when a letter is still sealed
+}
+
+// CapsuleResponse is a child's time capsule
+type CapsuleResponse struct {
+	DeliveryID   int           `json:"delivery_id"`
+	ChildName    string        `json:"child_name"`
+	DeliveredAt  time.Time     `json:"delivered_at"`
+	MessageCount int           `json:"message_count"`
+	VideoCount   int           `json:"video_count"`
+	Items        []CapsuleItem `json:"items"`
+}
+
+// GetCapsule shows a child the time capsule they were emailed. The child has no
+// account, so the signed link from the email is what lets them in; the videos
+// in it get the usual short-lived video links. This is the only place sealed
+// letters are opened, and only once the child is 18.
+func GetCapsule(db *pgxpool.Pool, links media.LinkSigner, videos VideoConfig, keys sealed.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		deliveryID, err := strconv.Atoi(c.Param("id"))
+		if err != nil || deliveryID <= 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Capsule not found",
+			})
+			return
+		}
+
+		if err := links.Verify(capsules.Resource(deliveryID), c.Request.URL.Query(), time.Now()); err != nil {
+			if errors.Is(err, media.ErrLinkExpired) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Capsule link has expired",
+				})
+				return
+			}
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Invalid capsule link",
+			})
+			return
+		}
+
+		delivery, err := capsules.Get(c.Request.Context(), db, deliveryID)
+		if err != nil {
+			if errors.Is(err, capsules.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Capsule not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+		// Links are only handed out in the email
+		if delivery.SentAt == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Capsule not found",
+			})
+			return
+		}
+
+		found, err := capsules.Items(c.Request.Context(), db, deliveryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to load capsule",
+			})
+			return
+		}
+
+		var videoAddresses []string
+		for _, item := range found {
+			if item.VideoAddress != nil {
+				videoAddresses = append(videoAddresses, *item.VideoAddress)
+			}
+		}
+		thumbnails, err := thumbnailURLs(c.Request.Context(), db, videoAddresses)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to query video thumbnails",
+			})
+			return
+		}
+
+		// A capsule delivered before the child is 18 keeps its letters sealed
+		var opener *sealed.Opener
+		for _, item := range found {
+			if !item.Sealed {
+				continue
+			}
+			o, err := keys.Opener(c.Request.Context(), db, deliveryID)
+			if err != nil {
+				log.Printf("Failed to open sealed letters in capsule %d: %v", deliveryID, err)
+			} else {
+				opener = &o
+			}
+			break
+		}
+		now := time.Now()
+
+		items := make([]CapsuleItem, 0, len(found))
+		for _, item := range found {
+			ci := CapsuleItem{
+				DonationID:  item.DonationID,
+				DonorName:   item.DonorName,
+				Message:     item.Message,
+				AmountPence: item.AmountPence,
+				EventName:   item.EventName,
+				CreatedAt:   item.CreatedAt,
+			}
+			if item.VideoAddress != nil {
+				if _, ok := thumbnails[*item.VideoAddress]; ok {
+					thumbnail := videos.signVideoAddress(*item.VideoAddress, "/thumbnail")
+					ci.ThumbnailURL = &thumbnail
+				}
+				address := videos.signVideoAddress(*item.VideoAddress, "")
+				ci.VideoAddress = &address
+			}
+			if item.Sealed {
+				ci.Sealed = true
+				switch {
+				case opener != nil && opener.Ready(now):
+					message, err := opener.Open(item.SealedMessage, now)
+					if err != nil {
+						log.Printf("Failed to open sealed letter %d: %v", item.DonationID, err)
+					} else {
+						ci.Message = &message
+					}
+				case opener != nil:
+					until := opener.OpensOn.Format("2006-01-02")
+					ci.SealedUntil = &until
+				}
+			}
+			items = append(items, ci)
+		}
+
+		if err := capsules.MarkOpened(c.Request.Context(), db, deliveryID); err != nil {
+			log.Printf("Failed to record capsule %d as opened: %v", deliveryID, err)
+		}
+
+		c.JSON(http.StatusOK, CapsuleResponse{
+			DeliveryID:   delivery.ID,
+			ChildName:    delivery.ChildName,
+			DeliveredAt:  *delivery.SentAt,
+			MessageCount: delivery.MessageCount,
+			VideoCount:   delivery.VideoCount,
+			Items:        items,
+		})
+	}
+}
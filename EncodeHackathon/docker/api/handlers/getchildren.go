@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
@@ -18,11 +20,15 @@ type Child struct {
 	ISAExpiry time.Time `json:"isa_expiry"`
 	CreatedAt time.Time `json:"created_at"`
 	ChildName string    `json:"child_name"`
+	// DeliveryDate is when their time capsule is emailed to them: the date the
+	// parent chose, or their 18th birthday
+	DeliveryDate       time.Time  `json:"delivery_date"`
+	CapsuleDeliveredAt *time.Time `json:"capsule_delivered_at"`
 }
 
 // GetChildrenRequest represents the request structure for getting children
 type GetChildrenRequest struct {
-	ParentID int `json:"parent_id" binding:"required"`
+	ParentID int `json:"parent_id"` // Optional, must match the authenticated parent
 }
 
 // GetChildrenResponse represents the response with list of children
@@ -31,13 +37,13 @@ type GetChildrenResponse struct {
 	Count    int     `json:"count"`
 }
 
-// GetChildren returns all children for a parent
+// GetChildren returns all children for the authenticated parent
 func GetChildren(db *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req GetChildrenRequest
 
-		// Bind JSON request body
-		if err := c.ShouldBindJSON(&req); err != nil {
+		// Bind JSON request body (may be empty, the caller comes from the token)
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid request format",
 				"details": err.Error(),
@@ -45,22 +51,34 @@ func GetChildren(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+		if !requireSameParent(c, parentID, req.ParentID) {
+			return
+		}
+
 		// Query to get all children for the parent
 		query := `
 			SELECT 
-				child_id,
-				DOB,
-				parent_id,
-				email,
-				isa_expiry,
-				created_at,
-				child_name
-			FROM children
-			WHERE parent_id = $1
-			ORDER BY child_name ASC
+				c.child_id,
+				c.DOB,
+				c.parent_id,
+				c.email,
+				c.isa_expiry,
+				c.created_at,
+				c.child_name,
+				COALESCE(c.delivery_date, (c.DOB + INTERVAL '18 years')::DATE),
+				d.sent_at
+			FROM children c
+			LEFT JOIN capsule_deliveries d ON d.child_id = c.child_id
+			WHERE c.parent_id = $1
+			ORDER BY c.child_name ASC
 		`
 
-		rows, err := db.Query(context.Background(), query, req.ParentID)
+		rows, err := db.Query(context.Background(), query, parentID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Database query failed",
@@ -81,6 +99,8 @@ func GetChildren(db *pgxpool.Pool) gin.HandlerFunc {
 				&child.ISAExpiry,
 				&child.CreatedAt,
 				&child.ChildName,
+				&child.DeliveryDate,
+				&child.CapsuleDeliveredAt,
 			)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
@@ -2,31 +2,86 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // EventSummary represents event data for listing view
 type EventSummary struct {
-	EventID       int       `json:"event_id"`
-	ChildID       int       `json:"child_id"`
-	EventName     string    `json:"event_name"`
-	ExpiresAt     time.Time `json:"expires_at"`
-	CreatedAt     time.Time `json:"created_at"`
-	EventMessage  *string   `json:"event_message"`
-	VideosEnabled bool      `json:"videos_enabled"`
-	PhotoAddress  *string   `json:"photo_address"`
-	ChildName     string    `json:"child_name"`
-	IsExpired     bool      `json:"is_expired"`
-	DaysRemaining int       `json:"days_remaining"`
+	EventID                     int        `json:"event_id"`
+	ChildID                     int        `json:"child_id"`
+	EventName                   string     `json:"event_name"`
+	ExpiresAt                   time.Time  `json:"expires_at"`
+	CreatedAt                   time.Time  `json:"created_at"`
+	EventMessage                *string    `json:"event_message"`
+	VideosEnabled               bool       `json:"videos_enabled"`
+	PhotoAddress                *string    `json:"photo_address"`
+	ExpiringAuthorizationPolicy string     `json:"expiring_authorization_policy"`
+	ChildName                   string     `json:"child_name"`
+	CancelledAt                 *time.Time `json:"cancelled_at"`
+	CancelReason                *string    `json:"cancel_reason"`
+	IsCancelled                 bool       `json:"is_cancelled"`
+	IsExpired                   bool       `json:"is_expired"`
+	DaysRemaining               int        `json:"days_remaining"`
+}
+
+// eventSummaryColumns are the columns scanned by scanEventSummary
+const eventSummaryColumns = `
+	e.event_id,
+	e.child_id,
+	e.event_name,
+	e.expires_at,
+	e.created_at,
+	e.event_message,
+	e.videos_enabled,
+	e.photo_address,
+	e.expiring_authorization_policy,
+	c.child_name,
+	e.cancelled_at,
+	e.cancel_reason
+`
+
+// scanEventSummary reads an event selected with eventSummaryColumns and works
+// out whether it is still open
+func scanEventSummary(row pgx.Row, now time.Time) (EventSummary, error) {
+	var event EventSummary
+	err := row.Scan(
+		&event.EventID,
+		&event.ChildID,
+		&event.EventName,
+		&event.ExpiresAt,
+		&event.CreatedAt,
+		&event.EventMessage,
+		&event.VideosEnabled,
+		&event.PhotoAddress,
+		&event.ExpiringAuthorizationPolicy,
+		&event.ChildName,
+		&event.CancelledAt,
+		&event.CancelReason,
+	)
+	if err != nil {
+		return EventSummary{}, err
+	}
+
+	// Calculate expiry status and days remaining (a cancelled event has none left)
+	event.IsCancelled = event.CancelledAt != nil
+	event.IsExpired = now.After(event.ExpiresAt)
+	if !event.IsExpired && !event.IsCancelled {
+		duration := event.ExpiresAt.Sub(now)
+		event.DaysRemaining = int(duration.Hours() / 24)
+	}
+	return event, nil
 }
 
 // GetEventsRequest represents the request structure for getting events
 type GetEventsRequest struct {
-	ParentID int `json:"parent_id" binding:"required"`
+	ParentID int `json:"parent_id"` // Optional, must match the authenticated parent
 }
 
 // GetEventsResponse represents the response with list of events
@@ -35,13 +90,13 @@ type GetEventsResponse struct {
 	Count  int            `json:"count"`
 }
 
-// GetEvents returns all events for a parent's children
+// GetEvents returns all events for the authenticated parent's children
 func GetEvents(db *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req GetEventsRequest
 
-		// Bind JSON request body
-		if err := c.ShouldBindJSON(&req); err != nil {
+		// Bind JSON request body (may be empty, the caller comes from the token)
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid request format",
 				"details": err.Error(),
@@ -49,25 +104,25 @@ func GetEvents(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+		if !requireSameParent(c, parentID, req.ParentID) {
+			return
+		}
+
 		// Query to get all events for the parent's children
 		query := `
-			SELECT 
-				e.event_id,
-				e.child_id,
-				e.event_name,
-				e.expires_at,
-				e.created_at,
-				e.event_message,
-				e.videos_enabled,
-				e.photo_address,
-				c.child_name
+			SELECT ` + eventSummaryColumns + `
 			FROM events e
 			JOIN children c ON e.child_id = c.child_id
 			WHERE c.parent_id = $1
 			ORDER BY e.expires_at ASC, e.created_at DESC
 		`
 
-		rows, err := db.Query(context.Background(), query, req.ParentID)
+		rows, err := db.Query(context.Background(), query, parentID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Database query failed",
@@ -80,18 +135,7 @@ func GetEvents(db *pgxpool.Pool) gin.HandlerFunc {
 		now := time.Now()
 
 		for rows.Next() {
-			var event EventSummary
-			err := rows.Scan(
-				&event.EventID,
-				&event.ChildID,
-				&event.EventName,
-				&event.ExpiresAt,
-				&event.CreatedAt,
-				&event.EventMessage,
-				&event.VideosEnabled,
-				&event.PhotoAddress,
-				&event.ChildName,
-			)
+			event, err := scanEventSummary(rows, now)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": "Failed to scan event data",
@@ -99,15 +143,6 @@ func GetEvents(db *pgxpool.Pool) gin.HandlerFunc {
 				return
 			}
 
-			// Calculate expiry status and days remaining
-			event.IsExpired = now.After(event.ExpiresAt)
-			if !event.IsExpired {
-				duration := event.ExpiresAt.Sub(now)
-				event.DaysRemaining = int(duration.Hours() / 24)
-			} else {
-				event.DaysRemaining = 0
-			}
-
 			events = append(events, event)
 		}
 
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"time"
 
+	"aletterahead-api/auth"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -45,6 +47,14 @@ func GetParent(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// The token's subject is the only Auth0 ID the caller may use
+		if req.Auth0ID != auth.Subject(c) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "auth0_id does not match the authenticated user",
+			})
+			return
+		}
+
 		// Query parent by auth0_id
 		query := `
 			SELECT parent_id, parent_email, auth0_id, stripe_customer_id, created_at
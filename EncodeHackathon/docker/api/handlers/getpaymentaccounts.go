@@ -2,16 +2,22 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"time"
 
+	"aletterahead-api/payments"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76/client"
 )
 
 // GetPaymentAccountsRequest represents the request structure for getting payment account status
 type GetPaymentAccountsRequest struct {
-	ParentID int `json:"parent_id" binding:"required"`
+	ParentID int `json:"parent_id"` // Optional, must match the authenticated parent
 }
 
 // PaymentAccountInfo represents payment account information
@@ -19,6 +25,9 @@ type PaymentAccountInfo struct {
 	AccountID              int       `json:"account_id"`
 	StripeConnectAccountID string    `json:"stripe_connect_account_id"`
 	OnboardingComplete     bool      `json:"onboarding_complete"`
+	ChargesEnabled         bool      `json:"charges_enabled"`
+	PayoutsEnabled         bool      `json:"payouts_enabled"`
+	DetailsSubmitted       bool      `json:"details_submitted"`
 	CreatedAt              time.Time `json:"created_at"`
 }
 
@@ -31,13 +40,15 @@ type GetPaymentAccountsResponse struct {
 	Message        string              `json:"message"`
 }
 
-// GetPaymentAccounts retrieves payment account status for a parent
-func GetPaymentAccounts(db *pgxpool.Pool) gin.HandlerFunc {
+// GetPaymentAccounts retrieves payment account status for the authenticated parent.
+// Accounts still onboarding are refreshed from Stripe, so calling this from the
+// onboarding return page picks up the result without waiting for the webhook.
+func GetPaymentAccounts(db *pgxpool.Pool, sc *client.API) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req GetPaymentAccountsRequest
 
-		// Bind JSON request body
-		if err := c.ShouldBindJSON(&req); err != nil {
+		// Bind JSON request body (may be empty, the caller comes from the token)
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid request format",
 				"details": err.Error(),
@@ -45,41 +56,53 @@ func GetPaymentAccounts(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		// Verify parent exists
-		parentCheckQuery := `SELECT parent_id FROM parents WHERE parent_id = $1`
-		var parentExists int
-		err := db.QueryRow(context.Background(), parentCheckQuery, req.ParentID).Scan(&parentExists)
-		if err != nil {
-			if err.Error() == "no rows in result set" {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error": "Parent not found",
-				})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Database query failed",
-			})
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+		if !requireSameParent(c, parentID, req.ParentID) {
 			return
 		}
 
 		// Query payment account for this parent
 		accountQuery := `
-			SELECT account_id, stripe_connect_account_id, onboarding_complete, created_at
+			SELECT account_id, stripe_connect_account_id, onboarding_complete,
+				charges_enabled, payouts_enabled, details_submitted, created_at
 			FROM payment_accounts 
 			WHERE parent_id = $1
 		`
 
 		var account PaymentAccountInfo
-		err = db.QueryRow(context.Background(), accountQuery, req.ParentID).Scan(
+		err := db.QueryRow(context.Background(), accountQuery, parentID).Scan(
 			&account.AccountID,
 			&account.StripeConnectAccountID,
 			&account.OnboardingComplete,
+			&account.ChargesEnabled,
+			&account.PayoutsEnabled,
+			&account.DetailsSubmitted,
 			&account.CreatedAt,
 		)
 
+		if err == nil && !account.OnboardingComplete {
+			// Stripe is the source of truth; fall back to what we have if it can't be reached
+			stripeAccount, stripeErr := payments.GetConnectAccount(sc, account.StripeConnectAccountID)
+			if stripeErr == nil {
+				stripeErr = saveAccountStatus(db, stripeAccount)
+			}
+			if stripeErr != nil {
+				log.Printf("Failed to refresh Stripe account %s: %v", account.StripeConnectAccountID, stripeErr)
+			} else {
+				account.OnboardingComplete = payments.OnboardingComplete(stripeAccount)
+				account.ChargesEnabled = stripeAccount.ChargesEnabled
+				account.PayoutsEnabled = stripeAccount.PayoutsEnabled
+				account.DetailsSubmitted = stripeAccount.DetailsSubmitted
+			}
+		}
+
 		// Build response based on account status
 		var response GetPaymentAccountsResponse
-		response.ParentID = req.ParentID
+		response.ParentID = parentID
 
 		if err != nil {
 			if err.Error() == "no rows in result set" {
@@ -105,7 +128,7 @@ func GetPaymentAccounts(db *pgxpool.Pool) gin.HandlerFunc {
 				response.Message = "Payment account is ready to receive donations."
 			} else {
 				response.Status = "pending_onboarding"
-				response.Message = "Complete Stripe onboarding to start receiving donations."
+				response.Message = "Complete Stripe onboarding to start receiving donations. Use /api/payments/onboarding-link to continue."
 			}
 		}
 
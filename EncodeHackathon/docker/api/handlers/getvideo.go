@@ -1,16 +1,56 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 
+	"aletterahead-api/storage"
+	"aletterahead-api/transcode"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// GetVideo serves video files from the uploads directory
-func GetVideo(c *gin.Context) {
+// GetVideo serves the transcoded MP4 of an uploaded video, either streamed through
+// the API or by redirecting to a presigned URL on the storage backend (see
+// VideoConfig.ServeMode). Videos are private, so only signed, unexpired links
+// (see ListDonations) are served. Videos still being transcoded aren't served yet.
+func GetVideo(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filename, ok := videoFilenameParam(c)
+		if !ok {
+			return
+		}
+		if !videos.requireVideoLink(c, videoResource(filename, "")) {
+			return
+		}
+
+		// Serve the normalized MP4; videos uploaded before transcoding have no job
+		// and are served as they were uploaded
+		key := videoKey(filename)
+		job, err := transcode.Get(c.Request.Context(), db, filename)
+		switch {
+		case errors.Is(err, transcode.ErrNotFound):
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		case job.Status == transcode.Ready && job.OutputKey != nil:
+			key = *job.OutputKey
+		default:
+			transcodeNotReady(c, job)
+			return
+		}
+
+		videos.serve(c, key, "Video")
+	}
+}
+
+// videoFilenameParam validates the :filename of a video route
+func videoFilenameParam(c *gin.Context) (string, bool) {
 	filename := c.Param("filename")
 
 	// Validate filename (prevent path traversal attacks)
@@ -18,43 +58,91 @@ func GetVideo(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid filename",
 		})
-		return
+		return "", false
 	}
 
 	// Validate file extension (only serve video files)
-	allowedExtensions := []string{".mp4", ".mov", ".avi", ".webm"}
-	fileExt := strings.ToLower(filepath.Ext(filename))
-	isValidExt := false
-	for _, ext := range allowedExtensions {
-		if fileExt == ext {
-			isValidExt = true
-			break
-		}
+	if !isVideoExtension(filename) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid file type",
+		})
+		return "", false
 	}
 
-	if !isValidExt {
+	if !storage.ValidKey(videoKey(filename)) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid file type",
+			"error": "Invalid filename",
+		})
+		return "", false
+	}
+
+	return filename, true
+}
+
+// transcodeNotReady reports a video whose transcode job is still running or has failed
+func transcodeNotReady(c *gin.Context, job transcode.Job) {
+	if job.Status == transcode.Failed {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Video could not be processed",
+			"status": job.Status,
 		})
 		return
 	}
 
-	// Build full file path
-	filePath := filepath.Join("/var/uploads/videos", filename)
+	c.Header("Retry-After", "10")
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":  "Video is still being processed",
+		"status": job.Status,
+	})
+}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+// serve sends the stored object at key to the browser, streamed or by presigned
+// redirect depending on ServeMode. what names it in error messages ("Video", ...).
+func (v VideoConfig) serve(c *gin.Context, key string, what string) {
+	// Let the browser fetch the file from the bucket directly
+	if v.ServeMode == VideoServePresign {
+		if _, err := v.Store.Stat(c.Request.Context(), key); err != nil {
+			objectUnavailable(c, what, err)
+			return
+		}
+
+		presigned, err := v.Store.PresignGet(c.Request.Context(), key, v.PresignTTL)
+		if err == nil {
+			// The link expires, so the redirect itself mustn't be cached
+			c.Header("Cache-Control", "no-store")
+			c.Redirect(http.StatusFound, presigned)
+			return
+		}
+		if !errors.Is(err, storage.ErrPresignUnsupported) {
+			log.Printf("Failed to presign %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to load " + strings.ToLower(what),
+			})
+			return
+		}
+	}
+
+	file, obj, err := v.Store.Open(c.Request.Context(), key)
+	if err != nil {
+		objectUnavailable(c, what, err)
+		return
+	}
+	defer file.Close()
+
+	streamObject(c, file, obj, what)
+}
+
+// objectUnavailable reports a file that couldn't be found or read
+func objectUnavailable(c *gin.Context, what string, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Video not found",
+			"error": what + " not found",
 		})
 		return
 	}
 
-	// Set appropriate headers for video serving
-	c.Header("Content-Type", "video/mp4")             // Default to mp4, browser will handle others
-	c.Header("Accept-Ranges", "bytes")                // Enable video seeking
-	c.Header("Cache-Control", "public, max-age=3600") // Cache for 1 hour
-
-	// Serve the file
-	c.File(filePath)
+	log.Printf("Failed to load %s: %v", strings.ToLower(what), err)
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "Failed to load " + strings.ToLower(what),
+	})
 }
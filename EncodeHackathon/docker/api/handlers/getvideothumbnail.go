@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"aletterahead-api/transcode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetVideoThumbnail serves the JPEG poster frame taken from a video when it was
+// transcoded, so the approval page can show what a video is without loading it.
+// Like the video, it needs a signed link.
+func GetVideoThumbnail(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filename, ok := videoFilenameParam(c)
+		if !ok {
+			return
+		}
+		if !videos.requireVideoLink(c, videoResource(filename, "/thumbnail")) {
+			return
+		}
+
+		job, err := transcode.Get(c.Request.Context(), db, filename)
+		if err != nil {
+			if errors.Is(err, transcode.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Thumbnail not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		if job.Status != transcode.Ready {
+			transcodeNotReady(c, job)
+			return
+		}
+		if job.ThumbnailKey == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Thumbnail not found",
+			})
+			return
+		}
+
+		videos.serve(c, *job.ThumbnailKey, "Thumbnail")
+	}
+}
+
+// thumbnailURL is the address of the thumbnail for a donation's video_address
+func thumbnailURL(videoAddress string) string {
+	return strings.TrimSuffix(videoAddress, "/") + "/thumbnail"
+}
+
+// videoFilenameFromAddress picks the uploaded video's filename out of a
+// video_address like http://host/api/videos/123_clip.mp4 (or just its path)
+func videoFilenameFromAddress(videoAddress string) (string, bool) {
+	u, err := url.Parse(videoAddress)
+	if err != nil {
+		return "", false
+	}
+
+	dir, filename := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if !strings.HasSuffix(dir, "/api/videos/") || !isVideoExtension(filename) {
+		return "", false
+	}
+	return filename, true
+}
+
+// thumbnailURLs returns the thumbnail_url for each video_address whose video has a thumbnail
+func thumbnailURLs(ctx context.Context, db *pgxpool.Pool, videoAddresses []string) (map[string]string, error) {
+	byFilename := map[string]string{}
+	var filenames []string
+	for _, address := range videoAddresses {
+		if filename, ok := videoFilenameFromAddress(address); ok {
+			byFilename[filename] = address
+			filenames = append(filenames, filename)
+		}
+	}
+
+	urls := map[string]string{}
+	if len(filenames) == 0 {
+		return urls, nil
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT source_filename
+		FROM video_transcodes
+		WHERE source_filename = ANY($1)
+		AND status = 'ready'
+		AND thumbnail_key IS NOT NULL
+	`, filenames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return nil, err
+		}
+		address := byFilename[filename]
+		urls[address] = thumbnailURL(address)
+	}
+	return urls, rows.Err()
+}
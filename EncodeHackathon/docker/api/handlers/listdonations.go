@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"time"
 
+	"aletterahead-api/lifecycle"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -12,13 +14,18 @@ import (
 // DonationReview represents donation data for review
 type DonationReview struct {
 	ID           int       `json:"id"`
-	Message      *string   `json:"message"`
+	Message      *string   `json:"message"` // Always null for sealed messages
 	DonorName    string    `json:"donor_name"`
 	AmountPence  int       `json:"amount_pence"`
 	Approved     bool      `json:"approved"`
+	Sealed       bool      `json:"sealed"`                          // Only the child reads the message
+	SealedLength *int      `json:"sealed_message_length,omitempty"` // Characters in a sealed message
+	Status       string    `json:"status"`
 	EventID      int       `json:"event_id"`
 	CreatedAt    time.Time `json:"created_at"`
+	VideoID      *int      `json:"video_id"`
 	VideoAddress *string   `json:"video_address"`
+	ThumbnailURL *string   `json:"thumbnail_url"` // Poster frame of the video, once it has been transcoded
 }
 
 // ListDonationsRequest represents the request structure for listing donations
@@ -26,20 +33,28 @@ type ListDonationsRequest struct {
 	EventID int `json:"event_id" binding:"required"`
 }
 
+// StatusStats counts the donations in one lifecycle status
+type StatusStats struct {
+	Count       int `json:"count"`
+	AmountPence int `json:"amount_pence"`
+}
+
 // ListDonationsResponse represents the response with donation statistics
 type ListDonationsResponse struct {
-	Donations           []DonationReview `json:"donations"`
-	TotalDonations      int              `json:"total_donations"`
-	ApprovedDonations   int              `json:"approved_donations"`
-	PendingDonations    int              `json:"pending_donations"`
-	TotalAmountPence    int              `json:"total_amount_pence"`
-	ApprovedAmountPence int              `json:"approved_amount_pence"`
-	EventName           string           `json:"event_name"`
-	ChildName           string           `json:"child_name"`
+	Donations           []DonationReview       `json:"donations"`
+	TotalDonations      int                    `json:"total_donations"`
+	ApprovedDonations   int                    `json:"approved_donations"` // approved, captured or disputed
+	PendingDonations    int                    `json:"pending_donations"`  // still waiting for the parent's decision
+	TotalAmountPence    int                    `json:"total_amount_pence"`
+	ApprovedAmountPence int                    `json:"approved_amount_pence"`
+	StatusBreakdown     map[string]StatusStats `json:"status_breakdown"`
+	EventName           string                 `json:"event_name"`
+	ChildName           string                 `json:"child_name"`
 }
 
-// ListDonations returns all donations for an event (for parent review)
-func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
+// ListDonations returns all donations for one of the caller's events (for parent
+// review). The text of sealed messages is never returned, only its length.
+func ListDonations(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req ListDonationsRequest
 
@@ -52,18 +67,26 @@ func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
 		// Verify event exists and get event details
 		eventQuery := `
 			SELECT 
 				e.event_name,
-				c.child_name
+				c.child_name,
+				c.parent_id
 			FROM events e
 			JOIN children c ON e.child_id = c.child_id
 			WHERE e.event_id = $1
 		`
 
 		var eventName, childName string
-		err := db.QueryRow(context.Background(), eventQuery, req.EventID).Scan(&eventName, &childName)
+		var ownerID int
+		err := db.QueryRow(context.Background(), eventQuery, req.EventID).Scan(&eventName, &childName, &ownerID)
 		if err != nil {
 			if err.Error() == "no rows in result set" {
 				c.JSON(http.StatusNotFound, gin.H{
@@ -77,20 +100,32 @@ func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Only the event's parent may review its donations
+		if ownerID != callerID {
+			forbidResource(c, "event")
+			return
+		}
+
 		// Query to get all donations for the event
+		// Uploaded videos are listed by path; signVideoAddress builds their links on PUBLIC_BASE_URL
 		donationsQuery := `
 			SELECT 
-				id,
-				message,
-				donor_name,
-				amount_pence,
-				approved,
-				event_id,
-				created_at,
-				video_address
-			FROM donations
-			WHERE event_id = $1
-			ORDER BY created_at DESC
+				d.id,
+				d.message,
+				d.donor_name,
+				d.amount_pence,
+				d.approved,
+				d.status,
+				d.event_id,
+				d.created_at,
+				d.video_id,
+				COALESCE('/api/videos/' || v.filename, d.video_address),
+				d.sealed,
+				d.sealed_length
+			FROM donations d
+			LEFT JOIN videos v ON v.video_id = d.video_id
+			WHERE d.event_id = $1
+			ORDER BY d.created_at DESC
 		`
 
 		rows, err := db.Query(context.Background(), donationsQuery, req.EventID)
@@ -106,6 +141,12 @@ func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
 		var totalAmount, approvedAmount int
 		var approvedCount, pendingCount int
 
+		// Report every status, even those with no donations
+		breakdown := make(map[string]StatusStats, len(lifecycle.All))
+		for _, status := range lifecycle.All {
+			breakdown[string(status)] = StatusStats{}
+		}
+
 		for rows.Next() {
 			var donation DonationReview
 			err := rows.Scan(
@@ -114,9 +155,13 @@ func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
 				&donation.DonorName,
 				&donation.AmountPence,
 				&donation.Approved,
+				&donation.Status,
 				&donation.EventID,
 				&donation.CreatedAt,
+				&donation.VideoID,
 				&donation.VideoAddress,
+				&donation.Sealed,
+				&donation.SealedLength,
 			)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
@@ -128,12 +173,19 @@ func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
 			donations = append(donations, donation)
 			totalAmount += donation.AmountPence
 
+			// Only the parent's own decision counts as approved
+			status := lifecycle.Status(donation.Status)
 			if donation.Approved {
 				approvedCount++
 				approvedAmount += donation.AmountPence
-			} else {
+			} else if status.AwaitingDecision(donation.Approved) {
 				pendingCount++
 			}
+
+			stats := breakdown[donation.Status]
+			stats.Count++
+			stats.AmountPence += donation.AmountPence
+			breakdown[donation.Status] = stats
 		}
 
 		// Check for errors from iterating over rows
@@ -144,6 +196,34 @@ func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// Let the approval page show a poster frame instead of loading every video
+		var videoAddresses []string
+		for _, donation := range donations {
+			if donation.VideoAddress != nil {
+				videoAddresses = append(videoAddresses, *donation.VideoAddress)
+			}
+		}
+
+		thumbnails, err := thumbnailURLs(context.Background(), db, videoAddresses)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to query video thumbnails",
+			})
+			return
+		}
+		// Videos are private, so the parent gets links that only work for a while
+		for i, donation := range donations {
+			if donation.VideoAddress == nil {
+				continue
+			}
+			if _, ok := thumbnails[*donation.VideoAddress]; ok {
+				thumbnail := videos.signVideoAddress(*donation.VideoAddress, "/thumbnail")
+				donations[i].ThumbnailURL = &thumbnail
+			}
+			address := videos.signVideoAddress(*donation.VideoAddress, "")
+			donations[i].VideoAddress = &address
+		}
+
 		// Return response with statistics
 		response := ListDonationsResponse{
 			Donations:           donations,
@@ -152,6 +232,7 @@ func ListDonations(db *pgxpool.Pool) gin.HandlerFunc {
 			PendingDonations:    pendingCount,
 			TotalAmountPence:    totalAmount,
 			ApprovedAmountPence: approvedAmount,
+			StatusBreakdown:     breakdown,
 			EventName:           eventName,
 			ChildName:           childName,
 		}
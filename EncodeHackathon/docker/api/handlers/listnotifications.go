@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ParentNotification represents a message for the parent portal
+type ParentNotification struct {
+	NotificationID int        `json:"notification_id"`
+	DonationID     *int       `json:"donation_id"`
+	Kind           string     `json:"kind"`
+	Message        string     `json:"message"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ReadAt         *time.Time `json:"read_at"`
+}
+
+// ListNotificationsRequest represents the request structure for listing notifications
+type ListNotificationsRequest struct {
+	UnreadOnly bool `json:"unread_only"`
+	MarkRead   bool `json:"mark_read"` // Mark the returned notifications as read
+}
+
+// ListNotificationsResponse represents the response with the parent's notifications
+type ListNotificationsResponse struct {
+	Notifications []ParentNotification `json:"notifications"`
+	Count         int                  `json:"count"`
+}
+
+// ListNotifications returns the authenticated parent's most recent notifications
+func ListNotifications(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ListNotificationsRequest
+
+		// Bind JSON request body (may be empty, the caller comes from the token)
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
+		// Query the latest notifications for the parent
+		query := `
+			SELECT notification_id, donation_id, kind, message, created_at, read_at
+			FROM parent_notifications
+			WHERE parent_id = $1
+			AND ($2 = false OR read_at IS NULL)
+			ORDER BY created_at DESC
+			LIMIT 50
+		`
+
+		rows, err := db.Query(context.Background(), query, parentID, req.UnreadOnly)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+		defer rows.Close()
+
+		notifications := []ParentNotification{}
+		var unreadIDs []int
+
+		for rows.Next() {
+			var n ParentNotification
+			err := rows.Scan(&n.NotificationID, &n.DonationID, &n.Kind, &n.Message, &n.CreatedAt, &n.ReadAt)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to scan notification data",
+				})
+				return
+			}
+			if n.ReadAt == nil {
+				unreadIDs = append(unreadIDs, n.NotificationID)
+			}
+			notifications = append(notifications, n)
+		}
+
+		// Check for errors from iterating over rows
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Error processing notification data",
+			})
+			return
+		}
+
+		if req.MarkRead && len(unreadIDs) > 0 {
+			_, err := db.Exec(context.Background(),
+				`UPDATE parent_notifications SET read_at = NOW() WHERE notification_id = ANY($1) AND parent_id = $2`,
+				unreadIDs, parentID,
+			)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to mark notifications as read",
+				})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, ListNotificationsResponse{
+			Notifications: notifications,
+			Count:         len(notifications),
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"aletterahead-api/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// requireParent resolves the parent_id of the authenticated caller from the token's
+// Auth0 subject. On failure it writes the error response and returns false.
+func requireParent(c *gin.Context, db *pgxpool.Pool) (int, bool) {
+	query := `SELECT parent_id FROM parents WHERE auth0_id = $1`
+
+	var parentID int
+	err := db.QueryRow(context.Background(), query, auth.Subject(c)).Scan(&parentID)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "No parent account for this user",
+			})
+			return 0, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database query failed",
+		})
+		return 0, false
+	}
+
+	return parentID, true
+}
+
+// requireSameParent rejects requests whose body names a parent other than the caller.
+// parent_id is optional now that the caller comes from the token.
+func requireSameParent(c *gin.Context, callerID int, requestedID int) bool {
+	if requestedID != 0 && requestedID != callerID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You do not have access to this parent account",
+		})
+		return false
+	}
+	return true
+}
+
+// forbidResource writes the response for a resource owned by another parent
+func forbidResource(c *gin.Context, resource string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "You do not have access to this " + resource,
+	})
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"aletterahead-api/media"
+	"aletterahead-api/scan"
+	"aletterahead-api/storage"
+	"aletterahead-api/transcode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PhotoConfig controls how event photos are checked, resized and stored
+type PhotoConfig struct {
+	Store   storage.Storage
+	Prober  media.Prober
+	Limits  media.PhotoLimits
+	FFmpeg  transcode.FFmpeg
+	HEIF    transcode.HEIFDecoder
+	MaxSize int64
+	// Scanner checks every upload for malware before it is decoded
+	Scanner scan.Clamd
+	// Timeout bounds the decoding and resizing of one upload
+	Timeout time.Duration
+	// PublicBaseURL is the API's address as browsers see it, as for VideoConfig
+	PublicBaseURL string
+}
+
+// PhotoUploadResponse represents the response after uploading a photo
+type PhotoUploadResponse struct {
+	PhotoID      int               `json:"photo_id"`
+	PhotoAddress string            `json:"photo_address"` // Send as photo_address when creating the event
+	Variants     map[string]string `json:"variants"`      // Address of each resized copy, by name
+	Width        int               `json:"width"`         // Of photo_address
+	Height       int               `json:"height"`
+	Message      string            `json:"message"`
+}
+
+// UploadPhoto handles event photo uploads from parents. The file's content must
+// be a JPEG, PNG, WebP or HEIC image; it is turned upright and re-encoded into
+// resized JPEGs (see media.PhotoVariants) that carry none of the original's
+// EXIF data, so a child's photo never gives away where it was taken. The
+// original isn't kept.
+func UploadPhoto(db *pgxpool.Pool, photos PhotoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
+		// Get the uploaded file
+		file, err := c.FormFile("photo")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "No photo file provided",
+			})
+			return
+		}
+
+		if file.Size > photos.MaxSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("File too large. Maximum size is %dMB", photos.MaxSize/(1024*1024)),
+			})
+			return
+		}
+
+		// ffmpeg and ffprobe need the upload as a file on disk
+		dir, err := os.MkdirTemp("", "photo-upload-*")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to save photo file",
+			})
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		input := filepath.Join(dir, "upload")
+		if err := saveFormFile(c, "photo", input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read photo file",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), photos.Timeout)
+		defer cancel()
+
+		photo, err := photos.ingestPhoto(ctx, db, callerID, dir, input)
+		if err != nil {
+			if rejectedUpload(c, err) {
+				return
+			}
+			log.Printf("Failed to save uploaded photo: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to save photo file",
+			})
+			return
+		}
+
+		response := PhotoUploadResponse{
+			PhotoID:      photo.ID,
+			PhotoAddress: photos.photoURL(photo.Filename),
+			Variants:     map[string]string{},
+			Width:        photo.Width,
+			Height:       photo.Height,
+			Message:      "Photo uploaded successfully",
+		}
+		for _, variant := range media.PhotoVariants {
+			response.Variants[variant.Name] = photos.photoURL(media.PhotoVariantFilename(photo.Filename, variant))
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// ingestPhoto scans the uploaded image at input for malware, checks it, stores
+// its resized variants under a random filename and records it for parentID. dir
+// is a scratch directory. Rejected files return *media.UnsupportedError,
+// *media.LimitError or *scan.InfectedError; an infected file is quarantined.
+func (p PhotoConfig) ingestPhoto(ctx context.Context, db *pgxpool.Pool, parentID int, dir, input string) (media.Photo, error) {
+	scanned, _, err := scanUpload(ctx, p.Scanner, input)
+	if err != nil {
+		return media.Photo{}, err
+	}
+	if scanned.Status == scan.Infected {
+		filename, _, err := quarantineUpload(ctx, p.Store, input, media.PhotoKey)
+		if err != nil {
+			return media.Photo{}, err
+		}
+		log.Printf("Quarantined photo from parent %d (%s): infected with %s", parentID, filename, scanned.Signature)
+		return media.Photo{}, &scan.InfectedError{Signature: scanned.Signature}
+	}
+
+	info, err := p.Prober.InspectPhoto(ctx, input)
+	if err != nil {
+		return media.Photo{}, err
+	}
+
+	// ffmpeg can't read HEIC, so it goes through libheif first
+	source := input
+	if info.IsHEIF() {
+		source = filepath.Join(dir, "decoded.png")
+		if err := p.HEIF.ToPNG(ctx, input, source); err != nil {
+			log.Printf("Failed to decode HEIC photo: %v", err)
+			return media.Photo{}, &media.UnsupportedError{Reason: "File could not be read as an image"}
+		}
+		if err := p.Prober.MeasurePhoto(ctx, source, &info); err != nil {
+			return media.Photo{}, err
+		}
+	}
+	if err := p.Limits.Check(info); err != nil {
+		return media.Photo{}, err
+	}
+
+	filename, err := media.NewFilename(".jpg")
+	if err != nil {
+		return media.Photo{}, err
+	}
+
+	var stored []string
+	for _, variant := range media.PhotoVariants {
+		variantFilename := media.PhotoVariantFilename(filename, variant)
+		output := filepath.Join(dir, variantFilename)
+		if err == nil {
+			err = p.FFmpeg.ResizePhoto(ctx, source, output, info.Orientation, variant.Width, variant.Height, variant.Crop)
+		}
+		if err == nil {
+			err = p.put(ctx, output, media.PhotoKey(variantFilename))
+		}
+		if err != nil {
+			break
+		}
+		stored = append(stored, media.PhotoKey(variantFilename))
+	}
+
+	var display media.PhotoInfo
+	if err == nil {
+		err = p.Prober.MeasurePhoto(ctx, filepath.Join(dir, filename), &display)
+	}
+
+	var photo media.Photo
+	if err == nil {
+		photo, err = media.CreatePhoto(ctx, db, parentID, filename, info.MIMEType, display.Width, display.Height)
+	}
+
+	// Don't leave half a photo behind
+	if err != nil {
+		for _, key := range stored {
+			if deleteErr := p.Store.Delete(context.Background(), key); deleteErr != nil {
+				log.Printf("Failed to delete %s: %v", key, deleteErr)
+			}
+		}
+		return media.Photo{}, err
+	}
+	return photo, nil
+}
+
+// put stores the local file at path under key
+func (p PhotoConfig) put(ctx context.Context, path, key string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := p.Store.Put(ctx, key, f, info.Size(), "image/jpeg"); err != nil {
+		return fmt.Errorf("failed to store photo: %w", err)
+	}
+	return nil
+}
+
+// GetPhoto serves an event photo (any of its variants). Event photos are shown
+// on the public donations page, so no signed link is needed.
+func GetPhoto(photos PhotoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filename := c.Param("filename")
+		key := media.PhotoKey(filename)
+		if strings.ToLower(path.Ext(filename)) != ".jpg" || strings.Contains(filename, "/") || !storage.ValidKey(key) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid filename",
+			})
+			return
+		}
+
+		file, obj, err := photos.Store.Open(c.Request.Context(), key)
+		if err != nil {
+			objectUnavailable(c, "Photo", err)
+			return
+		}
+		defer file.Close()
+
+		streamObject(c, file, obj, "Photo")
+	}
+}
+
+// photoURL is the address a photo variant is served from
+func (p PhotoConfig) photoURL(variantFilename string) string {
+	return strings.TrimSuffix(p.PublicBaseURL, "/") + "/api/photos/" + url.PathEscape(variantFilename)
+}
+
+// photoFilenameFromAddress picks an uploaded photo's filename out of a
+// photo_address like http://host/api/photos/<uuid>.jpg
+func photoFilenameFromAddress(photoAddress string) (string, bool) {
+	u, err := url.Parse(photoAddress)
+	if err != nil {
+		return "", false
+	}
+
+	dir, filename := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if !strings.HasSuffix(dir, "/api/photos/") || filename == "" {
+		return "", false
+	}
+	return filename, true
+}
+
+// requireOwnPhoto checks that a photo_address pointing at an uploaded photo
+// names one of parentID's. Other addresses are left alone. On failure it
+// writes the error response and returns false.
+func requireOwnPhoto(c *gin.Context, db *pgxpool.Pool, parentID int, photoAddress string) bool {
+	filename, ok := photoFilenameFromAddress(photoAddress)
+	if !ok {
+		return true
+	}
+
+	photo, err := media.GetPhotoByFilename(c.Request.Context(), db, filename)
+	if errors.Is(err, media.ErrPhotoNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "photo_address is not an uploaded photo. Use the photo_address returned by /api/uploads/photo",
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database query failed",
+		})
+		return false
+	}
+
+	if photo.ParentID != parentID {
+		forbidResource(c, "photo")
+		return false
+	}
+	return true
+}
+
+// saveFormFile copies the multipart file field to dest
+func saveFormFile(c *gin.Context, field, dest string) error {
+	header, err := c.FormFile(field)
+	if err != nil {
+		return err
+	}
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, src); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -56,7 +56,8 @@ func RequestEvent(db *pgxpool.Pool) gin.HandlerFunc {
 	e.photo_address,
 	c.child_name,
 	pa.stripe_connect_account_id,
-	pa.onboarding_complete
+	pa.onboarding_complete,
+	e.cancelled_at
 FROM events e
 JOIN children c ON e.child_id = c.child_id
 JOIN parents p ON c.parent_id = p.parent_id
@@ -64,6 +65,7 @@ LEFT JOIN payment_accounts pa ON p.parent_id = pa.parent_id
 WHERE e.event_id = $1	`
 
 		var event Event
+		var cancelledAt *time.Time
 		err := db.QueryRow(context.Background(), query, req.EventID).Scan(
 			&event.EventID,
 			&event.ChildID,
@@ -77,6 +79,7 @@ WHERE e.event_id = $1	`
 			// ADD THESE:
 			&event.StripeConnectAccountID,
 			&event.OnboardingComplete,
+			&cancelledAt,
 		)
 		if err != nil {
 			if err.Error() == "no rows in result set" {
@@ -91,6 +94,14 @@ WHERE e.event_id = $1	`
 			return
 		}
 
+		if cancelledAt != nil {
+			c.JSON(http.StatusGone, gin.H{
+				"error":        "This event has been cancelled",
+				"cancelled_at": cancelledAt,
+			})
+			return
+		}
+
 		// Check if event has expired
 		if time.Now().After(event.ExpiresAt) {
 			c.JSON(http.StatusGone, gin.H{
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"aletterahead-api/payments"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+// ResumeOnboardingRequest represents the request structure for resuming Stripe onboarding
+type ResumeOnboardingRequest struct {
+	ParentID int `json:"parent_id"` // Optional, must match the authenticated parent
+}
+
+// ResumeOnboardingResponse represents the response with a fresh onboarding link
+type ResumeOnboardingResponse struct {
+	AccountID              int        `json:"account_id"`
+	StripeConnectAccountID string     `json:"stripe_connect_account_id"`
+	OnboardingComplete     bool       `json:"onboarding_complete"`
+	OnboardingURL          string     `json:"onboarding_url,omitempty"`
+	OnboardingURLExpiresAt *time.Time `json:"onboarding_url_expires_at,omitempty"`
+	Message                string     `json:"message"`
+}
+
+// ResumeOnboarding returns a new Stripe onboarding link for the parent's Connect account.
+// Used when the parent abandoned onboarding or their previous link expired
+// (Stripe sends them to the refresh URL, which should call this endpoint).
+func ResumeOnboarding(db *pgxpool.Pool, sc *client.API, cfg payments.ConnectConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResumeOnboardingRequest
+
+		// Bind JSON request body (may be empty, the caller comes from the token)
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		// Work out the caller from the access token
+		parentID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+		if !requireSameParent(c, parentID, req.ParentID) {
+			return
+		}
+
+		var accountID int
+		var stripeAccountID string
+		err := db.QueryRow(context.Background(),
+			`SELECT account_id, stripe_connect_account_id FROM payment_accounts WHERE parent_id = $1`,
+			parentID,
+		).Scan(&accountID, &stripeAccountID)
+		if err != nil {
+			if err.Error() == "no rows in result set" {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "No payment account set up. Create one with /api/payments/create-account",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		// Check with Stripe first - the parent may have finished since we last heard
+		account, err := payments.GetConnectAccount(sc, stripeAccountID)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":   "Failed to look up Stripe account",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := saveAccountStatus(db, account); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update onboarding status",
+			})
+			return
+		}
+
+		response := ResumeOnboardingResponse{
+			AccountID:              accountID,
+			StripeConnectAccountID: stripeAccountID,
+			OnboardingComplete:     payments.OnboardingComplete(account),
+		}
+
+		if response.OnboardingComplete {
+			response.Message = "Onboarding is already complete"
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		link, err := payments.OnboardingLink(sc, cfg, stripeAccountID)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":   "Failed to create onboarding link",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		expiresAt := time.Unix(link.ExpiresAt, 0)
+		response.OnboardingURL = link.URL
+		response.OnboardingURLExpiresAt = &expiresAt
+		response.Message = "Continue onboarding with Stripe to start receiving payments."
+
+		c.JSON(http.StatusOK, response)
+	}
+}
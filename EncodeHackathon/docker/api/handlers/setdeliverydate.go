@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"aletterahead-api/capsules"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SetDeliveryDateRequest chooses when a child's time capsule is emailed to them
+type SetDeliveryDateRequest struct {
+	ChildID      int     `json:"child_id" binding:"required"`
+	DeliveryDate *string `json:"delivery_date"` // Format: "2035-07-15", or null for their 18th birthday
+}
+
+// SetDeliveryDateResponse represents the response after choosing a delivery date
+type SetDeliveryDateResponse struct {
+	ChildID      int       `json:"child_id"`
+	DeliveryDate time.Time `json:"delivery_date"` // When the capsule will be sent
+	Message      string    `json:"message"`
+}
+
+// SetDeliveryDate lets a parent move the date their child's time capsule is
+// delivered, until it has been
+func SetDeliveryDate(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetDeliveryDateRequest
+
+		// Bind JSON request body
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		var deliveryDate *time.Time
+		if req.DeliveryDate != nil {
+			date, ok := parseDeliveryDate(c, *req.DeliveryDate)
+			if !ok {
+				return
+			}
+			deliveryDate = &date
+		}
+
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
+		var parentID int
+		err := db.QueryRow(context.Background(), `SELECT parent_id FROM children WHERE child_id = $1`, req.ChildID).Scan(&parentID)
+		if err != nil {
+			if err.Error() == "no rows in result set" {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Child not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		// Parents can only schedule their own children's capsules
+		if parentID != callerID {
+			forbidResource(c, "child")
+			return
+		}
+
+		delivered, err := capsules.Delivered(context.Background(), db, req.ChildID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+		if delivered {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "This child's time capsule has already been delivered",
+			})
+			return
+		}
+
+		_, err = db.Exec(context.Background(),
+			`UPDATE children SET delivery_date = $2 WHERE child_id = $1`,
+			req.ChildID, deliveryDate,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update delivery date",
+			})
+			return
+		}
+
+		deliverOn, err := capsules.DeliverOn(context.Background(), db, req.ChildID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, SetDeliveryDateResponse{
+			ChildID:      req.ChildID,
+			DeliveryDate: deliverOn,
+			Message:      "Delivery date updated successfully",
+		})
+	}
+}
+
+// parseDeliveryDate reads a time capsule delivery date, which can't be in the
+// past. On failure it writes the error response and returns false.
+func parseDeliveryDate(c *gin.Context, value string) (time.Time, bool) {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid delivery_date format. Use YYYY-MM-DD (e.g., 2035-07-15)",
+		})
+		return time.Time{}, false
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if date.Before(today) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Delivery date cannot be in the past",
+		})
+		return time.Time{}, false
+	}
+	return date, true
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"aletterahead-api/media"
+	"aletterahead-api/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamObject sends a stored file to the browser. It is the one place stored
+// files are streamed from, and handles:
+//   - Content-Type from the file's own bytes, falling back to what storage says
+//   - Range requests: 206 for one range, multipart/byteranges for several,
+//     416 for ranges past the end, and If-Range
+//   - a strong ETag and Last-Modified, with If-None-Match / If-Modified-Since
+//     answered 304 and If-Match / If-Unmodified-Since answered 412
+//   - HEAD, which gets the headers without the body
+//
+// what names the file in error messages ("Video", ...).
+func streamObject(c *gin.Context, file io.ReadSeeker, obj storage.Object, what string) {
+	contentType, err := sniffContentType(file)
+	if err != nil {
+		objectUnavailable(c, what, err)
+		return
+	}
+	if contentType == "" {
+		contentType = obj.ContentType
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Accept-Ranges", "bytes") // Enable video seeking
+	if obj.ETag != "" {
+		c.Header("ETag", obj.ETag)
+	}
+	// Private messages - never kept by shared caches, and the browser checks the
+	// ETag (over a link that must still be valid) before replaying its copy
+	c.Header("Cache-Control", "private, no-cache")
+
+	// ServeContent does the ranges and conditional requests, using the ETag set above
+	http.ServeContent(c.Writer, c.Request, "", obj.ModTime, file)
+}
+
+// sniffContentType detects the type of file from its first bytes and rewinds it
+func sniffContentType(file io.ReadSeeker) (string, error) {
+	contentType, err := media.DetectContentType(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return contentType, nil
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"aletterahead-api/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamModTime is the fixture's modification time, whole seconds as in Last-Modified
+var streamModTime = time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+// streamFixture is a stored MP4 served by streamObject, like GetVideo does
+type streamFixture struct {
+	router  *gin.Engine
+	content []byte
+	obj     storage.Object
+}
+
+func newStreamFixture(t *testing.T) streamFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	// An MP4 header, so the type is sniffed from the bytes, then known filler
+	var content bytes.Buffer
+	content.Write([]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"))
+	for i := 0; content.Len() < 1000; i++ {
+		fmt.Fprintf(&content, "%04d", i)
+	}
+
+	dir := t.TempDir()
+	store, err := storage.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "videos/clip.mp4", bytes.NewReader(content.Bytes()), int64(content.Len()), "video/mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "videos", "clip.mp4"), streamModTime, streamModTime); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := store.Stat(ctx, "videos/clip.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(c *gin.Context) {
+		file, obj, err := store.Open(c.Request.Context(), "videos/clip.mp4")
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		streamObject(c, file, obj, "Video")
+	}
+	router := gin.New()
+	router.GET("/videos/clip.mp4", serve)
+	router.HEAD("/videos/clip.mp4", serve)
+
+	return streamFixture{router: router, content: content.Bytes(), obj: obj}
+}
+
+// do sends a request for the fixture with the given headers
+func (f streamFixture) do(method string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/videos/clip.mp4", nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestStreamObjectWholeFile(t *testing.T) {
+	f := newStreamFixture(t)
+	w := f.do(http.MethodGet, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), f.content) {
+		t.Error("body is not the stored file")
+	}
+	checkHeader(t, w, "Content-Type", "video/mp4")
+	checkHeader(t, w, "Accept-Ranges", "bytes")
+	checkHeader(t, w, "ETag", f.obj.ETag)
+	checkHeader(t, w, "Last-Modified", streamModTime.Format(http.TimeFormat))
+	checkHeader(t, w, "Cache-Control", "private, no-cache")
+	checkHeader(t, w, "Content-Length", strconv.Itoa(len(f.content)))
+}
+
+func TestStreamObjectSingleRange(t *testing.T) {
+	f := newStreamFixture(t)
+	w := f.do(http.MethodGet, map[string]string{"Range": "bytes=100-199"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", w.Code)
+	}
+	checkHeader(t, w, "Content-Range", fmt.Sprintf("bytes 100-199/%d", len(f.content)))
+	checkHeader(t, w, "Content-Length", "100")
+	checkHeader(t, w, "Content-Type", "video/mp4")
+	if !bytes.Equal(w.Body.Bytes(), f.content[100:200]) {
+		t.Errorf("body = %q, want bytes 100-199", w.Body.String())
+	}
+
+	// An open-ended range runs to the end of the file
+	w = f.do(http.MethodGet, map[string]string{"Range": "bytes=990-"})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("open-ended range: status = %d, want 206", w.Code)
+	}
+	checkHeader(t, w, "Content-Range", fmt.Sprintf("bytes 990-%d/%d", len(f.content)-1, len(f.content)))
+	if !bytes.Equal(w.Body.Bytes(), f.content[990:]) {
+		t.Errorf("open-ended range: body = %q, want bytes 990-", w.Body.String())
+	}
+}
+
+func TestStreamObjectMultipleRanges(t *testing.T) {
+	f := newStreamFixture(t)
+	w := f.do(http.MethodGet, map[string]string{"Range": "bytes=0-9,500-509"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", w.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Content-Type = %q, want multipart/byteranges", w.Header().Get("Content-Type"))
+	}
+
+	want := []struct {
+		contentRange string
+		body         []byte
+	}{
+		{fmt.Sprintf("bytes 0-9/%d", len(f.content)), f.content[0:10]},
+		{fmt.Sprintf("bytes 500-509/%d", len(f.content)), f.content[500:510]},
+	}
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	for i, part := range want {
+		p, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if got := p.Header.Get("Content-Range"); got != part.contentRange {
+			t.Errorf("part %d: Content-Range = %q, want %q", i, got, part.contentRange)
+		}
+		if got := p.Header.Get("Content-Type"); got != "video/mp4" {
+			t.Errorf("part %d: Content-Type = %q, want video/mp4", i, got)
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if !bytes.Equal(body, part.body) {
+			t.Errorf("part %d: body = %q, want %q", i, body, part.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected 2 parts, got more (err %v)", err)
+	}
+}
+
+func TestStreamObjectUnsatisfiableRange(t *testing.T) {
+	f := newStreamFixture(t)
+	w := f.do(http.MethodGet, map[string]string{"Range": fmt.Sprintf("bytes=%d-", len(f.content)+10)})
+
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("status = %d, want 416", w.Code)
+	}
+	checkHeader(t, w, "Content-Range", fmt.Sprintf("bytes */%d", len(f.content)))
+}
+
+func TestStreamObjectIfNoneMatch(t *testing.T) {
+	f := newStreamFixture(t)
+
+	w := f.do(http.MethodGet, map[string]string{"If-None-Match": f.obj.ETag})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("matching ETag: status = %d, want 304", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 has a body of %d bytes", w.Body.Len())
+	}
+	checkHeader(t, w, "ETag", f.obj.ETag)
+
+	w = f.do(http.MethodGet, map[string]string{"If-None-Match": `"something-else"`})
+	if w.Code != http.StatusOK {
+		t.Errorf("other ETag: status = %d, want 200", w.Code)
+	}
+}
+
+func TestStreamObjectIfRange(t *testing.T) {
+	f := newStreamFixture(t)
+
+	// A stale validator means the client's partial copy is out of date: send it all
+	w := f.do(http.MethodGet, map[string]string{"Range": "bytes=0-9", "If-Range": `"stale"`})
+	if w.Code != http.StatusOK {
+		t.Fatalf("stale If-Range: status = %d, want 200", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), f.content) {
+		t.Error("stale If-Range: body is not the whole file")
+	}
+	if got := w.Header().Get("Content-Range"); got != "" {
+		t.Errorf("stale If-Range: Content-Range = %q, want none", got)
+	}
+
+	w = f.do(http.MethodGet, map[string]string{"Range": "bytes=0-9", "If-Range": f.obj.ETag})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("current If-Range: status = %d, want 206", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), f.content[:10]) {
+		t.Errorf("current If-Range: body = %q, want bytes 0-9", w.Body.String())
+	}
+}
+
+func TestStreamObjectLastModified(t *testing.T) {
+	f := newStreamFixture(t)
+
+	w := f.do(http.MethodGet, map[string]string{"If-Modified-Since": streamModTime.Format(http.TimeFormat)})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("not modified since: status = %d, want 304", w.Code)
+	}
+
+	w = f.do(http.MethodGet, map[string]string{"If-Modified-Since": streamModTime.Add(-time.Hour).Format(http.TimeFormat)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("modified since: status = %d, want 200", w.Code)
+	}
+	checkHeader(t, w, "Last-Modified", streamModTime.Format(http.TimeFormat))
+
+	w = f.do(http.MethodGet, map[string]string{"If-Unmodified-Since": streamModTime.Add(-time.Hour).Format(http.TimeFormat)})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("unmodified since: status = %d, want 412", w.Code)
+	}
+}
+
+func TestStreamObjectIfMatch(t *testing.T) {
+	f := newStreamFixture(t)
+
+	w := f.do(http.MethodGet, map[string]string{"If-Match": `"something-else"`})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("other ETag: status = %d, want 412", w.Code)
+	}
+
+	w = f.do(http.MethodGet, map[string]string{"If-Match": f.obj.ETag})
+	if w.Code != http.StatusOK {
+		t.Errorf("matching ETag: status = %d, want 200", w.Code)
+	}
+}
+
+func TestStreamObjectHead(t *testing.T) {
+	f := newStreamFixture(t)
+
+	w := f.do(http.MethodHead, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD has a body of %d bytes", w.Body.Len())
+	}
+	checkHeader(t, w, "Content-Length", strconv.Itoa(len(f.content)))
+	checkHeader(t, w, "Content-Type", "video/mp4")
+	checkHeader(t, w, "ETag", f.obj.ETag)
+	checkHeader(t, w, "Accept-Ranges", "bytes")
+
+	w = f.do(http.MethodHead, map[string]string{"Range": "bytes=100-199"})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("ranged HEAD: status = %d, want 206", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("ranged HEAD has a body of %d bytes", w.Body.Len())
+	}
+	checkHeader(t, w, "Content-Range", fmt.Sprintf("bytes 100-199/%d", len(f.content)))
+}
+
+func checkHeader(t *testing.T, w *httptest.ResponseRecorder, name, want string) {
+	t.Helper()
+	if got := w.Header().Get(name); got != want {
+		t.Errorf("%s = %q, want %q", name, got, want)
+	}
+}
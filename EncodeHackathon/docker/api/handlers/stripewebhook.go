@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"aletterahead-api/lifecycle"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// maxWebhookBodyBytes limits the size of a Stripe event payload
+const maxWebhookBodyBytes = int64(65536)
+
+// StripeWebhook receives Stripe events, verifies their signature and applies them.
+// Every verified event is stored in stripe_events (with its raw payload) before it is
+// processed, so events that failed can be replayed later with `main webhook-replay`.
+func StripeWebhook(db *pgxpool.Pool, webhookSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if webhookSecret == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Webhook secret not configured",
+			})
+			return
+		}
+
+		// Read the raw body - the signature is computed over the exact bytes Stripe sent
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodyBytes)
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read request body",
+			})
+			return
+		}
+
+		// Verify the Stripe-Signature header
+		event, err := webhook.ConstructEventWithOptions(payload, c.GetHeader("Stripe-Signature"), webhookSecret,
+			webhook.ConstructEventOptions{IgnoreAPIVersionMismatch: true},
+		)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid webhook signature",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		// Store the event for replay (Stripe may deliver the same event more than once)
+		storeQuery := `
+			INSERT INTO stripe_events (event_id, event_type, payload)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (event_id) DO NOTHING
+		`
+
+		_, err = db.Exec(context.Background(), storeQuery, event.ID, string(event.Type), payload)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to store event",
+			})
+			return
+		}
+
+		// Skip events that were already applied successfully
+		var alreadyProcessed bool
+		err = db.QueryRow(context.Background(),
+			`SELECT processed_at IS NOT NULL FROM stripe_events WHERE event_id = $1`,
+			event.ID,
+		).Scan(&alreadyProcessed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		if alreadyProcessed {
+			c.JSON(http.StatusOK, gin.H{
+				"received":  true,
+				"duplicate": true,
+			})
+			return
+		}
+
+		// Apply the event to donations / payment_accounts
+		if err := ProcessStripeEvent(db, event); err != nil {
+			log.Printf("Failed to process Stripe event %s (%s): %v", event.ID, event.Type, err)
+			// Non-2xx makes Stripe retry the delivery
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to process event",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"received": true,
+		})
+	}
+}
+
+// ProcessStripeEvent applies a stored event and records the outcome in stripe_events:
+// processed_at when it was applied, processing_error when it wasn't
+func ProcessStripeEvent(db *pgxpool.Pool, event stripe.Event) error {
+	if err := applyStripeEvent(db, event); err != nil {
+		db.Exec(context.Background(),
+			`UPDATE stripe_events SET processing_error = $1 WHERE event_id = $2`,
+			err.Error(), event.ID,
+		)
+		return err
+	}
+
+	_, err := db.Exec(context.Background(),
+		`UPDATE stripe_events SET processed_at = NOW(), processing_error = NULL WHERE event_id = $1`,
+		event.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark event processed: %w", err)
+	}
+	return nil
+}
+
+// applyStripeEvent updates the database to reflect what Stripe did.
+// Event types we don't care about are accepted and ignored.
+func applyStripeEvent(db *pgxpool.Pool, event stripe.Event) error {
+	actor := lifecycle.StripeActor(event.ID)
+	reason := string(event.Type)
+
+	switch event.Type {
+	case stripe.EventTypePaymentIntentSucceeded:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return fmt.Errorf("failed to parse payment intent: %w", err)
+		}
+		if err := setDonationPaymentStatus(db, pi.ID, "succeeded"); err != nil {
+			return err
+		}
+		return lifecycle.TransitionPayment(context.Background(), db, pi.ID, lifecycle.Captured, actor, reason)
+
+	case stripe.EventTypePaymentIntentAmountCapturableUpdated:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return fmt.Errorf("failed to parse payment intent: %w", err)
+		}
+
+		// The donor's card is authorised; the hold expires after about 7 days
+		updateQuery := `
+			UPDATE donations
+			SET payment_status = 'authorized', authorized_at = COALESCE(authorized_at, NOW())
+			WHERE payment_intent_id = $1
+			AND payment_status IN ('pending', 'failed')
+		`
+
+		if _, err := db.Exec(context.Background(), updateQuery, pi.ID); err != nil {
+			return err
+		}
+		// Already-approved donations stay approved; the sweeper captures them
+		return lifecycle.TransitionPayment(context.Background(), db, pi.ID, lifecycle.PendingReview, actor, reason)
+
+	case stripe.EventTypePaymentIntentCanceled:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return fmt.Errorf("failed to parse payment intent: %w", err)
+		}
+
+		// Cancelling an authorisation we hold is recorded by ApproveDonation; this
+		// catches authorisations that Stripe let expire
+		updateQuery := `
+			UPDATE donations
+			SET payment_status = 'canceled'
+			WHERE payment_intent_id = $1
+			AND payment_status IN ('pending', 'authorized', 'failed')
+		`
+
+		if _, err := db.Exec(context.Background(), updateQuery, pi.ID); err != nil {
+			return err
+		}
+		return lifecycle.TransitionPayment(context.Background(), db, pi.ID, lifecycle.Expired, actor, reason)
+
+	case stripe.EventTypePaymentIntentPaymentFailed:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return fmt.Errorf("failed to parse payment intent: %w", err)
+		}
+		if err := setDonationPaymentStatus(db, pi.ID, "failed"); err != nil {
+			return err
+		}
+		return lifecycle.TransitionPayment(context.Background(), db, pi.ID, lifecycle.PaymentFailed, actor, reason)
+
+	case stripe.EventTypeChargeRefunded:
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return fmt.Errorf("failed to parse charge: %w", err)
+		}
+		// Releasing an uncaptured hold refunds the charge too, but no money was taken:
+		// whoever released it has already recorded that
+		if charge.PaymentIntent == nil || !charge.Captured || charge.AmountRefunded <= 0 {
+			return nil
+		}
+
+		// Refunds can also be issued from the Stripe dashboard, so record the amount here too.
+		// A partial refund leaves the rest of the donation with the child, and only that
+		// counts towards their ISA allowance.
+		paymentStatus := "refunded"
+		if charge.AmountRefunded < charge.Amount {
+			paymentStatus = "partially_refunded"
+		}
+
+		updateQuery := `
+			UPDATE donations
+			SET payment_status = $1,
+				refunded_amount_pence = $2,
+				refunded_at = COALESCE(refunded_at, NOW()),
+				updated_at = NOW()
+			WHERE payment_intent_id = $3
+		`
+
+		if _, err := db.Exec(context.Background(), updateQuery, paymentStatus, charge.AmountRefunded, charge.PaymentIntent.ID); err != nil {
+			return err
+		}
+		if paymentStatus == "partially_refunded" {
+			return nil
+		}
+		return lifecycle.TransitionPayment(context.Background(), db, charge.PaymentIntent.ID, lifecycle.Refunded, actor, reason)
+
+	case stripe.EventTypeChargeDisputeCreated, stripe.EventTypeChargeDisputeClosed:
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return fmt.Errorf("failed to parse dispute: %w", err)
+		}
+		if dispute.PaymentIntent == nil {
+			return nil
+		}
+
+		// A lost dispute means the donor's bank has taken the money back
+		to := lifecycle.Disputed
+		if event.Type == stripe.EventTypeChargeDisputeClosed {
+			to = lifecycle.Captured
+			if dispute.Status == stripe.DisputeStatusLost {
+				to = lifecycle.Refunded
+			}
+		}
+		reason = fmt.Sprintf("%s (%s)", event.Type, dispute.Status)
+		return lifecycle.TransitionPayment(context.Background(), db, dispute.PaymentIntent.ID, to, actor, reason)
+
+	case stripe.EventTypeAccountUpdated:
+		var account stripe.Account
+		if err := json.Unmarshal(event.Data.Raw, &account); err != nil {
+			return fmt.Errorf("failed to parse account: %w", err)
+		}
+
+		// An account can only take donations once Stripe lets it charge and pay out
+		return saveAccountStatus(db, &account)
+	}
+
+	return nil
+}
+
+// setDonationPaymentStatus records a payment outcome on the donation for a PaymentIntent.
+// Refunds (partial or full) and cancellations come last, so late success/failure events never overwrite them.
+func setDonationPaymentStatus(db *pgxpool.Pool, paymentIntentID string, status string) error {
+	updateQuery := `
+		UPDATE donations
+		SET payment_status = $1
+		WHERE payment_intent_id = $2
+		AND payment_status NOT IN ('refunded', 'partially_refunded', 'canceled')
+	`
+
+	_, err := db.Exec(context.Background(), updateQuery, status, paymentIntentID)
+	return err
+}
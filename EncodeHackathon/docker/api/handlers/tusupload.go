@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"aletterahead-api/media"
+	"aletterahead-api/scan"
+	"aletterahead-api/transcode"
+	"aletterahead-api/uploads"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tus protocol details (https://tus.io/protocols/resumable-upload)
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,creation-with-upload,termination,expiration"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// TusConfig controls resumable video uploads
+type TusConfig struct {
+	// MaxSize is the largest Upload-Length accepted
+	MaxSize int64
+	// Expiry is how long an unfinished upload is kept after its last PATCH
+	Expiry time.Duration
+}
+
+// TusUploadStatus represents an upload's progress, for clients that prefer JSON to HEAD
+type TusUploadStatus struct {
+	UploadID     string    `json:"upload_id"`
+	UploadOffset int64     `json:"upload_offset"`
+	UploadLength int64     `json:"upload_length"`
+	Complete     bool      `json:"complete"`
+	VideoID      *int      `json:"video_id"` // Send as video_id when creating the donation
+	VideoURL     *string   `json:"video_url"`
+	VideoStatus  *string   `json:"video_status"` // Transcoding status once complete
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// TusOptions describes the server's tus support
+func TusOptions(tus TusConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		c.Header("Tus-Version", tusVersion)
+		c.Header("Tus-Extension", tusExtensions)
+		c.Header("Tus-Max-Size", strconv.FormatInt(tus.MaxSize, 10))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// CreateTusUpload starts a resumable video upload. The Upload-Metadata header must
+// include the event_id the video is for, and may include its filename; the body
+// may carry the first chunk.
+func CreateTusUpload(db *pgxpool.Pool, videos VideoConfig, tus TusConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusResumable(c) {
+			return
+		}
+
+		if c.GetHeader("Upload-Defer-Length") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Upload-Defer-Length is not supported, send Upload-Length",
+			})
+			return
+		}
+
+		length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+		if err != nil || length < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Upload-Length header is required",
+			})
+			return
+		}
+		if length > tus.MaxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":    "File too large",
+				"max_size": tus.MaxSize,
+			})
+			return
+		}
+
+		// The video's type is worked out from its content once it has all arrived
+		rawMetadata := c.GetHeader("Upload-Metadata")
+		metadata, err := parseTusMetadata(rawMetadata)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid Upload-Metadata header",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		// Videos belong to the event they are uploaded for
+		eventID, err := strconv.Atoi(metadata["event_id"])
+		if err != nil || eventID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Upload-Metadata must include the event_id",
+			})
+			return
+		}
+		if !requireVideoEvent(c, db, eventID) {
+			return
+		}
+
+		filename := "video"
+		if metadata["filename"] != "" {
+			filename = cleanOriginalFilename(metadata["filename"])
+		}
+
+		var storedMetadata *string
+		if rawMetadata != "" {
+			storedMetadata = &rawMetadata
+		}
+
+		upload, err := uploads.Create(c.Request.Context(), db, eventID, length, filename, storedMetadata, time.Now().Add(tus.Expiry))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to create upload",
+			})
+			return
+		}
+
+		c.Header("Location", videos.baseURL()+"/api/uploads/tus/"+upload.ID)
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+
+		// creation-with-upload: the first chunk came with the request
+		if c.ContentType() == tusContentType && c.Request.ContentLength != 0 {
+			upload, err = uploads.Append(c.Request.Context(), db, videos.Store, upload, c.Request.Body, time.Now().Add(tus.Expiry))
+			if err == nil {
+				upload, err = videos.finishUpload(c.Request.Context(), db, upload)
+				if err != nil && rejectedUpload(c, err) {
+					return
+				}
+			}
+			if err != nil {
+				log.Printf("Failed to store first chunk of upload %s: %v", upload.ID, err)
+			}
+			c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+			setTusVideo(c, videos, upload)
+		}
+
+		c.Status(http.StatusCreated)
+	}
+}
+
+// HeadTusUpload tells the client how much of an upload has been received, so it can resume
+func HeadTusUpload(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusResumable(c) {
+			return
+		}
+
+		upload, ok := loadTusUpload(c, db)
+		if !ok {
+			return
+		}
+
+		// Finish an upload whose last PATCH stored every byte but failed to assemble
+		upload, err := videos.finishUpload(c.Request.Context(), db, upload)
+		if err != nil {
+			if rejectedUpload(c, err) {
+				return
+			}
+			log.Printf("Failed to finish upload %s: %v", upload.ID, err)
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+		if upload.Metadata != nil {
+			c.Header("Upload-Metadata", *upload.Metadata)
+		}
+		if !upload.Complete() {
+			c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+		}
+		setTusVideo(c, videos, upload)
+		c.Status(http.StatusOK)
+	}
+}
+
+// GetTusUpload returns an upload's progress (and its video_id once complete) as JSON
+func GetTusUpload(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		upload, ok := loadTusUpload(c, db)
+		if !ok {
+			return
+		}
+
+		response := TusUploadStatus{
+			UploadID:     upload.ID,
+			UploadOffset: upload.Offset,
+			UploadLength: upload.Length,
+			Complete:     upload.Complete(),
+			VideoID:      upload.VideoID,
+			ExpiresAt:    upload.ExpiresAt,
+		}
+		if upload.VideoFilename != nil {
+			videoURL := videos.signedVideoURL(*upload.VideoFilename)
+			response.VideoURL = &videoURL
+
+			if job, err := transcode.Get(c.Request.Context(), db, *upload.VideoFilename); err == nil {
+				status := string(job.Status)
+				response.VideoStatus = &status
+			}
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// PatchTusUpload appends the request body to an upload at Upload-Offset. The last
+// chunk completes the upload: the video is checked like UploadVideo's (and the
+// upload deleted if it is rejected), and the response's Video-Id header carries
+// the video_id to send with the donation.
+func PatchTusUpload(db *pgxpool.Pool, videos VideoConfig, tus TusConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusResumable(c) {
+			return
+		}
+
+		if c.ContentType() != tusContentType {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "Content-Type must be " + tusContentType,
+			})
+			return
+		}
+
+		offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Upload-Offset header is required",
+			})
+			return
+		}
+
+		upload, ok := loadTusUpload(c, db)
+		if !ok {
+			return
+		}
+
+		// Chunks must be sent in order, starting where the server says it is
+		if offset != upload.Offset {
+			c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+			c.JSON(http.StatusConflict, gin.H{
+				"error":         "Upload-Offset does not match the upload",
+				"upload_offset": upload.Offset,
+			})
+			return
+		}
+		if c.Request.ContentLength > upload.Length-upload.Offset {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Chunk goes past the end of the upload",
+			})
+			return
+		}
+
+		if !upload.Complete() {
+			upload, err = uploads.Append(c.Request.Context(), db, videos.Store, upload, c.Request.Body, time.Now().Add(tus.Expiry))
+			if errors.Is(err, uploads.ErrOffsetConflict) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "Upload was changed by another request, check the offset and try again",
+				})
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to append to upload %s: %v", upload.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to save upload",
+				})
+				return
+			}
+
+			upload, err = videos.finishUpload(c.Request.Context(), db, upload)
+			if err != nil {
+				if rejectedUpload(c, err) {
+					return
+				}
+				log.Printf("Failed to finish upload %s: %v", upload.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to save video file",
+				})
+				return
+			}
+		}
+
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		if !upload.Complete() {
+			c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+		}
+		setTusVideo(c, videos, upload)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DeleteTusUpload abandons an upload and deletes the bytes received
+func DeleteTusUpload(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusResumable(c) {
+			return
+		}
+
+		upload, ok := loadTusUpload(c, db)
+		if !ok {
+			return
+		}
+
+		if err := uploads.Terminate(c.Request.Context(), db, videos.Store, upload); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete upload",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// finishUpload turns a fully received upload into a video, checked, stored and
+// queued for transcoding like UploadVideo's. An upload that turns out not to be
+// an acceptable video is deleted (an infected one having been quarantined
+// first), and the rejection returned.
+func (v VideoConfig) finishUpload(ctx context.Context, db *pgxpool.Pool, upload uploads.Upload) (uploads.Upload, error) {
+	if upload.Complete() || upload.Offset < upload.Length {
+		return upload, nil
+	}
+
+	// ffprobe needs the video as a file on disk
+	tmp, err := os.CreateTemp("", "video-upload-*")
+	if err != nil {
+		return upload, err
+	}
+	defer os.Remove(tmp.Name())
+
+	content := uploads.Content(ctx, v.Store, upload)
+	_, err = io.Copy(tmp, content)
+	content.Close()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return upload, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	video, _, err := v.ingestVideo(ctx, db, upload.EventID, tmp.Name(), upload.OriginalFilename)
+	if err != nil {
+		var unsupported *media.UnsupportedError
+		var limit *media.LimitError
+		var infected *scan.InfectedError
+		if errors.As(err, &unsupported) || errors.As(err, &limit) || errors.As(err, &infected) {
+			if termErr := uploads.Terminate(ctx, db, v.Store, upload); termErr != nil {
+				log.Printf("Failed to delete rejected upload %s: %v", upload.ID, termErr)
+			}
+		}
+		return upload, err
+	}
+
+	return uploads.Complete(ctx, db, v.Store, upload, video.ID, video.Filename)
+}
+
+// tusResumable checks the client speaks our tus version; every response carries ours
+func tusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Unsupported tus version, use " + tusVersion,
+		})
+		return false
+	}
+	return true
+}
+
+// loadTusUpload loads the upload named in the URL, responding if it can't be used
+func loadTusUpload(c *gin.Context, db *pgxpool.Pool) (uploads.Upload, bool) {
+	id := c.Param("id")
+	if !uploads.ValidID(id) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return uploads.Upload{}, false
+	}
+
+	upload, err := uploads.Get(c.Request.Context(), db, id)
+	if errors.Is(err, uploads.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return uploads.Upload{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database query failed",
+		})
+		return uploads.Upload{}, false
+	}
+
+	if upload.Expired(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Upload has expired, please start again",
+		})
+		return uploads.Upload{}, false
+	}
+
+	return upload, true
+}
+
+// setTusVideo adds the finished video's ID and address to the response
+func setTusVideo(c *gin.Context, videos VideoConfig, upload uploads.Upload) {
+	if upload.VideoID != nil {
+		c.Header("Video-Id", strconv.Itoa(*upload.VideoID))
+	}
+	if upload.VideoFilename != nil {
+		c.Header("Video-Url", videos.signedVideoURL(*upload.VideoFilename))
+	}
+}
+
+// parseTusMetadata decodes an Upload-Metadata header: comma-separated
+// "key base64(value)" pairs, where the value may be left out
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, errors.New("expected \"key base64value\" pairs")
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, errors.New("value for " + parts[0] + " is not base64")
+			}
+			value = string(decoded)
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata, nil
+}
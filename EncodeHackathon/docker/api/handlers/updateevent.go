@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UpdateEventRequest represents the request structure for updating an event.
+// Only the fields that are sent are changed; an empty event_message or
+// photo_address removes it.
+type UpdateEventRequest struct {
+	EventID                     int     `json:"event_id" binding:"required"`
+	EventName                   *string `json:"event_name"`
+	ExpiresAt                   *string `json:"expires_at"` // Format: "2025-07-15"
+	EventMessage                *string `json:"event_message"`
+	VideosEnabled               *bool   `json:"videos_enabled"`
+	PhotoAddress                *string `json:"photo_address"`
+	ExpiringAuthorizationPolicy *string `json:"expiring_authorization_policy"`
+}
+
+// UpdateEventResponse represents the response after updating an event
+type UpdateEventResponse struct {
+	Event   EventSummary `json:"event"`
+	Message string       `json:"message"`
+}
+
+// UpdateEvent changes an event's details. The same rules as CreateEvent apply:
+// a new expiry date must be in the future and no more than 2 years away, and
+// the child can't have two active events with the same name. Cancelled events
+// can't be changed.
+func UpdateEvent(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateEventRequest
+
+		// Bind JSON request body
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if req.EventName == nil && req.ExpiresAt == nil && req.EventMessage == nil &&
+			req.VideosEnabled == nil && req.PhotoAddress == nil && req.ExpiringAuthorizationPolicy == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "No changes given",
+			})
+			return
+		}
+
+		if req.EventName != nil && strings.TrimSpace(*req.EventName) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "event_name cannot be empty",
+			})
+			return
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresAt != nil {
+			parsed, ok := parseEventExpiry(c, *req.ExpiresAt)
+			if !ok {
+				return
+			}
+			expiresAt = &parsed
+		}
+
+		if req.ExpiringAuthorizationPolicy != nil && !validAuthorizationPolicy(c, *req.ExpiringAuthorizationPolicy) {
+			return
+		}
+
+		// Work out the caller from the access token
+		callerID, ok := requireParent(c, db)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin(context.Background())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+		defer tx.Rollback(context.Background())
+
+		// Verify the event exists and belongs to the caller, and hold it until it is saved
+		eventQuery := `
+			SELECT 
+				e.child_id,
+				e.event_name,
+				e.expires_at,
+				e.cancelled_at,
+				c.parent_id
+			FROM events e
+			JOIN children c ON e.child_id = c.child_id
+			WHERE e.event_id = $1
+			FOR UPDATE OF e
+		`
+
+		var childID int
+		var eventName string
+		var currentExpiry time.Time
+		var cancelledAt *time.Time
+		var parentID int
+
+		err = tx.QueryRow(context.Background(), eventQuery, req.EventID).Scan(
+			&childID,
+			&eventName,
+			&currentExpiry,
+			&cancelledAt,
+			&parentID,
+		)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Event not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database query failed",
+			})
+			return
+		}
+
+		// Parents can only change their own children's events
+		if parentID != callerID {
+			forbidResource(c, "event")
+			return
+		}
+
+		if cancelledAt != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":        "This event has been cancelled and can't be changed",
+				"cancelled_at": cancelledAt,
+			})
+			return
+		}
+
+		// An uploaded photo must be one of the caller's
+		if req.PhotoAddress != nil && *req.PhotoAddress != "" && !requireOwnPhoto(c, db, callerID, *req.PhotoAddress) {
+			return
+		}
+
+		// Renaming, or reopening an expired event, mustn't leave the child with
+		// two active events of the same name
+		if req.EventName != nil {
+			eventName = *req.EventName
+		}
+		if expiresAt != nil {
+			currentExpiry = *expiresAt
+		}
+		if (req.EventName != nil || req.ExpiresAt != nil) && currentExpiry.After(time.Now()) {
+			if !requireUniqueEventName(c, tx, childID, eventName, req.EventID) {
+				return
+			}
+		}
+
+		updateQuery := `
+			UPDATE events e
+			SET event_name = COALESCE($2, e.event_name),
+				expires_at = COALESCE($3, e.expires_at),
+				event_message = CASE WHEN $4::TEXT IS NULL THEN e.event_message ELSE NULLIF($4, '') END,
+				videos_enabled = COALESCE($5, e.videos_enabled),
+				photo_address = CASE WHEN $6::TEXT IS NULL THEN e.photo_address ELSE NULLIF($6, '') END,
+				expiring_authorization_policy = COALESCE($7, e.expiring_authorization_policy)
+			FROM children c
+			WHERE e.event_id = $1
+			AND c.child_id = e.child_id
+			AND e.cancelled_at IS NULL
+			RETURNING ` + eventSummaryColumns
+
+		event, err := scanEventSummary(tx.QueryRow(context.Background(), updateQuery,
+			req.EventID,
+			req.EventName,
+			expiresAt,
+			req.EventMessage,
+			req.VideosEnabled,
+			req.PhotoAddress,
+			req.ExpiringAuthorizationPolicy,
+		), time.Now())
+		if err == nil {
+			err = tx.Commit(context.Background())
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update event",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, UpdateEventResponse{
+			Event:   event,
+			Message: "Event updated successfully",
+		})
+	}
+}
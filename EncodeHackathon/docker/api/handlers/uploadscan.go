@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"aletterahead-api/media"
+	"aletterahead-api/scan"
+	"aletterahead-api/storage"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// scanUpload streams the uploaded file at path through the virus scanner
+// before anything else reads it, returning the verdict and the file's hex
+// SHA-256 checksum
+func scanUpload(ctx context.Context, scanner scan.Clamd, path string) (scan.Result, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return scan.Result{}, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	scanned, err := scanner.Scan(ctx, io.TeeReader(f, hash))
+	if err != nil {
+		return scan.Result{}, "", err
+	}
+	// Nothing was read if scanning is off
+	if _, err := io.Copy(hash, f); err != nil {
+		return scan.Result{}, "", err
+	}
+	return scanned, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// quarantineUpload keeps an infected upload under quarantine/ rather than
+// deleting it, so it can be checked by hand. It is stored as key(filename) for
+// a random filename, and that filename returned along with the file's detected
+// type and size. Nothing else has read the file.
+func quarantineUpload(ctx context.Context, store storage.Storage, path string, key func(string) string) (string, media.Info, error) {
+	detected, err := mimetype.DetectFile(path)
+	if err != nil {
+		return "", media.Info{}, err
+	}
+	filename, err := media.NewFilename(detected.Extension())
+	if err != nil {
+		return "", media.Info{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", media.Info{}, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", media.Info{}, err
+	}
+	info := media.Info{
+		MIMEType:  strings.TrimSpace(strings.Split(detected.String(), ";")[0]),
+		SizeBytes: stat.Size(),
+	}
+
+	if err := store.Put(ctx, media.QuarantineKey(key(filename)), f, info.SizeBytes, "application/octet-stream"); err != nil {
+		return "", media.Info{}, fmt.Errorf("failed to quarantine upload: %w", err)
+	}
+	return filename, info, nil
+}
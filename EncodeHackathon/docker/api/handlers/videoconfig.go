@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"aletterahead-api/media"
+	"aletterahead-api/scan"
+	"aletterahead-api/storage"
+	"aletterahead-api/transcode"
+)
+
+// Ways of serving a video to the browser
+const (
+	// VideoServeStream streams the file through the API
+	VideoServeStream = "stream"
+	// VideoServePresign redirects to a short-lived URL on the storage backend
+	VideoServePresign = "presign"
+)
+
+// VideoConfig controls how uploaded videos are checked, where they are kept and
+// how they are served
+type VideoConfig struct {
+	Store      storage.Storage
+	Prober     media.Prober
+	Limits     media.Limits
+	ServeMode  string
+	PresignTTL time.Duration
+	// Scanner checks every upload for malware before it is stored
+	Scanner scan.Clamd
+	// Links signs the expiring links videos are played from; only the event's
+	// parent is given them
+	Links media.LinkSigner
+	// PublicBaseURL is the API's address as browsers see it (e.g. https://api.aletterahead.com).
+	// Every video link is built from it when it is handed out, never from the request.
+	PublicBaseURL string
+}
+
+// maxVideoSize is the largest video that can be uploaded (50MB)
+const maxVideoSize = int64(50 * 1024 * 1024)
+
+// allowedVideoExtensions are the extensions videos are served with (uploads are
+// identified from their content - see the media package)
+var allowedVideoExtensions = []string{".mp4", ".mov", ".avi", ".webm"}
+
+// isVideoExtension reports whether filename has one of allowedVideoExtensions
+func isVideoExtension(filename string) bool {
+	fileExt := strings.ToLower(filepath.Ext(filename))
+	for _, ext := range allowedVideoExtensions {
+		if fileExt == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// videoKey is the storage key of an uploaded video
+func videoKey(filename string) string {
+	return transcode.SourceKey(filename)
+}
+
+// videoURL is the address donors and parents play a video from
+func (v VideoConfig) videoURL(filename string) string {
+	return v.baseURL() + "/api/videos/" + url.PathEscape(filename)
+}
+
+// baseURL is where browsers reach the API
+func (v VideoConfig) baseURL() string {
+	return strings.TrimSuffix(v.PublicBaseURL, "/")
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"aletterahead-api/media"
+	"aletterahead-api/scan"
+	"aletterahead-api/transcode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ingestVideo scans the uploaded file at path for malware, checks it really is a
+// video within the limits, stores it under a random filename, records it in the
+// videos table against eventID and queues it for transcoding. Rejected files
+// return *media.UnsupportedError, *media.LimitError or *scan.InfectedError; an
+// infected file is quarantined and recorded as such, never served.
+func (v VideoConfig) ingestVideo(ctx context.Context, db *pgxpool.Pool, eventID *int, path, originalFilename string) (media.Video, transcode.Job, error) {
+	scanned, checksum, err := scanUpload(ctx, v.Scanner, path)
+	if err != nil {
+		return media.Video{}, transcode.Job{}, err
+	}
+	if scanned.Status == scan.Infected {
+		return media.Video{}, transcode.Job{}, v.quarantineVideo(ctx, db, eventID, path, originalFilename, checksum, scanned)
+	}
+
+	info, err := v.Prober.InspectVideo(ctx, path)
+	if err != nil {
+		return media.Video{}, transcode.Job{}, err
+	}
+	if err := v.Limits.Check(info); err != nil {
+		return media.Video{}, transcode.Job{}, err
+	}
+
+	filename, err := media.NewFilename(info.Extension)
+	if err != nil {
+		return media.Video{}, transcode.Job{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return media.Video{}, transcode.Job{}, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return media.Video{}, transcode.Job{}, err
+	}
+	info.SizeBytes = stat.Size()
+
+	if err := v.Store.Put(ctx, videoKey(filename), f, info.SizeBytes, info.MIMEType); err != nil {
+		return media.Video{}, transcode.Job{}, fmt.Errorf("failed to store video: %w", err)
+	}
+
+	video, err := media.CreateVideo(ctx, db, eventID, filename, cleanOriginalFilename(originalFilename), checksum, info, scanned)
+	if err != nil {
+		return media.Video{}, transcode.Job{}, err
+	}
+
+	// GetVideo serves the transcoded copy once it is ready
+	job, err := transcode.Enqueue(ctx, db, filename)
+	if err != nil {
+		return video, transcode.Job{}, fmt.Errorf("failed to queue video for processing: %w", err)
+	}
+
+	return video, job, nil
+}
+
+// quarantineVideo stores an infected upload under quarantine/ and records it,
+// already quarantined, so the scan's verdict is kept with the event's videos. It
+// returns the *scan.InfectedError to reject the upload with.
+func (v VideoConfig) quarantineVideo(ctx context.Context, db *pgxpool.Pool, eventID *int, path, originalFilename, checksum string, scanned scan.Result) error {
+	filename, info, err := quarantineUpload(ctx, v.Store, path, videoKey)
+	if err != nil {
+		return err
+	}
+
+	video, err := media.CreateVideo(ctx, db, eventID, filename, cleanOriginalFilename(originalFilename), checksum, info, scanned)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Quarantined video %d (%s): infected with %s", video.ID, filename, scanned.Signature)
+	return &scan.InfectedError{Signature: scanned.Signature}
+}
+
+// requireVideoEvent checks eventID is an event that is still taking videos,
+// responding if it isn't
+func requireVideoEvent(c *gin.Context, db *pgxpool.Pool, eventID int) bool {
+	var expiresAt time.Time
+	var videosEnabled bool
+	var cancelledAt *time.Time
+
+	err := db.QueryRow(c.Request.Context(),
+		`SELECT expires_at, videos_enabled, cancelled_at FROM events WHERE event_id = $1`,
+		eventID,
+	).Scan(&expiresAt, &videosEnabled, &cancelledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Event not found",
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database query failed",
+		})
+		return false
+	}
+
+	if cancelledAt != nil {
+		c.JSON(http.StatusGone, gin.H{
+			"error":        "This event has been cancelled",
+			"cancelled_at": cancelledAt,
+		})
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		c.JSON(http.StatusGone, gin.H{
+			"error":      "This event has expired",
+			"expired_at": expiresAt,
+		})
+		return false
+	}
+	if !videosEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Video uploads are not enabled for this event",
+		})
+		return false
+	}
+	return true
+}
+
+// rejectedUpload responds to a file that isn't an acceptable video or photo, or
+// couldn't be scanned for malware, returning false for other errors
+func rejectedUpload(c *gin.Context, err error) bool {
+	var unsupported *media.UnsupportedError
+	if errors.As(err, &unsupported) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": unsupported.Reason,
+		})
+		return true
+	}
+
+	var limit *media.LimitError
+	if errors.As(err, &limit) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": limit.Reason,
+		})
+		return true
+	}
+
+	var infected *scan.InfectedError
+	if errors.As(err, &infected) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "File was rejected by the virus scan",
+		})
+		return true
+	}
+
+	// Never let a file through unscanned
+	if errors.Is(err, scan.ErrUnavailable) {
+		log.Printf("Virus scan failed: %v", err)
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Uploads can't be scanned for viruses right now, please try again later",
+		})
+		return true
+	}
+
+	return false
+}
+
+// cleanOriginalFilename keeps just the name of the uploader's file, for reference.
+// Browsers on Windows may send the whole C:\... path, so backslashes separate
+// directories too. Long names keep their last 255 bytes, cut between characters.
+func cleanOriginalFilename(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if len(name) > 255 {
+		start := len(name) - 255
+		for start < len(name) && !utf8.RuneStart(name[start]) {
+			start++
+		}
+		name = name[start:]
+	}
+	return name
+}
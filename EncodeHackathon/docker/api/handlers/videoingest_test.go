@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanOriginalFilename(t *testing.T) {
+	// 404 bytes (é is two), so cutting to 255 would split an é: 254 are kept
+	long := strings.Repeat("é", 200) + ".mp4"
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "birthday.mp4", "birthday.mp4"},
+		{"unix path", "/home/gran/videos/birthday.mp4", "birthday.mp4"},
+		{"windows path", `C:\Users\Gran\Videos\birthday.mp4`, "birthday.mp4"},
+		{"mixed separators", `C:\Users\Gran/Videos\birthday.mp4`, "birthday.mp4"},
+		{"invalid utf-8", "birth\xffday.mp4", "birthday.mp4"},
+		{"long name", long, long[len(long)-254:]},
+	}
+
+	for _, tt := range tests {
+		got := cleanOriginalFilename(tt.in)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > 255 || !utf8.ValidString(got) {
+			t.Errorf("%s: %q is not valid UTF-8 of at most 255 bytes", tt.name, got)
+		}
+	}
+}
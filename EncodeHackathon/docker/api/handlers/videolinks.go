@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"aletterahead-api/media"
+
+	"github.com/gin-gonic/gin"
+)
+
+// videoResource names a video (suffix "") or its thumbnail (suffix "/thumbnail")
+// in signed links
+func videoResource(filename string, suffix string) string {
+	return "videos/" + filename + suffix
+}
+
+// requireVideoLink rejects requests that don't carry an unexpired signed link
+// to resource. On failure it writes the error response and returns false.
+func (v VideoConfig) requireVideoLink(c *gin.Context, resource string) bool {
+	err := v.Links.Verify(resource, c.Request.URL.Query(), time.Now())
+	if err == nil {
+		return true
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if errors.Is(err, media.ErrLinkExpired) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Video link has expired",
+		})
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Invalid video link",
+	})
+	return false
+}
+
+// signVideoAddress turns a donation's video_address (plus suffix, see
+// videoResource) into a link that plays for Links.TTL. Links to uploaded videos
+// are rebuilt on PublicBaseURL, whatever host the address names; addresses that
+// aren't uploaded videos are returned as they are.
+func (v VideoConfig) signVideoAddress(videoAddress string, suffix string) string {
+	filename, ok := videoFilenameFromAddress(videoAddress)
+	if !ok {
+		return videoAddress + suffix
+	}
+
+	videoAddress = v.videoURL(filename)
+	signed, err := v.Links.SignURL(videoAddress+suffix, videoResource(filename, suffix), time.Now())
+	if err != nil {
+		log.Printf("Failed to sign video link %s: %v", videoAddress, err)
+		return videoAddress + suffix
+	}
+	return signed
+}
+
+// signedVideoURL is a link that plays the uploaded video filename for Links.TTL
+func (v VideoConfig) signedVideoURL(filename string) string {
+	return v.signVideoAddress(v.videoURL(filename), "")
+}
@@ -1,119 +1,127 @@
 package handlers
 
 import (
-	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // VideoUploadResponse represents the response after uploading a video
 type VideoUploadResponse struct {
-	VideoURL string `json:"video_url"`
-	Message  string `json:"message"`
+	VideoID         int      `json:"video_id"` // Send as video_id when creating the donation
+	EventID         int      `json:"event_id"`
+	VideoURL        string   `json:"video_url"`
+	MIMEType        string   `json:"mime_type"`
+	DurationSeconds *float64 `json:"duration_seconds"`
+	SizeBytes       int64    `json:"size_bytes"`
+	Status          string   `json:"status"`      // Transcoding status, see the transcode package
+	ScanStatus      string   `json:"scan_status"` // clean, or skipped when no virus scanner is configured
+	Message         string   `json:"message"`
 }
 
-// UploadVideo handles video file uploads
-func UploadVideo(c *gin.Context) {
-	// Get the uploaded file
-	file, err := c.FormFile("video")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No video file provided",
-		})
-		return
-	}
-
-	// Validate file size (50MB max)
-	maxSize := int64(50 * 1024 * 1024) // 50MB in bytes
-	if file.Size > maxSize {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "File too large. Maximum size is 50MB",
-		})
-		return
-	}
-
-	// Validate file extension
-	allowedExtensions := []string{".mp4", ".mov", ".avi", ".webm"}
-	fileExt := strings.ToLower(filepath.Ext(file.Filename))
-
-	isValidExt := false
-	for _, ext := range allowedExtensions {
-		if fileExt == ext {
-			isValidExt = true
-			break
+// UploadVideo handles video file uploads for an event. The file is scanned for
+// malware, and its content (not its name) must be a supported video within the
+// configured limits; it is stored under a random filename and queued to be
+// converted into a browser-friendly MP4. The returned video_id can be attached
+// to one donation to that event.
+func UploadVideo(db *pgxpool.Pool, videos VideoConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Videos belong to the event they are uploaded for
+		eventID, err := strconv.Atoi(c.PostForm("event_id"))
+		if err != nil || eventID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "event_id is required",
+			})
+			return
+		}
+		if !requireVideoEvent(c, db, eventID) {
+			return
 		}
-	}
 
-	if !isValidExt {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid file type. Allowed: .mp4, .mov, .avi, .webm",
-		})
-		return
-	}
+		// Get the uploaded file
+		file, err := c.FormFile("video")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "No video file provided",
+			})
+			return
+		}
 
-	// Create uploads directory if it doesn't exist
-	uploadDir := "/var/uploads/videos"
-	if err := os.MkdirAll(uploadDir, 0o755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create upload directory",
-		})
-		return
-	}
+		// Validate file size (50MB max)
+		if file.Size > maxVideoSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "File too large. Maximum size is 50MB",
+			})
+			return
+		}
 
-	// Generate unique filename
-	// Format: timestamp_originalname.ext
-	timestamp := time.Now().Unix()
-	filename := fmt.Sprintf("%d_%s", timestamp, file.Filename)
-	filepath := filepath.Join(uploadDir, filename)
+		// ffprobe needs the upload as a file on disk
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read video file",
+			})
+			return
+		}
+		defer src.Close()
+
+		tmp, err := os.CreateTemp("", "video-upload-*")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to save video file",
+			})
+			return
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if _, err := io.Copy(tmp, src); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read video file",
+			})
+			return
+		}
 
-	// Save the file
-	if err := c.SaveUploadedFile(file, filepath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save video file",
-		})
-		return
-	}
+		// Check, store and record the video
+		video, job, err := videos.ingestVideo(c.Request.Context(), db, &eventID, tmp.Name(), file.Filename)
+		if err != nil {
+			if rejectedUpload(c, err) {
+				return
+			}
+			log.Printf("Failed to save uploaded video: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to save video file",
+			})
+			return
+		}
 
-	// Generate the public URL
-	// This assumes your server is accessible at the host/port
-	videoURL := fmt.Sprintf("http://localhost:8080/api/videos/%s", filename)
+		response := VideoUploadResponse{
+			VideoID:         video.ID,
+			EventID:         eventID,
+			VideoURL:        videos.signedVideoURL(video.Filename),
+			MIMEType:        video.MIMEType,
+			DurationSeconds: durationSeconds(video.Duration),
+			SizeBytes:       video.SizeBytes,
+			Status:          string(job.Status),
+			ScanStatus:      string(video.Scan.Status),
+			Message:         "Video uploaded successfully. It can be played once processing has finished",
+		}
 
-	response := VideoUploadResponse{
-		VideoURL: videoURL,
-		Message:  "Video uploaded successfully",
+		c.JSON(http.StatusCreated, response)
 	}
-
-	c.JSON(http.StatusCreated, response)
 }
 
-// ServeVideo serves video files from the uploads directory
-func ServeVideo(c *gin.Context) {
-	filename := c.Param("filename")
-
-	// Validate filename (prevent path traversal attacks)
-	if strings.Contains(filename, "..") || strings.Contains(filename, "/") {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid filename",
-		})
-		return
+// durationSeconds converts a video's duration for JSON responses
+func durationSeconds(d *time.Duration) *float64 {
+	if d == nil {
+		return nil
 	}
-
-	filepath := filepath.Join("/var/uploads/videos", filename)
-
-	// Check if file exists
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Video not found",
-		})
-		return
-	}
-
-	// Serve the file
-	// Gin automatically sets the correct Content-Type based on file extension
-	c.File(filepath)
+	seconds := d.Seconds()
+	return &seconds
 }
@@ -0,0 +1,120 @@
+// Package isa tracks how much of a child's annual Junior ISA allowance their
+// donations have used. Allowance runs per UK tax year (6 April to 5 April).
+package isa
+
+import (
+	"context"
+	"fmt"
+	"time"
+	_ "time/tzdata" // the API image has no zoneinfo
+
+	"github.com/jackc/pgx/v5"
+)
+
+// What CreateDonation does with a donation that would go over the allowance
+const (
+	ModeWarn   = "warn"   // accept it, but tell the donor
+	ModeReject = "reject" // refuse it
+)
+
+// Config is the allowance to enforce
+type Config struct {
+	AllowancePence int
+	Mode           string
+}
+
+// ukTime is the timezone the tax year boundaries are set in
+var ukTime = loadUKTime()
+
+func loadUKTime() *time.Location {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// TaxYear is a UK tax year, from 6 April (inclusive) to the next 6 April (exclusive)
+type TaxYear struct {
+	Start time.Time
+	End   time.Time
+}
+
+// TaxYearFor returns the tax year a moment falls in
+func TaxYearFor(t time.Time) TaxYear {
+	t = t.In(ukTime)
+	year := t.Year()
+	if t.Before(time.Date(year, time.April, 6, 0, 0, 0, 0, ukTime)) {
+		year--
+	}
+
+	return TaxYear{
+		Start: time.Date(year, time.April, 6, 0, 0, 0, 0, ukTime),
+		End:   time.Date(year+1, time.April, 6, 0, 0, 0, 0, ukTime),
+	}
+}
+
+// Label names the tax year the way HMRC does, e.g. "2025/26"
+func (y TaxYear) Label() string {
+	return fmt.Sprintf("%d/%02d", y.Start.Year(), (y.Start.Year()+1)%100)
+}
+
+// LastDay is the final day of the tax year (5 April)
+func (y TaxYear) LastDay() time.Time {
+	return y.End.AddDate(0, 0, -1)
+}
+
+// Usage is how much of a child's allowance is used in a tax year
+type Usage struct {
+	// CapturedPence is money already taken in the tax year, less what was refunded
+	CapturedPence int
+	// PendingPence is money held on donors' cards (or being paid) that will be taken if approved
+	PendingPence int
+}
+
+// Committed is the captured money plus what is waiting to be captured
+func (u Usage) Committed() int {
+	return u.CapturedPence + u.PendingPence
+}
+
+// Querier runs a query on a connection pool or inside a transaction
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ChildUsage totals a child's donations across all of their events for a tax year.
+// Pending donations are the ones held right now, or still being paid for, so they
+// only mean much for the current year. Refunded money no longer counts.
+func ChildUsage(ctx context.Context, db Querier, childID int, year TaxYear) (Usage, error) {
+	query := `
+		SELECT
+			COALESCE(SUM(d.amount_pence - COALESCE(d.refunded_amount_pence, 0)) FILTER (
+				WHERE d.status IN ('captured', 'disputed') AND d.captured_at >= $2 AND d.captured_at < $3
+			), 0),
+			COALESCE(SUM(d.amount_pence) FILTER (
+				WHERE d.status IN ('awaiting_payment', 'pending_review', 'approved')
+			), 0)
+		FROM donations d
+		JOIN events e ON d.event_id = e.event_id
+		WHERE e.child_id = $1
+	`
+
+	var usage Usage
+	err := db.QueryRow(ctx, query, childID, year.Start.UTC(), year.End.UTC()).Scan(&usage.CapturedPence, &usage.PendingPence)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to total donations: %w", err)
+	}
+	return usage, nil
+}
+
+// Remaining is how much more can be donated in the tax year once the donations
+// already held are captured (never negative); WouldExceed is checked against the same figure
+func (c Config) Remaining(usage Usage) int {
+	return max(c.AllowancePence-usage.Committed(), 0)
+}
+
+// WouldExceed reports whether accepting amountPence would take the child over the allowance,
+// counting donations that are already held as well as those captured
+func (c Config) WouldExceed(usage Usage, amountPence int) bool {
+	return usage.Committed()+amountPence > c.AllowancePence
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"aletterahead-api/jobs"
+	"aletterahead-api/mailer"
+	"aletterahead-api/media"
+	"aletterahead-api/storage"
+	"aletterahead-api/transcode"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+// StartJobs launches the background workers; they stop when ctx is cancelled
+func StartJobs(ctx context.Context, db *pgxpool.Pool, sc *client.API, store storage.Storage, mail mailer.Sender, capsuleLinks media.LinkSigner) {
+	// Capture approved donations and handle card authorisations close to expiry
+	sweeper := &jobs.AuthorizationSweeper{
+		DB:            db,
+		Stripe:        sc,
+		Interval:      getDurationEnv("AUTHORIZATION_SWEEP_INTERVAL", time.Hour),
+		ExpiringAfter: getDurationEnv("AUTHORIZATION_EXPIRING_AFTER", 6*24*time.Hour),
+	}
+	go sweeper.Run(ctx)
+
+	// Delete resumable uploads that were abandoned part way
+	expirer := &jobs.UploadExpirer{
+		DB:       db,
+		Store:    store,
+		Interval: getDurationEnv("TUS_EXPIRY_SWEEP_INTERVAL", time.Hour),
+	}
+	go expirer.Run(ctx)
+
+	// Convert uploaded videos into MP4s every browser can play
+	transcoder := &jobs.Transcoder{
+		DB:           db,
+		Store:        store,
+		FFmpeg:       transcode.FFmpeg{Path: getEnv("FFMPEG_PATH", "ffmpeg")},
+		Workers:      getIntEnv("TRANSCODE_WORKERS", 1),
+		PollInterval: getDurationEnv("TRANSCODE_POLL_INTERVAL", 5*time.Second),
+		Timeout:      getDurationEnv("TRANSCODE_TIMEOUT", 10*time.Minute),
+		MaxAttempts:  getIntEnv("TRANSCODE_MAX_ATTEMPTS", 3),
+		// The first frame is often black, so the poster frame comes from a little way in
+		ThumbnailOffset: getDurationEnv("THUMBNAIL_OFFSET", time.Second),
+	}
+	go transcoder.Run(ctx)
+
+	// Remove videos that never made it into a donation, or whose donation was rejected
+	go newVideoCollector(db, store).Run(ctx)
+
+	// Email children their time capsule when it is due
+	if mail != nil {
+		deliverer := newCapsuleDeliverer(db, mail, capsuleLinks)
+		if deliverer.LinkBaseURL == "" {
+			log.Printf("PUBLIC_BASE_URL and CAPSULE_LINK_BASE_URL are not set, time capsules will not be delivered")
+		} else {
+			go deliverer.Run(ctx)
+		}
+	}
+}
+
+// newCapsuleDeliverer reads the time capsule delivery settings; the capsules
+// subcommand starts from the same ones
+func newCapsuleDeliverer(db *pgxpool.Pool, mail mailer.Sender, links media.LinkSigner) *jobs.CapsuleDeliverer {
+	linkBaseURL := getEnv("CAPSULE_LINK_BASE_URL", "")
+	if linkBaseURL == "" {
+		if publicBaseURL := getEnv("PUBLIC_BASE_URL", ""); publicBaseURL != "" {
+			linkBaseURL = strings.TrimSuffix(publicBaseURL, "/") + "/api/capsules"
+		}
+	}
+
+	return &jobs.CapsuleDeliverer{
+		DB:          db,
+		Mailer:      mail,
+		Links:       links,
+		LinkBaseURL: linkBaseURL,
+		Interval:    getDurationEnv("CAPSULE_DELIVERY_INTERVAL", time.Hour),
+		MaxAttempts: getIntEnv("CAPSULE_MAX_ATTEMPTS", 5),
+	}
+}
+
+// newVideoCollector reads the orphaned video collector's settings; the gc
+// subcommand starts from the same ones
+func newVideoCollector(db *pgxpool.Pool, store storage.Storage) *jobs.VideoCollector {
+	collector := &jobs.VideoCollector{
+		DB:          db,
+		Store:       store,
+		Interval:    getDurationEnv("VIDEO_GC_INTERVAL", 24*time.Hour),
+		GracePeriod: getDurationEnv("VIDEO_GC_GRACE_PERIOD", 72*time.Hour),
+		Mode:        media.RemoveMode(getEnv("VIDEO_GC_MODE", string(media.RemoveQuarantine))),
+		DryRun:      getEnv("VIDEO_GC_DRY_RUN", "false") == "true",
+	}
+
+	if collector.Mode != media.RemoveDelete && collector.Mode != media.RemoveQuarantine {
+		log.Printf("Invalid VIDEO_GC_MODE %q, using %q", collector.Mode, media.RemoveQuarantine)
+		collector.Mode = media.RemoveQuarantine
+	}
+	return collector
+}
+
+// getDurationEnv reads a duration such as "30m" or "144h", falling back on bad values
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getIntEnv reads a positive whole number, falling back on bad values
+func getIntEnv(key string, defaultValue int) int {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
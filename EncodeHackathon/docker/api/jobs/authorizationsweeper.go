@@ -0,0 +1,266 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"aletterahead-api/lifecycle"
+	"aletterahead-api/payments"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+// AuthorizationSweeper deals with authorised donations that still need capturing.
+// Approved donations are captured as soon as they are authorised. Unmoderated ones
+// whose card authorisation is close to expiring (Stripe holds last about 7 days) are
+// either captured or flagged to the parent, depending on the event's policy; a
+// captured one still waits for the parent's review before it reaches the child. Those
+// left pending on a cancelled event are never captured; cancelling releases them, and
+// the sweeper rejects any it missed (a payment set up while the event was being
+// cancelled, or one whose release failed).
+type AuthorizationSweeper struct {
+	DB     *pgxpool.Pool
+	Stripe *client.API
+
+	// Interval is how often the sweep runs
+	Interval time.Duration
+	// ExpiringAfter is how old an authorisation must be before it counts as about to expire
+	ExpiringAfter time.Duration
+}
+
+// cancelGracePeriod gives CancelEvent time to reject an event's pending donations itself
+const cancelGracePeriod = 10 * time.Minute
+
+// pendingCapture is an authorised donation the sweeper needs to act on
+type pendingCapture struct {
+	donationID       int
+	paymentIntentID  string
+	approved         bool
+	authorizedAt     time.Time
+	expiryNotifiedAt *time.Time
+	policy           string
+	parentID         int
+	donorName        string
+	amountPence      int
+	eventName        string
+}
+
+// Run sweeps every Interval until ctx is cancelled
+func (s *AuthorizationSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sweep(ctx); err != nil {
+			log.Printf("Authorization sweep failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep runs one pass over authorised donations
+func (s *AuthorizationSweeper) Sweep(ctx context.Context) error {
+	query := `
+		SELECT
+			d.id,
+			d.payment_intent_id,
+			d.status = 'approved',
+			d.authorized_at,
+			d.expiry_notified_at,
+			e.expiring_authorization_policy,
+			c.parent_id,
+			d.donor_name,
+			d.amount_pence,
+			e.event_name
+		FROM donations d
+		JOIN events e ON d.event_id = e.event_id
+		JOIN children c ON e.child_id = c.child_id
+		WHERE d.payment_status = 'authorized'
+		AND d.payment_intent_id IS NOT NULL
+		AND (d.status = 'approved' OR (d.status = 'pending_review' AND d.authorized_at < $1 AND e.cancelled_at IS NULL))
+		ORDER BY d.authorized_at ASC
+	`
+
+	rows, err := s.DB.Query(ctx, query, time.Now().Add(-s.ExpiringAfter))
+	if err != nil {
+		return fmt.Errorf("failed to query authorised donations: %w", err)
+	}
+
+	var pending []pendingCapture
+	for rows.Next() {
+		var p pendingCapture
+		err := rows.Scan(
+			&p.donationID,
+			&p.paymentIntentID,
+			&p.approved,
+			&p.authorizedAt,
+			&p.expiryNotifiedAt,
+			&p.policy,
+			&p.parentID,
+			&p.donorName,
+			&p.amountPence,
+			&p.eventName,
+		)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan authorised donation: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read authorised donations: %w", err)
+	}
+
+	// One failing donation shouldn't stop the rest from being captured
+	for _, p := range pending {
+		var err error
+		switch {
+		case p.approved, p.policy == "capture":
+			err = s.capture(ctx, p)
+		case p.expiryNotifiedAt == nil:
+			err = s.notify(ctx, p)
+		}
+		if err != nil {
+			log.Printf("Authorization sweep: donation %d: %v", p.donationID, err)
+		}
+	}
+
+	return s.rejectCancelled(ctx)
+}
+
+// rejectCancelled rejects donations still waiting for a decision on events that were
+// cancelled, releasing the donors' money as CancelEvent does. Donations rejected
+// before their payment was set up, whose payment is still open, are released too.
+func (s *AuthorizationSweeper) rejectCancelled(ctx context.Context) error {
+	query := `
+		SELECT d.id, d.amount_pence, d.payment_intent_id, d.payment_status
+		FROM donations d
+		JOIN events e ON d.event_id = e.event_id
+		WHERE e.cancelled_at < $1
+		AND (
+			d.status IN ($2, $3, $4)
+			OR (d.status = $5 AND d.payment_intent_id IS NOT NULL AND d.payment_status IN ('pending', 'authorized'))
+		)
+		ORDER BY d.id ASC
+	`
+
+	rows, err := s.DB.Query(ctx, query, time.Now().Add(-cancelGracePeriod),
+		lifecycle.AwaitingPayment, lifecycle.PendingReview, lifecycle.PaymentFailed, lifecycle.Rejected)
+	if err != nil {
+		return fmt.Errorf("failed to query donations on cancelled events: %w", err)
+	}
+
+	type leftover struct {
+		id              int
+		amountPence     int
+		paymentIntentID *string
+		paymentStatus   string
+	}
+	var pending []leftover
+	for rows.Next() {
+		var d leftover
+		if err := rows.Scan(&d.id, &d.amountPence, &d.paymentIntentID, &d.paymentStatus); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan donation on cancelled event: %w", err)
+		}
+		pending = append(pending, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read donations on cancelled events: %w", err)
+	}
+
+	for _, d := range pending {
+		var refundedAmount *int
+		if d.paymentIntentID != nil && d.paymentStatus != "refunded" && d.paymentStatus != "canceled" {
+			release, err := payments.ReleaseDonation(s.Stripe, d.id, *d.paymentIntentID)
+			if err != nil {
+				log.Printf("Authorization sweep: donation %d on cancelled event: %v", d.id, err)
+				continue
+			}
+			d.paymentStatus = release.Status
+			if release.Status == "refunded" {
+				refundedAmount = &d.amountPence
+			}
+		}
+
+		updateQuery := `
+			UPDATE donations
+			SET payment_status = $1,
+				refunded_amount_pence = COALESCE($2, refunded_amount_pence),
+				refunded_at = CASE WHEN $2::INTEGER IS NOT NULL THEN NOW() ELSE refunded_at END
+			WHERE id = $3
+		`
+
+		if _, err := s.DB.Exec(ctx, updateQuery, d.paymentStatus, refundedAmount, d.id); err != nil {
+			log.Printf("Authorization sweep: donation %d on cancelled event: %v", d.id, err)
+			continue
+		}
+
+		_, err := lifecycle.Transition(ctx, s.DB, d.id, lifecycle.Rejected,
+			lifecycle.SystemActor("authorization_sweeper"), "event cancelled by parent")
+		if err != nil {
+			log.Printf("Authorization sweep: donation %d on cancelled event: %v", d.id, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *AuthorizationSweeper) capture(ctx context.Context, p pendingCapture) error {
+	captured, err := payments.CaptureDonation(s.Stripe, p.donationID, p.paymentIntentID)
+	if err != nil {
+		return err
+	}
+	if !captured {
+		return nil
+	}
+
+	updateQuery := `
+		UPDATE donations
+		SET payment_status = 'succeeded', updated_at = NOW()
+		WHERE id = $1
+		AND payment_status = 'authorized'
+	`
+
+	if _, err := s.DB.Exec(ctx, updateQuery, p.donationID); err != nil {
+		return err
+	}
+
+	reason := "captured after parent approval"
+	if !p.approved {
+		reason = "captured by event policy before the authorisation expired, awaiting parent review"
+	}
+	_, err = lifecycle.Transition(ctx, s.DB, p.donationID, lifecycle.Captured, lifecycle.SystemActor("authorization_sweeper"), reason)
+	return err
+}
+
+// notify asks the parent to moderate the donation before its authorisation lapses
+func (s *AuthorizationSweeper) notify(ctx context.Context, p pendingCapture) error {
+	expiresAt := p.authorizedAt.Add(7 * 24 * time.Hour)
+	message := fmt.Sprintf(
+		"%s's donation of £%.2f to %s is waiting for your approval. Approve it before %s or the payment will lapse.",
+		p.donorName, float64(p.amountPence)/100, p.eventName, expiresAt.Format("2 Jan 2006 15:04"),
+	)
+
+	insertQuery := `
+		INSERT INTO parent_notifications (parent_id, donation_id, kind, message)
+		VALUES ($1, $2, 'authorization_expiring', $3)
+	`
+
+	if _, err := s.DB.Exec(ctx, insertQuery, p.parentID, p.donationID, message); err != nil {
+		return err
+	}
+
+	_, err := s.DB.Exec(ctx, `UPDATE donations SET expiry_notified_at = NOW() WHERE id = $1`, p.donationID)
+	return err
+}
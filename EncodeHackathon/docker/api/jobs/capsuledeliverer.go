@@ -0,0 +1,208 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"aletterahead-api/capsules"
+	"aletterahead-api/mailer"
+	"aletterahead-api/media"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CapsuleDeliverer emails children their time capsule - the messages and videos
+// of every approved donation made to them - once they turn 18 (or on the date
+// their parent chose), as a signed link to the capsule. Deliveries are
+// recorded, so running it again only sends what hasn't been sent.
+type CapsuleDeliverer struct {
+	DB     *pgxpool.Pool
+	Mailer mailer.Sender
+	// Links signs the link in the email, which works for Links.TTL
+	Links media.LinkSigner
+	// LinkBaseURL is where the link points; the delivery ID is added to it
+	// (e.g. https://api.aletterahead.com/api/capsules)
+	LinkBaseURL string
+
+	// Interval is how often due capsules are looked for
+	Interval time.Duration
+	// MaxAttempts is how many times a failing email is tried
+	MaxAttempts int
+	// DryRun only reports what would be sent
+	DryRun bool
+}
+
+// DeliverOptions narrows one pass
+type DeliverOptions struct {
+	// Today is the date capsules are due by
+	Today time.Time
+	// ChildID only delivers to one child (0 for every child)
+	ChildID int
+	// Resend emails a fresh link for capsules that have already been sent,
+	// adding any donations approved since
+	Resend bool
+}
+
+// DeliveryOutcome is what happened to one due capsule
+type DeliveryOutcome struct {
+	Due      capsules.Due
+	Delivery capsules.Delivery // Unset in a dry run, or if another pass had it
+	Result   string            // sent, empty, failed, skipped or dry-run
+	Err      error
+}
+
+// Run delivers due capsules every Interval until ctx is cancelled
+func (d *CapsuleDeliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		outcomes, err := d.Deliver(ctx, DeliverOptions{Today: time.Now()})
+		if err != nil {
+			log.Printf("Capsule delivery failed: %v", err)
+		}
+		sent := 0
+		for _, o := range outcomes {
+			if o.Err != nil {
+				log.Printf("Capsule for child %d: %v", o.Due.ChildID, o.Err)
+			}
+			if o.Result == "sent" {
+				sent++
+			}
+		}
+		if sent > 0 {
+			log.Printf("Delivered %d time capsules", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver runs one pass, sending every capsule that is due unless DryRun is set
+func (d *CapsuleDeliverer) Deliver(ctx context.Context, opts DeliverOptions) ([]DeliveryOutcome, error) {
+	due, err := capsules.FindDue(ctx, d.DB, capsules.DueOptions{
+		Today:       opts.Today,
+		ChildID:     opts.ChildID,
+		Resend:      opts.Resend,
+		MaxAttempts: d.MaxAttempts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes := make([]DeliveryOutcome, 0, len(due))
+	for _, child := range due {
+		if d.DryRun {
+			outcomes = append(outcomes, DeliveryOutcome{Due: child, Result: "dry-run"})
+			continue
+		}
+		outcomes = append(outcomes, d.deliver(ctx, child, opts.Resend))
+	}
+	return outcomes, nil
+}
+
+// deliver claims, compiles and emails one child's capsule
+func (d *CapsuleDeliverer) deliver(ctx context.Context, child capsules.Due, resend bool) DeliveryOutcome {
+	outcome := DeliveryOutcome{Due: child}
+
+	delivery, claimed, err := capsules.Claim(ctx, d.DB, child, resend)
+	if err != nil || !claimed {
+		outcome.Result, outcome.Err = "skipped", err
+		return outcome
+	}
+
+	compiled, err := capsules.Compile(ctx, d.DB, delivery.ID)
+	if err != nil {
+		outcome.Result, outcome.Err = "failed", err
+		if markErr := capsules.MarkFailed(ctx, d.DB, delivery.ID, err); markErr != nil {
+			log.Printf("Failed to record capsule failure for child %d: %v", child.ChildID, markErr)
+		}
+		return outcome
+	}
+	delivery = compiled
+	outcome.Delivery = delivery
+
+	// Nothing to send yet; looked at again once a donation is approved
+	if delivery.ItemCount == 0 {
+		outcome.Result = "empty"
+		outcome.Err = capsules.MarkEmpty(ctx, d.DB, delivery.ID)
+		return outcome
+	}
+
+	msg, err := d.message(delivery, time.Now())
+	if err == nil {
+		err = d.Mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		outcome.Result, outcome.Err = "failed", err
+		if markErr := capsules.MarkFailed(ctx, d.DB, delivery.ID, err); markErr != nil {
+			log.Printf("Failed to record capsule failure for child %d: %v", child.ChildID, markErr)
+		}
+		return outcome
+	}
+
+	outcome.Result = "sent"
+	outcome.Err = capsules.MarkSent(ctx, d.DB, delivery.ID, delivery.Email)
+	return outcome
+}
+
+// Link is the signed address of a delivery's capsule
+func (d *CapsuleDeliverer) Link(deliveryID int, now time.Time) (string, error) {
+	address := strings.TrimSuffix(d.LinkBaseURL, "/") + "/" + strconv.Itoa(deliveryID)
+	return d.Links.SignURL(address, capsules.Resource(deliveryID), now)
+}
+
+// message writes the email that delivers a capsule
+func (d *CapsuleDeliverer) message(delivery capsules.Delivery, now time.Time) (mailer.Message, error) {
+	link, err := d.Link(delivery.ID, now)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+
+	var contents string
+	switch {
+	case delivery.MessageCount > 0 && delivery.VideoCount > 0:
+		contents = plural(delivery.MessageCount, "message") + " and " + plural(delivery.VideoCount, "video")
+	case delivery.VideoCount > 0:
+		contents = plural(delivery.VideoCount, "video")
+	case delivery.MessageCount > 0:
+		contents = plural(delivery.MessageCount, "message")
+	default:
+		contents = plural(delivery.ItemCount, "gift")
+	}
+
+	text := fmt.Sprintf(`Dear %s,
+
+Over the years, the people who love you sent you %s through A Letter Ahead, along with gifts towards your future. Your family has kept them safe until now.
+
+Open your time capsule:
+%s
+
+This link is just for you and works until %s. If it stops working, get in touch and we'll send you a new one.
+
+With love,
+A Letter Ahead
+`, delivery.ChildName, contents, link, now.Add(d.Links.TTL).Format("2 January 2006"))
+
+	return mailer.Message{
+		To:      delivery.Email,
+		Subject: fmt.Sprintf("%s, your time capsule is ready", delivery.ChildName),
+		Text:    text,
+	}, nil
+}
+
+// plural writes a count of things, e.g. "1 message" or "12 messages"
+func plural(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"aletterahead-api/storage"
+	"aletterahead-api/transcode"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Transcoder works the video transcode queue: it downloads each uploaded video,
+// runs ffmpeg on it and stores the normalized MP4 that GetVideo serves
+type Transcoder struct {
+	DB     *pgxpool.Pool
+	Store  storage.Storage
+	FFmpeg transcode.FFmpeg
+
+	// Workers is how many videos are transcoded at once
+	Workers int
+	// PollInterval is how often an idle worker checks for new jobs
+	PollInterval time.Duration
+	// Timeout limits a single ffmpeg run; processing jobs older than twice this are retried
+	Timeout time.Duration
+	// MaxAttempts is how many times a job is tried before it is marked failed
+	MaxAttempts int
+	// ThumbnailOffset is how far into the video the thumbnail frame is taken
+	ThumbnailOffset time.Duration
+}
+
+// Run works the queue with Workers workers until ctx is cancelled
+func (t *Transcoder) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < max(t.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (t *Transcoder) work(ctx context.Context) {
+	for {
+		// Keep going while there are jobs, then wait for more
+		for {
+			job, err := transcode.Claim(ctx, t.DB, 2*t.Timeout)
+			if errors.Is(err, transcode.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				log.Printf("Failed to claim transcode job: %v", err)
+				break
+			}
+			t.process(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(t.PollInterval):
+		}
+	}
+}
+
+func (t *Transcoder) process(ctx context.Context, job transcode.Job) {
+	outputKey := transcode.OutputKey(job.SourceFilename)
+
+	size, thumbnailKey, err := t.transcode(ctx, job.SourceFilename, outputKey)
+	if err == nil {
+		err = transcode.MarkReady(ctx, t.DB, job.ID, outputKey, size, thumbnailKey)
+		if err == nil {
+			log.Printf("Transcoded video %s", job.SourceFilename)
+			return
+		}
+	}
+
+	status, markErr := transcode.MarkFailed(ctx, t.DB, job, err, t.MaxAttempts)
+	if markErr != nil {
+		log.Printf("Transcode of %s failed (%v) and could not be recorded: %v", job.SourceFilename, err, markErr)
+		return
+	}
+	log.Printf("Transcode of %s failed (attempt %d, now %s): %v", job.SourceFilename, job.Attempts, status, err)
+}
+
+// transcode normalizes one video and takes its thumbnail, returning the size of
+// the stored output and the thumbnail's key (nil if no frame could be taken)
+func (t *Transcoder) transcode(ctx context.Context, sourceFilename, outputKey string) (int64, *string, error) {
+	dir, err := os.MkdirTemp("", "transcode-*")
+	if err != nil {
+		return 0, nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// ffmpeg needs a seekable file, and the source may be in a bucket
+	input := filepath.Join(dir, "source"+filepath.Ext(sourceFilename))
+	if err := t.download(ctx, transcode.SourceKey(sourceFilename), input); err != nil {
+		return 0, nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	output := filepath.Join(dir, "output.mp4")
+	if err := t.FFmpeg.Normalize(runCtx, input, output); err != nil {
+		return 0, nil, err
+	}
+
+	size, err := t.upload(ctx, output, outputKey, "video/mp4")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to store transcoded video: %w", err)
+	}
+
+	// A missing thumbnail shouldn't stop the video being played
+	thumbnailKey := transcode.ThumbnailKey(sourceFilename)
+	thumbnail := filepath.Join(dir, "thumbnail.jpg")
+	if err := t.FFmpeg.Thumbnail(runCtx, output, thumbnail, t.ThumbnailOffset); err != nil {
+		log.Printf("No thumbnail for %s: %v", sourceFilename, err)
+		return size, nil, nil
+	}
+	if _, err := t.upload(ctx, thumbnail, thumbnailKey, "image/jpeg"); err != nil {
+		log.Printf("Failed to store thumbnail for %s: %v", sourceFilename, err)
+		return size, nil, nil
+	}
+
+	return size, &thumbnailKey, nil
+}
+
+// upload stores the local file at path under key, returning its size
+func (t *Transcoder) upload(ctx context.Context, path, key, contentType string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := t.Store.Put(ctx, key, f, info.Size(), contentType); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (t *Transcoder) download(ctx context.Context, key, dest string) error {
+	src, _, err := t.Store.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to open source video: %w", err)
+	}
+	defer src.Close()
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, src); err != nil {
+		return fmt.Errorf("failed to download source video: %w", err)
+	}
+	return f.Close()
+}
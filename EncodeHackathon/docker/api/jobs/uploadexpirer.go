@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"aletterahead-api/storage"
+	"aletterahead-api/uploads"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UploadExpirer deletes resumable uploads that were abandoned part way, so their
+// chunks don't fill up storage. Finished videos are left alone.
+type UploadExpirer struct {
+	DB    *pgxpool.Pool
+	Store storage.Storage
+
+	// Interval is how often expired uploads are looked for
+	Interval time.Duration
+}
+
+// Run removes expired uploads every Interval until ctx is cancelled
+func (e *UploadExpirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := uploads.RemoveExpired(ctx, e.DB, e.Store, time.Now())
+		if err != nil {
+			log.Printf("Upload expiry failed: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d expired uploads", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"aletterahead-api/media"
+	"aletterahead-api/storage"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// VideoCollector removes uploaded videos nobody needs: ones no donation was ever
+// made with, ones whose donation the parent rejected, and stray files nothing
+// refers to. Each is left alone for GracePeriod first, so a donor has time to
+// finish their donation after uploading.
+type VideoCollector struct {
+	DB    *pgxpool.Pool
+	Store storage.Storage
+
+	// Interval is how often orphaned videos are looked for
+	Interval time.Duration
+	// GracePeriod is how long a video must have been unneeded before it is removed
+	GracePeriod time.Duration
+	// Mode is whether orphaned videos are deleted or moved to quarantine/
+	Mode media.RemoveMode
+	// DryRun only reports what would be removed
+	DryRun bool
+}
+
+// CollectResult is what one pass found and did
+type CollectResult struct {
+	Orphans      []media.Orphan
+	Removed      int
+	RemovedBytes int64
+}
+
+// Run collects orphaned videos every Interval until ctx is cancelled
+func (v *VideoCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(v.Interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := v.Collect(ctx)
+		if err != nil {
+			log.Printf("Video collection failed: %v", err)
+		} else if v.DryRun && len(result.Orphans) > 0 {
+			log.Printf("Found %d orphaned videos (dry run, nothing removed)", len(result.Orphans))
+		} else if result.Removed > 0 {
+			log.Printf("Removed %d orphaned videos (%d bytes, %s)", result.Removed, result.RemovedBytes, v.Mode)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect runs one pass, removing what it finds unless DryRun is set
+func (v *VideoCollector) Collect(ctx context.Context) (CollectResult, error) {
+	orphans, err := media.FindOrphans(ctx, v.DB, v.Store, time.Now().Add(-v.GracePeriod))
+	if err != nil {
+		return CollectResult{}, err
+	}
+
+	result := CollectResult{Orphans: orphans}
+	if v.DryRun {
+		return result, nil
+	}
+
+	// One stuck file shouldn't stop the rest from being collected
+	for _, o := range orphans {
+		removed, err := media.RemoveOrphan(ctx, v.DB, v.Store, o, v.Mode)
+		if err != nil {
+			log.Printf("Video collection: %s: %v", o.Filename, err)
+		}
+		if removed {
+			result.Removed++
+			result.RemovedBytes += o.SizeBytes
+		}
+	}
+	return result, nil
+}
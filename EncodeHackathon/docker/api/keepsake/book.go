@@ -0,0 +1,308 @@
+package keepsake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"aletterahead-api/pdf"
+	"aletterahead-api/qr"
+	"aletterahead-api/storage"
+)
+
+// BookOptions are what WriteBook needs from outside the keepsake
+type BookOptions struct {
+	// PhotoKey is the event photo's key in storage, "" when it has none (or it
+	// isn't an uploaded photo)
+	PhotoKey string
+	// VideoLink is the address a donation's video QR code opens, "" for no code
+	VideoLink func(Donation) string
+	// LinksExpire is when the video links stop working, printed under the
+	// codes; zero if they don't
+	LinksExpire time.Time
+}
+
+// maxBookImage is the largest photo or thumbnail put in a book (they are
+// resized copies, so this is only a guard)
+const maxBookImage = 10 * 1024 * 1024
+
+// Page layout, in points
+const (
+	bookMargin    = 56.0
+	bookWidth     = pdf.A4Width - 2*bookMargin
+	bookBottom    = pdf.A4Height - 64
+	messageSize   = 11.0
+	messageLeader = 15.0
+	qrWidth       = 100.0
+)
+
+var (
+	grey      = pdf.Color{R: 0.4, G: 0.4, B: 0.4}
+	lightGrey = pdf.Color{R: 0.82, G: 0.82, B: 0.82}
+	accent    = pdf.Color{R: 0.75, G: 0.33, B: 0.36}
+)
+
+// BookName is the name of an event's book file (e.g. "emmas-8th-birthday-book")
+func (k Keepsake) BookName() string {
+	name := k.ChildName
+	if k.Event != nil {
+		name = k.Event.EventName
+	}
+	if slug := slugify(name); slug != "" {
+		return slug + "-book"
+	}
+	return "book"
+}
+
+// WriteBook writes an event's keepsake to w as a printable A4 PDF: a cover with
+// the event's name, photo and message, then every approved donation's message
+// with the donor's name and the date. Videos are shown by their thumbnail and a
+// QR code linking to them. Photos and thumbnails that can't be read from store
+// are left out, and logged. The book is put together in memory before any of
+// it is written.
+func WriteBook(ctx context.Context, w io.Writer, store storage.Storage, k Keepsake, opts BookOptions) error {
+	if k.Event == nil {
+		return errors.New("a book is made for one event")
+	}
+
+	doc := pdf.New(k.Event.EventName)
+	doc.Author = "A Letter Ahead"
+	doc.Created = k.GeneratedAt
+
+	b := &book{ctx: ctx, doc: doc, store: store, opts: opts}
+	b.cover(k)
+	for i, d := range k.Donations {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.donation(d, k.ChildName, i == 0)
+	}
+	b.footers(k.Event.EventName)
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// book lays out pages one after another
+type book struct {
+	ctx   context.Context
+	doc   *pdf.Document
+	store storage.Storage
+	opts  BookOptions
+
+	page *pdf.Page
+	y    float64 // Where the next thing goes on page
+}
+
+// cover is the first page: the event's name, who it's for, its photo and message
+func (b *book) cover(k Keepsake) {
+	b.newPage()
+	b.y = 110
+
+	b.centered("A LETTER AHEAD", pdf.HelveticaBold, 9, accent)
+	b.y += 44
+	for _, line := range pdf.HelveticaBold.Wrap(k.Event.EventName, 28, bookWidth) {
+		b.centered(line, pdf.HelveticaBold, 28, pdf.Black)
+		b.y += 34
+	}
+	b.centered("For "+k.ChildName, pdf.Helvetica, 15, grey)
+	b.y += 30
+
+	if b.opts.PhotoKey != "" {
+		if img := b.image(b.opts.PhotoKey); img != nil {
+			w, h := img.Fit(bookWidth-60, 330)
+			b.page.Image(img, (pdf.A4Width-w)/2, b.y, w, h)
+			b.y += h + 36
+		}
+	}
+
+	if k.Event.Message != nil && strings.TrimSpace(*k.Event.Message) != "" {
+		for _, line := range pdf.HelveticaOblique.Wrap(*k.Event.Message, 13, bookWidth-60) {
+			if b.y > bookBottom-40 {
+				break
+			}
+			b.centered(line, pdf.HelveticaOblique, 13, pdf.Black)
+			b.y += 18
+		}
+		b.y += 18
+	}
+
+	summary := "No messages have been added yet"
+	if n := len(k.Donations); n > 0 {
+		summary = strconv.Itoa(n) + " message" + plural(n) + " from family and friends"
+	}
+	b.y = max(b.y, bookBottom-40)
+	b.centered(summary, pdf.Helvetica, 10, grey)
+}
+
+// donation adds one donation: the donor and date, the message, and the video
+// with its QR code. Sealed messages are only mentioned. Messages start on a new page, and are split across pages
+// only when they have to be.
+func (b *book) donation(d Donation, childName string, first bool) {
+	lines := []string{}
+	if d.Message != nil && strings.TrimSpace(*d.Message) != "" {
+		lines = pdf.Helvetica.Wrap(strings.TrimSpace(*d.Message), messageSize, bookWidth)
+	}
+	link := ""
+	if b.opts.VideoLink != nil && (d.Video != nil || d.VideoAddress != nil) {
+		link = b.opts.VideoLink(d)
+	}
+	videoHeight := 0.0
+	if link != "" {
+		videoHeight = qrWidth + 16
+	}
+
+	// Keep the donor with the start of their message, and short messages whole
+	header := 28.0
+	if d.Sealed {
+		header += messageLeader
+	}
+	together := header + float64(min(len(lines), 4))*messageLeader
+	if len(lines) <= 12 {
+		together = header + float64(len(lines))*messageLeader + videoHeight
+	}
+	if first {
+		b.newPage()
+	} else {
+		b.y += 18
+		if b.y+together > bookBottom {
+			b.newPage()
+		} else {
+			b.page.Line(bookMargin, b.y-9, pdf.A4Width-bookMargin, b.y-9, 0.5, lightGrey)
+			b.y += 9
+		}
+	}
+
+	b.y += 14
+	b.page.Text(bookMargin, b.y, pdf.HelveticaBold, 14, pdf.Black, d.DonorName)
+	date := d.CreatedAt.Format("2 January 2006")
+	b.page.Text(pdf.A4Width-bookMargin-pdf.Helvetica.Width(date, 10), b.y, pdf.Helvetica, 10, grey, date)
+	b.y += 14
+
+	for _, line := range lines {
+		if b.y+messageLeader > bookBottom {
+			b.newPage()
+		}
+		b.y += messageLeader
+		b.page.Text(bookMargin, b.y, pdf.Helvetica, messageSize, pdf.Black, line)
+	}
+	if d.Sealed {
+		b.y += messageLeader
+		b.page.Text(bookMargin, b.y, pdf.HelveticaOblique, messageSize, grey, "A sealed letter, kept for "+childName+"'s time capsule")
+	}
+
+	if link != "" {
+		b.video(d, link)
+	}
+}
+
+// video adds a donation's video thumbnail (when it has one) and a QR code
+// linking to the video
+func (b *book) video(d Donation, link string) {
+	code, err := qr.Encode(link)
+	if err != nil {
+		log.Printf("Book: no QR code for donation %d's video: %v", d.DonationID, err)
+		return
+	}
+
+	if b.y+12+qrWidth > bookBottom {
+		b.newPage()
+	}
+	b.y += 12
+	x := bookMargin
+
+	if d.Video != nil && d.Video.ThumbnailKey != nil {
+		if img := b.image(*d.Video.ThumbnailKey); img != nil {
+			w, h := img.Fit(150, qrWidth)
+			b.page.Image(img, x, b.y+(qrWidth-h)/2, w, h)
+			x += w + 12
+		}
+	}
+
+	// Readers need four modules of white around a code
+	module := qrWidth / float64(code.Size+8)
+	b.page.Modules(x+4*module, b.y+4*module, module, code.Size, code.Dark, pdf.Black)
+	x += qrWidth + 8
+
+	caption := "Scan to watch " + d.DonorName + "'s video"
+	b.page.Text(x, b.y+qrWidth/2-2, pdf.HelveticaBold, 10, pdf.Black, fitLine(caption, pdf.HelveticaBold, 10, pdf.A4Width-bookMargin-x))
+	if d.Video != nil && !b.opts.LinksExpire.IsZero() {
+		b.page.Text(x, b.y+qrWidth/2+12, pdf.Helvetica, 9, grey, "The link works until "+b.opts.LinksExpire.Format("2 January 2006"))
+	}
+	b.y += qrWidth
+}
+
+// footers numbers the pages after the cover
+func (b *book) footers(title string) {
+	pages := b.doc.Pages()
+	for i, page := range pages {
+		if i == 0 {
+			continue
+		}
+		number := fmt.Sprintf("Page %d of %d", i+1, len(pages))
+		y := pdf.A4Height - 36
+		page.Line(bookMargin, y-14, pdf.A4Width-bookMargin, y-14, 0.5, lightGrey)
+		page.Text(bookMargin, y, pdf.Helvetica, 8, grey, fitLine(title, pdf.Helvetica, 8, bookWidth-100))
+		page.Text(pdf.A4Width-bookMargin-pdf.Helvetica.Width(number, 8), y, pdf.Helvetica, 8, grey, number)
+	}
+}
+
+func (b *book) newPage() {
+	b.page = b.doc.AddPage()
+	b.y = bookMargin
+}
+
+// centered writes one line centred across the page at b.y
+func (b *book) centered(s string, font pdf.Font, size float64, c pdf.Color) {
+	b.page.Text((pdf.A4Width-font.Width(s, size))/2, b.y, font, size, c, s)
+}
+
+// image adds the JPEG at key to the document, or returns nil if it can't
+func (b *book) image(key string) *pdf.Image {
+	f, _, err := b.store.Open(b.ctx, key)
+	if err != nil {
+		log.Printf("Book: leaving out %s: %v", key, err)
+		return nil
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxBookImage+1))
+	if err == nil && len(data) > maxBookImage {
+		err = errors.New("too large")
+	}
+	if err != nil {
+		log.Printf("Book: leaving out %s: %v", key, err)
+		return nil
+	}
+
+	img, err := b.doc.AddJPEG(data)
+	if err != nil {
+		log.Printf("Book: leaving out %s: %v", key, err)
+		return nil
+	}
+	return img
+}
+
+// fitLine shortens s with an ellipsis until it fits in width
+func fitLine(s string, font pdf.Font, size, width float64) string {
+	if font.Width(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && font.Width(string(runes)+"…", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,118 @@
+package keepsake
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// indexTemplate is index.html: the keepsake to open in a browser, with the
+// videos played from the archive's videos/ folder
+var indexTemplate = template.Must(template.New("index.html").Funcs(template.FuncMap{
+	"pounds": pounds,
+	"lines":  func(s string) []string { return strings.Split(s, "\n") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+  body { font-family: Georgia, serif; max-width: 760px; margin: 2rem auto; padding: 0 1rem; color: #2b2b2b; background: #fdfaf5; }
+  h1 { font-weight: normal; margin-bottom: 0.2rem; }
+  .summary { color: #6b6b6b; margin-top: 0; }
+  .event { margin-top: 2.5rem; border-bottom: 1px solid #e6dfd3; }
+  .gift { background: #fff; border: 1px solid #e6dfd3; border-radius: 8px; padding: 1rem 1.25rem; margin: 1rem 0; }
+  .from { font-weight: bold; }
+  .meta { color: #6b6b6b; font-size: 0.9rem; }
+  .message p { margin: 0.4rem 0; }
+  video { width: 100%; margin-top: 0.75rem; border-radius: 6px; background: #000; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="summary">{{len .Donations}} {{if eq (len .Donations) 1}}gift{{else}}gifts{{end}} totalling {{pounds .TotalPence}}, kept {{.GeneratedAt.Format "2 January 2006"}}</p>
+{{range .Events}}
+<h2 class="event">{{.Event.EventName}}</h2>
+{{range .Gifts}}
+<div class="gift">
+  <div class="from">From {{.DonorName}}</div>
+  <div class="meta">{{pounds .AmountPence}} &middot; {{.CreatedAt.Format "2 January 2006"}}</div>
+  {{with .Message}}<div class="message">{{range lines .}}<p>{{.}}</p>{{end}}</div>{{end}}
+  {{if .Sealed}}<div class="meta">A sealed letter, kept for {{$.ChildName}}'s time capsule</div>{{end}}
+  {{with .File}}<video controls preload="metadata" src="{{.}}"></video>{{end}}
+  {{with .VideoAddress}}<div class="meta">Video: {{.}}</div>{{end}}
+</div>
+{{else}}
+<p class="meta">No gifts for this event.</p>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// indexGift is one donation on the page
+type indexGift struct {
+	DonorName    string
+	AmountPence  int
+	Message      string
+	Sealed       bool
+	CreatedAt    time.Time
+	File         string
+	VideoAddress string
+}
+
+// indexEvent is an event's donations on the page
+type indexEvent struct {
+	Event Event
+	Gifts []indexGift
+}
+
+// renderIndex writes index.html; files are the donations' video paths in the archive
+func renderIndex(w io.Writer, k Keepsake, files []string) error {
+	title := k.ChildName + "'s keepsake"
+	if k.Event != nil {
+		title = k.Event.EventName
+	}
+
+	events := make([]indexEvent, 0, len(k.Events))
+	byID := map[int]int{}
+	for _, e := range k.Events {
+		byID[e.EventID] = len(events)
+		events = append(events, indexEvent{Event: e})
+	}
+	for i, d := range k.Donations {
+		gift := indexGift{
+			DonorName:   d.DonorName,
+			AmountPence: d.AmountPence,
+			Sealed:      d.Sealed,
+			CreatedAt:   d.CreatedAt,
+			File:        files[i],
+		}
+		if d.Message != nil {
+			gift.Message = strings.TrimSpace(*d.Message)
+		}
+		if d.Video == nil && d.VideoAddress != nil {
+			gift.VideoAddress = *d.VideoAddress
+		}
+		if at, ok := byID[d.EventID]; ok {
+			events[at].Gifts = append(events[at].Gifts, gift)
+		}
+	}
+
+	return indexTemplate.Execute(w, map[string]any{
+		"Title":       title,
+		"ChildName":   k.ChildName,
+		"Donations":   k.Donations,
+		"TotalPence":  k.TotalPence(),
+		"GeneratedAt": k.GeneratedAt,
+		"Events":      events,
+	})
+}
+
+// pounds formats pence as pounds, e.g. 1250 as £12.50
+func pounds(pence int) string {
+	return fmt.Sprintf("£%d.%02d", pence/100, pence%100)
+}
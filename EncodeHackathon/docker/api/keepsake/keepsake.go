@@ -0,0 +1,264 @@
+// Package keepsake gathers everything collected for a child - each approved
+// donation's message, donor, amount, date and video - so it can be downloaded
+// as an archive to keep (see Write), or printed as a book (see WriteBook).
+package keepsake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound is returned when the child or event doesn't exist
+var ErrNotFound = errors.New("keepsake not found")
+
+// Scope is what a keepsake covers: one of the child's events, or all of them
+type Scope struct {
+	ChildID int
+	EventID int // When set, ChildID is ignored
+}
+
+// Keepsake is what has been collected for a child
+type Keepsake struct {
+	ChildID   int
+	ChildName string
+	DOB       time.Time
+	ParentID  int
+	// Event is set when the keepsake is for one event
+	Event       *Event
+	Events      []Event
+	Donations   []Donation
+	GeneratedAt time.Time
+}
+
+// Event is one of the child's events
+type Event struct {
+	EventID      int
+	EventName    string
+	Message      *string
+	PhotoAddress *string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// Donation is an approved donation's part of the keepsake
+type Donation struct {
+	DonationID  int
+	EventID     int
+	EventName   string
+	DonorName   string
+	Message     *string
+	Sealed      bool // A letter for the child alone: Message is nil, it is only opened in their time capsule
+	AmountPence int
+	CreatedAt   time.Time
+	// Video is the donation's uploaded video, nil if it has none (or it has been removed)
+	Video *Video
+	// VideoAddress is a video link given before videos were uploaded, which
+	// can't be put in the archive
+	VideoAddress *string
+}
+
+// Video is a donation's video file in storage
+type Video struct {
+	VideoID int
+	// Filename is the upload's, which the video is played by
+	Filename string
+	// Key is the file put in the archive: the transcoded MP4 when there is one,
+	// otherwise the upload
+	Key            string
+	Extension      string
+	MIMEType       string
+	ChecksumSHA256 string // Of the upload
+	Duration       *time.Duration
+	// ThumbnailKey is the video's poster frame (a JPEG), if one was made
+	ThumbnailKey *string
+	// SizeBytes is set by Write, from storage
+	SizeBytes int64
+}
+
+// Load gathers the keepsake for scope
+func Load(ctx context.Context, db *pgxpool.Pool, scope Scope) (Keepsake, error) {
+	k := Keepsake{GeneratedAt: time.Now().UTC()}
+
+	childQuery := `
+		SELECT c.child_id, c.child_name, c.DOB, c.parent_id
+		FROM children c
+		WHERE c.child_id = $1
+	`
+	childID := scope.ChildID
+	if scope.EventID != 0 {
+		var event Event
+		err := db.QueryRow(ctx,
+			`SELECT event_id, event_name, event_message, photo_address, created_at, expires_at, child_id FROM events WHERE event_id = $1`,
+			scope.EventID,
+		).Scan(&event.EventID, &event.EventName, &event.Message, &event.PhotoAddress, &event.CreatedAt, &event.ExpiresAt, &childID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Keepsake{}, ErrNotFound
+		}
+		if err != nil {
+			return Keepsake{}, fmt.Errorf("failed to load event %d: %w", scope.EventID, err)
+		}
+		k.Event = &event
+	}
+
+	err := db.QueryRow(ctx, childQuery, childID).Scan(&k.ChildID, &k.ChildName, &k.DOB, &k.ParentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Keepsake{}, ErrNotFound
+	}
+	if err != nil {
+		return Keepsake{}, fmt.Errorf("failed to load child %d: %w", childID, err)
+	}
+
+	eventsQuery := `
+		SELECT event_id, event_name, event_message, photo_address, created_at, expires_at
+		FROM events
+		WHERE child_id = $1
+		AND ($2 = 0 OR event_id = $2)
+		ORDER BY created_at, event_id
+	`
+	rows, err := db.Query(ctx, eventsQuery, k.ChildID, scope.EventID)
+	if err != nil {
+		return Keepsake{}, fmt.Errorf("failed to load events: %w", err)
+	}
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.EventID, &event.EventName, &event.Message, &event.PhotoAddress, &event.CreatedAt, &event.ExpiresAt); err != nil {
+			rows.Close()
+			return Keepsake{}, err
+		}
+		k.Events = append(k.Events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Keepsake{}, err
+	}
+
+	// Only videos still attached to their donation have files to put in. approved is
+	// the parent's own decision, so a donation captured by policy waits for their review
+	donationsQuery := `
+		SELECT
+			d.id, d.event_id, e.event_name, d.donor_name, d.message, d.sealed, d.amount_pence, d.created_at,
+			v.video_id, v.filename, v.mime_type, COALESCE(v.checksum_sha256, ''), v.duration_ms,
+			t.output_key, t.thumbnail_key,
+			CASE WHEN d.video_id IS NULL THEN d.video_address END
+		FROM donations d
+		JOIN events e ON e.event_id = d.event_id
+		LEFT JOIN videos v ON v.video_id = d.video_id AND v.status = 'attached'
+		LEFT JOIN video_transcodes t ON t.source_filename = v.filename AND t.status = 'ready'
+		WHERE e.child_id = $1
+		AND ($2 = 0 OR e.event_id = $2)
+		AND d.approved = true
+		ORDER BY d.created_at, d.id
+	`
+	rows, err = db.Query(ctx, donationsQuery, k.ChildID, scope.EventID)
+	if err != nil {
+		return Keepsake{}, fmt.Errorf("failed to load donations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d Donation
+		var videoID *int
+		var filename, mimeType *string
+		var checksum string
+		var durationMS *int64
+		var outputKey, thumbnailKey *string
+		if err := rows.Scan(
+			&d.DonationID,
+			&d.EventID,
+			&d.EventName,
+			&d.DonorName,
+			&d.Message,
+			&d.Sealed,
+			&d.AmountPence,
+			&d.CreatedAt,
+			&videoID,
+			&filename,
+			&mimeType,
+			&checksum,
+			&durationMS,
+			&outputKey,
+			&thumbnailKey,
+			&d.VideoAddress,
+		); err != nil {
+			return Keepsake{}, err
+		}
+
+		if videoID != nil && filename != nil {
+			video := &Video{
+				VideoID:        *videoID,
+				Filename:       *filename,
+				Key:            "videos/" + *filename,
+				Extension:      strings.ToLower(path.Ext(*filename)),
+				ChecksumSHA256: checksum,
+				ThumbnailKey:   thumbnailKey,
+			}
+			if mimeType != nil {
+				video.MIMEType = *mimeType
+			}
+			if outputKey != nil {
+				video.Key, video.Extension, video.MIMEType = *outputKey, ".mp4", "video/mp4"
+			}
+			if durationMS != nil {
+				duration := time.Duration(*durationMS) * time.Millisecond
+				video.Duration = &duration
+			}
+			d.Video = video
+		}
+		k.Donations = append(k.Donations, d)
+	}
+	return k, rows.Err()
+}
+
+// Name is the keepsake's name, used for the archive and the folder in it
+// (e.g. "emma-keepsake" or "emma-8th-birthday-keepsake")
+func (k Keepsake) Name() string {
+	name := k.ChildName
+	if k.Event != nil {
+		name = k.Event.EventName
+	}
+	if slug := slugify(name); slug != "" {
+		return slug + "-keepsake"
+	}
+	return "keepsake"
+}
+
+// TotalPence adds up the donations
+func (k Keepsake) TotalPence() int {
+	total := 0
+	for _, d := range k.Donations {
+		total += d.AmountPence
+	}
+	return total
+}
+
+// slugify makes s safe for a filename: lower case letters and digits joined by
+// hyphens ("Emma's 8th Birthday" becomes "emmas-8th-birthday")
+func slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == '\'' || r == '’':
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		default:
+			hyphen = true
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,241 @@
+package keepsake
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"aletterahead-api/storage"
+)
+
+// ManifestVersion is the version of manifest.json's layout
+const ManifestVersion = 1
+
+// Manifest is manifest.json: the keepsake for programs to read
+type Manifest struct {
+	Format      string             `json:"format"`
+	Version     int                `json:"version"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Child       ManifestChild      `json:"child"`
+	Event       *ManifestEvent     `json:"event"` // Set when the keepsake is for one event
+	Events      []ManifestEvent    `json:"events"`
+	Donations   []ManifestDonation `json:"donations"`
+	Totals      ManifestTotals     `json:"totals"`
+}
+
+// ManifestChild is the child the keepsake is for
+type ManifestChild struct {
+	ChildID   int    `json:"child_id"`
+	ChildName string `json:"child_name"`
+	DOB       string `json:"dob"` // YYYY-MM-DD
+}
+
+// ManifestEvent is one of the child's events
+type ManifestEvent struct {
+	EventID   int       `json:"event_id"`
+	EventName string    `json:"event_name"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt string    `json:"expires_at"` // YYYY-MM-DD
+}
+
+// ManifestDonation is one approved donation
+type ManifestDonation struct {
+	DonationID  int            `json:"donation_id"`
+	EventID     int            `json:"event_id"`
+	DonorName   string         `json:"donor_name"`
+	Message     *string        `json:"message"`
+	Sealed      bool           `json:"sealed"` // A letter only the child reads, in their time capsule; message is null
+	AmountPence int            `json:"amount_pence"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Video       *ManifestVideo `json:"video"`
+	// VideoAddress is a video link given before videos were uploaded; its file isn't in the archive
+	VideoAddress *string `json:"video_address,omitempty"`
+}
+
+// ManifestVideo is a video file in the archive
+type ManifestVideo struct {
+	VideoID        int      `json:"video_id"`
+	File           string   `json:"file"` // Path in the archive
+	MIMEType       string   `json:"mime_type"`
+	SizeBytes      int64    `json:"size_bytes"`
+	DurationSec    *float64 `json:"duration_seconds"`
+	ChecksumSHA256 string   `json:"upload_checksum_sha256"` // Of the file as uploaded, before transcoding
+}
+
+// ManifestTotals sums up the donations
+type ManifestTotals struct {
+	Donations   int `json:"donations"`
+	AmountPence int `json:"amount_pence"`
+	Videos      int `json:"videos"`
+}
+
+// Write streams the keepsake to w as a ZIP: a folder named k.Name() holding
+// index.html, manifest.json and videos/. Videos are copied from store one at a
+// time without being compressed or held in memory. Videos missing from store
+// are left out, and logged. If it fails part way through, what was written is
+// not a valid archive.
+func Write(ctx context.Context, w io.Writer, store storage.Storage, k Keepsake) error {
+	k.Donations = append([]Donation(nil), k.Donations...)
+	files := make([]string, len(k.Donations))
+	for i, d := range k.Donations {
+		if d.Video == nil {
+			continue
+		}
+		obj, err := store.Stat(ctx, d.Video.Key)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Keepsake for child %d: video %s is missing, leaving it out", k.ChildID, d.Video.Key)
+			k.Donations[i].Video = nil
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find video %s: %w", d.Video.Key, err)
+		}
+		d.Video.SizeBytes = obj.Size
+		files[i] = fmt.Sprintf("videos/%03d-%s%s", i+1, slugOr(d.DonorName, "video"), d.Video.Extension)
+	}
+
+	folder := k.Name() + "/"
+	zw := zip.NewWriter(w)
+
+	index, err := create(zw, folder+"index.html", k.GeneratedAt, zip.Deflate)
+	if err != nil {
+		return err
+	}
+	if err := renderIndex(index, k, files); err != nil {
+		return fmt.Errorf("failed to write index.html: %w", err)
+	}
+
+	manifest, err := create(zw, folder+"manifest.json", k.GeneratedAt, zip.Deflate)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(manifest)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(buildManifest(k, files)); err != nil {
+		return fmt.Errorf("failed to write manifest.json: %w", err)
+	}
+
+	// Videos are already compressed, so they are stored as they are
+	for i, d := range k.Donations {
+		if d.Video == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := copyVideo(ctx, zw, store, folder+files[i], d); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// copyVideo streams one donation's video into the archive
+func copyVideo(ctx context.Context, zw *zip.Writer, store storage.Storage, name string, d Donation) error {
+	file, _, err := store.Open(ctx, d.Video.Key)
+	if err != nil {
+		return fmt.Errorf("failed to open video %s: %w", d.Video.Key, err)
+	}
+	defer file.Close()
+
+	entry, err := create(zw, name, d.CreatedAt, zip.Store)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, file); err != nil {
+		return fmt.Errorf("failed to copy video %s: %w", d.Video.Key, err)
+	}
+	return nil
+}
+
+// create starts an entry in the archive
+func create(zw *zip.Writer, name string, modified time.Time, method uint16) (io.Writer, error) {
+	entry, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: modified,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return entry, nil
+}
+
+// buildManifest describes the keepsake; files are the donations' video paths
+// in the archive (relative to its folder)
+func buildManifest(k Keepsake, files []string) Manifest {
+	m := Manifest{
+		Format:      "aletterahead-keepsake",
+		Version:     ManifestVersion,
+		GeneratedAt: k.GeneratedAt,
+		Child: ManifestChild{
+			ChildID:   k.ChildID,
+			ChildName: k.ChildName,
+			DOB:       k.DOB.Format("2006-01-02"),
+		},
+		Events:    []ManifestEvent{},
+		Donations: []ManifestDonation{},
+	}
+	if k.Event != nil {
+		event := manifestEvent(*k.Event)
+		m.Event = &event
+	}
+	for _, e := range k.Events {
+		m.Events = append(m.Events, manifestEvent(e))
+	}
+
+	for i, d := range k.Donations {
+		md := ManifestDonation{
+			DonationID:   d.DonationID,
+			EventID:      d.EventID,
+			DonorName:    d.DonorName,
+			Message:      d.Message,
+			Sealed:       d.Sealed,
+			AmountPence:  d.AmountPence,
+			CreatedAt:    d.CreatedAt,
+			VideoAddress: d.VideoAddress,
+		}
+		if d.Video != nil {
+			md.Video = &ManifestVideo{
+				VideoID:        d.Video.VideoID,
+				File:           files[i],
+				MIMEType:       d.Video.MIMEType,
+				SizeBytes:      d.Video.SizeBytes,
+				ChecksumSHA256: d.Video.ChecksumSHA256,
+			}
+			if d.Video.Duration != nil {
+				seconds := d.Video.Duration.Seconds()
+				md.Video.DurationSec = &seconds
+			}
+			m.Totals.Videos++
+		}
+		m.Donations = append(m.Donations, md)
+		m.Totals.AmountPence += d.AmountPence
+	}
+	m.Totals.Donations = len(k.Donations)
+	return m
+}
+
+func manifestEvent(e Event) ManifestEvent {
+	return ManifestEvent{
+		EventID:   e.EventID,
+		EventName: e.EventName,
+		CreatedAt: e.CreatedAt,
+		ExpiresAt: e.ExpiresAt.Format("2006-01-02"),
+	}
+}
+
+// slugOr is slugify(s), or fallback when nothing is left of s
+func slugOr(s, fallback string) string {
+	if slug := slugify(s); slug != "" {
+		return slug
+	}
+	return fallback
+}
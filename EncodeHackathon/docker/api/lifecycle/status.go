@@ -0,0 +1,122 @@
+// Package lifecycle owns a donation's status and the transitions between statuses.
+// Handlers and jobs never write donations.status directly; they call Transition,
+// which checks the move is allowed and records it in donation_status_history.
+package lifecycle
+
+import "fmt"
+
+// Status is where a donation is in its lifecycle
+type Status string
+
+const (
+	// AwaitingPayment: created, the donor hasn't finished paying yet
+	AwaitingPayment Status = "awaiting_payment"
+	// PendingReview: the donor's card is authorised, waiting for the parent
+	PendingReview Status = "pending_review"
+	// Approved: the parent approved it while the payment is held; it is captured later
+	Approved Status = "approved"
+	// Captured: the money has been taken and sent to the parent's account. The event's
+	// policy can capture a donation before the parent reviews it; it isn't approved until they do
+	Captured Status = "captured"
+	// Rejected: the parent rejected it before any money was taken
+	Rejected Status = "rejected"
+	// PaymentFailed: the donor's payment was declined (they may retry)
+	PaymentFailed Status = "payment_failed"
+	// Refunded: captured money was given back to the donor
+	Refunded Status = "refunded"
+	// Disputed: the donor's bank is disputing a captured payment
+	Disputed Status = "disputed"
+	// Expired: the payment was cancelled or its authorisation lapsed without a decision
+	Expired Status = "expired"
+)
+
+// All lists every status, in the order they are reported
+var All = []Status{
+	AwaitingPayment,
+	PendingReview,
+	Approved,
+	Captured,
+	Rejected,
+	PaymentFailed,
+	Refunded,
+	Disputed,
+	Expired,
+}
+
+// transitions lists the statuses each status may move to
+var transitions = map[Status][]Status{
+	AwaitingPayment: {PendingReview, Rejected, PaymentFailed, Expired},
+	PaymentFailed:   {PendingReview, Rejected, Expired},
+	PendingReview:   {Approved, Captured, Rejected, Expired},
+	Approved:        {Captured, Rejected, PaymentFailed, Expired},
+	Captured:        {Refunded, Disputed},
+	Disputed:        {Captured, Refunded},
+	Expired:         {Rejected},
+	Rejected:        {},
+	Refunded:        {},
+}
+
+// CanTransition reports whether a donation may move from one status to another
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepsApproval reports whether a parent's approval still stands in this status: the
+// money is held or taken. Moving anywhere else withdraws it.
+func (s Status) KeepsApproval() bool {
+	return s == Approved || s == Captured || s == Disputed
+}
+
+// Approvable reports whether the parent can approve a donation in this status.
+// Only a held payment can be approved; the donor may still abandon an unpaid one.
+// A donation captured by the event's policy still needs the parent's review.
+func (s Status) Approvable() bool {
+	return s == PendingReview || s == Captured
+}
+
+// AwaitingDecision reports whether the parent still needs to approve or reject a
+// donation in this status that they haven't approved
+func (s Status) AwaitingDecision(approved bool) bool {
+	return !approved && (s == AwaitingPayment || s == PendingReview || s == PaymentFailed || s == Captured)
+}
+
+// Discarded reports whether the donation ended without reaching the child: the
+// parent rejected it, its money was given back or it expired. None can be approved again.
+func (s Status) Discarded() bool {
+	return s == Rejected || s == Refunded || s == Expired
+}
+
+// InvalidTransitionError is returned when a transition isn't allowed
+type InvalidTransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("donation cannot move from %s to %s", e.From, e.To)
+}
+
+// Actors recorded in the status history
+
+// ParentActor is a parent acting through the portal
+func ParentActor(parentID int) string {
+	return fmt.Sprintf("parent:%d", parentID)
+}
+
+// StripeActor is a Stripe webhook event
+func StripeActor(eventID string) string {
+	return "stripe:" + eventID
+}
+
+// SystemActor is a background job or the API itself
+func SystemActor(name string) string {
+	return "system:" + name
+}
+
+// DonorActor is the person making the donation
+const DonorActor = "donor"
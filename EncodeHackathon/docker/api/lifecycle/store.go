@@ -0,0 +1,115 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound is returned when the donation doesn't exist
+var ErrNotFound = errors.New("donation not found")
+
+// Created records the initial status of a newly inserted donation
+func Created(ctx context.Context, db *pgxpool.Pool, donationID int, actor string) error {
+	insertQuery := `
+		INSERT INTO donation_status_history (donation_id, from_status, to_status, actor, reason)
+		SELECT id, NULL, status, $2, 'donation created'
+		FROM donations
+		WHERE id = $1
+	`
+
+	_, err := db.Exec(ctx, insertQuery, donationID, actor)
+	return err
+}
+
+// Transition moves a donation to a new status and records who did it.
+// Moving to the status it already has is a no-op. It returns the previous status,
+// or an *InvalidTransitionError if the move isn't allowed.
+// Only Approve marks a donation approved; Transition withdraws the parent's approval
+// when the donation leaves the statuses that keep it.
+func Transition(ctx context.Context, db *pgxpool.Pool, donationID int, to Status, actor string, reason string) (Status, error) {
+	return transition(ctx, db, donationID, to, actor, reason, false)
+}
+
+// Approve records the parent's approval of a donation as it moves to a status that
+// keeps it (Approved, or Captured when the money is taken straight away). A donation
+// the event's policy already captured is approved where it is.
+func Approve(ctx context.Context, db *pgxpool.Pool, donationID int, to Status, parentID int, reason string) (Status, error) {
+	if !to.KeepsApproval() {
+		return "", fmt.Errorf("a donation cannot be approved into %s", to)
+	}
+	return transition(ctx, db, donationID, to, ParentActor(parentID), reason, true)
+}
+
+func transition(ctx context.Context, db *pgxpool.Pool, donationID int, to Status, actor string, reason string, approve bool) (Status, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the row so concurrent webhooks/handlers apply transitions one at a time
+	var from Status
+	err = tx.QueryRow(ctx, `SELECT status FROM donations WHERE id = $1 FOR UPDATE`, donationID).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read donation status: %w", err)
+	}
+
+	if from == to && !approve {
+		return from, nil
+	}
+	if from != to && !CanTransition(from, to) {
+		return from, &InvalidTransitionError{From: from, To: to}
+	}
+
+	// captured_at keeps the first capture (a won dispute returns to captured)
+	updateQuery := `
+		UPDATE donations
+		SET status = $1,
+			approved = $2 OR (approved AND $3),
+			approved_at = CASE WHEN $2 THEN NOW() ELSE approved_at END,
+			captured_at = CASE WHEN $1 = 'captured' THEN COALESCE(captured_at, NOW()) ELSE captured_at END,
+			updated_at = NOW()
+		WHERE id = $4
+	`
+	if _, err := tx.Exec(ctx, updateQuery, to, approve, to.KeepsApproval(), donationID); err != nil {
+		return from, fmt.Errorf("failed to update donation status: %w", err)
+	}
+
+	historyQuery := `
+		INSERT INTO donation_status_history (donation_id, from_status, to_status, actor, reason)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := tx.Exec(ctx, historyQuery, donationID, from, to, actor, reason); err != nil {
+		return from, fmt.Errorf("failed to record status history: %w", err)
+	}
+
+	return from, tx.Commit(ctx)
+}
+
+// TransitionPayment is Transition for the donation paid by a Stripe PaymentIntent.
+// Stripe events can arrive late or out of order, so a transition that is no longer
+// allowed (or a PaymentIntent we don't know) is ignored rather than reported.
+func TransitionPayment(ctx context.Context, db *pgxpool.Pool, paymentIntentID string, to Status, actor string, reason string) error {
+	var donationID int
+	err := db.QueryRow(ctx, `SELECT id FROM donations WHERE payment_intent_id = $1`, paymentIntentID).Scan(&donationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up donation: %w", err)
+	}
+
+	_, err = Transition(ctx, db, donationID, to, actor, reason)
+	var invalid *InvalidTransitionError
+	if errors.As(err, &invalid) {
+		return nil
+	}
+	return err
+}
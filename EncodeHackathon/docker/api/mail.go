@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"aletterahead-api/handlers"
+	"aletterahead-api/mailer"
+	"aletterahead-api/media"
+)
+
+// InitMailer reads the SMTP server the API sends its own emails through.
+// Without SMTP_HOST it returns nil and nothing is emailed.
+func InitMailer() mailer.Sender {
+	host := getEnv("SMTP_HOST", "")
+	if host == "" {
+		log.Printf("SMTP_HOST is not set, time capsules will not be delivered")
+		return nil
+	}
+
+	port, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil || port <= 0 {
+		log.Printf("Invalid SMTP_PORT %q, using 587", getEnv("SMTP_PORT", ""))
+		port = 587
+	}
+
+	return mailer.SMTP{
+		Host:     host,
+		Port:     port,
+		Username: getEnv("SMTP_USERNAME", ""),
+		Password: getEnv("SMTP_PASSWORD", ""),
+		From:     getEnv("MAIL_FROM", "A Letter Ahead <hello@aletterahead.com>"),
+		Timeout:  getDurationEnv("SMTP_TIMEOUT", 30*time.Second),
+	}
+}
+
+// InitCapsuleLinks reads how long the link in a time capsule email works. It is
+// signed with the video link key, so VIDEO_LINK_SECRET must be set for links to
+// outlive a restart.
+func InitCapsuleLinks(videos handlers.VideoConfig) media.LinkSigner {
+	return media.LinkSigner{
+		Secret: videos.Links.Secret,
+		TTL:    getDurationEnv("CAPSULE_LINK_TTL", 365*24*time.Hour),
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strings"
 
 	"aletterahead-api/handlers"
 
@@ -23,16 +25,90 @@ func main() {
 	}
 	defer db.Close()
 
+	// `main migrate ...` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatal("Migration failed:", err)
+		}
+		return
+	}
+
+	// `main gc ...` removes orphaned videos once and exits
+	if len(os.Args) > 1 && os.Args[1] == "gc" {
+		if err := runGC(db, os.Args[2:]); err != nil {
+			log.Fatal("Video collection failed:", err)
+		}
+		return
+	}
+
+	// `main capsules ...` delivers due time capsules once and exits
+	if len(os.Args) > 1 && os.Args[1] == "capsules" {
+		if err := runCapsules(db, os.Args[2:]); err != nil {
+			log.Fatal("Capsule delivery failed:", err)
+		}
+		return
+	}
+
+	// `main webhook-replay ...` applies stored Stripe events that failed and exits
+	if len(os.Args) > 1 && os.Args[1] == "webhook-replay" {
+		if err := runWebhookReplay(db, os.Args[2:]); err != nil {
+			log.Fatal("Webhook replay failed:", err)
+		}
+		return
+	}
+
+	// `main export ...` writes a child's keepsake ZIP and exits
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(db, os.Args[2:]); err != nil {
+			log.Fatal("Export failed:", err)
+		}
+		return
+	}
+
+	// Apply pending schema migrations
+	if err := migrateOnStartup(db); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	// Initialize Stripe client
+	sc := InitStripe()
+	connect := InitConnect()
+	allowance := InitAllowance()
+
+	// Initialize upload storage
+	store, err := InitStorage()
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+	scanner := InitScanner()
+	publicBaseURL := InitPublicBaseURL()
+	videos := InitVideos(store, scanner, publicBaseURL)
+	tus := InitTus()
+	photos := InitPhotos(store, scanner, publicBaseURL)
+	mail := InitMailer()
+	capsuleLinks := InitCapsuleLinks(videos)
+	bookLinks := InitBookLinks()
+	sealedKeys := InitSealedMessages()
+
+	// Initialize Auth0 token verification
+	verifier, err := InitAuth()
+	if err != nil {
+		log.Fatal("Failed to initialize auth:", err)
+	}
+
 	// Initialize router
 	r := gin.Default()
 
 	// Add CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Defer-Length")
+		// Let browser tus clients read the upload headers
+		c.Header("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires, Video-Id, Video-Url")
 
-		if c.Request.Method == "OPTIONS" {
+		// tus clients send OPTIONS to discover the server's capabilities
+		if c.Request.Method == "OPTIONS" && !strings.HasPrefix(c.Request.URL.Path, "/api/uploads/tus") {
 			c.AbortWithStatus(204)
 			return
 		}
@@ -51,29 +127,52 @@ func main() {
 	// API routes
 	api := r.Group("/api")
 	{
-		// Events routes
+		// Public routes (donations page, video playback, event photos, time capsules, Stripe)
 		api.POST("/events/request", handlers.RequestEvent(db))
-		api.POST("/events/list", handlers.GetEvents(db))
-		api.POST("/events/create", handlers.CreateEvent(db))
-		api.POST("/donations/create", handlers.CreateDonation(db))
-		api.POST("/donations/list", handlers.ListDonations(db))
-		api.POST("/donations/approve", handlers.ApproveDonation(db))
-		api.POST("/uploads/video", handlers.UploadVideo)
-		api.POST("/children/list", handlers.GetChildren(db))
-		api.POST("/children/create", handlers.CreateChild(db))
-		api.POST("/parents/create", handlers.CreateParent(db))
-		api.POST("/parents/get", handlers.GetParent(db))
-		api.POST("/payments/save-account", handlers.SaveStripeAccount(db))
-		api.POST("/payments/onboarding-complete", handlers.UpdateOnboardingStatus(db))
-		api.POST("/payments/status", handlers.GetPaymentAccounts(db))
-		api.GET("/videos/:filename", handlers.GetVideo)
-		// Future endpoints will follow this pattern:
-		// api.POST("/donations/create", createDonation(db))
-		// api.POST("/donations/list", listDonations(db))
-		// api.POST("/children/create", createChild(db))
-		// etc.
+		api.POST("/donations/create", handlers.CreateDonation(db, sc, allowance, sealedKeys))
+		api.POST("/uploads/video", handlers.UploadVideo(db, videos))
+		api.OPTIONS("/uploads/tus", handlers.TusOptions(tus))
+		api.POST("/uploads/tus", handlers.CreateTusUpload(db, videos, tus))
+		api.OPTIONS("/uploads/tus/:id", handlers.TusOptions(tus))
+		api.HEAD("/uploads/tus/:id", handlers.HeadTusUpload(db, videos))
+		api.GET("/uploads/tus/:id", handlers.GetTusUpload(db, videos))
+		api.PATCH("/uploads/tus/:id", handlers.PatchTusUpload(db, videos, tus))
+		api.DELETE("/uploads/tus/:id", handlers.DeleteTusUpload(db, videos))
+		api.GET("/videos/:filename", handlers.GetVideo(db, videos))
+		api.HEAD("/videos/:filename", handlers.GetVideo(db, videos))
+		api.GET("/videos/:filename/thumbnail", handlers.GetVideoThumbnail(db, videos))
+		api.HEAD("/videos/:filename/thumbnail", handlers.GetVideoThumbnail(db, videos))
+		api.GET("/photos/:filename", handlers.GetPhoto(photos))
+		api.HEAD("/photos/:filename", handlers.GetPhoto(photos))
+		api.GET("/capsules/:id", handlers.GetCapsule(db, capsuleLinks, videos, sealedKeys))
+		api.POST("/payments/webhook", handlers.StripeWebhook(db, getEnv("STRIPE_WEBHOOK_SECRET", "")))
+
+		// Parent routes - require an Auth0 access token, the parent comes from its "sub"
+		parent := api.Group("", verifier.Middleware())
+		parent.POST("/events/list", handlers.GetEvents(db))
+		parent.POST("/events/create", handlers.CreateEvent(db))
+		parent.POST("/events/update", handlers.UpdateEvent(db))
+		parent.POST("/events/cancel", handlers.CancelEvent(db, sc))
+		parent.POST("/events/book", handlers.EventBook(db, videos, bookLinks))
+		parent.POST("/uploads/photo", handlers.UploadPhoto(db, photos))
+		parent.POST("/donations/list", handlers.ListDonations(db, videos))
+		parent.POST("/donations/approve", handlers.ApproveDonation(db, sc))
+		parent.POST("/children/list", handlers.GetChildren(db))
+		parent.POST("/children/create", handlers.CreateChild(db))
+		parent.POST("/children/allowance", handlers.GetAllowance(db, allowance))
+		parent.POST("/children/delivery", handlers.SetDeliveryDate(db))
+		parent.POST("/children/export", handlers.ExportKeepsake(db, videos))
+		parent.POST("/parents/create", handlers.CreateParent(db))
+		parent.POST("/parents/get", handlers.GetParent(db))
+		parent.POST("/payments/create-account", handlers.CreateStripeAccount(db, sc, connect))
+		parent.POST("/payments/onboarding-link", handlers.ResumeOnboarding(db, sc, connect))
+		parent.POST("/payments/status", handlers.GetPaymentAccounts(db, sc))
+		parent.POST("/notifications/list", handlers.ListNotifications(db))
 	}
 
+	// Start background jobs
+	StartJobs(context.Background(), db, sc, store, mail, capsuleLinks)
+
 	// Get port from env or default to 8080
 	port := os.Getenv("PORT")
 	if port == "" {
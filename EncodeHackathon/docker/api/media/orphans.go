@@ -0,0 +1,263 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aletterahead-api/lifecycle"
+	"aletterahead-api/storage"
+	"aletterahead-api/transcode"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OrphanReason says why a stored video is no longer needed
+type OrphanReason string
+
+const (
+	// NeverAttached videos were uploaded but no donation took them within the grace period
+	NeverAttached OrphanReason = "never_attached"
+	// DonationRejected videos belong to a donation the parent rejected
+	DonationRejected OrphanReason = "donation_rejected"
+	// DonationRefunded videos belong to a donation whose money was given back
+	DonationRefunded OrphanReason = "donation_refunded"
+	// DonationExpired videos belong to a donation that expired without a decision
+	DonationExpired OrphanReason = "donation_expired"
+	// Unrecorded files are in storage with no video record or donation referring to them
+	Unrecorded OrphanReason = "unrecorded"
+)
+
+// RemoveMode is what happens to an orphaned video's files
+type RemoveMode string
+
+const (
+	// RemoveDelete deletes the files for good
+	RemoveDelete RemoveMode = "delete"
+	// RemoveQuarantine moves the files under quarantine/, to be checked (and
+	// deleted, or restored) by hand
+	RemoveQuarantine RemoveMode = "quarantine"
+)
+
+// Orphan is a stored video that can be removed
+type Orphan struct {
+	VideoID  *int // nil for Unrecorded files
+	Filename string
+	Reason   OrphanReason
+	// Since is when the video stopped being needed (its upload, or when the donation ended)
+	Since time.Time
+	// Keys are the video's stored files: the upload, its transcoded copy and thumbnail
+	Keys []string
+	// SizeBytes is the total size of Keys
+	SizeBytes int64
+}
+
+// QuarantineKey is where a quarantined file is moved to
+func QuarantineKey(key string) string {
+	return "quarantine/" + key
+}
+
+// FindOrphans lists the videos that stopped being needed before cutoff: ones no
+// donation took, ones whose donation was discarded (rejected, refunded or
+// expired), and files in storage that nothing refers to at all. Videos still
+// being transcoded are left alone.
+func FindOrphans(ctx context.Context, db *pgxpool.Pool, store storage.Storage, cutoff time.Time) ([]Orphan, error) {
+	query := `
+		SELECT v.video_id, v.filename, $2::TEXT, v.created_at
+		FROM videos v
+		WHERE v.status = $3
+		AND v.created_at < $1
+		AND NOT EXISTS (SELECT 1 FROM donations d WHERE d.video_id = v.video_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM video_transcodes t
+			WHERE t.source_filename = v.filename
+			AND t.status IN ('queued', 'processing')
+		)
+
+		UNION ALL
+
+		-- The reason is named after the status: donation_rejected, _refunded or _expired
+		SELECT v.video_id, v.filename, 'donation_' || d.status, COALESCE(d.updated_at, d.created_at)
+		FROM videos v
+		JOIN donations d ON d.video_id = v.video_id
+		WHERE v.status = $4
+		AND d.status = ANY($5)
+		AND COALESCE(d.updated_at, d.created_at) < $1
+
+		ORDER BY 4
+	`
+
+	var discarded []string
+	for _, status := range lifecycle.All {
+		if status.Discarded() {
+			discarded = append(discarded, string(status))
+		}
+	}
+
+	rows, err := db.Query(ctx, query, cutoff, NeverAttached, Uploaded, Attached, discarded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orphaned videos: %w", err)
+	}
+
+	var orphans []Orphan
+	for rows.Next() {
+		var o Orphan
+		var videoID int
+		if err := rows.Scan(&videoID, &o.Filename, &o.Reason, &o.Since); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan orphaned video: %w", err)
+		}
+		o.VideoID = &videoID
+		orphans = append(orphans, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orphaned videos: %w", err)
+	}
+
+	unrecorded, err := findUnrecorded(ctx, db, store, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, unrecorded...)
+
+	for i := range orphans {
+		if err := orphans[i].stat(ctx, store); err != nil {
+			return nil, err
+		}
+	}
+	return orphans, nil
+}
+
+// findUnrecorded lists uploads in storage that no video record or donation
+// refers to, such as files left behind by a failed upload
+func findUnrecorded(ctx context.Context, db *pgxpool.Pool, store storage.Storage, cutoff time.Time) ([]Orphan, error) {
+	objects, err := store.List(ctx, transcode.SourceKey(""))
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	query := `
+		SELECT filename FROM videos
+		UNION
+		SELECT substring(video_address from '/api/videos/([^/?#]+)$')
+		FROM donations
+		WHERE video_address ~ '/api/videos/[^/?#]+$'
+	`
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recorded videos: %w", err)
+	}
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan recorded video: %w", err)
+		}
+		known[filename] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recorded videos: %w", err)
+	}
+
+	var orphans []Orphan
+	for _, obj := range objects {
+		// Transcoded copies and thumbnails are in sub-folders and go with their upload
+		filename := strings.TrimPrefix(obj.Key, transcode.SourceKey(""))
+		if strings.Contains(filename, "/") || known[filename] || !obj.ModTime.Before(cutoff) {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Filename: filename,
+			Reason:   Unrecorded,
+			Since:    obj.ModTime,
+		})
+	}
+	return orphans, nil
+}
+
+// stat fills in the orphan's stored files and their size
+func (o *Orphan) stat(ctx context.Context, store storage.Storage) error {
+	o.Keys, o.SizeBytes = nil, 0
+	for _, key := range []string{transcode.SourceKey(o.Filename), transcode.OutputKey(o.Filename), transcode.ThumbnailKey(o.Filename)} {
+		obj, err := store.Stat(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", key, err)
+		}
+		o.Keys = append(o.Keys, key)
+		o.SizeBytes += obj.Size
+	}
+	return nil
+}
+
+// RemoveOrphan deletes or quarantines an orphaned video's files and marks its
+// record. It returns false, without touching anything, if the video was
+// attached to a donation since it was found.
+func RemoveOrphan(ctx context.Context, db *pgxpool.Pool, store storage.Storage, o Orphan, mode RemoveMode) (bool, error) {
+	status := Deleted
+	if mode == RemoveQuarantine {
+		status = Quarantined
+	}
+
+	// Mark the record first, so the video can't be attached while its files go
+	if o.VideoID != nil {
+		from := Uploaded
+		if o.Reason != NeverAttached {
+			from = Attached
+		}
+
+		updateQuery := `
+			UPDATE videos
+			SET status = $1, removed_at = NOW(), removed_reason = $2
+			WHERE video_id = $3
+			AND status = $4
+		`
+		tag, err := db.Exec(ctx, updateQuery, status, o.Reason, *o.VideoID, from)
+		if err != nil {
+			return false, fmt.Errorf("failed to mark video %d %s: %w", *o.VideoID, status, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return false, nil
+		}
+	}
+
+	for _, key := range o.Keys {
+		if mode == RemoveQuarantine {
+			if err := move(ctx, store, key, QuarantineKey(key)); err != nil {
+				return true, err
+			}
+			continue
+		}
+		if err := store.Delete(ctx, key); err != nil {
+			return true, fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+	return true, nil
+}
+
+// move copies an object to a new key and deletes the original
+func move(ctx context.Context, store storage.Storage, from, to string) error {
+	r, obj, err := store.Open(ctx, from)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", from, err)
+	}
+	defer r.Close()
+
+	if err := store.Put(ctx, to, r, obj.Size, obj.ContentType); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", from, to, err)
+	}
+	if err := store.Delete(ctx, from); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", from, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// photoTypes are the image formats we accept, detected from the file's content
+var photoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+	"image/heic": true,
+	"image/heif": true,
+}
+
+// ErrPhotoNotFound is returned when the photo doesn't exist
+var ErrPhotoNotFound = errors.New("photo not found")
+
+// PhotoInfo describes an uploaded photo
+type PhotoInfo struct {
+	MIMEType string
+	// Width and Height are as stored, before Orientation is applied (0 for HEIC,
+	// which is measured once it has been decoded)
+	Width  int
+	Height int
+	// Orientation is the EXIF orientation (1-8) of a JPEG, 1 when it has none
+	Orientation int
+}
+
+// IsHEIF reports whether the photo is HEIC/HEIF, which ffmpeg can't decode
+func (i PhotoInfo) IsHEIF() bool {
+	return i.MIMEType == "image/heic" || i.MIMEType == "image/heif"
+}
+
+// InspectPhoto sniffs the file at path from its magic bytes and, for formats
+// ffprobe can read, measures it. Content problems are returned as *UnsupportedError.
+func (p Prober) InspectPhoto(ctx context.Context, path string) (PhotoInfo, error) {
+	detected, err := mimetype.DetectFile(path)
+	if err != nil {
+		return PhotoInfo{}, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	info := PhotoInfo{Orientation: 1}
+	for m := detected; m != nil; m = m.Parent() {
+		if photoTypes[m.String()] {
+			info.MIMEType = m.String()
+			break
+		}
+	}
+	if info.MIMEType == "" {
+		return PhotoInfo{}, &UnsupportedError{Reason: fmt.Sprintf("Unsupported file type %s. Allowed: JPEG, PNG, WebP or HEIC photo", detected.String())}
+	}
+	if info.IsHEIF() {
+		return info, nil
+	}
+
+	if err := p.MeasurePhoto(ctx, path, &info); err != nil {
+		return PhotoInfo{}, err
+	}
+	if info.MIMEType == "image/jpeg" {
+		info.Orientation = JPEGOrientation(path)
+	}
+	return info, nil
+}
+
+// MeasurePhoto has ffprobe read the image at path and fills in its size
+func (p Prober) MeasurePhoto(ctx context.Context, path string, info *PhotoInfo) error {
+	probe, err := p.ffprobe(ctx, path)
+	if err != nil {
+		return &UnsupportedError{Reason: "File could not be read as an image"}
+	}
+	for _, stream := range probe.Streams {
+		if stream.CodecType == "video" && stream.Width > 0 && stream.Height > 0 {
+			info.Width, info.Height = stream.Width, stream.Height
+			return nil
+		}
+	}
+	return &UnsupportedError{Reason: "File could not be read as an image"}
+}
+
+// PhotoLimits bounds the photos that can be uploaded
+type PhotoLimits struct {
+	// MaxDimension is the most pixels allowed on either side, so a small file
+	// can't decode into an enormous image
+	MaxDimension int
+}
+
+// Check returns a *LimitError if the photo is outside the limits
+func (l PhotoLimits) Check(info PhotoInfo) error {
+	if l.MaxDimension > 0 && max(info.Width, info.Height) > l.MaxDimension {
+		return &LimitError{Reason: fmt.Sprintf("Photo is too large. Maximum is %d pixels on each side", l.MaxDimension)}
+	}
+	return nil
+}
+
+// PhotoVariant is one of the resized JPEGs made from every uploaded photo
+type PhotoVariant struct {
+	Name string
+	// Suffix goes after the photo's filename (before .jpg) in the variant's key
+	Suffix string
+	Width  int
+	Height int
+	// Crop fills Width x Height exactly, cutting off the edges; otherwise the
+	// photo is shrunk to fit inside it
+	Crop bool
+}
+
+// PhotoVariants are made from every photo. The first, "display", is the one
+// events link to as their photo_address.
+var PhotoVariants = []PhotoVariant{
+	{Name: "display", Suffix: "", Width: 1600, Height: 1600},
+	// Open Graph size, for link previews when an event is shared
+	{Name: "card", Suffix: "_card", Width: 1200, Height: 630, Crop: true},
+	{Name: "thumbnail", Suffix: "_thumbnail", Width: 400, Height: 400, Crop: true},
+}
+
+// PhotoVariantFilename is the filename of a variant of the photo stored as filename
+func PhotoVariantFilename(filename string, v PhotoVariant) string {
+	return strings.TrimSuffix(filename, ".jpg") + v.Suffix + ".jpg"
+}
+
+// PhotoKey is the storage key of a photo's variant file
+func PhotoKey(variantFilename string) string {
+	return "photos/" + variantFilename
+}
+
+// Photo is the record of an uploaded photo
+type Photo struct {
+	ID       int
+	ParentID int
+	// Filename is the display variant's; the others are named from it (see PhotoVariantFilename)
+	Filename         string
+	OriginalMIMEType string
+	Width            int // Of the display variant
+	Height           int
+	CreatedAt        time.Time
+}
+
+const photoColumns = `photo_id, parent_id, filename, original_mime_type, width, height, created_at`
+
+func scanPhoto(row pgx.Row) (Photo, error) {
+	var p Photo
+	err := row.Scan(&p.ID, &p.ParentID, &p.Filename, &p.OriginalMIMEType, &p.Width, &p.Height, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Photo{}, ErrPhotoNotFound
+	}
+	return p, err
+}
+
+// CreatePhoto records a photo parentID uploaded, whose variants are stored
+func CreatePhoto(ctx context.Context, db *pgxpool.Pool, parentID int, filename, originalMIMEType string, width, height int) (Photo, error) {
+	insertQuery := `
+		INSERT INTO photos (parent_id, filename, original_mime_type, width, height)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + photoColumns
+
+	photo, err := scanPhoto(db.QueryRow(ctx, insertQuery, parentID, filename, originalMIMEType, width, height))
+	if err != nil {
+		return Photo{}, fmt.Errorf("failed to record photo: %w", err)
+	}
+	return photo, nil
+}
+
+// GetPhotoByFilename loads the photo whose display variant is filename
+func GetPhotoByFilename(ctx context.Context, db *pgxpool.Pool, filename string) (Photo, error) {
+	return scanPhoto(db.QueryRow(ctx, `SELECT `+photoColumns+` FROM photos WHERE filename = $1`, filename))
+}
@@ -0,0 +1,199 @@
+// Package media checks that uploaded files really are what they claim to be and
+// keeps a record of every stored video.
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// videoExtensions maps the video formats we accept (detected from the file's
+// content, never its name) to the extension they are stored with
+var videoExtensions = map[string]string{
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+	"video/x-msvideo": ".avi",
+	"video/webm":      ".webm",
+}
+
+// Info describes an uploaded video
+type Info struct {
+	MIMEType  string
+	Extension string
+	SizeBytes int64
+	Duration  *time.Duration // nil when the container doesn't record it (e.g. browser-recorded WebM)
+	Width     int
+	Height    int
+}
+
+// UnsupportedError means the file isn't a video we accept
+type UnsupportedError struct {
+	Reason string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Reason
+}
+
+// LimitError means the video is a supported format but too long or too large
+type LimitError struct {
+	Reason string
+}
+
+func (e *LimitError) Error() string {
+	return e.Reason
+}
+
+// Limits bounds the videos that can be uploaded
+type Limits struct {
+	MaxDuration time.Duration
+	// MaxWidth and MaxHeight apply to landscape videos; portrait videos may be
+	// as tall as MaxWidth and as wide as MaxHeight
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Check returns a *LimitError if the video is outside the limits
+func (l Limits) Check(info Info) error {
+	if info.Duration != nil && l.MaxDuration > 0 && *info.Duration > l.MaxDuration {
+		return &LimitError{Reason: fmt.Sprintf("Video is too long. Maximum length is %s", l.MaxDuration)}
+	}
+
+	long, short := max(info.Width, info.Height), min(info.Width, info.Height)
+	if l.MaxWidth > 0 && l.MaxHeight > 0 && (long > max(l.MaxWidth, l.MaxHeight) || short > min(l.MaxWidth, l.MaxHeight)) {
+		return &LimitError{Reason: fmt.Sprintf("Video resolution is too high. Maximum is %dx%d", l.MaxWidth, l.MaxHeight)}
+	}
+
+	return nil
+}
+
+// Prober inspects uploaded files
+type Prober struct {
+	// FFprobePath is the ffprobe executable (looked up on $PATH if it has no slash)
+	FFprobePath string
+}
+
+// InspectVideo sniffs the file at path from its magic bytes, then has ffprobe
+// read the container to make sure it holds a playable video stream. Content
+// problems are returned as *UnsupportedError.
+func (p Prober) InspectVideo(ctx context.Context, path string) (Info, error) {
+	detected, err := mimetype.DetectFile(path)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	var info Info
+	for m := detected; m != nil; m = m.Parent() {
+		if ext, ok := videoExtensions[m.String()]; ok {
+			info.MIMEType, info.Extension = m.String(), ext
+			break
+		}
+	}
+	if info.MIMEType == "" {
+		return Info{}, &UnsupportedError{Reason: fmt.Sprintf("Unsupported file type %s. Allowed: MP4, MOV, AVI or WebM video", detected.String())}
+	}
+
+	probe, err := p.ffprobe(ctx, path)
+	if err != nil {
+		return Info{}, &UnsupportedError{Reason: "File could not be read as a video"}
+	}
+
+	hasVideo := false
+	for _, stream := range probe.Streams {
+		// Cover art in audio files shows up as a single-frame video stream
+		if stream.CodecType == "video" && stream.Disposition.AttachedPic == 0 && stream.Width > 0 && stream.Height > 0 {
+			hasVideo = true
+			info.Width, info.Height = stream.Width, stream.Height
+			if d, ok := parseSeconds(stream.Duration); ok && info.Duration == nil {
+				info.Duration = &d
+			}
+			break
+		}
+	}
+	if !hasVideo {
+		return Info{}, &UnsupportedError{Reason: "File does not contain a video"}
+	}
+
+	if d, ok := parseSeconds(probe.Format.Duration); ok {
+		info.Duration = &d
+	}
+	if size, err := strconv.ParseInt(probe.Format.Size, 10, 64); err == nil {
+		info.SizeBytes = size
+	}
+
+	return info, nil
+}
+
+// ffprobeOutput is the part of `ffprobe -print_format json` we use
+type ffprobeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType   string `json:"codec_type"`
+		Width       int    `json:"width"`
+		Height      int    `json:"height"`
+		Duration    string `json:"duration"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+}
+
+func (p Prober) ffprobe(ctx context.Context, path string) (ffprobeOutput, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.FFprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		path,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	var out ffprobeOutput
+	if err := cmd.Run(); err != nil {
+		return out, fmt.Errorf("ffprobe failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return out, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	return out, nil
+}
+
+// parseSeconds reads ffprobe's "12.345000" durations ("N/A" when unknown)
+func parseSeconds(s string) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// DetectContentType works out a stored file's type from its first bytes, so it
+// is served as what it really is whatever its name says. It returns "" when the
+// content isn't recognised.
+func DetectContentType(r io.Reader) (string, error) {
+	detected, err := mimetype.DetectReader(r)
+	if err != nil {
+		return "", err
+	}
+	if detected.Is("application/octet-stream") {
+		return "", nil
+	}
+	return detected.String(), nil
+}
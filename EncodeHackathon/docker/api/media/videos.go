@@ -0,0 +1,218 @@
+package media
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"aletterahead-api/scan"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// VideoStatus is where a video is in its life
+type VideoStatus string
+
+const (
+	// Uploaded videos are stored and waiting to be attached to a donation
+	Uploaded VideoStatus = "uploaded"
+	// Attached videos belong to a donation and can't be used again
+	Attached VideoStatus = "attached"
+	// Quarantined videos were no longer needed and have been moved under quarantine/
+	Quarantined VideoStatus = "quarantined"
+	// Deleted videos were no longer needed and their files have been deleted
+	Deleted VideoStatus = "deleted"
+)
+
+// RemovedInfected is the removed_reason of videos the virus scan caught, which
+// are quarantined as soon as they are uploaded
+const RemovedInfected = "infected"
+
+var (
+	// ErrNotFound is returned when the video doesn't exist
+	ErrNotFound = errors.New("video not found")
+	// ErrWrongEvent is returned when a video was uploaded for a different event
+	ErrWrongEvent = errors.New("video was uploaded for a different event")
+	// ErrAlreadyAttached is returned when a video already belongs to a donation
+	ErrAlreadyAttached = errors.New("video is already attached to a donation")
+)
+
+// Video is the record of a stored video
+type Video struct {
+	ID               int
+	EventID          *int // nil for videos uploaded before they belonged to an event
+	Status           VideoStatus
+	Filename         string
+	OriginalFilename string
+	MIMEType         string
+	SizeBytes        int64
+	ChecksumSHA256   string // Hex SHA-256 of the file as uploaded
+	Duration         *time.Duration
+	Width            int
+	Height           int
+	// Scan is the virus scan's verdict (empty for videos uploaded before scanning)
+	Scan      scan.Result
+	CreatedAt time.Time
+}
+
+const videoColumns = `
+	video_id, event_id, status, filename, COALESCE(original_filename, ''), mime_type,
+	COALESCE(size_bytes, 0), COALESCE(checksum_sha256, ''), duration_ms,
+	COALESCE(width, 0), COALESCE(height, 0), COALESCE(scan_status, ''),
+	COALESCE(scan_signature, ''), created_at
+`
+
+func scanVideo(row pgx.Row) (Video, error) {
+	var v Video
+	var durationMs *int64
+	err := row.Scan(
+		&v.ID,
+		&v.EventID,
+		&v.Status,
+		&v.Filename,
+		&v.OriginalFilename,
+		&v.MIMEType,
+		&v.SizeBytes,
+		&v.ChecksumSHA256,
+		&durationMs,
+		&v.Width,
+		&v.Height,
+		&v.Scan.Status,
+		&v.Scan.Signature,
+		&v.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Video{}, ErrNotFound
+	}
+	if durationMs != nil {
+		d := time.Duration(*durationMs) * time.Millisecond
+		v.Duration = &d
+	}
+	return v, err
+}
+
+// NewFilename returns a random (UUID v4) filename with the given extension, so
+// stored names never collide or carry anything the uploader chose
+func NewFilename(extension string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x%s", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16], extension), nil
+}
+
+// CreateVideo records a video that has been uploaded for eventID, scanned and
+// stored as filename. An infected video is recorded as already quarantined.
+func CreateVideo(ctx context.Context, db *pgxpool.Pool, eventID *int, filename, originalFilename, checksum string, info Info, scanned scan.Result) (Video, error) {
+	var durationMs *int64
+	if info.Duration != nil {
+		ms := info.Duration.Milliseconds()
+		durationMs = &ms
+	}
+
+	now := time.Now()
+	status := Uploaded
+	var removedAt *time.Time
+	var removedReason *string
+	if scanned.Status == scan.Infected {
+		status = Quarantined
+		removedAt = &now
+		reason := RemovedInfected
+		removedReason = &reason
+	}
+	var scannedAt *time.Time
+	if scanned.Status != scan.Skipped {
+		scannedAt = &now
+	}
+	var signature *string
+	if scanned.Signature != "" {
+		signature = &scanned.Signature
+	}
+
+	insertQuery := `
+		INSERT INTO videos (
+			event_id, filename, original_filename, mime_type, size_bytes, checksum_sha256, duration_ms, width, height,
+			status, removed_at, removed_reason, scan_status, scan_signature, scanned_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING ` + videoColumns
+
+	video, err := scanVideo(db.QueryRow(ctx, insertQuery,
+		eventID,
+		filename,
+		originalFilename,
+		info.MIMEType,
+		info.SizeBytes,
+		checksum,
+		durationMs,
+		info.Width,
+		info.Height,
+		status,
+		removedAt,
+		removedReason,
+		scanned.Status,
+		signature,
+		scannedAt,
+	))
+	if err != nil {
+		return Video{}, fmt.Errorf("failed to record video: %w", err)
+	}
+	return video, nil
+}
+
+// GetVideo loads a video
+func GetVideo(ctx context.Context, db *pgxpool.Pool, videoID int) (Video, error) {
+	return scanVideo(db.QueryRow(ctx, `SELECT `+videoColumns+` FROM videos WHERE video_id = $1`, videoID))
+}
+
+// Attach claims an uploaded video for a donation to eventID. It returns
+// ErrNotFound, ErrWrongEvent or ErrAlreadyAttached if the video can't be used.
+func Attach(ctx context.Context, db *pgxpool.Pool, videoID, eventID int) (Video, error) {
+	// Only one donation can win the video
+	updateQuery := `
+		UPDATE videos
+		SET status = $1
+		WHERE video_id = $2
+		AND event_id = $3
+		AND status = $4
+		RETURNING ` + videoColumns
+
+	video, err := scanVideo(db.QueryRow(ctx, updateQuery, Attached, videoID, eventID, Uploaded))
+	if !errors.Is(err, ErrNotFound) {
+		return video, err
+	}
+
+	// Work out why it couldn't be claimed
+	video, err = GetVideo(ctx, db, videoID)
+	if err != nil {
+		return Video{}, err
+	}
+	if video.Status == Quarantined || video.Status == Deleted {
+		return Video{}, ErrNotFound
+	}
+	if video.EventID == nil || *video.EventID != eventID {
+		return Video{}, ErrWrongEvent
+	}
+	return Video{}, ErrAlreadyAttached
+}
+
+// Release makes an attached video available again, when the donation it was
+// claimed for couldn't be saved
+func Release(ctx context.Context, db *pgxpool.Pool, videoID int) error {
+	updateQuery := `
+		UPDATE videos
+		SET status = $1
+		WHERE video_id = $2
+		AND status = $3
+		AND NOT EXISTS (SELECT 1 FROM donations WHERE video_id = $2)
+	`
+
+	_, err := db.Exec(ctx, updateQuery, Uploaded, videoID, Attached)
+	return err
+}
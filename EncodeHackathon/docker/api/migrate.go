@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"aletterahead-api/migrations"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runMigrate handles the `migrate up|down [steps]|status|seed` subcommand
+func runMigrate(db *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [steps] | status | seed")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+			steps = n
+		}
+
+		reverted, err := migrations.Down(ctx, db, steps)
+		for _, m := range reverted {
+			log.Printf("Reverted migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			log.Println("No migrations to revert")
+		}
+
+	case "status":
+		statuses, err := migrations.List(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	case "seed":
+		seeded, err := migrations.Seed(ctx, db)
+		if err != nil {
+			return err
+		}
+		if seeded {
+			log.Println("Inserted sample data")
+		} else {
+			log.Println("Database already has data, sample data skipped")
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}
+
+// migrateOnStartup brings the schema up to date before the API starts serving
+func migrateOnStartup(db *pgxpool.Pool) error {
+	if getEnv("MIGRATE_ON_STARTUP", "true") != "true" {
+		return nil
+	}
+	if err := runMigrate(db, []string{"up"}); err != nil {
+		return err
+	}
+
+	// Sample data for local development (see migrations/seed.sql)
+	if getEnv("SEED_SAMPLE_DATA", "false") == "true" {
+		return runMigrate(db, []string{"seed"})
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+-- Sample data for local development and the tests/ scripts
+INSERT INTO parents (parent_email, auth0_id, stripe_customer_id) VALUES
+('parent@example.com', 'auth0|sample123', 'cus_sample123');
+
+INSERT INTO children (DOB, parent_id, email, isa_expiry, child_name) VALUES
+('2017-07-15', 1, 'emma@example.com', '2035-07-15', 'Emma');
+
+INSERT INTO events (child_id, event_name, expires_at, event_message, videos_enabled, photo_address) VALUES
+(1, 'Emma''s 8th Birthday', '2025-07-15', 'Help us make Emma''s birthday extra special this year!', true, 'https://example.com/emma-photo.jpg');
+
+-- Sample payment account (for testing - normally created via API)
+INSERT INTO payment_accounts (parent_id, stripe_connect_account_id, onboarding_complete, charges_enabled, payouts_enabled, details_submitted) VALUES
+(1, 'acct_sample123', true, true, true, true);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS payment_accounts;
+DROP TABLE IF EXISTS donations;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS children;
+DROP TABLE IF EXISTS parents;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_donations_payment_intent_id;
+
+ALTER TABLE donations DROP COLUMN IF EXISTS payment_status;
+ALTER TABLE donations DROP COLUMN IF EXISTS payment_intent_id;
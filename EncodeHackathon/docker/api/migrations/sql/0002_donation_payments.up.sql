@@ -0,0 +1,5 @@
+-- Stripe PaymentIntent tracking on donations
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS payment_intent_id VARCHAR(255);
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS payment_status VARCHAR(50) DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_donations_payment_intent_id ON donations(payment_intent_id);
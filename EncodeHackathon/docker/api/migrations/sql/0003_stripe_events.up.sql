@@ -0,0 +1,9 @@
+-- Verified Stripe webhook events, kept for replay
+CREATE TABLE IF NOT EXISTS stripe_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    event_type VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    received_at TIMESTAMP DEFAULT NOW(),
+    processed_at TIMESTAMP,
+    processing_error TEXT
+);
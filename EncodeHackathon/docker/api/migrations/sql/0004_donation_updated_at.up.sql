@@ -0,0 +1,2 @@
+-- Track when a donation was last changed (e.g. approved or rejected)
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW();
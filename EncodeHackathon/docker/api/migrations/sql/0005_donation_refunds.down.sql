@@ -0,0 +1,3 @@
+ALTER TABLE donations DROP COLUMN IF EXISTS refunded_at;
+ALTER TABLE donations DROP COLUMN IF EXISTS refunded_amount_pence;
+ALTER TABLE donations DROP COLUMN IF EXISTS refund_id;
@@ -0,0 +1,4 @@
+-- Refund details for donations rejected by the parent
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS refund_id VARCHAR(255);
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS refunded_amount_pence INTEGER;
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMP;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS parent_notifications;
+DROP INDEX IF EXISTS idx_donations_authorized;
+
+ALTER TABLE events DROP COLUMN IF EXISTS expiring_authorization_policy;
+ALTER TABLE donations DROP COLUMN IF EXISTS expiry_notified_at;
+ALTER TABLE donations DROP COLUMN IF EXISTS authorized_at;
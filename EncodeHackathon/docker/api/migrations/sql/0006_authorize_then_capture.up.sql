@@ -0,0 +1,21 @@
+-- Donations are authorised when made and captured on approval
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS authorized_at TIMESTAMP;
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP;
+
+-- What to do with an unmoderated donation whose authorisation is about to expire:
+-- 'capture' takes the money (it can still be refunded on rejection), 'notify' asks the parent
+ALTER TABLE events ADD COLUMN IF NOT EXISTS expiring_authorization_policy VARCHAR(20) NOT NULL DEFAULT 'notify'
+    CHECK (expiring_authorization_policy IN ('capture', 'notify'));
+
+CREATE TABLE IF NOT EXISTS parent_notifications (
+    notification_id SERIAL PRIMARY KEY,
+    parent_id INTEGER NOT NULL REFERENCES parents(parent_id),
+    donation_id INTEGER REFERENCES donations(id),
+    kind VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_donations_authorized ON donations(authorized_at) WHERE payment_status = 'authorized';
+CREATE INDEX IF NOT EXISTS idx_parent_notifications_parent_id ON parent_notifications(parent_id);
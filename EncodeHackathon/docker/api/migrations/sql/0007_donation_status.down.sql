@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS donation_status_history;
+DROP INDEX IF EXISTS idx_donations_event_status;
+
+ALTER TABLE donations DROP COLUMN IF EXISTS status;
@@ -0,0 +1,33 @@
+-- Explicit donation lifecycle (see the lifecycle package for the allowed transitions).
+-- approved is kept in step with status for older clients.
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS status VARCHAR(30) NOT NULL DEFAULT 'awaiting_payment'
+    CHECK (status IN ('awaiting_payment', 'pending_review', 'approved', 'captured',
+                      'rejected', 'payment_failed', 'refunded', 'disputed', 'expired'));
+
+UPDATE donations SET status = CASE
+    WHEN payment_status = 'refunded' THEN 'refunded'
+    WHEN approved AND payment_status = 'succeeded' THEN 'captured'
+    WHEN approved THEN 'approved'
+    WHEN payment_status = 'canceled' THEN 'rejected'
+    WHEN payment_status = 'failed' THEN 'payment_failed'
+    WHEN payment_status IN ('authorized', 'succeeded') THEN 'pending_review'
+    ELSE 'awaiting_payment'
+END;
+
+-- Every status change, who made it and why
+CREATE TABLE IF NOT EXISTS donation_status_history (
+    history_id SERIAL PRIMARY KEY,
+    donation_id INTEGER NOT NULL REFERENCES donations(id),
+    from_status VARCHAR(30),
+    to_status VARCHAR(30) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT,
+    changed_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO donation_status_history (donation_id, from_status, to_status, actor, reason, changed_at)
+SELECT id, NULL, status, 'system:migration', 'backfilled from approved/payment_status', COALESCE(updated_at, created_at, NOW())
+FROM donations;
+
+CREATE INDEX IF NOT EXISTS idx_donations_event_status ON donations(event_id, status);
+CREATE INDEX IF NOT EXISTS idx_donation_status_history_donation_id ON donation_status_history(donation_id);
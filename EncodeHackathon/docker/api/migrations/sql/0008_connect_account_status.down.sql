@@ -0,0 +1,4 @@
+ALTER TABLE payment_accounts DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE payment_accounts DROP COLUMN IF EXISTS details_submitted;
+ALTER TABLE payment_accounts DROP COLUMN IF EXISTS payouts_enabled;
+ALTER TABLE payment_accounts DROP COLUMN IF EXISTS charges_enabled;
@@ -0,0 +1,8 @@
+-- Connect accounts are created by the API; their capabilities come from Stripe
+ALTER TABLE payment_accounts ADD COLUMN IF NOT EXISTS charges_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE payment_accounts ADD COLUMN IF NOT EXISTS payouts_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE payment_accounts ADD COLUMN IF NOT EXISTS details_submitted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE payment_accounts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW();
+
+-- Accounts marked complete before this point were confirmed by the old client-side flow
+UPDATE payment_accounts SET charges_enabled = TRUE, payouts_enabled = TRUE WHERE onboarding_complete;
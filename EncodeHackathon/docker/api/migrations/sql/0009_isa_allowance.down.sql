@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_donations_captured_at;
+
+ALTER TABLE donations DROP COLUMN IF EXISTS captured_at;
@@ -0,0 +1,12 @@
+-- When a donation's money was taken; Junior ISA allowance is counted by the tax year it falls in
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS captured_at TIMESTAMP;
+
+UPDATE donations d
+SET captured_at = COALESCE(
+    (SELECT MIN(h.changed_at) FROM donation_status_history h WHERE h.donation_id = d.id AND h.to_status = 'captured'),
+    d.updated_at,
+    d.created_at
+)
+WHERE d.status IN ('captured', 'disputed') AND d.captured_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_donations_captured_at ON donations(captured_at) WHERE captured_at IS NOT NULL;
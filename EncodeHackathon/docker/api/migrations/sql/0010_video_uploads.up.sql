@@ -0,0 +1,18 @@
+-- Resumable (tus) video uploads in progress; each PATCH is stored as a numbered chunk
+-- under uploads/<upload_id>/ until the upload completes and is assembled into a video
+CREATE TABLE IF NOT EXISTS video_uploads (
+    upload_id VARCHAR(64) PRIMARY KEY,
+    upload_length BIGINT NOT NULL CHECK (upload_length >= 0),
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    chunk_count INTEGER NOT NULL DEFAULT 0,
+    original_filename VARCHAR(255) NOT NULL,
+    metadata TEXT,
+    video_filename VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    CHECK (upload_offset <= upload_length)
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_uploads_expires_at ON video_uploads(expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_video_transcodes_pending;
+
+DROP TABLE IF EXISTS video_transcodes;
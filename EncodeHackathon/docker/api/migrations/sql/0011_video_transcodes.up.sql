@@ -0,0 +1,25 @@
+-- Background ffmpeg jobs that turn each uploaded video into a web-friendly H.264/AAC MP4.
+-- GetVideo serves output_key once a video's job is ready.
+CREATE TABLE IF NOT EXISTS video_transcodes (
+    job_id SERIAL PRIMARY KEY,
+    source_filename VARCHAR(255) NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'processing', 'ready', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    output_key VARCHAR(500),
+    output_size_bytes BIGINT,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_transcodes_pending ON video_transcodes(created_at) WHERE status IN ('queued', 'processing');
+
+-- Queue the videos donations already link to
+INSERT INTO video_transcodes (source_filename)
+SELECT DISTINCT substring(video_address from '/api/videos/([^/?#]+)$')
+FROM donations
+WHERE video_address ~ '/api/videos/[^/?#]+$'
+ON CONFLICT (source_filename) DO NOTHING;
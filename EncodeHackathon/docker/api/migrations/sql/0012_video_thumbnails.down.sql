@@ -0,0 +1 @@
+ALTER TABLE video_transcodes DROP COLUMN IF EXISTS thumbnail_key;
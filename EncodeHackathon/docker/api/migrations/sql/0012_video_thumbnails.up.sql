@@ -0,0 +1,2 @@
+-- JPEG poster frame taken from each video when it is transcoded
+ALTER TABLE video_transcodes ADD COLUMN IF NOT EXISTS thumbnail_key VARCHAR(500);
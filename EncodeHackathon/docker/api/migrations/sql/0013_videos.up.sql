@@ -0,0 +1,13 @@
+-- Every stored video, with what was detected from its content when it was uploaded.
+-- filename is a random UUID; original_filename is only kept for reference.
+CREATE TABLE IF NOT EXISTS videos (
+    video_id SERIAL PRIMARY KEY,
+    filename VARCHAR(255) NOT NULL UNIQUE,
+    original_filename VARCHAR(255),
+    mime_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    duration_ms BIGINT,
+    width INTEGER,
+    height INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+ALTER TABLE donations DROP COLUMN IF EXISTS video_id;
+ALTER TABLE video_uploads DROP COLUMN IF EXISTS video_id;
+ALTER TABLE video_uploads DROP COLUMN IF EXISTS event_id;
+DROP INDEX IF EXISTS idx_videos_event_id;
+DELETE FROM videos WHERE size_bytes IS NULL;
+ALTER TABLE videos ALTER COLUMN size_bytes SET NOT NULL;
+ALTER TABLE videos DROP COLUMN IF EXISTS checksum_sha256;
+ALTER TABLE videos DROP COLUMN IF EXISTS status;
+ALTER TABLE videos DROP COLUMN IF EXISTS event_id;
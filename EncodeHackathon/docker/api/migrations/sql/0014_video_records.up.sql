@@ -0,0 +1,45 @@
+-- Videos belong to the event they were uploaded for and can be attached to a single
+-- donation, which refers to them by video_id instead of a free-text address.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS event_id INTEGER REFERENCES events(event_id) ON DELETE CASCADE;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'uploaded'
+    CHECK (status IN ('uploaded', 'attached'));
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS checksum_sha256 CHAR(64);
+-- Videos linked before uploads were checked weren't measured
+ALTER TABLE videos ALTER COLUMN size_bytes DROP NOT NULL;
+
+ALTER TABLE video_uploads ADD COLUMN IF NOT EXISTS event_id INTEGER REFERENCES events(event_id) ON DELETE CASCADE;
+ALTER TABLE video_uploads ADD COLUMN IF NOT EXISTS video_id INTEGER REFERENCES videos(video_id) ON DELETE SET NULL;
+
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS video_id INTEGER UNIQUE REFERENCES videos(video_id);
+
+CREATE INDEX IF NOT EXISTS idx_videos_event_id ON videos(event_id);
+
+-- Record the videos donations already link to
+INSERT INTO videos (filename, mime_type)
+SELECT DISTINCT
+    substring(video_address from '/api/videos/([^/?#]+)$'),
+    CASE lower(substring(video_address from '\.([A-Za-z0-9]+)$'))
+        WHEN 'mov' THEN 'video/quicktime'
+        WHEN 'avi' THEN 'video/x-msvideo'
+        WHEN 'webm' THEN 'video/webm'
+        ELSE 'video/mp4'
+    END
+FROM donations
+WHERE video_address ~ '/api/videos/[^/?#]+$'
+ON CONFLICT (filename) DO NOTHING;
+
+-- A video shared by several donations stays with the first of them
+UPDATE donations d
+SET video_id = v.video_id
+FROM videos v
+WHERE v.filename = substring(d.video_address from '/api/videos/([^/?#]+)$')
+AND NOT EXISTS (
+    SELECT 1 FROM donations earlier
+    WHERE earlier.id < d.id
+    AND substring(earlier.video_address from '/api/videos/([^/?#]+)$') = v.filename
+);
+
+UPDATE videos v
+SET status = 'attached', event_id = d.event_id
+FROM donations d
+WHERE d.video_id = v.video_id;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_videos_uploaded;
+ALTER TABLE videos DROP COLUMN IF EXISTS removed_reason;
+ALTER TABLE videos DROP COLUMN IF EXISTS removed_at;
+UPDATE videos v SET status = CASE
+    WHEN EXISTS (SELECT 1 FROM donations d WHERE d.video_id = v.video_id) THEN 'attached'
+    ELSE 'uploaded'
+END
+WHERE status IN ('quarantined', 'deleted');
+ALTER TABLE videos DROP CONSTRAINT IF EXISTS videos_status_check;
+ALTER TABLE videos ADD CONSTRAINT videos_status_check CHECK (status IN ('uploaded', 'attached'));
@@ -0,0 +1,10 @@
+-- Videos nobody needs any more (never attached to a donation, or their donation was
+-- rejected) are removed by the video collector, either deleted or moved to quarantine/.
+-- Their records are kept so donations still know what they linked to.
+ALTER TABLE videos DROP CONSTRAINT IF EXISTS videos_status_check;
+ALTER TABLE videos ADD CONSTRAINT videos_status_check
+    CHECK (status IN ('uploaded', 'attached', 'quarantined', 'deleted'));
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS removed_reason VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_videos_uploaded ON videos(created_at) WHERE status = 'uploaded';
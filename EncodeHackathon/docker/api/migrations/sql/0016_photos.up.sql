@@ -0,0 +1,13 @@
+-- Event photos parents upload. Only the resized, re-encoded variants are stored
+-- (see media.PhotoVariants); filename is the display variant's.
+CREATE TABLE IF NOT EXISTS photos (
+    photo_id SERIAL PRIMARY KEY,
+    parent_id INTEGER NOT NULL REFERENCES parents(parent_id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL UNIQUE,
+    original_mime_type VARCHAR(50) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_photos_parent_id ON photos(parent_id);
@@ -0,0 +1,3 @@
+ALTER TABLE videos DROP COLUMN IF EXISTS scanned_at;
+ALTER TABLE videos DROP COLUMN IF EXISTS scan_signature;
+ALTER TABLE videos DROP COLUMN IF EXISTS scan_status;
@@ -0,0 +1,6 @@
+-- Uploads are scanned by clamd before they can be served. Videos from before
+-- scanning have no result. Infected uploads are recorded straight into quarantine.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS scan_status VARCHAR(20)
+    CHECK (scan_status IN ('clean', 'infected', 'skipped'));
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS scan_signature VARCHAR(255);
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS scanned_at TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS capsule_items;
+DROP TABLE IF EXISTS capsule_deliveries;
+ALTER TABLE children DROP COLUMN IF EXISTS delivery_date;
@@ -0,0 +1,32 @@
+-- Time capsules: on the child's 18th birthday (or the date their parent picked)
+-- the messages and videos of every approved donation are emailed to them as a
+-- signed link. One delivery per child; capsule_items is what it was compiled from.
+ALTER TABLE children ADD COLUMN IF NOT EXISTS delivery_date DATE;
+
+CREATE TABLE IF NOT EXISTS capsule_deliveries (
+    delivery_id SERIAL PRIMARY KEY,
+    child_id INTEGER NOT NULL UNIQUE REFERENCES children(child_id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'sending'
+        CHECK (status IN ('sending', 'sent', 'failed', 'empty')),
+    scheduled_for DATE NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    item_count INTEGER NOT NULL DEFAULT 0,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    video_count INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    claimed_at TIMESTAMP,
+    sent_at TIMESTAMP,
+    last_sent_at TIMESTAMP,
+    send_count INTEGER NOT NULL DEFAULT 0,
+    opened_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS capsule_items (
+    delivery_id INTEGER NOT NULL REFERENCES capsule_deliveries(delivery_id) ON DELETE CASCADE,
+    donation_id INTEGER NOT NULL REFERENCES donations(id) ON DELETE CASCADE,
+    added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (delivery_id, donation_id)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE donations DROP CONSTRAINT IF EXISTS donations_sealed_check;
+ALTER TABLE donations DROP COLUMN IF EXISTS sealed_length;
+ALTER TABLE donations DROP COLUMN IF EXISTS sealed_message;
+ALTER TABLE donations DROP COLUMN IF EXISTS sealed;
+DROP TABLE IF EXISTS child_keys;
@@ -0,0 +1,16 @@
+-- Sealed messages: a private letter only the child reads once they are 18. The
+-- text is kept encrypted (AES-256-GCM) under a key of the child's own, which is
+-- itself stored encrypted with SEALED_MESSAGE_KEY. message stays NULL, so the
+-- parent only ever sees the letter's length.
+CREATE TABLE IF NOT EXISTS child_keys (
+    child_id INTEGER PRIMARY KEY REFERENCES children(child_id) ON DELETE CASCADE,
+    wrapped_key BYTEA NOT NULL,
+    master_key_id VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS sealed BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS sealed_message BYTEA;
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS sealed_length INTEGER;
+ALTER TABLE donations ADD CONSTRAINT donations_sealed_check
+    CHECK (NOT sealed OR (message IS NULL AND sealed_message IS NOT NULL));
@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN IF EXISTS cancel_reason;
+ALTER TABLE events DROP COLUMN IF EXISTS cancelled_at;
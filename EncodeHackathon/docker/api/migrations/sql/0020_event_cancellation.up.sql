@@ -0,0 +1,5 @@
+-- Parents can cancel an event. It stays in their list (and keeps its approved
+-- donations), but takes no more donations or videos, and its pending donations
+-- are rejected with the donors' card holds released.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_payment_accounts_parent_id;
+CREATE INDEX IF NOT EXISTS idx_payment_accounts_parent_id ON payment_accounts(parent_id);
@@ -0,0 +1,22 @@
+-- A parent has one Stripe Connect account. Earlier duplicates (two create-account
+-- requests racing) each point at a live Connect account, so they aren't dropped here:
+-- the migration stops until someone has chosen which account each parent keeps.
+DO $$
+DECLARE
+    duplicated TEXT;
+BEGIN
+    SELECT string_agg(parent_id::TEXT, ', ' ORDER BY parent_id) INTO duplicated
+    FROM (
+        SELECT parent_id
+        FROM payment_accounts
+        GROUP BY parent_id
+        HAVING COUNT(*) > 1
+    ) duplicates;
+
+    IF duplicated IS NOT NULL THEN
+        RAISE EXCEPTION 'parents % have more than one payment account; remove the extra rows (after closing their Connect accounts in Stripe) and migrate again', duplicated;
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS idx_payment_accounts_parent_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_accounts_parent_id ON payment_accounts(parent_id);
@@ -0,0 +1,2 @@
+-- Approvals cleared by the up migration weren't the parent's; they are not restored.
+SELECT 1;
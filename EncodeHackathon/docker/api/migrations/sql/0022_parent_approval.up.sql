@@ -0,0 +1,13 @@
+-- approved records the parent's decision only. Donations the event's policy captured
+-- (or that were approved from their status alone) go back to waiting for review.
+-- The 0007 backfill took its statuses from approved, so it counts as the parent's.
+UPDATE donations d
+SET approved = FALSE
+WHERE d.approved = TRUE
+AND NOT EXISTS (
+    SELECT 1
+    FROM donation_status_history h
+    WHERE h.donation_id = d.id
+    AND h.to_status IN ('approved', 'captured')
+    AND (h.actor LIKE 'parent:%' OR h.actor = 'system:migration')
+);
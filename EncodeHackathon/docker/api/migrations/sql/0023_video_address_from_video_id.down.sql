@@ -0,0 +1,6 @@
+-- The host isn't known here; the path is enough for the links to be rebuilt
+UPDATE donations d
+SET video_address = '/api/videos/' || v.filename
+FROM videos v
+WHERE v.video_id = d.video_id
+AND d.video_address IS NULL;
@@ -0,0 +1,3 @@
+-- Donations with an uploaded video are linked to it by video_id alone; links are built
+-- from PUBLIC_BASE_URL when handed out. Stored addresses took their host from the request.
+UPDATE donations SET video_address = NULL WHERE video_id IS NOT NULL;
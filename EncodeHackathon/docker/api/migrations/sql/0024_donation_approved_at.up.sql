@@ -0,0 +1,18 @@
+-- When the parent approved each donation, so capsules that were empty are only
+-- looked at again once something new has been approved
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+
+UPDATE donations d
+SET approved_at = COALESCE(
+    (
+        SELECT MAX(h.changed_at)
+        FROM donation_status_history h
+        WHERE h.donation_id = d.id
+        AND h.to_status IN ('approved', 'captured')
+        AND (h.actor LIKE 'parent:%' OR h.actor = 'system:migration')
+    ),
+    d.updated_at,
+    d.created_at
+)
+WHERE d.approved = TRUE
+AND d.approved_at IS NULL;
@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+// ConnectConfig controls how parents' Stripe Connect accounts are created and onboarded
+type ConnectConfig struct {
+	AccountType string // "express" or "custom"
+	Country     string // two-letter country code, e.g. "GB"
+	RefreshURL  string // where Stripe sends the parent when an onboarding link has expired
+	ReturnURL   string // where Stripe sends the parent when they leave onboarding
+}
+
+// CreateConnectAccount creates the Connect account that receives a parent's donations
+func CreateConnectAccount(sc *client.API, cfg ConnectConfig, parentID int, email string) (*stripe.Account, error) {
+	params := &stripe.AccountParams{
+		Type:    stripe.String(cfg.AccountType),
+		Country: stripe.String(cfg.Country),
+		Email:   stripe.String(email),
+		Capabilities: &stripe.AccountCapabilitiesParams{
+			CardPayments: &stripe.AccountCapabilitiesCardPaymentsParams{Requested: stripe.Bool(true)},
+			Transfers:    &stripe.AccountCapabilitiesTransfersParams{Requested: stripe.Bool(true)},
+		},
+	}
+	params.AddMetadata("parent_id", strconv.Itoa(parentID))
+	// A retried request must never create a second account for the same parent
+	params.SetIdempotencyKey(fmt.Sprintf("connect-account-parent-%d", parentID))
+
+	account, err := sc.Accounts.New(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+	return account, nil
+}
+
+// OnboardingLink creates a single-use link to Stripe's hosted onboarding for an account.
+// Links expire after a few minutes, so a new one is made every time the parent needs one.
+func OnboardingLink(sc *client.API, cfg ConnectConfig, accountID string) (*stripe.AccountLink, error) {
+	params := &stripe.AccountLinkParams{
+		Account:    stripe.String(accountID),
+		RefreshURL: stripe.String(cfg.RefreshURL),
+		ReturnURL:  stripe.String(cfg.ReturnURL),
+		Type:       stripe.String(string(stripe.AccountLinkTypeAccountOnboarding)),
+	}
+
+	link, err := sc.AccountLinks.New(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create onboarding link: %w", err)
+	}
+	return link, nil
+}
+
+// GetConnectAccount fetches the current state of a Connect account
+func GetConnectAccount(sc *client.API, accountID string) (*stripe.Account, error) {
+	account, err := sc.Accounts.GetByID(accountID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up account: %w", err)
+	}
+	return account, nil
+}
+
+// OnboardingComplete reports whether an account can take donations:
+// Stripe must let it both accept charges and pay out to the parent's bank
+func OnboardingComplete(account *stripe.Account) bool {
+	return account.ChargesEnabled && account.PayoutsEnabled
+}
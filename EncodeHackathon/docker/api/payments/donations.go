@@ -0,0 +1,85 @@
+package payments
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+// Release describes how a rejected donation's payment was undone
+type Release struct {
+	Status   string  // "refunded" if money was taken or held, "canceled" if the donor never paid
+	RefundID *string // only set when a captured charge was refunded
+}
+
+// ReleaseDonation returns a rejected donation's money to the donor.
+// An authorised but uncaptured PaymentIntent is cancelled, which releases the hold;
+// a captured one is refunded, pulling the transfer back from the parent's account.
+func ReleaseDonation(sc *client.API, donationID int, paymentIntentID string) (Release, error) {
+	pi, err := sc.PaymentIntents.Get(paymentIntentID, nil)
+	if err != nil {
+		return Release{}, fmt.Errorf("failed to look up payment: %w", err)
+	}
+
+	switch pi.Status {
+	case stripe.PaymentIntentStatusCanceled:
+		return Release{Status: "canceled"}, nil
+
+	case stripe.PaymentIntentStatusSucceeded:
+		params := &stripe.RefundParams{
+			PaymentIntent:   stripe.String(paymentIntentID),
+			ReverseTransfer: stripe.Bool(true),
+			Reason:          stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+		}
+		params.AddMetadata("donation_id", strconv.Itoa(donationID))
+		params.SetIdempotencyKey(fmt.Sprintf("refund-donation-%d", donationID))
+
+		refund, err := sc.Refunds.New(params)
+		if err != nil {
+			return Release{}, fmt.Errorf("failed to refund payment: %w", err)
+		}
+		return Release{Status: "refunded", RefundID: &refund.ID}, nil
+
+	default:
+		params := &stripe.PaymentIntentCancelParams{
+			CancellationReason: stripe.String(string(stripe.PaymentIntentCancellationReasonRequestedByCustomer)),
+		}
+		if _, err := sc.PaymentIntents.Cancel(paymentIntentID, params); err != nil {
+			return Release{}, fmt.Errorf("failed to cancel payment: %w", err)
+		}
+
+		// Only an authorised payment was holding the donor's money
+		if pi.Status == stripe.PaymentIntentStatusRequiresCapture {
+			return Release{Status: "refunded"}, nil
+		}
+		return Release{Status: "canceled"}, nil
+	}
+}
+
+// CaptureDonation takes the money for an authorised donation.
+// It reports false if there is nothing to capture yet (the donor hasn't finished paying).
+func CaptureDonation(sc *client.API, donationID int, paymentIntentID string) (bool, error) {
+	pi, err := sc.PaymentIntents.Get(paymentIntentID, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up payment: %w", err)
+	}
+
+	switch pi.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		return true, nil
+
+	case stripe.PaymentIntentStatusRequiresCapture:
+		params := &stripe.PaymentIntentCaptureParams{}
+		params.SetIdempotencyKey(fmt.Sprintf("capture-donation-%d", donationID))
+
+		if _, err := sc.PaymentIntents.Capture(paymentIntentID, params); err != nil {
+			return false, fmt.Errorf("failed to capture payment: %w", err)
+		}
+		return true, nil
+
+	default:
+		return false, nil
+	}
+}
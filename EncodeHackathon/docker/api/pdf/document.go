@@ -0,0 +1,310 @@
+// Package pdf writes simple PDF documents - text in the standard fonts, JPEG
+// images, filled rectangles and lines - in pure Go, so documents can be made in
+// the API container without external tools. Positions are in points (1/72
+// inch) from the top left of the page.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A4 page size, in points
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Color is an RGB colour, each part from 0 to 1
+type Color struct{ R, G, B float64 }
+
+// Black is the default colour of text and shapes
+var Black = Color{0, 0, 0}
+
+// Document is a PDF being put together in memory
+type Document struct {
+	Title   string
+	Author  string
+	Created time.Time
+
+	pages  []*Page
+	images []*Image
+}
+
+// Page is one page of a document
+type Page struct {
+	Width, Height float64
+
+	content bytes.Buffer
+	images  map[*Image]bool
+	fonts   map[Font]bool
+}
+
+// Image is a JPEG added to a document, which any of its pages can draw
+type Image struct {
+	Width, Height int
+
+	data       []byte
+	colorSpace string
+	decode     string
+	name       string
+}
+
+// New starts an empty document
+func New(title string) *Document {
+	return &Document{Title: title, Created: time.Now()}
+}
+
+// AddPage adds an A4 page to the end of the document
+func (d *Document) AddPage() *Page {
+	p := &Page{Width: A4Width, Height: A4Height, images: map[*Image]bool{}, fonts: map[Font]bool{}}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages are the document's pages, in order
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// AddJPEG adds a JPEG image, which is embedded as it is (not decoded)
+func (d *Document) AddJPEG(data []byte) (*Image, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JPEG: %w", err)
+	}
+
+	img := &Image{
+		Width:  cfg.Width,
+		Height: cfg.Height,
+		data:   data,
+		name:   "Im" + strconv.Itoa(len(d.images)+1),
+	}
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		img.colorSpace = "/DeviceGray"
+	case color.CMYKModel:
+		// Adobe's CMYK JPEGs are stored inverted
+		img.colorSpace, img.decode = "/DeviceCMYK", "/Decode [1 0 1 0 1 0 1 0]"
+	default:
+		img.colorSpace = "/DeviceRGB"
+	}
+	d.images = append(d.images, img)
+	return img, nil
+}
+
+// Fit is the size img is drawn at to fit inside maxW x maxH, keeping its shape
+func (img *Image) Fit(maxW, maxH float64) (float64, float64) {
+	w, h := float64(img.Width), float64(img.Height)
+	scale := min(maxW/w, maxH/h)
+	return w * scale, h * scale
+}
+
+// Text draws s with its baseline at y, starting at x
+func (p *Page) Text(x, y float64, font Font, size float64, c Color, s string) {
+	p.fonts[font] = true
+	fmt.Fprintf(&p.content, "BT %s rg /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		c.operands(), int(font)+1, num(size), num(x), num(p.Height-y), escape(encode(s)))
+}
+
+// Image draws img stretched to w x h, with its top left corner at x, y
+func (p *Page) Image(img *Image, x, y, w, h float64) {
+	p.images[img] = true
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		num(w), num(h), num(x), num(p.Height-y-h), img.name)
+}
+
+// Rect fills a w x h rectangle with its top left corner at x, y
+func (p *Page) Rect(x, y, w, h float64, c Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", c.operands(), num(x), num(p.Height-y-h), num(w), num(h))
+}
+
+// Line draws a line width points thick from x1, y1 to x2, y2
+func (p *Page) Line(x1, y1, x2, y2, width float64, c Color) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s m %s %s l S\n",
+		c.operands(), num(width), num(x1), num(p.Height-y1), num(x2), num(p.Height-y2))
+}
+
+// Modules draws a grid of size x size square modules (a QR code, say) where
+// dark reports true, each module points across, with its top left corner at x, y
+func (p *Page) Modules(x, y, module float64, size int, dark func(x, y int) bool, c Color) {
+	// Scaled so modules are whole units, and neighbours meet exactly
+	fmt.Fprintf(&p.content, "q %s rg %s 0 0 %s %s %s cm\n",
+		c.operands(), num(module), num(module), num(x), num(p.Height-y-float64(size)*module))
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			if !dark(col, row) {
+				continue
+			}
+			// One rectangle for each run of dark modules along the row
+			run := 1
+			for col+run < size && dark(col+run, row) {
+				run++
+			}
+			fmt.Fprintf(&p.content, "%d %d %d 1 re\n", col, size-1-row, run)
+			col += run - 1
+		}
+	}
+	p.content.WriteString("f Q\n")
+}
+
+// WriteTo writes the finished document to w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	out := &pdfWriter{w: w}
+	out.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 and 2 are the catalog and page tree, then the info, the fonts
+	// and images, and each page with its content
+	const catalog, pages, info = 1, 2, 3
+	next := 4
+	fontObjects := map[Font]int{}
+	for font := range fontNames {
+		fontObjects[Font(font)] = next
+		next++
+	}
+	imageObjects := map[*Image]int{}
+	for _, img := range d.images {
+		imageObjects[img] = next
+		next++
+	}
+	pageObjects := make([]int, len(d.pages))
+	for i := range d.pages {
+		pageObjects[i] = next
+		next += 2
+	}
+
+	out.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+
+	kids := make([]string, len(pageObjects))
+	for i, id := range pageObjects {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	out.object(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	out.object(info, fmt.Sprintf("<< /Title (%s) /Author (%s) /Producer (A Letter Ahead) /CreationDate (D:%s) >>",
+		escape(encode(d.Title)), escape(encode(d.Author)), d.Created.UTC().Format("20060102150405Z")))
+
+	for font, name := range fontNames {
+		out.object(fontObjects[Font(font)], fmt.Sprintf(
+			"<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+
+	for _, img := range d.images {
+		out.stream(imageObjects[img], fmt.Sprintf(
+			"/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 %s /Filter /DCTDecode",
+			img.Width, img.Height, img.colorSpace, img.decode), img.data)
+	}
+
+	for i, page := range d.pages {
+		var resources strings.Builder
+		resources.WriteString("/Font <<")
+		for font := range fontNames {
+			if page.fonts[Font(font)] {
+				fmt.Fprintf(&resources, " /F%d %d 0 R", font+1, fontObjects[Font(font)])
+			}
+		}
+		resources.WriteString(" >> /XObject <<")
+		for _, img := range d.images {
+			if page.images[img] {
+				fmt.Fprintf(&resources, " /%s %d 0 R", img.name, imageObjects[img])
+			}
+		}
+		resources.WriteString(" >>")
+
+		out.object(pageObjects[i], fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+			pages, num(page.Width), num(page.Height), resources.String(), pageObjects[i]+1))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(page.content.Bytes())
+		zw.Close()
+		out.stream(pageObjects[i]+1, "/Filter /FlateDecode", compressed.Bytes())
+	}
+
+	// Cross-reference table: where each object starts
+	xref := out.n
+	out.printf("xref\n0 %d\n0000000000 65535 f \n", next)
+	for id := 1; id < next; id++ {
+		out.printf("%010d 00000 n \n", out.offsets[id])
+	}
+	out.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", next, catalog, info, xref)
+
+	return out.n, out.err
+}
+
+// pdfWriter writes objects, remembering where each starts
+type pdfWriter struct {
+	w       io.Writer
+	n       int64
+	err     error
+	offsets map[int]int64
+}
+
+func (p *pdfWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	p.err = err
+}
+
+func (p *pdfWriter) printf(format string, args ...any) {
+	p.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (p *pdfWriter) object(id int, body string) {
+	p.start(id)
+	p.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (p *pdfWriter) stream(id int, dict string, data []byte) {
+	p.start(id)
+	p.printf("%d 0 obj\n<< %s /Length %d >>\nstream\n", id, dict, len(data))
+	p.write(data)
+	p.printf("\nendstream\nendobj\n")
+}
+
+func (p *pdfWriter) start(id int) {
+	if p.offsets == nil {
+		p.offsets = map[int]int64{}
+	}
+	p.offsets[id] = p.n
+}
+
+func (c Color) operands() string {
+	return num(c.R) + " " + num(c.G) + " " + num(c.B)
+}
+
+// num formats a number briefly, to a hundredth of a point
+func num(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// escape makes s a PDF string literal's contents
+func escape(s []byte) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Font is one of the standard PDF fonts, which every reader has, so nothing
+// needs embedding. They cover Western European text (Windows-1252); other
+// characters can't be shown.
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	HelveticaOblique
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// helveticaWidths are the widths of characters 32 to 126, in thousandths of
+// the font size (from the Adobe font metrics); Oblique matches the regular font
+var helveticaWidths = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = []int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// Width is how wide s is set in f at size points
+func (f Font) Width(s string, size float64) float64 {
+	widths := helveticaWidths
+	if f == HelveticaBold {
+		widths = helveticaBoldWidths
+	}
+
+	total := 0
+	for _, b := range encode(s) {
+		switch {
+		case b >= 32 && b <= 126:
+			total += widths[b-32]
+		case b == 0x91 || b == 0x92:
+			total += 222 // Curly single quotes
+		case b == 0x93 || b == 0x94:
+			total += 333
+		case b == 0x85 || b == 0x97:
+			total += 1000 // Ellipsis and em dash
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap splits text into lines no wider than width, breaking between words
+// (and inside words too long for a line). Newlines in text start new lines.
+func (f Font) Wrap(text string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if len(encode(word)) == 0 {
+				continue // Nothing the font can show, such as an emoji
+			}
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if f.Width(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// A word wider than the line is broken wherever it has to be
+			for f.Width(word, size) > width {
+				cut := f.fit(word, size, width)
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fit is how many bytes of s fit in width, always at least its first character
+func (f Font) fit(s string, size, width float64) int {
+	end := 0
+	for i, r := range s {
+		next := i + utf8.RuneLen(r)
+		if end > 0 && f.Width(s[:next], size) > width {
+			break
+		}
+		end = next
+	}
+	return end
+}
+
+// windows1252 maps the characters Windows-1252 puts in 0x80 to 0x9F
+var windows1252 = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// encode converts s to Windows-1252 for the standard fonts. Symbols it can't
+// show (emoji and the like) are dropped, and other characters become "?".
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r < 32 || r == 0x7F:
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		case windows1252[r] != 0:
+			out = append(out, windows1252[r])
+		case unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r) || unicode.Is(unicode.Mn, r) ||
+			unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Cs, r) || unicode.Is(unicode.Co, r):
+			// Emoji, their modifiers and joiners
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+// Package qr encodes short text (links) as QR codes, in byte mode with medium
+// (M, ~15%) error correction, for versions 1 to 15 - up to 412 bytes.
+package qr
+
+import (
+	"errors"
+)
+
+// ErrTooLong is returned when the text doesn't fit in the largest supported version
+var ErrTooLong = errors.New("text is too long for a QR code")
+
+// Code is an encoded QR code: a Size x Size grid of modules, without the quiet
+// zone (4 modules of white on every side) readers need around it
+type Code struct {
+	Size    int
+	Version int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// blockLayout is how a version's codewords are split at level M: groups of
+// blocks with DataCodewords each (the second group has one more), and
+// ECCodewords of error correction per block
+type blockLayout struct {
+	ECCodewords int
+	Group1      int
+	Group2      int
+	Data1       int // Data codewords in each group 1 block; group 2 blocks have Data1+1
+}
+
+// layoutsM are the level M block layouts of versions 1 to 15 (ISO/IEC 18004 table 9)
+var layoutsM = []blockLayout{
+	{}, // versions count from 1
+	{10, 1, 0, 16},
+	{16, 1, 0, 28},
+	{26, 1, 0, 44},
+	{18, 2, 0, 32},
+	{24, 2, 0, 43},
+	{16, 4, 0, 27},
+	{18, 4, 0, 31},
+	{22, 2, 2, 38},
+	{22, 3, 2, 36},
+	{26, 4, 1, 43},
+	{30, 1, 4, 50},
+	{22, 6, 2, 36},
+	{22, 8, 1, 37},
+	{24, 4, 5, 40},
+	{24, 5, 5, 41},
+}
+
+// MaxVersion is the largest version Encode makes
+const MaxVersion = 15
+
+func (l blockLayout) dataCodewords() int {
+	return l.Group1*l.Data1 + l.Group2*(l.Data1+1)
+}
+
+// Encode makes the smallest QR code holding text
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+
+	version := 0
+	for v := 1; v <= MaxVersion; v++ {
+		// Mode indicator, character count (8 bits up to version 9, then 16) and the data
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= layoutsM[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	codewords := interleave(version, encodeData(version, data))
+
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(codewords)
+
+	// Use the mask that leaves the fewest patterns a reader could trip over
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask) // Masking twice undoes it
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+
+	return &Code{Size: c.size, Version: version, modules: c.modules}, nil
+}
+
+// encodeData writes the byte mode segment for data, and pads it to the
+// version's data capacity
+func encodeData(version int, data []byte) []byte {
+	capacity := layoutsM[version].dataCodewords() * 8
+
+	var bits bitBuffer
+	bits.append(0b0100, 4) // Byte mode
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	// Terminator, then up to a whole byte, then alternating pad bytes
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	out := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			out[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return out
+}
+
+// interleave splits data into the version's blocks, adds each block's error
+// correction, and interleaves them in the order they are placed
+func interleave(version int, data []byte) []byte {
+	layout := layoutsM[version]
+	generator := rsGenerator(layout.ECCodewords)
+
+	var blocks, ecc [][]byte
+	offset := 0
+	for i := 0; i < layout.Group1+layout.Group2; i++ {
+		n := layout.Data1
+		if i >= layout.Group1 {
+			n++
+		}
+		block := data[offset : offset+n]
+		offset += n
+		blocks = append(blocks, block)
+		ecc = append(ecc, rsRemainder(block, generator))
+	}
+
+	var out []byte
+	for i := 0; i <= layout.Data1; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ECCodewords; i++ {
+		for _, block := range ecc {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+// bitBuffer collects bits, most significant first
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
@@ -0,0 +1,167 @@
+// Package scan checks uploads for malware with ClamAV's clamd daemon, before
+// anything else reads them
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Status is what the scan made of a file
+type Status string
+
+const (
+	// Clean files had nothing found in them
+	Clean Status = "clean"
+	// Infected files matched a signature; they are quarantined, never served
+	Infected Status = "infected"
+	// Skipped files were uploaded while scanning was switched off (CLAMD_DISABLED)
+	Skipped Status = "skipped"
+)
+
+// Result is the outcome of scanning one file
+type Result struct {
+	Status Status
+	// Signature is the name of what clamd found (e.g. Win.Test.EICAR_HDB-1), when Infected
+	Signature string
+}
+
+// ErrUnavailable is returned when clamd couldn't be reached or couldn't scan
+// the file. Uploads are refused rather than let through unscanned.
+var ErrUnavailable = errors.New("virus scanner unavailable")
+
+// InfectedError means the upload matched a malware signature
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return "upload is infected with " + e.Signature
+}
+
+// chunkSize is how much of the file goes in each INSTREAM chunk
+const chunkSize = 64 * 1024
+
+// Clamd streams files to clamd with its INSTREAM command
+type Clamd struct {
+	// Address is clamd's TCP host:port, or the path of its unix socket
+	Address string
+	// Disabled switches scanning off: every file is Skipped. Without it a
+	// missing Address refuses every file, rather than let them through unscanned.
+	Disabled bool
+	// Timeout bounds connecting to clamd and scanning one file
+	Timeout time.Duration
+}
+
+// Enabled reports whether files are scanned
+func (c Clamd) Enabled() bool {
+	return !c.Disabled
+}
+
+// Scan sends everything read from r to clamd and returns its verdict. Errors
+// talking to clamd (or clamd refusing the file, e.g. over its StreamMaxLength)
+// wrap ErrUnavailable.
+func (c Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if !c.Enabled() {
+		return Result{Status: Skipped}, nil
+	}
+	if c.Address == "" {
+		return Result{}, fmt.Errorf("%w: no clamd address configured", ErrUnavailable)
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	network := "tcp"
+	if strings.HasPrefix(c.Address, "/") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, c.Address)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// clamd stops reading and replies as soon as it has had too much, so a
+	// failed write may still leave a reply to read
+	writeErr := c.send(conn, r)
+	var readErr *readError
+	if errors.As(writeErr, &readErr) {
+		return Result{}, readErr.err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if writeErr != nil {
+			err = writeErr
+		}
+		return Result{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return parseReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+// send writes the INSTREAM command, r in length-prefixed chunks and the
+// zero-length chunk that ends the stream
+func (c Clamd) send(conn net.Conn, r io.Reader) error {
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, writeErr := conn.Write(buf[:4+n]); writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &readError{err}
+		}
+	}
+
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// readError is a failure reading the file being scanned, which isn't clamd's fault
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string {
+	return e.err.Error()
+}
+
+// parseReply reads clamd's "stream: OK" / "stream: <signature> FOUND" / "... ERROR"
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+
+	switch {
+	case reply == "OK":
+		return Result{Status: Clean}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Status: Infected, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("%w: clamd replied %q", ErrUnavailable, reply)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/base64"
+	"log"
+
+	"aletterahead-api/sealed"
+)
+
+// InitSealedMessages reads the master key sealed messages are kept under
+// (SEALED_MESSAGE_KEY, 32 bytes in base64 - e.g. `openssl rand -base64 32`).
+// Without it donors can't seal messages. Losing or changing it loses every
+// sealed message, so it is never generated.
+func InitSealedMessages() sealed.Keyring {
+	value := getEnv("SEALED_MESSAGE_KEY", "")
+	if value == "" {
+		log.Printf("SEALED_MESSAGE_KEY is not set, donors can't send sealed messages")
+		return sealed.Keyring{}
+	}
+
+	master, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		log.Fatalf("Invalid SEALED_MESSAGE_KEY: not base64: %v", err)
+	}
+	keys, err := sealed.NewKeyring(master)
+	if err != nil {
+		log.Fatalf("Invalid SEALED_MESSAGE_KEY: %v", err)
+	}
+	return keys
+}
@@ -0,0 +1,212 @@
+// Package sealed keeps sealed messages - private letters a donor writes for the
+// child alone - encrypted until the child is 18. Each child has their own
+// AES-256 key, kept in child_keys encrypted by the master key
+// (SEALED_MESSAGE_KEY), and every letter is encrypted with it using AES-GCM.
+// Nothing but a delivered time capsule opens them (see Keyring.Opener).
+package sealed
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// KeySize is the length of the master key and of each child's key
+const KeySize = 32
+
+// ReleaseAge is how old a child must be before their sealed letters are opened
+const ReleaseAge = 18
+
+var (
+	// ErrDisabled is returned when there is no master key to seal with
+	ErrDisabled = errors.New("sealed messages are not configured")
+	// ErrNotDelivered is returned when a capsule hasn't been delivered
+	ErrNotDelivered = errors.New("time capsule has not been delivered")
+	// ErrWrongKey is returned when a child's key was sealed by another master key
+	ErrWrongKey = errors.New("child key was sealed with a different master key")
+)
+
+// Keyring seals and opens letters with each child's key. The zero Keyring has
+// no master key, and can't do either.
+type Keyring struct {
+	master cipher.AEAD
+	id     string
+}
+
+// NewKeyring uses master (KeySize bytes) to protect the children's keys
+func NewKeyring(master []byte) (Keyring, error) {
+	if len(master) != KeySize {
+		return Keyring{}, fmt.Errorf("master key must be %d bytes, not %d", KeySize, len(master))
+	}
+	aead, err := newGCM(master)
+	if err != nil {
+		return Keyring{}, err
+	}
+	// The key's ID tells a wrong master key from a corrupt child key
+	sum := sha256.Sum256(append([]byte("aletterahead sealed master key\n"), master...))
+	return Keyring{master: aead, id: hex.EncodeToString(sum[:8])}, nil
+}
+
+// Enabled reports whether the keyring has a master key
+func (k Keyring) Enabled() bool {
+	return k.master != nil
+}
+
+// Seal encrypts a letter for childID, creating the child's key the first time
+func (k Keyring) Seal(ctx context.Context, db *pgxpool.Pool, childID int, message string) ([]byte, error) {
+	if !k.Enabled() {
+		return nil, ErrDisabled
+	}
+	aead, err := k.childKey(ctx, db, childID, true)
+	if err != nil {
+		return nil, err
+	}
+	return seal(aead, []byte(message), childAD(childID))
+}
+
+// Opener opens the sealed letters in one delivered time capsule
+type Opener struct {
+	childID int
+	aead    cipher.AEAD
+	// OpensOn is the child's 18th birthday; before it letters stay sealed
+	OpensOn time.Time
+}
+
+// Opener is the only way to read sealed letters: it is given for a capsule
+// that has been emailed to the child, and only opens letters once the child is
+// 18 (a parent can have the capsule delivered earlier, see Opener.Ready).
+func (k Keyring) Opener(ctx context.Context, db *pgxpool.Pool, deliveryID int) (Opener, error) {
+	if !k.Enabled() {
+		return Opener{}, ErrDisabled
+	}
+
+	query := `
+		SELECT c.child_id, (c.DOB + make_interval(years => $2))::DATE
+		FROM capsule_deliveries d
+		JOIN children c ON c.child_id = d.child_id
+		WHERE d.delivery_id = $1
+		AND d.sent_at IS NOT NULL
+	`
+	var o Opener
+	err := db.QueryRow(ctx, query, deliveryID, ReleaseAge).Scan(&o.childID, &o.OpensOn)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Opener{}, ErrNotDelivered
+	}
+	if err != nil {
+		return Opener{}, fmt.Errorf("failed to load capsule %d: %w", deliveryID, err)
+	}
+
+	// A child with no key has no sealed letters yet
+	aead, err := k.childKey(ctx, db, o.childID, false)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return Opener{}, err
+	}
+	o.aead = aead
+	return o, nil
+}
+
+// Ready reports whether the child is old enough, on now, for their letters to open
+func (o Opener) Ready(now time.Time) bool {
+	return !now.Before(o.OpensOn)
+}
+
+// Open decrypts one of the child's letters. It fails until Ready.
+func (o Opener) Open(sealed []byte, now time.Time) (string, error) {
+	if !o.Ready(now) {
+		return "", fmt.Errorf("sealed letters open on %s", o.OpensOn.Format("2006-01-02"))
+	}
+	if o.aead == nil {
+		return "", errors.New("child has no sealed letter key")
+	}
+	message, err := open(o.aead, sealed, childAD(o.childID))
+	if err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// childKey loads the child's key, creating it first if create is set. Two
+// requests creating it at once both end up with the one that was stored.
+func (k Keyring) childKey(ctx context.Context, db *pgxpool.Pool, childID int, create bool) (cipher.AEAD, error) {
+	if create {
+		key := make([]byte, KeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		wrapped, err := seal(k.master, key, keyAD(childID))
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Exec(ctx, `
+			INSERT INTO child_keys (child_id, wrapped_key, master_key_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (child_id) DO NOTHING
+		`, childID, wrapped, k.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store key for child %d: %w", childID, err)
+		}
+	}
+
+	var wrapped []byte
+	var masterKeyID string
+	err := db.QueryRow(ctx,
+		`SELECT wrapped_key, master_key_id FROM child_keys WHERE child_id = $1`,
+		childID,
+	).Scan(&wrapped, &masterKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if masterKeyID != k.id {
+		return nil, ErrWrongKey
+	}
+
+	key, err := open(k.master, wrapped, keyAD(childID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key for child %d: %w", childID, err)
+	}
+	return newGCM(key)
+}
+
+// childAD and keyAD tie a ciphertext to its child, so it can't be moved to another
+func childAD(childID int) []byte {
+	return []byte("message child " + strconv.Itoa(childID))
+}
+
+func keyAD(childID int) []byte {
+	return []byte("key child " + strconv.Itoa(childID))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext as nonce || ciphertext
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(aead cipher.AEAD, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, ad)
+}
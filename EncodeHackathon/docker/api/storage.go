@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"aletterahead-api/handlers"
+	"aletterahead-api/media"
+	"aletterahead-api/scan"
+	"aletterahead-api/storage"
+	"aletterahead-api/transcode"
+)
+
+// InitStorage opens the backend uploads are kept in: a local directory, or an
+// S3-compatible bucket so several API hosts can share the same files
+func InitStorage() (storage.Storage, error) {
+	switch driver := getEnv("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return storage.NewLocal(getEnv("STORAGE_LOCAL_DIR", "/var/uploads"))
+
+	case "s3":
+		pathStyle, err := strconv.ParseBool(getEnv("S3_USE_PATH_STYLE", "false"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3_USE_PATH_STYLE: %w", err)
+		}
+
+		store, err := storage.NewS3(storage.S3Config{
+			Endpoint:        getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          getEnv("S3_REGION", "us-east-1"),
+			Bucket:          getEnv("S3_BUCKET", ""),
+			AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+			SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+			UsePathStyle:    pathStyle,
+			PublicEndpoint:  getEnv("S3_PUBLIC_ENDPOINT", ""),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Not fatal - the credentials may be allowed to use the bucket but not create it
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := store.EnsureBucket(ctx); err != nil {
+			log.Printf("Could not create S3 bucket: %v", err)
+		}
+		return store, nil
+
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q (use local or s3)", driver)
+	}
+}
+
+// InitScanner reads where uploads are scanned for malware. Every upload is
+// scanned unless CLAMD_DISABLED=true, so a missing CLAMD_ADDRESS is fatal.
+func InitScanner() scan.Clamd {
+	disabled, err := strconv.ParseBool(getEnv("CLAMD_DISABLED", "false"))
+	if err != nil {
+		log.Fatalf("Invalid CLAMD_DISABLED: %v", err)
+	}
+
+	scanner := scan.Clamd{
+		Address:  getEnv("CLAMD_ADDRESS", ""),
+		Disabled: disabled,
+		Timeout:  getDurationEnv("CLAMD_TIMEOUT", time.Minute),
+	}
+	if scanner.Disabled {
+		log.Printf("CLAMD_DISABLED is set, uploads will not be scanned for malware")
+	} else if scanner.Address == "" {
+		log.Fatalf("CLAMD_ADDRESS is not set; set it to clamd's address, or CLAMD_DISABLED=true to accept uploads unscanned")
+	}
+	return scanner
+}
+
+// InitPublicBaseURL reads the API's address as browsers see it. Video and photo
+// links are built from it, never from a request's Host or X-Forwarded-* headers,
+// which whoever sends the request controls.
+func InitPublicBaseURL() string {
+	baseURL := strings.TrimSuffix(getEnv("PUBLIC_BASE_URL", ""), "/")
+	if baseURL == "" {
+		log.Fatalf("PUBLIC_BASE_URL is not set; set it to the address browsers reach the API on (e.g. https://api.aletterahead.com)")
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		log.Fatalf("Invalid PUBLIC_BASE_URL %q: must be an http(s) address like https://api.aletterahead.com", baseURL)
+	}
+	return baseURL
+}
+
+// InitVideos reads how uploaded videos are served
+func InitVideos(store storage.Storage, scanner scan.Clamd, publicBaseURL string) handlers.VideoConfig {
+	cfg := handlers.VideoConfig{
+		Store:         store,
+		Scanner:       scanner,
+		ServeMode:     getEnv("VIDEO_SERVE_MODE", handlers.VideoServeStream),
+		PresignTTL:    getDurationEnv("VIDEO_PRESIGN_TTL", 15*time.Minute),
+		PublicBaseURL: publicBaseURL,
+		Prober:        media.Prober{FFprobePath: getEnv("FFPROBE_PATH", "ffprobe")},
+		Limits: media.Limits{
+			MaxDuration: getDurationEnv("VIDEO_MAX_DURATION", 5*time.Minute),
+			MaxWidth:    getIntEnv("VIDEO_MAX_WIDTH", 3840),
+			MaxHeight:   getIntEnv("VIDEO_MAX_HEIGHT", 2160),
+		},
+		Links: media.LinkSigner{
+			Secret: []byte(getEnv("VIDEO_LINK_SECRET", "")),
+			TTL:    getDurationEnv("VIDEO_LINK_TTL", time.Hour),
+		},
+	}
+
+	if cfg.ServeMode != handlers.VideoServeStream && cfg.ServeMode != handlers.VideoServePresign {
+		log.Printf("Invalid VIDEO_SERVE_MODE %q, using %q", cfg.ServeMode, handlers.VideoServeStream)
+		cfg.ServeMode = handlers.VideoServeStream
+	}
+	if _, local := store.(*storage.Local); local && cfg.ServeMode == handlers.VideoServePresign {
+		log.Printf("VIDEO_SERVE_MODE %q needs STORAGE_DRIVER=s3, streaming videos instead", cfg.ServeMode)
+		cfg.ServeMode = handlers.VideoServeStream
+	}
+
+	// Without a shared secret links only work on this host until it restarts
+	if len(cfg.Links.Secret) == 0 {
+		log.Printf("VIDEO_LINK_SECRET is not set, signing video links with a random key")
+		cfg.Links.Secret = make([]byte, 32)
+		if _, err := rand.Read(cfg.Links.Secret); err != nil {
+			log.Fatal("Failed to generate video link key:", err)
+		}
+	}
+
+	return cfg
+}
+
+// InitTus reads the limits for resumable (tus) video uploads
+func InitTus() handlers.TusConfig {
+	cfg := handlers.TusConfig{
+		MaxSize: 50 * 1024 * 1024,
+		Expiry:  getDurationEnv("TUS_UPLOAD_EXPIRY", 24*time.Hour),
+	}
+
+	if value := getEnv("TUS_MAX_SIZE", ""); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 {
+			log.Printf("Invalid TUS_MAX_SIZE %q, using %d", value, cfg.MaxSize)
+		} else {
+			cfg.MaxSize = size
+		}
+	}
+
+	return cfg
+}
+
+// InitPhotos reads how uploaded event photos are checked and resized
+func InitPhotos(store storage.Storage, scanner scan.Clamd, publicBaseURL string) handlers.PhotoConfig {
+	cfg := handlers.PhotoConfig{
+		Store:         store,
+		Scanner:       scanner,
+		Prober:        media.Prober{FFprobePath: getEnv("FFPROBE_PATH", "ffprobe")},
+		Limits:        media.PhotoLimits{MaxDimension: getIntEnv("PHOTO_MAX_DIMENSION", 12000)},
+		FFmpeg:        transcode.FFmpeg{Path: getEnv("FFMPEG_PATH", "ffmpeg")},
+		HEIF:          transcode.HEIFDecoder{Path: getEnv("HEIF_DEC_PATH", "heif-dec")},
+		MaxSize:       20 * 1024 * 1024,
+		Timeout:       getDurationEnv("PHOTO_PROCESS_TIMEOUT", 30*time.Second),
+		PublicBaseURL: publicBaseURL,
+	}
+
+	if value := getEnv("PHOTO_MAX_SIZE", ""); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 {
+			log.Printf("Invalid PHOTO_MAX_SIZE %q, using %d", value, cfg.MaxSize)
+		} else {
+			cfg.MaxSize = size
+		}
+	}
+
+	return cfg
+}
+
+// InitBookLinks reads how long the video links printed as QR codes in event
+// books work. Like capsule links they are signed with VIDEO_LINK_SECRET, never
+// the random key InitVideos falls back to: printed codes must survive restarts.
+func InitBookLinks() media.LinkSigner {
+	links := media.LinkSigner{
+		Secret: []byte(getEnv("VIDEO_LINK_SECRET", "")),
+		TTL:    getDurationEnv("BOOK_VIDEO_LINK_TTL", 5*365*24*time.Hour),
+	}
+	if len(links.Secret) == 0 {
+		log.Printf("VIDEO_LINK_SECRET is not set, event books are disabled")
+	}
+	return links
+}
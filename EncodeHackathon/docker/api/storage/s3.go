@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config holds the connection details for an S3-compatible bucket (AWS S3, MinIO, ...)
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-west-2.amazonaws.com or http://minio:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// UsePathStyle addresses objects as endpoint/bucket/key (needed for MinIO)
+	// instead of bucket.endpoint/key
+	UsePathStyle bool
+	// PublicEndpoint is the endpoint browsers use to reach the bucket, when it
+	// differs from Endpoint (e.g. MinIO inside docker compose); used for presigned URLs
+	PublicEndpoint string
+}
+
+// S3 stores objects in an S3-compatible bucket using the MinIO client
+type S3 struct {
+	cfg    S3Config
+	client *minio.Core
+	// public signs URLs for the public endpoint; it never sends requests
+	public *minio.Client
+}
+
+// NewS3 creates an S3 store for cfg
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket not configured")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	client, err := newMinioClient(cfg, cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: %w", cfg.Endpoint, err)
+	}
+
+	public := client
+	if cfg.PublicEndpoint != "" {
+		public, err = newMinioClient(cfg, cfg.PublicEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 public endpoint %q: %w", cfg.PublicEndpoint, err)
+		}
+	}
+
+	return &S3{
+		cfg:    cfg,
+		client: &minio.Core{Client: client},
+		public: public,
+	}, nil
+}
+
+// newMinioClient connects to endpoint, a URL such as http://minio:9000
+func newMinioClient(cfg S3Config, endpoint string) (*minio.Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("must be an http or https URL")
+	}
+	if strings.Trim(u.Path, "/") != "" {
+		return nil, errors.New("must not have a path")
+	}
+
+	lookup := minio.BucketLookupDNS
+	if cfg.UsePathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	return minio.New(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:       u.Scheme == "https",
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+}
+
+// EnsureBucket creates the bucket if it doesn't exist yet
+func (s *S3) EnsureBucket(ctx context.Context) error {
+	err := s.client.MakeBucket(ctx, s.cfg.Bucket, minio.MakeBucketOptions{Region: s.cfg.Region})
+	if err == nil {
+		return nil
+	}
+	if code := minio.ToErrorResponse(err).Code; code == "BucketAlreadyOwnedByYou" || code == "BucketAlreadyExists" {
+		return nil
+	}
+	return fmt.Errorf("failed to create bucket %s: %w", s.cfg.Bucket, err)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+
+	_, err := s.client.Client.PutObject(ctx, s.cfg.Bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadSeekCloser, Object, error) {
+	obj, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	return &s3Reader{ctx: ctx, s3: s, key: key, size: obj.Size}, obj, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Object, error) {
+	if !ValidKey(key) {
+		return Object{}, fmt.Errorf("invalid storage key %q", key)
+	}
+
+	info, err := s.client.Client.StatObject(ctx, s.cfg.Bucket, key, minio.StatObjectOptions{})
+	if isNotFound(err) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	return s.object(info), nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+
+	err := s.client.Client.RemoveObject(ctx, s.cfg.Bucket, key, minio.RemoveObjectOptions{})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns every object under prefix, paging through the bucket listing
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var objects []Object
+	for info := range s.client.Client.ListObjects(ctx, s.cfg.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, info.Err)
+		}
+		obj := s.object(info)
+		obj.ContentType = ContentTypeFor(info.Key)
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// PresignGet signs a GET for the object against the public endpoint
+func (s *S3) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+
+	u, err := s.public.PresignedGetObject(ctx, s.cfg.Bucket, key, expires, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+	return u.String(), nil
+}
+
+// object converts the client's object info, quoting the ETag as HTTP does
+func (s *S3) object(info minio.ObjectInfo) Object {
+	obj := Object{
+		Key:         info.Key,
+		Size:        info.Size,
+		ModTime:     info.LastModified,
+		ContentType: info.ContentType,
+	}
+	if info.ETag != "" {
+		obj.ETag = strconv.Quote(strings.Trim(info.ETag, `"`))
+	}
+	if obj.ContentType == "" || obj.ContentType == "application/octet-stream" {
+		obj.ContentType = ContentTypeFor(info.Key)
+	}
+	return obj
+}
+
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	resp := minio.ToErrorResponse(err)
+	return resp.StatusCode == http.StatusNotFound || resp.Code == "NoSuchKey"
+}
+
+// s3Reader reads an object with ranged GETs, so seeking (for HTTP range
+// requests) doesn't download the part of the file that is skipped
+type s3Reader struct {
+	ctx    context.Context
+	s3     *S3
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		opts := minio.GetObjectOptions{}
+		if r.offset > 0 {
+			if err := opts.SetRange(r.offset, 0); err != nil {
+				return 0, err
+			}
+		}
+
+		body, _, header, err := r.s3.client.GetObject(r.ctx, r.s3.cfg.Bucket, r.key, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", r.key, err)
+		}
+
+		// A backend that ignores the range sends the object from byte 0 with a
+		// 200, which would be served as the wrong part of the file
+		if r.offset > 0 && !strings.HasPrefix(header.Get("Content-Range"), fmt.Sprintf("bytes %d-", r.offset)) {
+			body.Close()
+			return 0, fmt.Errorf("failed to read %s: storage did not return a partial response from byte %d", r.key, r.offset)
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = r.offset + offset
+	case io.SeekEnd:
+		next = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if next != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = next
+	return next, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
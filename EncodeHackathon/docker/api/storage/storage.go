@@ -0,0 +1,77 @@
+// Package storage keeps uploaded files (videos, photos) on local disk or in an
+// S3-compatible bucket, so the API can run on more than one host.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no object exists for a key
+var ErrNotFound = errors.New("object not found")
+
+// ErrPresignUnsupported is returned by backends that can't hand out direct URLs
+var ErrPresignUnsupported = errors.New("presigned URLs are not supported by this storage backend")
+
+// Object describes a stored file
+type Object struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+	// ETag is a strong validator for the object's content, quoted as in HTTP
+	ETag string
+}
+
+// Storage stores files under slash-separated keys such as "videos/123_clip.mp4"
+type Storage interface {
+	// Put stores size bytes from r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the object's content; the reader supports seeking, for range requests
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, Object, error)
+	// Stat returns the object's metadata
+	Stat(ctx context.Context, key string) (Object, error)
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// PresignGet returns a URL the client can fetch the object from directly until it expires
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// ValidKey reports whether key is a safe relative key (no "..", no leading slash, no backslashes)
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// ContentTypeFor guesses a content type from a key's extension
+func ContentTypeFor(key string) string {
+	switch ext := strings.ToLower(path.Ext(key)); ext {
+	case ".mp4":
+		return "video/mp4"
+	case ".mov":
+		return "video/quicktime"
+	case ".avi":
+		return "video/x-msvideo"
+	case ".webm":
+		return "video/webm"
+	default:
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
+		return "application/octet-stream"
+	}
+}
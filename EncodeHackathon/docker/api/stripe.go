@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+
+	"aletterahead-api/payments"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+func InitStripe() *client.API {
+	secretKey := getEnv("STRIPE_SECRET_KEY", "")
+	if secretKey == "" {
+		log.Println("STRIPE_SECRET_KEY not set, payment requests will fail")
+	}
+
+	// Point the client at a local Stripe stand-in (e.g. stripe-mock) if configured
+	var backends *stripe.Backends
+	if apiBase := getEnv("STRIPE_API_BASE", ""); apiBase != "" {
+		backends = &stripe.Backends{
+			API: stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+				URL: stripe.String(apiBase),
+			}),
+			Connect: stripe.GetBackendWithConfig(stripe.ConnectBackend, &stripe.BackendConfig{
+				URL: stripe.String(apiBase),
+			}),
+			Uploads: stripe.GetBackendWithConfig(stripe.UploadsBackend, &stripe.BackendConfig{
+				URL: stripe.String(apiBase),
+			}),
+		}
+	}
+
+	sc := &client.API{}
+	sc.Init(secretKey, backends)
+	return sc
+}
+
+// InitConnect reads how parents' Connect accounts are created and where Stripe
+// onboarding sends them back to (the sign-in page handles both return URLs)
+func InitConnect() payments.ConnectConfig {
+	return payments.ConnectConfig{
+		AccountType: getEnv("STRIPE_CONNECT_ACCOUNT_TYPE", "express"),
+		Country:     getEnv("STRIPE_CONNECT_COUNTRY", "GB"),
+		RefreshURL:  getEnv("STRIPE_CONNECT_REFRESH_URL", "http://localhost:3000/sign-in/?stripe_status=refresh"),
+		ReturnURL:   getEnv("STRIPE_CONNECT_RETURN_URL", "http://localhost:3000/sign-in/?stripe_status=success"),
+	}
+}
@@ -0,0 +1,140 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FFmpeg runs a local ffmpeg binary
+type FFmpeg struct {
+	// Path is the ffmpeg executable (looked up on $PATH if it has no slash)
+	Path string
+}
+
+// Normalize converts the video at input into an H.264/AAC MP4 at output, with
+// the moov atom moved to the front (faststart) so browsers can start playing
+// while it downloads
+func (f FFmpeg) Normalize(ctx context.Context, input, output string) error {
+	args := []string{
+		"-hide_banner", "-nostdin", "-y",
+		"-i", input,
+		// First video stream and (if there is one) the first audio stream
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+		"-profile:v", "high", "-pix_fmt", "yuv420p",
+		// H.264 with yuv420p needs even dimensions
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+		"-c:a", "aac", "-b:a", "128k", "-ac", "2",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		output,
+	}
+
+	return f.run(ctx, args)
+}
+
+// Thumbnail saves the frame at offset into the video as a JPEG at most 640 pixels
+// wide. Videos shorter than offset get their first frame instead.
+func (f FFmpeg) Thumbnail(ctx context.Context, input, output string, offset time.Duration) error {
+	err := f.frame(ctx, input, output, offset)
+	if err == nil || offset == 0 {
+		return err
+	}
+	return f.frame(ctx, input, output, 0)
+}
+
+func (f FFmpeg) frame(ctx context.Context, input, output string, offset time.Duration) error {
+	os.Remove(output)
+
+	args := []string{
+		"-hide_banner", "-nostdin", "-y",
+		// Seeking before -i jumps straight to the nearest keyframe
+		"-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64),
+		"-i", input,
+		"-frames:v", "1",
+		"-vf", "scale='min(640,iw)':-2",
+		"-q:v", "3",
+		"-f", "image2",
+		output,
+	}
+	if err := f.run(ctx, args); err != nil {
+		return err
+	}
+
+	// Seeking past the end succeeds but writes nothing
+	if info, err := os.Stat(output); err != nil || info.Size() == 0 {
+		return fmt.Errorf("no frame at %s", offset)
+	}
+	return nil
+}
+
+// orientationFilters turn a photo with EXIF orientation 2-8 the right way up
+var orientationFilters = map[int]string{
+	2: "hflip",
+	3: "hflip,vflip",
+	4: "vflip",
+	5: "transpose=cclock_flip",
+	6: "transpose=clock",
+	7: "transpose=clock_flip",
+	8: "transpose=cclock",
+}
+
+// ResizePhoto writes the image at input to output as a JPEG, turned upright
+// for its EXIF orientation and then either shrunk to fit inside width x height
+// or, with crop, scaled and cut to fill it exactly. Nothing of the input's
+// metadata (EXIF, GPS position, comments) is copied to the output.
+func (f FFmpeg) ResizePhoto(ctx context.Context, input, output string, orientation, width, height int, crop bool) error {
+	var filters []string
+	if filter, ok := orientationFilters[orientation]; ok {
+		filters = append(filters, filter)
+	}
+	if crop {
+		filters = append(filters,
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase", width, height),
+			fmt.Sprintf("crop=%d:%d", width, height),
+		)
+	} else {
+		// Never enlarge a photo that is already small enough
+		filters = append(filters, fmt.Sprintf("scale='min(%d,iw)':'min(%d,ih)':force_original_aspect_ratio=decrease", width, height))
+	}
+
+	args := []string{
+		"-hide_banner", "-nostdin", "-y",
+		// The orientation is applied above, from the EXIF data we read ourselves
+		"-noautorotate",
+		"-i", input,
+		"-frames:v", "1",
+		"-map_metadata", "-1",
+		"-vf", strings.Join(filters, ","),
+		"-q:v", "3",
+		"-f", "image2", "-c:v", "mjpeg",
+		output,
+	}
+	return f.run(ctx, args)
+}
+
+func (f FFmpeg) run(ctx context.Context, args []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, f.Path, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %v: %s", err, lastLines(stderr.String(), 5))
+	}
+	return nil
+}
+
+// lastLines keeps the end of ffmpeg's output, where the error is
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
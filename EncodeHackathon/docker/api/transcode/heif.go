@@ -0,0 +1,28 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// HEIFDecoder runs libheif's heif-dec, for the HEIC photos iPhones take, which
+// ffmpeg can't read
+type HEIFDecoder struct {
+	// Path is the heif-dec executable (looked up on $PATH if it has no slash)
+	Path string
+}
+
+// ToPNG decodes the primary image of the HEIC/HEIF file at input to a PNG at
+// output, already turned the way its rotation and mirroring say
+func (h HEIFDecoder) ToPNG(ctx context.Context, input, output string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Path, input, output)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("heif-dec failed: %v: %s", err, lastLines(stderr.String(), 5))
+	}
+	return nil
+}
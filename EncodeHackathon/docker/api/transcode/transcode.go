@@ -0,0 +1,149 @@
+// Package transcode converts uploaded videos into MP4s every browser can play
+// (H.264 video, AAC audio, moov atom at the front so playback starts before the
+// whole file has downloaded). Jobs are queued in the video_transcodes table and
+// worked by jobs.Transcoder, so any API host can pick them up.
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Status is where a video's transcode job has got to
+type Status string
+
+const (
+	Queued     Status = "queued"
+	Processing Status = "processing"
+	Ready      Status = "ready"
+	Failed     Status = "failed"
+)
+
+// ErrNotFound is returned when a video has no transcode job (it was uploaded
+// before videos were transcoded)
+var ErrNotFound = errors.New("transcode job not found")
+
+// Job is the transcode job for one uploaded video
+type Job struct {
+	ID             int
+	SourceFilename string
+	Status         Status
+	Attempts       int
+	OutputKey      *string
+	ThumbnailKey   *string
+	Error          *string
+	CreatedAt      time.Time
+	FinishedAt     *time.Time
+}
+
+// SourceKey is the storage key of an uploaded video
+func SourceKey(sourceFilename string) string {
+	return "videos/" + sourceFilename
+}
+
+// OutputKey is the storage key of the normalized MP4 for an uploaded video
+func OutputKey(sourceFilename string) string {
+	return "videos/transcoded/" + sourceFilename + ".mp4"
+}
+
+const jobColumns = `job_id, source_filename, status, attempts, output_key, thumbnail_key, error, created_at, finished_at`
+
+func scanJob(row pgx.Row) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.SourceFilename, &j.Status, &j.Attempts, &j.OutputKey, &j.ThumbnailKey, &j.Error, &j.CreatedAt, &j.FinishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Job{}, ErrNotFound
+	}
+	return j, err
+}
+
+// Enqueue queues a newly uploaded video for transcoding
+func Enqueue(ctx context.Context, db *pgxpool.Pool, sourceFilename string) (Job, error) {
+	insertQuery := `
+		INSERT INTO video_transcodes (source_filename)
+		VALUES ($1)
+		ON CONFLICT (source_filename) DO UPDATE SET updated_at = NOW()
+		RETURNING ` + jobColumns
+
+	return scanJob(db.QueryRow(ctx, insertQuery, sourceFilename))
+}
+
+// Get returns the transcode job for an uploaded video
+func Get(ctx context.Context, db *pgxpool.Pool, sourceFilename string) (Job, error) {
+	return scanJob(db.QueryRow(ctx, `SELECT `+jobColumns+` FROM video_transcodes WHERE source_filename = $1`, sourceFilename))
+}
+
+// Claim takes the oldest queued job, or a processing one whose worker stopped
+// before staleAfter, and marks it processing. It returns ErrNotFound when there
+// is nothing to do. SKIP LOCKED lets several workers (and hosts) claim at once.
+func Claim(ctx context.Context, db *pgxpool.Pool, staleAfter time.Duration) (Job, error) {
+	claimQuery := `
+		UPDATE video_transcodes
+		SET status = 'processing',
+			attempts = attempts + 1,
+			started_at = NOW(),
+			updated_at = NOW()
+		WHERE job_id = (
+			SELECT job_id FROM video_transcodes
+			WHERE status = 'queued'
+			OR (status = 'processing' AND started_at < $1)
+			ORDER BY created_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	return scanJob(db.QueryRow(ctx, claimQuery, time.Now().Add(-staleAfter).UTC()))
+}
+
+// ThumbnailKey is the storage key of the poster frame for an uploaded video
+func ThumbnailKey(sourceFilename string) string {
+	return "videos/thumbnails/" + sourceFilename + ".jpg"
+}
+
+// MarkReady records the normalized output of a job, and its thumbnail (which
+// may be nil if no frame could be taken)
+func MarkReady(ctx context.Context, db *pgxpool.Pool, jobID int, outputKey string, outputSize int64, thumbnailKey *string) error {
+	updateQuery := `
+		UPDATE video_transcodes
+		SET status = 'ready',
+			output_key = $1,
+			output_size_bytes = $2,
+			thumbnail_key = $3,
+			error = NULL,
+			finished_at = NOW(),
+			updated_at = NOW()
+		WHERE job_id = $4
+	`
+
+	_, err := db.Exec(ctx, updateQuery, outputKey, outputSize, thumbnailKey, jobID)
+	return err
+}
+
+// MarkFailed records why a job failed. Jobs that still have attempts left go
+// back on the queue; the rest are failed for good.
+func MarkFailed(ctx context.Context, db *pgxpool.Pool, job Job, cause error, maxAttempts int) (Status, error) {
+	status := Queued
+	if job.Attempts >= maxAttempts {
+		status = Failed
+	}
+
+	updateQuery := `
+		UPDATE video_transcodes
+		SET status = $1,
+			error = $2,
+			finished_at = CASE WHEN $1 = 'failed' THEN NOW() ELSE NULL END,
+			updated_at = NOW()
+		WHERE job_id = $3
+	`
+
+	if _, err := db.Exec(ctx, updateQuery, string(status), cause.Error(), job.ID); err != nil {
+		return status, fmt.Errorf("failed to record transcode failure: %w", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,305 @@
+// Package uploads keeps track of resumable (tus) video uploads. The bytes received so
+// far are kept as numbered chunks in the storage backend, so an upload can be resumed
+// on any API host, and are read back as one stream once the upload is complete.
+package uploads
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"aletterahead-api/storage"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound is returned when the upload doesn't exist (or has been removed)
+var ErrNotFound = errors.New("upload not found")
+
+// ErrOffsetConflict is returned when another request moved the upload on first
+var ErrOffsetConflict = errors.New("upload offset has changed")
+
+// Upload is a resumable upload and how much of it has been received
+type Upload struct {
+	ID               string
+	EventID          *int // Event the video is for; nil for uploads started before videos belonged to events
+	Length           int64
+	Offset           int64
+	ChunkCount       int
+	OriginalFilename string
+	Metadata         *string // Raw tus Upload-Metadata header
+	VideoID          *int    // Set once the upload is complete
+	VideoFilename    *string // Set once the upload is complete
+	ExpiresAt        time.Time
+	CompletedAt      *time.Time
+}
+
+// Complete reports whether the upload has been assembled into a video
+func (u Upload) Complete() bool {
+	return u.CompletedAt != nil
+}
+
+// Expired reports whether an unfinished upload has been abandoned
+func (u Upload) Expired(now time.Time) bool {
+	return !u.Complete() && now.After(u.ExpiresAt)
+}
+
+// NewID returns a random upload ID
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidID reports whether id looks like an ID from NewID
+func ValidID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// chunkKey is where the index'th piece of an upload is stored
+func chunkKey(id string, index int) string {
+	return fmt.Sprintf("uploads/%s/%06d", id, index)
+}
+
+const uploadColumns = `
+	upload_id, event_id, upload_length, upload_offset, chunk_count, original_filename,
+	metadata, video_id, video_filename, expires_at, completed_at
+`
+
+func scanUpload(row pgx.Row) (Upload, error) {
+	var u Upload
+	err := row.Scan(
+		&u.ID,
+		&u.EventID,
+		&u.Length,
+		&u.Offset,
+		&u.ChunkCount,
+		&u.OriginalFilename,
+		&u.Metadata,
+		&u.VideoID,
+		&u.VideoFilename,
+		&u.ExpiresAt,
+		&u.CompletedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Upload{}, ErrNotFound
+	}
+	return u, err
+}
+
+// Create starts a new upload of length bytes, of a video for eventID
+func Create(ctx context.Context, db *pgxpool.Pool, eventID int, length int64, originalFilename string, metadata *string, expiresAt time.Time) (Upload, error) {
+	id, err := NewID()
+	if err != nil {
+		return Upload{}, err
+	}
+
+	insertQuery := `
+		INSERT INTO video_uploads (upload_id, event_id, upload_length, original_filename, metadata, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + uploadColumns
+
+	return scanUpload(db.QueryRow(ctx, insertQuery, id, eventID, length, originalFilename, metadata, expiresAt.UTC()))
+}
+
+// Get loads an upload
+func Get(ctx context.Context, db *pgxpool.Pool, id string) (Upload, error) {
+	return scanUpload(db.QueryRow(ctx, `SELECT `+uploadColumns+` FROM video_uploads WHERE upload_id = $1`, id))
+}
+
+// Append stores the next piece of the upload from r, reading no further than the
+// upload's length. If r fails part way (a dropped connection) the bytes received
+// so far are still kept, so the client can resume from there.
+func Append(ctx context.Context, db *pgxpool.Pool, store storage.Storage, u Upload, r io.Reader, expiresAt time.Time) (Upload, error) {
+	// Buffer the piece on disk first: the storage backend needs to know its size
+	tmp, err := os.CreateTemp("", "upload-chunk-*")
+	if err != nil {
+		return u, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, readErr := io.Copy(tmp, io.LimitReader(r, u.Length-u.Offset))
+	if readErr != nil {
+		log.Printf("Upload %s interrupted after %d bytes: %v", u.ID, n, readErr)
+	}
+	if n == 0 {
+		return u, nil
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return u, err
+	}
+
+	key := chunkKey(u.ID, u.ChunkCount)
+	if err := store.Put(ctx, key, tmp, n, "application/octet-stream"); err != nil {
+		return u, fmt.Errorf("failed to store upload chunk: %w", err)
+	}
+
+	// Only move the offset on if nobody else did in the meantime
+	updateQuery := `
+		UPDATE video_uploads
+		SET upload_offset = upload_offset + $1,
+			chunk_count = chunk_count + 1,
+			expires_at = $2,
+			updated_at = NOW()
+		WHERE upload_id = $3
+		AND upload_offset = $4
+		AND chunk_count = $5
+		AND completed_at IS NULL
+		RETURNING ` + uploadColumns
+
+	updated, err := scanUpload(db.QueryRow(ctx, updateQuery, n, expiresAt.UTC(), u.ID, u.Offset, u.ChunkCount))
+	if errors.Is(err, ErrNotFound) {
+		store.Delete(ctx, key)
+		return u, ErrOffsetConflict
+	}
+	if err != nil {
+		store.Delete(ctx, key)
+		return u, err
+	}
+	return updated, nil
+}
+
+// Content reads a fully received upload's chunks back as one stream
+func Content(ctx context.Context, store storage.Storage, u Upload) io.ReadCloser {
+	return &chunkReader{ctx: ctx, store: store, id: u.ID, count: u.ChunkCount}
+}
+
+// Complete records the video a finished upload was stored as and removes its
+// chunks. If another request completed the upload first, that request's video
+// is kept and the upload is returned as it completed it.
+func Complete(ctx context.Context, db *pgxpool.Pool, store storage.Storage, u Upload, videoID int, videoFilename string) (Upload, error) {
+	if u.Offset != u.Length {
+		return u, fmt.Errorf("upload %s is incomplete (%d of %d bytes)", u.ID, u.Offset, u.Length)
+	}
+
+	updateQuery := `
+		UPDATE video_uploads
+		SET video_id = $1, video_filename = $2, completed_at = NOW(), updated_at = NOW()
+		WHERE upload_id = $3
+		AND completed_at IS NULL
+		RETURNING ` + uploadColumns
+
+	completed, err := scanUpload(db.QueryRow(ctx, updateQuery, videoID, videoFilename, u.ID))
+	if errors.Is(err, ErrNotFound) {
+		return Get(ctx, db, u.ID)
+	}
+	if err != nil {
+		return u, err
+	}
+
+	deleteChunks(ctx, store, u)
+	return completed, nil
+}
+
+// Terminate abandons an upload and deletes what was received
+func Terminate(ctx context.Context, db *pgxpool.Pool, store storage.Storage, u Upload) error {
+	if _, err := db.Exec(ctx, `DELETE FROM video_uploads WHERE upload_id = $1`, u.ID); err != nil {
+		return err
+	}
+	if !u.Complete() {
+		deleteChunks(ctx, store, u)
+	}
+	return nil
+}
+
+// RemoveExpired deletes unfinished uploads whose expiry has passed, along with
+// their chunks. Finished uploads are kept, so HEAD and GET still find their video
+// (their chunks were deleted when they completed). It returns how many uploads
+// were removed.
+func RemoveExpired(ctx context.Context, db *pgxpool.Pool, store storage.Storage, now time.Time) (int, error) {
+	rows, err := db.Query(ctx, `SELECT `+uploadColumns+` FROM video_uploads WHERE expires_at < $1 AND completed_at IS NULL`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired uploads: %w", err)
+	}
+
+	var expired []Upload
+	for rows.Next() {
+		u, err := scanUpload(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expired upload: %w", err)
+		}
+		expired = append(expired, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read expired uploads: %w", err)
+	}
+
+	removed := 0
+	for _, u := range expired {
+		if err := Terminate(ctx, db, store, u); err != nil {
+			log.Printf("Failed to remove expired upload %s: %v", u.ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func deleteChunks(ctx context.Context, store storage.Storage, u Upload) {
+	for i := 0; i < u.ChunkCount; i++ {
+		if err := store.Delete(ctx, chunkKey(u.ID, i)); err != nil {
+			log.Printf("Failed to delete chunk %d of upload %s: %v", i, u.ID, err)
+		}
+	}
+}
+
+// chunkReader reads an upload's chunks one after another, opening each as it is reached
+type chunkReader struct {
+	ctx     context.Context
+	store   storage.Storage
+	id      string
+	count   int
+	next    int
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.next >= r.count {
+				return 0, io.EOF
+			}
+			chunk, _, err := r.store.Open(r.ctx, chunkKey(r.id, r.next))
+			if err != nil {
+				return 0, fmt.Errorf("failed to open chunk %d: %w", r.next, err)
+			}
+			r.current = chunk
+			r.next++
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"aletterahead-api/handlers"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v76"
+)
+
+// storedEvent is a Stripe event kept in stripe_events that hasn't been applied
+type storedEvent struct {
+	id              string
+	eventType       string
+	payload         []byte
+	receivedAt      time.Time
+	processingError *string
+}
+
+// runWebhookReplay handles the `webhook-replay [-dry-run] [-event evt_...]`
+// subcommand: applies the stored Stripe events that were never processed, oldest first
+func runWebhookReplay(db *pgxpool.Pool, args []string) error {
+	flags := flag.NewFlagSet("webhook-replay", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only list the events that would be replayed")
+	eventID := flags.String("event", "", "replay just this event")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	query := `
+		SELECT event_id, event_type, payload, received_at, processing_error
+		FROM stripe_events
+		WHERE processed_at IS NULL
+		AND ($1 = '' OR event_id = $1)
+		ORDER BY received_at ASC, event_id ASC
+	`
+
+	rows, err := db.Query(context.Background(), query, *eventID)
+	if err != nil {
+		return fmt.Errorf("failed to query unprocessed events: %w", err)
+	}
+
+	var events []storedEvent
+	for rows.Next() {
+		var e storedEvent
+		if err := rows.Scan(&e.id, &e.eventType, &e.payload, &e.receivedAt, &e.processingError); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read events: %w", err)
+	}
+
+	if *eventID != "" && len(events) == 0 {
+		return fmt.Errorf("event %s not found or already processed", *eventID)
+	}
+
+	failed := 0
+	for _, e := range events {
+		lastError := "-"
+		if e.processingError != nil {
+			lastError = *e.processingError
+		}
+		fmt.Printf("%-30s %-40s %-20s %s\n", e.id, e.eventType, e.receivedAt.Format("2006-01-02 15:04:05"), lastError)
+		if *dryRun {
+			continue
+		}
+
+		// The payload was verified against the webhook secret when it arrived
+		var event stripe.Event
+		if err := json.Unmarshal(e.payload, &event); err != nil {
+			log.Printf("Failed to parse event %s: %v", e.id, err)
+			failed++
+			continue
+		}
+		if err := handlers.ProcessStripeEvent(db, event); err != nil {
+			log.Printf("Failed to process event %s: %v", e.id, err)
+			failed++
+		}
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d unprocessed events would be replayed", len(events))
+		return nil
+	}
+	log.Printf("Replayed %d of %d unprocessed events", len(events)-failed, len(events))
+	if failed > 0 {
+		return fmt.Errorf("%d events failed", failed)
+	}
+	return nil
+}
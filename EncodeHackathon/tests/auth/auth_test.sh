@@ -0,0 +1,94 @@
+#!/bin/bash
+
+# Auth0 Token Verification Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (the API loads tests/auth/jwks.json instead of the Auth0 tenant keys)
+
+echo "🔐 Testing Auth0 Token Verification"
+echo "==================================="
+
+BASE_URL="http://localhost:8080"
+AUTH_DIR="$(dirname "$0")"
+
+SAMPLE_TOKEN=$("$AUTH_DIR/token.sh" "auth0|sample123")
+OTHER_TOKEN=$("$AUTH_DIR/token.sh" "auth0|someoneelse999")
+
+# Setup: a second parent who must not see the sample family's data
+curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "someoneelse@example.com",
+    "auth0_id": "auth0|someoneelse999"
+  }' > /dev/null
+
+# 1. No token (should fail with 401)
+echo "1. Missing Token..."
+curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq .
+echo -e "\n"
+
+# 2. Tampered token (should fail with 401)
+echo "2. Tampered Token..."
+curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer ${SAMPLE_TOKEN}x" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq .
+echo -e "\n"
+
+# 3. Valid token, parent comes from the token
+echo "3. Valid Token (no parent_id in body)..."
+curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $SAMPLE_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq .
+echo -e "\n"
+
+# 4. Asking for another parent's children (should fail with 403)
+echo "4. Another Parent's parent_id..."
+curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"parent_id": 1}' | jq .
+echo -e "\n"
+
+# 5. Listing another family's donations (should fail with 403)
+echo "5. Another Parent's Event Donations..."
+curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | jq .
+echo -e "\n"
+
+# 6. Approving another family's donation (should fail with 403)
+echo "6. Another Parent's Donation Approval..."
+curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"donation_id": 1, "approved": true}' | jq .
+echo -e "\n"
+
+# 7. Creating an event for someone else's child (should fail with 403)
+echo "7. Another Parent's Child Event..."
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1, "event_name": "Not My Party", "expires_at": "2026-12-31"}' | jq .
+echo -e "\n"
+
+# 8. Reading a different Auth0 user's profile (should fail with 403)
+echo "8. Another User's Profile..."
+curl -s -X POST "$BASE_URL/api/parents/get" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"auth0_id": "auth0|sample123"}' | jq .
+echo -e "\n"
+
+# 9. Public donations page routes still work without a token
+echo "9. Public Event Details (no token)..."
+curl -s -X POST "$BASE_URL/api/events/request" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | jq '{event_id, event_name}'
+echo -e "\n"
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,24 @@
+#!/bin/bash
+
+# Issue an Auth0-style RS256 access token signed with the local test key
+# Usage: TOKEN=$(./token.sh "auth0|sample123")
+# Matches the AUTH0_* settings in docker-compose.yml and docker-compose.test.yml - never use this key in production
+
+AUTH_DIR="$(cd "$(dirname "$0")" && pwd)"
+
+SUBJECT="${1:-auth0|sample123}"
+ISSUER="https://dev-1j1laxnkr7v5eef8.us.auth0.com/"
+AUDIENCE="https://api.aletterahead.com"
+KEY_ID="aletterahead-test-key"
+
+base64url() {
+  openssl base64 -A | tr '+/' '-_' | tr -d '='
+}
+
+NOW=$(date +%s)
+HEADER=$(printf '{"alg":"RS256","typ":"JWT","kid":"%s"}' "$KEY_ID" | base64url)
+PAYLOAD=$(printf '{"iss":"%s","sub":"%s","aud":"%s","iat":%d,"exp":%d}' \
+  "$ISSUER" "$SUBJECT" "$AUDIENCE" "$NOW" "$((NOW + 3600))" | base64url)
+SIGNATURE=$(printf '%s.%s' "$HEADER" "$PAYLOAD" | openssl dgst -sha256 -sign "$AUTH_DIR/test_private_key.pem" | base64url)
+
+echo "$HEADER.$PAYLOAD.$SIGNATURE"
@@ -0,0 +1,18 @@
+#!/bin/bash
+
+# Sign a video (or thumbnail) link the way /donations/list does, so tests can play uploads
+# Usage: URL=$(./video_link.sh "http://localhost:8080/api/videos/<filename>[/thumbnail]" [ttl_seconds])
+# Matches VIDEO_LINK_SECRET in docker-compose.yml - never use this secret in production
+
+SECRET="local-test-video-link-secret"
+
+URL="${1%%\?*}"
+TTL="${2:-3600}"
+
+# Links are signed for "videos/<filename>[/thumbnail]", whatever host they're on
+RESOURCE="videos/${URL#*/api/videos/}"
+EXPIRES=$(($(date +%s) + TTL))
+SIGNATURE=$(printf '%s\n%s' "$RESOURCE" "$EXPIRES" | openssl dgst -sha256 -hmac "$SECRET" -binary \
+  | openssl base64 -A | tr '+/' '-_' | tr -d '=')
+
+echo "$URL?expires=$EXPIRES&signature=$SIGNATURE"
@@ -1,13 +1,17 @@
 #!/bin/bash
 
 # Create Donation API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "🎁 Testing Create Donation API"
 echo "=============================="
 
 BASE_URL="http://localhost:8080"
 
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
 # 1. Valid donation (£5.00)
 echo "1. Valid Donation (£5.00)..."
 curl -s -X POST "$BASE_URL/api/donations/create" \
@@ -20,8 +24,12 @@ curl -s -X POST "$BASE_URL/api/donations/create" \
   }' | jq .
 echo -e "\n"
 
-# 2. Valid donation with video
+# 2. Valid donation with video (upload it first - donations take the video_id)
 echo "2. Valid Donation with Video..."
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=1:size=320x240:rate=25 /tmp/donation_video.mp4
+docker cp donations_api:/tmp/donation_video.mp4 /tmp/donation_video.mp4
+VIDEO_ID=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/donation_video.mp4" | jq -r '.video_id')
+rm -f /tmp/donation_video.mp4
 curl -s -X POST "$BASE_URL/api/donations/create" \
   -H "Content-Type: application/json" \
   -d '{
@@ -29,7 +37,7 @@ curl -s -X POST "$BASE_URL/api/donations/create" \
     "donor_name": "Grandma Sarah",
     "amount_pence": 1000,
     "message": "Love you so much sweetie!",
-    "video_address": "https://example.com/birthday-video.mp4"
+    "video_id": '"$VIDEO_ID"'
   }' | jq .
 echo -e "\n"
 
@@ -0,0 +1,64 @@
+#!/bin/bash
+
+# Junior ISA Allowance Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (default allowance £9,000, JUNIOR_ISA_LIMIT_MODE=reject)
+
+echo "🏦 Testing Junior ISA Allowance"
+echo "==============================="
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+# 1. Remaining allowance for each child
+echo "1. Allowance for All Children..."
+curl -s -X POST "$BASE_URL/api/children/allowance" \
+  -H "Authorization: Bearer $TOKEN" | jq .
+echo -e "\n"
+
+# 2. One child
+echo "2. Allowance for Child 1..."
+BEFORE=$(curl -s -X POST "$BASE_URL/api/children/allowance" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1}')
+echo "$BEFORE" | jq .
+echo -e "\n"
+
+# 3. Donation over the allowance (should fail with 422)
+echo "3. Donation Over Allowance..."
+curl -s -w "\nHTTP %{http_code}\n" -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "Generous Grandad", "amount_pence": 900100}'
+echo -e "\n"
+
+# 4. Captured donations count towards the allowance
+echo "4. Captured Donation Reduces Remaining Allowance..."
+DONATION_ID=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "ISA Tester", "amount_pence": 5000}' | jq -r '.donation_id')
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE donations SET status = 'captured', approved = true, payment_status = 'succeeded', captured_at = NOW() WHERE id = $DONATION_ID;" > /dev/null
+AFTER=$(curl -s -X POST "$BASE_URL/api/children/allowance" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1}')
+REMAINING_BEFORE=$(echo "$BEFORE" | jq -r '.children[0].remaining_pence')
+REMAINING_AFTER=$(echo "$AFTER" | jq -r '.children[0].remaining_pence')
+if [[ $((REMAINING_BEFORE - REMAINING_AFTER)) -eq 5000 ]]; then
+  echo "✅ Remaining allowance went down by £50.00"
+else
+  echo "❌ Remaining allowance went from $REMAINING_BEFORE to $REMAINING_AFTER"
+fi
+echo -e "\n"
+
+# 5. Another parent's child (should fail with 403)
+echo "5. Another Parent's Child..."
+curl -s -X POST "$BASE_URL/api/children/allowance" \
+  -H "Authorization: Bearer $("$(dirname "$0")/../auth/token.sh" "auth0|stripesave123")" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1}' | jq .
+echo -e "\n"
+
+echo "✅ Testing Complete!"
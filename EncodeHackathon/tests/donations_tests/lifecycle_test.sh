@@ -0,0 +1,98 @@
+#!/bin/bash
+
+# Donation Lifecycle Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
+# Walks one donation through its statuses and checks the history is recorded
+
+echo "🔁 Testing Donation Lifecycle"
+echo "============================="
+
+BASE_URL="http://localhost:8080"
+WEBHOOK_SECRET="whsec_test_secret"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+# Sign a payload the way Stripe does: HMAC-SHA256 of "timestamp.payload"
+send_event() {
+  local payload="$1"
+  local timestamp=$(date +%s)
+  local signature=$(printf "%s.%s" "$timestamp" "$payload" | openssl dgst -sha256 -hmac "$WEBHOOK_SECRET" | sed 's/^.* //')
+  curl -s -X POST "$BASE_URL/api/payments/webhook" \
+    -H "Content-Type: application/json" \
+    -H "Stripe-Signature: t=$timestamp,v1=$signature" \
+    -d "$payload" > /dev/null
+}
+
+status_of() {
+  curl -s -X POST "$BASE_URL/api/donations/list" \
+    -H "Authorization: Bearer $TOKEN" \
+    -H "Content-Type: application/json" \
+    -d '{"event_id": 1}' | jq -r ".donations[] | select(.id == $1) | .status"
+}
+
+expect_status() {
+  local actual=$(status_of "$1")
+  if [[ "$actual" == "$2" ]]; then
+    echo "✅ status is $2"
+  else
+    echo "❌ expected status $2, got $actual"
+  fi
+}
+
+EVENT_SUFFIX=$(date +%s)
+
+# 1. New donation waits for payment
+echo "1. Create Donation..."
+DONATION=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "Lifecycle Tester", "amount_pence": 800}')
+DONATION_ID=$(echo "$DONATION" | jq -r '.donation_id')
+PAYMENT_INTENT_ID=$(echo "$DONATION" | jq -r '.payment_intent_id')
+expect_status "$DONATION_ID" "awaiting_payment"
+echo ""
+
+# 2. Card authorised -> pending_review
+echo "2. payment_intent.amount_capturable_updated..."
+send_event "{\"id\":\"evt_auth_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"payment_intent.amount_capturable_updated\",\"data\":{\"object\":{\"id\":\"$PAYMENT_INTENT_ID\",\"object\":\"payment_intent\"}}}"
+expect_status "$DONATION_ID" "pending_review"
+echo ""
+
+# 3. Parent rejects -> rejected
+echo "3. Reject Donation..."
+curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"donation_id\": $DONATION_ID, \"approved\": false}" | jq .
+expect_status "$DONATION_ID" "rejected"
+echo ""
+
+# 4. Rejection is final
+echo "4. Approve Rejected Donation (should fail with 409)..."
+curl -s -w "\nHTTP %{http_code}\n" -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"donation_id\": $DONATION_ID, \"approved\": true}"
+echo ""
+
+# 5. A late Stripe event doesn't undo the rejection
+echo "5. Late payment_intent.canceled (status should stay rejected)..."
+send_event "{\"id\":\"evt_canceled_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"payment_intent.canceled\",\"data\":{\"object\":{\"id\":\"$PAYMENT_INTENT_ID\",\"object\":\"payment_intent\"}}}"
+expect_status "$DONATION_ID" "rejected"
+echo ""
+
+# 6. History records every change with its actor
+echo "6. Status History..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT from_status, to_status, actor, reason, changed_at FROM donation_status_history WHERE donation_id = $DONATION_ID ORDER BY history_id;"
+echo ""
+
+# 7. Per-status statistics
+echo "7. Status Breakdown..."
+curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | jq '{total_donations, approved_donations, pending_donations, status_breakdown}'
+echo ""
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,46 @@
+#!/bin/bash
+
+# Donation PaymentIntent Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (starts stripe-mock, nothing hits the real Stripe API)
+
+echo "💳 Testing Donation PaymentIntent Flow"
+echo "======================================"
+
+BASE_URL="http://localhost:8080"
+
+# 1. Donation returns a PaymentIntent and client secret
+echo "1. Donation Creates PaymentIntent..."
+RESPONSE=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "event_id": 1,
+    "donor_name": "Auntie Pat",
+    "amount_pence": 2500,
+    "message": "Something for your savings pot!"
+  }')
+echo "$RESPONSE" | jq .
+
+DONATION_ID=$(echo "$RESPONSE" | jq -r '.donation_id')
+PAYMENT_INTENT_ID=$(echo "$RESPONSE" | jq -r '.payment_intent_id')
+CLIENT_SECRET=$(echo "$RESPONSE" | jq -r '.client_secret')
+
+if [[ "$PAYMENT_INTENT_ID" == pi_* ]]; then
+  echo "✅ PaymentIntent created: $PAYMENT_INTENT_ID"
+else
+  echo "❌ No PaymentIntent ID returned"
+fi
+
+if [[ -n "$CLIENT_SECRET" && "$CLIENT_SECRET" != "null" ]]; then
+  echo "✅ Client secret returned"
+else
+  echo "❌ No client secret returned"
+fi
+echo -e "\n"
+
+# 2. PaymentIntent is persisted on the donation row
+echo "2. PaymentIntent Stored on Donation..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT id, amount_pence, payment_intent_id, payment_status FROM donations WHERE id = $DONATION_ID;"
+echo -e "\n"
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,139 @@
+#!/bin/bash
+
+# Sealed Message Testing (capsule emails land in mailpit)
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (SEALED_MESSAGE_KEY set)
+
+echo "🔏 Testing Sealed Messages"
+echo "=========================="
+
+BASE_URL="http://localhost:8080"
+MAILPIT="http://localhost:8025"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+TODAY=$(date -u +%Y-%m-%d)
+EMAIL="sealed.$(date +%s)@example.com"
+SECRET="Sealed test: a secret only you should read"
+
+sql() {
+  docker exec donations_db psql -U postgres -d donations -t -A -c "$1"
+}
+
+# The capsule link in the newest email to $EMAIL
+capsule_link() {
+  ID=$(curl -s "$MAILPIT/api/v1/search?query=to:$EMAIL" | jq -r '.messages[0].ID')
+  curl -s "$MAILPIT/api/v1/message/$ID" | jq -r '.Text' | grep -o 'http://[^ ]*/api/capsules/[^ ]*' | head -1
+}
+
+# Setup: a 10 year old whose capsule is delivered today, and an event for them
+echo "🔧 Setting up..."
+CHILD_ID=$(curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_name": "Sealed Test",
+    "dob": "'"$(date -u -d '10 years ago' +%Y-%m-%d)"'",
+    "email": "'"$EMAIL"'",
+    "delivery_date": "'"$TODAY"'"
+  }' | jq -r '.child_id')
+EVENT_ID=$(sql "INSERT INTO events (child_id, event_name, expires_at, event_message)
+  VALUES ($CHILD_ID, 'Sealed Test Birthday', NOW() + INTERVAL '30 days', 'Happy birthday!') RETURNING event_id;" | head -1)
+echo ""
+
+# 1. Validation
+echo "1. Sealed Without A Message (should be 400)..."
+curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "event_id": '"$EVENT_ID"',
+    "donor_name": "Sealed Gran",
+    "amount_pence": 500,
+    "sealed": true
+  }' | jq .
+echo -e "\n"
+
+# 2. A sealed donation, and an ordinary one
+echo "2. Sealed Donation (should be 201, awaiting_payment)..."
+SEALED_ID=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "event_id": '"$EVENT_ID"',
+    "donor_name": "Sealed Gran",
+    "amount_pence": 2000,
+    "message": "'"$SECRET"'",
+    "sealed": true
+  }' | tee /dev/stderr | jq -r '.donation_id')
+curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "event_id": '"$EVENT_ID"',
+    "donor_name": "Open Bob",
+    "amount_pence": 500,
+    "message": "Sealed test: happy birthday!"
+  }' > /dev/null
+echo -e "\n"
+
+echo "3. Stored Encrypted (message empty, ciphertext without the text, one key for the child)..."
+sql "SELECT message IS NULL AS no_message, sealed, sealed_length,
+  position(convert_to('$SECRET', 'UTF8') in sealed_message) = 0 AS encrypted
+  FROM donations WHERE id = $SEALED_ID;"
+sql "SELECT COUNT(*) AS child_keys FROM child_keys WHERE child_id = $CHILD_ID;"
+echo -e "\n"
+
+# 4. The parent reviews it without reading it
+echo "4. List Donations (Sealed Gran: sealed, message null, sealed_message_length 42)..."
+curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": '"$EVENT_ID"'}' \
+  | jq '[.donations[] | {donor_name, status, sealed, message, sealed_message_length}]'
+echo -e "\n"
+
+sql "UPDATE donations SET approved = true, approved_at = NOW(), status = 'approved' WHERE event_id = $EVENT_ID;" > /dev/null
+
+echo "5. Keepsake Export Keeps It Sealed (sealed true, message null; the text nowhere in the archive)..."
+curl -s -o /tmp/sealed_keepsake.zip -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": '"$CHILD_ID"'}'
+unzip -p /tmp/sealed_keepsake.zip sealed-test-keepsake/manifest.json | jq '[.donations[] | {donor_name, sealed, message}]'
+echo "text in archive: $(unzip -p /tmp/sealed_keepsake.zip | grep -c "$SECRET")"
+echo -e "\n"
+
+# 6. Delivered before the child is 18 - still sealed
+echo "6. Capsule Delivered Early (Sealed Gran: sealed, message null, sealed_until their 18th birthday)..."
+docker exec donations_api ./main capsules -child "$CHILD_ID"
+sleep 1
+LINK=$(capsule_link)
+curl -s "$LINK" | jq '{message_count, items: [.items[] | {donor_name, sealed, message, sealed_until}]}'
+echo -e "\n"
+
+# 7. Once they are 18 the same link opens it
+echo "7. Child Turns 18 (Sealed Gran's message should now read: $SECRET)..."
+sql "UPDATE children SET DOB = (CURRENT_DATE - INTERVAL '18 years')::DATE WHERE child_id = $CHILD_ID;" > /dev/null
+curl -s "$LINK" | jq '[.items[] | {donor_name, sealed, message, sealed_until}]'
+echo -e "\n"
+
+# 8. A letter moved to another child's donation can't be opened with their key
+echo "8. Ciphertext Copied To Another Child (should stay null - tied to its child)..."
+OTHER_CHILD=$(sql "INSERT INTO children (child_name, DOB, parent_id, email, isa_expiry)
+  SELECT 'Sealed Other', (CURRENT_DATE - INTERVAL '19 years')::DATE, parent_id, 'sealed.other.$(date +%s)@example.com', isa_expiry
+  FROM children WHERE child_id = $CHILD_ID RETURNING child_id;" | head -1)
+OTHER_EVENT=$(sql "INSERT INTO events (child_id, event_name, expires_at)
+  VALUES ($OTHER_CHILD, 'Sealed Other Birthday', NOW() + INTERVAL '30 days') RETURNING event_id;" | head -1)
+sql "INSERT INTO donations (donor_name, amount_pence, approved, approved_at, status, event_id, sealed, sealed_message, sealed_length)
+  SELECT 'Copied', 100, true, NOW(), 'approved', $OTHER_EVENT, true, sealed_message, sealed_length FROM donations WHERE id = $SEALED_ID;
+  INSERT INTO child_keys (child_id, wrapped_key, master_key_id)
+  SELECT $OTHER_CHILD, wrapped_key, master_key_id FROM child_keys WHERE child_id = $CHILD_ID;" > /dev/null
+OTHER_EMAIL=$(sql "SELECT email FROM children WHERE child_id = $OTHER_CHILD;")
+docker exec donations_api ./main capsules -child "$OTHER_CHILD"
+sleep 1
+ID=$(curl -s "$MAILPIT/api/v1/search?query=to:$OTHER_EMAIL" | jq -r '.messages[0].ID')
+OTHER_LINK=$(curl -s "$MAILPIT/api/v1/message/$ID" | jq -r '.Text' | grep -o 'http://[^ ]*/api/capsules/[^ ]*' | head -1)
+curl -s "$OTHER_LINK" | jq '[.items[] | {donor_name, sealed, message}]'
+echo -e "\n"
+
+rm -f /tmp/sealed_keepsake.zip
+
+echo "✅ Testing Complete!"
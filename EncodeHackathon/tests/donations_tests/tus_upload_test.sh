@@ -0,0 +1,168 @@
+#!/bin/bash
+
+# Resumable (tus) Video Upload Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (the api image includes ffmpeg)
+
+echo "⏯️  Testing Resumable Video Upload"
+echo "=================================="
+
+BASE_URL="http://localhost:8080"
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+TUS="$BASE_URL/api/uploads/tus"
+
+# Short test video, sent in three chunks (uploads are checked once complete, so it must be real)
+docker exec donations_api ffmpeg -loglevel error -y \
+  -f lavfi -i testsrc=duration=2:size=320x240:rate=25 /tmp/tus_video.mp4
+docker cp donations_api:/tmp/tus_video.mp4 /tmp/tus_video.mp4
+SIZE=$(wc -c < /tmp/tus_video.mp4 | tr -d ' ')
+head -c 400 /tmp/tus_video.mp4 > /tmp/tus_chunk1
+tail -c +401 /tmp/tus_video.mp4 | head -c 300 > /tmp/tus_chunk2
+tail -c +701 /tmp/tus_video.mp4 > /tmp/tus_chunk3
+METADATA="event_id $(printf '1' | base64),filename $(printf 'tus_video.mp4' | base64),filetype $(printf 'video/mp4' | base64)"
+
+header() {
+  grep -i "^$1:" | cut -d' ' -f2- | tr -d '\r'
+}
+
+# 1. Server capabilities
+echo "1. OPTIONS (Tus-Version / Tus-Extension)..."
+curl -s -i -X OPTIONS "$TUS" | grep -iE "(HTTP|Tus-)"
+echo -e "\n"
+
+# 2. Create the upload
+echo "2. Create Upload (should be 201)..."
+CREATE=$(curl -s -i -X POST "$TUS" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Upload-Length: $SIZE" \
+  -H "Upload-Metadata: $METADATA")
+echo "$CREATE" | grep -iE "(HTTP|Location|Upload-Expires)"
+LOCATION=$(echo "$CREATE" | header Location)
+echo -e "\n"
+
+# 3. First chunk
+echo "3. PATCH Chunk 1 (Upload-Offset should be 400)..."
+curl -s -i -X PATCH "$LOCATION" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Content-Type: application/offset+octet-stream" \
+  -H "Upload-Offset: 0" \
+  --data-binary @/tmp/tus_chunk1 | grep -iE "(HTTP|Upload-Offset)"
+echo -e "\n"
+
+# 4. Resend chunk 1 as if the response was lost (should be 409)
+echo "4. PATCH At Stale Offset (should be 409)..."
+curl -s -i -X PATCH "$LOCATION" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Content-Type: application/offset+octet-stream" \
+  -H "Upload-Offset: 0" \
+  --data-binary @/tmp/tus_chunk1 | grep -iE "(HTTP|Upload-Offset)"
+echo -e "\n"
+
+# 5. Resume: ask the server where to carry on from
+echo "5. HEAD (Upload-Offset should be 400)..."
+OFFSET=$(curl -s -I "$LOCATION" -H "Tus-Resumable: 1.0.0" | header Upload-Offset)
+echo "Upload-Offset: $OFFSET"
+echo -e "\n"
+
+# 6. Remaining chunks
+echo "6. PATCH Chunks 2 and 3 (last one should return Video-Id and Video-Url)..."
+curl -s -i -X PATCH "$LOCATION" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Content-Type: application/offset+octet-stream" \
+  -H "Upload-Offset: $OFFSET" \
+  --data-binary @/tmp/tus_chunk2 | grep -iE "(HTTP|Upload-Offset)"
+FINAL=$(curl -s -i -X PATCH "$LOCATION" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Content-Type: application/offset+octet-stream" \
+  -H "Upload-Offset: 700" \
+  --data-binary @/tmp/tus_chunk3)
+echo "$FINAL" | grep -iE "(HTTP|Upload-Offset|Video-Id|Video-Url)"
+VIDEO_ID=$(echo "$FINAL" | header Video-Id)
+VIDEO_URL=$(echo "$FINAL" | header Video-Url)
+echo -e "\n"
+
+# 7. JSON status
+echo "7. Upload Status..."
+curl -s "$LOCATION" | jq .
+FINISHED="$LOCATION"
+echo -e "\n"
+
+# 8. Assembled video matches what was sent (read from storage - it is served once transcoded)
+echo "8. Assembled Video..."
+docker cp "donations_api:/var/uploads/videos/$(basename "${VIDEO_URL%%\?*}")" /tmp/tus_download.mp4
+cmp -s /tmp/tus_video.mp4 /tmp/tus_download.mp4 && echo "✅ video matches" || echo "❌ video differs"
+echo -e "\n"
+
+# 9. video_id is accepted by CreateDonation
+echo "9. Donation With Resumable Upload..."
+curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d "{\"event_id\": 1, \"donor_name\": \"Patient Grandma\", \"amount_pence\": 1000, \"video_id\": $VIDEO_ID}" | jq .
+echo -e "\n"
+
+# 10. Missing Tus-Resumable
+echo "10. Missing Tus-Resumable (should be 412)..."
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -X POST "$TUS" -H "Upload-Length: 10" -H "Upload-Metadata: $METADATA"
+echo -e "\n"
+
+# 11. Content that isn't a video is refused when the upload completes, and the upload deleted
+echo "11. Upload Of A Text File Named .mp4 (should be 415, then 404)..."
+printf 'This is not a video' > /tmp/tus_notes.mp4
+CREATE=$(curl -s -i -X POST "$TUS" -H "Tus-Resumable: 1.0.0" -H "Upload-Length: 19" -H "Upload-Metadata: $METADATA")
+LOCATION=$(echo "$CREATE" | header Location)
+curl -s -X PATCH "$LOCATION" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Content-Type: application/offset+octet-stream" \
+  -H "Upload-Offset: 0" \
+  --data-binary @/tmp/tus_notes.mp4 | jq .
+curl -s -o /dev/null -w "HEAD after rejection: HTTP %{http_code}\n" -I "$LOCATION" -H "Tus-Resumable: 1.0.0"
+echo -e "\n"
+
+# 12. Too large
+echo "12. Upload-Length Over TUS_MAX_SIZE (should be 413)..."
+curl -s -X POST "$TUS" -H "Tus-Resumable: 1.0.0" -H "Upload-Length: 999999999999" \
+  -H "Upload-Metadata: $METADATA" | jq .
+echo -e "\n"
+
+# 13. creation-with-upload, then terminate
+echo "13. Create With First Chunk, Then DELETE..."
+CREATE=$(curl -s -i -X POST "$TUS" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Upload-Length: 1024" \
+  -H "Upload-Metadata: $METADATA" \
+  -H "Content-Type: application/offset+octet-stream" \
+  --data-binary @/tmp/tus_chunk1)
+echo "$CREATE" | grep -iE "(HTTP|Upload-Offset)"
+LOCATION=$(echo "$CREATE" | header Location)
+curl -s -o /dev/null -w "DELETE: HTTP %{http_code}\n" -X DELETE "$LOCATION" -H "Tus-Resumable: 1.0.0"
+curl -s -o /dev/null -w "HEAD after DELETE (should be 404): HTTP %{http_code}\n" -I "$LOCATION" -H "Tus-Resumable: 1.0.0"
+echo -e "\n"
+
+# 14. Expired uploads are refused and cleaned up
+echo "14. Expired Upload (should be 410)..."
+CREATE=$(curl -s -i -X POST "$TUS" -H "Tus-Resumable: 1.0.0" -H "Upload-Length: 1024" -H "Upload-Metadata: $METADATA")
+LOCATION=$(echo "$CREATE" | header Location)
+UPLOAD_ID=$(basename "$LOCATION")
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE video_uploads SET expires_at = NOW() - INTERVAL '1 hour' WHERE upload_id = '$UPLOAD_ID';" >/dev/null
+curl -s -X PATCH "$LOCATION" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Content-Type: application/offset+octet-stream" \
+  -H "Upload-Offset: 0" \
+  --data-binary @/tmp/tus_chunk1 | jq .
+echo "(removed on the next TUS_EXPIRY_SWEEP_INTERVAL, or restart the api to sweep now)"
+echo -e "\n"
+
+# 15. Finished uploads are never swept, so their video can still be looked up
+echo "15. Finished Upload Past Its Expiry (should be 200 with Video-Id after a sweep)..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE video_uploads SET expires_at = NOW() - INTERVAL '1 hour' WHERE upload_id = '$(basename "$FINISHED")';" >/dev/null
+docker restart donations_api >/dev/null && sleep 5
+curl -s -I "$FINISHED" -H "Tus-Resumable: 1.0.0" | grep -iE "(HTTP|Video-Id)"
+echo -e "\n"
+
+rm -f /tmp/tus_video.mp4 /tmp/tus_notes.mp4 /tmp/tus_chunk1 /tmp/tus_chunk2 /tmp/tus_chunk3 /tmp/tus_download.mp4
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,88 @@
+#!/bin/bash
+
+# Video Records Testing (videos belong to an event and go with one donation)
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (the api image includes ffmpeg)
+
+echo "🗂️  Testing Video Records"
+echo "========================"
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+donate() {
+  curl -s -w "\nHTTP %{http_code}\n" -X POST "$BASE_URL/api/donations/create" \
+    -H "Content-Type: application/json" \
+    -d "$1"
+}
+
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=1:size=320x240:rate=25 /tmp/record_test.mp4
+docker cp donations_api:/tmp/record_test.mp4 /tmp/record_test.mp4
+
+# A second event of Emma's to try the video against
+OTHER_EVENT_ID=$(curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1, "event_name": "Video Record Test", "expires_at": "'"$(date -d '+30 days' +%F)"'", "videos_enabled": true}' | jq -r '.event_id')
+
+# 1. Uploads need an event
+echo "1. Upload Without event_id (should be 400)..."
+curl -s -X POST "$BASE_URL/api/uploads/video" -F "video=@/tmp/record_test.mp4" | jq .
+echo -e "\n"
+
+echo "2. Upload For A Missing Event (should be 404)..."
+curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=999999" -F "video=@/tmp/record_test.mp4" | jq .
+echo -e "\n"
+
+# 3. Upload returns a video ID
+echo "3. Upload For Event 1 (should return video_id)..."
+UPLOAD=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/record_test.mp4")
+echo "$UPLOAD" | jq .
+VIDEO_ID=$(echo "$UPLOAD" | jq -r '.video_id')
+echo -e "\n"
+
+# 4. The record has the event, status and checksum of the upload
+echo "4. Video Record (status uploaded, checksum matches)..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT video_id, event_id, status, checksum_sha256 FROM videos WHERE video_id = $VIDEO_ID;"
+echo "expected checksum: $(sha256sum /tmp/record_test.mp4 | cut -d' ' -f1)"
+echo -e "\n"
+
+# 5. Addresses are no longer accepted
+echo "5. Donation With video_address (should be 400)..."
+donate '{"event_id": 1, "donor_name": "Link Sharer", "amount_pence": 500, "video_address": "https://example.com/video.mp4"}'
+echo -e "\n"
+
+# 6. Only videos uploaded for the donation's event
+echo "6. Donation To Another Event With This Video (should be 400)..."
+donate '{"event_id": '"$OTHER_EVENT_ID"', "donor_name": "Wrong Event", "amount_pence": 500, "video_id": '"$VIDEO_ID"'}'
+echo -e "\n"
+
+echo "7. Donation With Unknown video_id (should be 400)..."
+donate '{"event_id": 1, "donor_name": "Made Up Video", "amount_pence": 500, "video_id": 999999}'
+echo -e "\n"
+
+# 8. The right event takes it
+echo "8. Donation With The Video (should be 201)..."
+donate '{"event_id": 1, "donor_name": "Video Record Tester", "amount_pence": 500, "video_id": '"$VIDEO_ID"'}'
+echo -e "\n"
+
+# 9. But only once
+echo "9. Second Donation With The Same Video (should be 409)..."
+donate '{"event_id": 1, "donor_name": "Copy Cat", "amount_pence": 500, "video_id": '"$VIDEO_ID"'}'
+echo -e "\n"
+
+# 10. The donation and video are linked
+echo "10. Linked Records (video attached, donation has its video_id)..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT d.id, d.donor_name, d.video_id, v.status, d.video_address FROM donations d JOIN videos v ON v.video_id = d.video_id WHERE v.video_id = $VIDEO_ID;"
+echo -e "\n"
+
+rm -f /tmp/record_test.mp4
+
+echo "✅ Testing Complete!"
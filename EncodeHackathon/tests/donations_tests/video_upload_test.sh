@@ -1,44 +1,52 @@
 #!/bin/bash
 
 # Video Upload API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (the api image includes ffmpeg)
 
 echo "🎥 Testing Video Upload API"
 echo "==========================="
 
 BASE_URL="http://localhost:8080"
 
-# Create a test video file (small dummy file for testing)
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+# Create a test video file (uploads must really be videos, so make a short clip)
 echo "Creating test video file..."
-echo "This is a fake video file for testing" > test_video.mp4
+docker exec donations_api ffmpeg -loglevel error -y \
+  -f lavfi -i testsrc=duration=2:size=320x240:rate=25 /tmp/test_video.mp4
+docker cp donations_api:/tmp/test_video.mp4 test_video.mp4
 
 # 1. Valid video upload
 echo "1. Valid Video Upload..."
 curl -s -X POST "$BASE_URL/api/uploads/video" \
-  -F "video=@test_video.mp4" | jq .
+  -F "event_id=1" -F "video=@test_video.mp4" | jq .
 echo -e "\n"
 
 # 2. No file provided
 echo "2. No File Provided..."
-curl -s -X POST "$BASE_URL/api/uploads/video" | jq .
+curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" | jq .
 echo -e "\n"
 
 # 3. Invalid file type (create a txt file)
 echo "Creating invalid file type..."
 echo "This is not a video" > test_document.txt
-echo "3. Invalid File Type..."
+echo "3. Invalid File Type (should be 415)..."
 curl -s -X POST "$BASE_URL/api/uploads/video" \
-  -F "video=@test_document.txt" | jq .
+  -F "event_id=1" -F "video=@test_document.txt" | jq .
 echo -e "\n"
 
 # 4. Test video serving (first upload a video and get its URL)
 echo "4. Testing Video Serving..."
-UPLOAD_RESPONSE=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "video=@test_video.mp4")
+UPLOAD_RESPONSE=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@test_video.mp4")
 VIDEO_URL=$(echo "$UPLOAD_RESPONSE" | jq -r '.video_url')
 
 if [ "$VIDEO_URL" != "null" ] && [ "$VIDEO_URL" != "" ]; then
-    echo "Trying to serve video at: $VIDEO_URL"
+    echo "Returned link: $VIDEO_URL (signed, 503 until transcoded, then 200)"
     curl -s -I "$VIDEO_URL" | head -n 5
+    echo "Unsigned address (should be 403):"
+    curl -s -I "${VIDEO_URL%%\?*}" | head -n 5
 else
     echo "Failed to get video URL from upload"
 fi
@@ -0,0 +1,85 @@
+#!/bin/bash
+
+# Video Upload Validation Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (the api image includes ffmpeg)
+# Uses the default limits (VIDEO_MAX_DURATION=5m, VIDEO_MAX_WIDTH=3840, VIDEO_MAX_HEIGHT=2160)
+
+echo "🔍 Testing Video Upload Validation"
+echo "=================================="
+
+BASE_URL="http://localhost:8080"
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+# Make a clip inside the api container (it has ffmpeg) and copy it out: make_clip <name> <ffmpeg args...>
+make_clip() {
+  local name=$1
+  shift
+  docker exec donations_api ffmpeg -loglevel error -y "$@" "/tmp/$name"
+  docker cp "donations_api:/tmp/$name" "/tmp/$name"
+}
+
+upload() {
+  curl -s -w "\nHTTP %{http_code}\n" -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@$1"
+}
+
+echo "Creating test files..."
+make_clip validation_ok.webm -f lavfi -i testsrc=duration=2:size=320x240:rate=25 -c:v libvpx
+make_clip validation_long.mp4 -f lavfi -i testsrc=duration=301:size=64x64:rate=1 -c:v libx264 -preset ultrafast
+make_clip validation_huge.mp4 -f lavfi -i testsrc=duration=1:size=4096x2304:rate=1 -c:v libx264 -preset ultrafast
+make_clip validation_portrait.mp4 -f lavfi -i testsrc=duration=1:size=1080x1920:rate=1 -c:v libx264 -preset ultrafast
+make_clip validation_audio.mp4 -f lavfi -i sine=duration=2 -c:a aac
+printf 'MZ\x90\x00\x03\x00\x00\x00This program cannot be run in DOS mode' > /tmp/validation_program.mp4
+echo "Just some notes, honest" > /tmp/validation_notes.mov
+echo -e "\n"
+
+# 1. A real video is accepted whatever it is called, and stored under a random name
+echo "1. WebM Uploaded As .txt (should be 201, video/webm, UUID .webm filename)..."
+cp /tmp/validation_ok.webm /tmp/validation_ok.txt
+RESPONSE=$(upload /tmp/validation_ok.txt)
+echo "$RESPONSE"
+VIDEO_URL=$(echo "$RESPONSE" | sed '$d' | jq -r '.video_url | split("?")[0]')
+FILENAME=$(basename "$VIDEO_URL")
+echo "$FILENAME" | grep -qE '^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\.webm$' \
+  && echo "✅ random filename" || echo "❌ unexpected filename $FILENAME"
+echo -e "\n"
+
+# 2. Renamed non-videos are refused
+echo "2. Windows Program Named .mp4 (should be 415)..."
+upload /tmp/validation_program.mp4
+echo -e "\n"
+
+echo "3. Text File Named .mov (should be 415)..."
+upload /tmp/validation_notes.mov
+echo -e "\n"
+
+# 4. An MP4 container with no picture in it
+echo "4. Audio-only MP4 (should be 415)..."
+upload /tmp/validation_audio.mp4
+echo -e "\n"
+
+# 5. Limits
+echo "5. Video Over 5 Minutes (should be 422)..."
+upload /tmp/validation_long.mp4
+echo -e "\n"
+
+echo "6. Video Over 3840x2160 (should be 422)..."
+upload /tmp/validation_huge.mp4
+echo -e "\n"
+
+echo "7. Portrait 1080x1920 Video (should be 201)..."
+upload /tmp/validation_portrait.mp4
+echo -e "\n"
+
+# 8. What was recorded about the accepted video
+echo "8. Video Record..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT video_id, filename, original_filename, mime_type, size_bytes, duration_ms, width, height FROM videos WHERE filename = '$FILENAME';"
+echo -e "\n"
+
+rm -f /tmp/validation_ok.webm /tmp/validation_ok.txt /tmp/validation_long.mp4 /tmp/validation_huge.mp4 \
+  /tmp/validation_portrait.mp4 /tmp/validation_audio.mp4 /tmp/validation_program.mp4 /tmp/validation_notes.mov
+
+echo "✅ Testing Complete!"
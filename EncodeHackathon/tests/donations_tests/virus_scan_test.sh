@@ -0,0 +1,149 @@
+#!/bin/bash
+
+# Upload Virus Scan Testing (clamd INSTREAM, against a fake clamd)
+# Run: CLAMD_ADDRESS=fake_clamd:3310 docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build
+#      (this script starts fake_clamd - tests/scan/fake_clamd.py - next to the API)
+
+echo "🦠 Testing Upload Virus Scanning"
+echo "================================"
+
+BASE_URL="http://localhost:8080"
+TUS="$BASE_URL/api/uploads/tus"
+SCAN_DIR="$(cd "$(dirname "$0")/../scan" && pwd)"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+header() {
+  grep -i "^$1:" | cut -d' ' -f2- | tr -d '\r'
+}
+
+videos() {
+  docker exec donations_db psql -U postgres -d donations -c \
+    "SELECT video_id, status, scan_status, scan_signature, removed_reason FROM videos ORDER BY video_id DESC LIMIT $1;"
+}
+
+# Setup: the fake clamd on the API's network, a clean video and photo, and the
+# EICAR test file (harmless, but every scanner reports it)
+echo "🔧 Starting fake clamd..."
+NETWORK=$(docker inspect donations_api -f '{{range $name, $_ := .NetworkSettings.Networks}}{{$name}}{{end}}')
+docker rm -f fake_clamd >/dev/null 2>&1
+docker run -d --name fake_clamd --network "$NETWORK" -v "$SCAN_DIR:/scan:ro" \
+  python:3-alpine python /scan/fake_clamd.py >/dev/null
+sleep 2
+docker exec donations_api env | grep CLAMD_ADDRESS || echo "❌ CLAMD_ADDRESS not set, see the Run line above"
+
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=1:size=320x240:rate=25 \
+  -c:v mpeg4 /tmp/scan_clean.mp4
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=size=320x240 -frames:v 1 /tmp/scan_clean.png
+docker cp donations_api:/tmp/scan_clean.mp4 /tmp/scan_clean.mp4
+docker cp donations_api:/tmp/scan_clean.png /tmp/scan_clean.png
+
+printf '%s' 'X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*' > /tmp/scan_eicar.mp4
+cp /tmp/scan_eicar.mp4 /tmp/scan_eicar.jpg
+# A real, playable video carrying the test string (the fake finds it anywhere in the stream)
+{ cat /tmp/scan_clean.mp4; cat /tmp/scan_eicar.mp4; } > /tmp/scan_infected_video.mp4
+echo ""
+
+# 1. Clean upload
+echo "1. Upload Clean Video (should be 201, scan_status clean)..."
+curl -s -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=1" -F "video=@/tmp/scan_clean.mp4" | jq '{video_id, status, scan_status}'
+echo -e "\n"
+
+# 2. Infected uploads
+echo "2. Upload EICAR Test File (should be 422, rejected by the virus scan)..."
+curl -s -w "HTTP %{http_code}\n" -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=1" -F "video=@/tmp/scan_eicar.mp4"
+echo -e "\n"
+
+echo "3. Upload Playable Video Carrying EICAR (should be 422 - scanned before it is probed)..."
+curl -s -w "HTTP %{http_code}\n" -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=1" -F "video=@/tmp/scan_infected_video.mp4"
+echo -e "\n"
+
+echo "4. Video Records (newest two: quarantined / infected / Eicar-Test-Signature / infected; then the clean one)..."
+videos 3
+echo -e "\n"
+
+echo "5. Infected Files Are Quarantined, Not Deleted (should list two files under quarantine/videos)..."
+INFECTED=$(docker exec donations_db psql -U postgres -d donations -t -A -c \
+  "SELECT filename FROM videos WHERE scan_status = 'infected' ORDER BY video_id DESC LIMIT 2;")
+for f in $INFECTED; do
+  docker exec donations_api ls -l "/var/uploads/quarantine/videos/$f"
+  docker exec donations_api test -e "/var/uploads/videos/$f" && echo "❌ $f is servable" || echo "✅ $f not under videos/"
+done
+echo -e "\n"
+
+echo "6. Infected Video Can't Be Used In A Donation (should be 400)..."
+INFECTED_ID=$(docker exec donations_db psql -U postgres -d donations -t -A -c \
+  "SELECT video_id FROM videos WHERE scan_status = 'infected' ORDER BY video_id DESC LIMIT 1;")
+curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "event_id": 1,
+    "donor_name": "Scan Test",
+    "amount_pence": 500,
+    "message": "Happy birthday!",
+    "video_id": '"$INFECTED_ID"'
+  }' | jq .
+echo -e "\n"
+
+# 7. Resumable uploads are scanned once complete
+echo "7. tus Upload Of EICAR (final PATCH should be 422, then the upload is gone - 404)..."
+SIZE=$(wc -c < /tmp/scan_eicar.mp4 | tr -d ' ')
+METADATA="event_id $(printf '1' | base64),filename $(printf 'eicar.mp4' | base64)"
+LOCATION=$(curl -s -i -X POST "$TUS" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Upload-Length: $SIZE" \
+  -H "Upload-Metadata: $METADATA" | header Location)
+curl -s -w "HTTP %{http_code}\n" -X PATCH "$LOCATION" \
+  -H "Tus-Resumable: 1.0.0" \
+  -H "Content-Type: application/offset+octet-stream" \
+  -H "Upload-Offset: 0" \
+  --data-binary @/tmp/scan_eicar.mp4
+curl -s -o /dev/null -w "HEAD: HTTP %{http_code}\n" -I "$LOCATION" -H "Tus-Resumable: 1.0.0"
+videos 1
+echo -e "\n"
+
+# 8. Photos
+echo "8. Upload EICAR As A Photo (should be 422, and quarantined under quarantine/photos)..."
+curl -s -w "HTTP %{http_code}\n" -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/scan_eicar.jpg"
+docker exec donations_api sh -c "ls -t /var/uploads/quarantine/photos | head -1"
+echo -e "\n"
+
+echo "9. Upload Clean Photo (should be 201)..."
+curl -s -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/scan_clean.png" | jq '{photo_address, message}'
+echo -e "\n"
+
+# 10. Uploads are refused, not let through, while the scanner is down
+echo "10. Scanner Down (should be 503 with Retry-After, and no new video record)..."
+BEFORE=$(docker exec donations_db psql -U postgres -d donations -t -A -c "SELECT COUNT(*) FROM videos;")
+docker stop fake_clamd >/dev/null
+curl -s -D - -o /tmp/scan_body.json -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=1" -F "video=@/tmp/scan_clean.mp4" | grep -iE "^(HTTP|Retry-After)"
+jq . /tmp/scan_body.json
+AFTER=$(docker exec donations_db psql -U postgres -d donations -t -A -c "SELECT COUNT(*) FROM videos;")
+echo "videos before: $BEFORE after: $AFTER"
+echo -e "\n"
+
+echo "11. Scanner Back (should be 201 again)..."
+docker start fake_clamd >/dev/null
+sleep 2
+curl -s -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=1" -F "video=@/tmp/scan_clean.mp4" | jq '{video_id, scan_status}'
+echo -e "\n"
+
+docker rm -f fake_clamd >/dev/null
+docker exec donations_api rm -f /tmp/scan_clean.mp4 /tmp/scan_clean.png
+rm -f /tmp/scan_clean.mp4 /tmp/scan_clean.png /tmp/scan_eicar.mp4 /tmp/scan_eicar.jpg /tmp/scan_infected_video.mp4 /tmp/scan_body.json
+
+echo "✅ Testing Complete!"
@@ -1,7 +1,7 @@
 #!/bin/bash
 
 # API Testing Commands for Birthday Donations API
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "🚀 Testing Birthday Donations API"
 echo "================================="
@@ -1,16 +1,20 @@
 #!/bin/bash
 
 # Approve Donation API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "✅ Testing Approve Donation API"
 echo "================================"
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
 # First, let's get a list of donations to find some IDs to work with
 echo "Getting donation IDs for testing..."
 DONATION_DATA=$(curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"event_id": 1}')
 
@@ -25,6 +29,7 @@ echo -e "\n"
 # 1. Approve a pending donation
 echo "1. Approve Pending Donation (ID: $PENDING_DONATION_ID)..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "donation_id": '$PENDING_DONATION_ID',
@@ -45,12 +50,14 @@ curl -s -X POST "$BASE_URL/api/donations/create" \
 
 # Get the new donation ID
 NEW_DONATION_DATA=$(curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"event_id": 1}')
 NEW_DONATION_ID=$(echo "$NEW_DONATION_DATA" | jq -r '.donations[0].id')
 
 echo "3. Reject New Donation (ID: $NEW_DONATION_ID)..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "donation_id": '$NEW_DONATION_ID',
@@ -61,6 +68,7 @@ echo -e "\n"
 # 4. Try to approve an already approved donation
 echo "4. Try to Approve Already Approved Donation..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "donation_id": '$APPROVED_DONATION_ID',
@@ -71,6 +79,7 @@ echo -e "\n"
 # 5. Non-existent donation
 echo "5. Non-existent Donation (ID: 99999)..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "donation_id": 99999,
@@ -81,6 +90,7 @@ echo -e "\n"
 # 6. Missing required fields
 echo "6. Missing Required Fields..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "donation_id": '$PENDING_DONATION_ID'
@@ -90,6 +100,7 @@ echo -e "\n"
 # 7. Invalid JSON
 echo "7. Invalid JSON..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"invalid": json}' | jq .
 echo -e "\n"
@@ -98,6 +109,7 @@ echo -e "\n"
 echo "8. Toggle Approval Status..."
 echo "  8a. Reject previously approved donation..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "donation_id": '$PENDING_DONATION_ID',
@@ -105,8 +117,9 @@ curl -s -X POST "$BASE_URL/api/donations/approve" \
   }' | jq .
 echo -e "\n"
 
-echo "  8b. Approve it again..."
+echo "  8b. Approve it again (should fail - rejection is final)..."
 curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "donation_id": '$PENDING_DONATION_ID',
@@ -116,4 +129,4 @@ echo -e "\n"
 
 echo "✅ Testing Complete!"
 echo "Check final donation statuses:"
-echo "curl -X POST $BASE_URL/api/donations/list -H \"Content-Type: application/json\" -d '{\"event_id\": 1}' | jq '.donations[] | {id, donor_name, approved}'"
+echo "curl -X POST $BASE_URL/api/donations/list -H \"Content-Type: application/json\" -d '{\"event_id\": 1}' | jq '.donations[] | {id, donor_name, approved, status}'"
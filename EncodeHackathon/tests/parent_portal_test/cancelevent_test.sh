@@ -0,0 +1,132 @@
+#!/bin/bash
+
+# Cancel Event API Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (payments go to stripe-mock)
+
+echo "🚫 Testing Cancel Event API"
+echo "==========================="
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+OTHER_TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|cancelother123")
+
+NAME="Cancel Test $(date +%s)"
+IN_A_MONTH=$(date -u -d '+1 month' +%Y-%m-%d)
+
+sql() {
+  docker exec donations_db psql -U postgres -d donations -t -A -c "$1"
+}
+
+donate() {
+  curl -s -X POST "$BASE_URL/api/donations/create" \
+    -H "Content-Type: application/json" \
+    -d '{"event_id": '"$EVENT_ID"', "donor_name": "'"$1"'", "amount_pence": '"$2"', "message": "Cancel test"}'
+}
+
+cancel() {
+  curl -s -X POST "$BASE_URL/api/events/cancel" \
+    -H "Authorization: Bearer ${2:-$TOKEN}" \
+    -H "Content-Type: application/json" \
+    -d "$1" | jq .
+}
+
+# Setup: an event for Emma with a pending, an authorised and an approved donation
+echo "🔧 Setting up..."
+curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "cancelother@example.com",
+    "auth0_id": "auth0|cancelother123"
+  }' > /dev/null
+EVENT_ID=$(curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "'"$NAME"'",
+    "expires_at": "'"$IN_A_MONTH"'",
+    "videos_enabled": true
+  }' | jq -r '.event_id')
+PENDING_ID=$(donate "Cancel Unpaid" 500 | jq -r '.donation_id')
+AUTHORIZED_ID=$(donate "Cancel Authorised" 1500 | jq -r '.donation_id')
+APPROVED_ID=$(donate "Cancel Approved" 2500 | jq -r '.donation_id')
+sql "UPDATE donations SET payment_status = 'authorized', authorized_at = NOW(), status = 'pending_review' WHERE id = $AUTHORIZED_ID;
+  UPDATE donations SET payment_status = 'succeeded', status = 'captured', approved = true WHERE id = $APPROVED_ID;" > /dev/null
+echo "Created event $EVENT_ID with donations $PENDING_ID (unpaid), $AUTHORIZED_ID (authorised), $APPROVED_ID (captured)"
+echo ""
+
+# 1. Access
+echo "1. Another Parent's Event (should be 403)..."
+cancel '{"event_id": '"$EVENT_ID"'}' "$OTHER_TOKEN"
+echo -e "\n"
+
+echo "2. Unknown Event (should be 404)..."
+cancel '{"event_id": 99999}'
+echo -e "\n"
+
+echo "3. Missing event_id (should be 400)..."
+cancel '{}'
+echo -e "\n"
+
+# 4. Cancel
+echo "4. Cancel (should be 200, rejected_donations 2, kept_donations 1)..."
+cancel '{"event_id": '"$EVENT_ID"', "reason": "Party postponed"}'
+echo -e "\n"
+
+echo "5. Donations (unpaid and authorised rejected with payments released, captured kept)..."
+sql "SELECT id, donor_name, status, payment_status FROM donations WHERE event_id = $EVENT_ID ORDER BY id;"
+sql "SELECT donation_id, from_status, to_status, reason FROM donation_status_history
+  WHERE donation_id IN ($PENDING_ID, $AUTHORIZED_ID) AND to_status = 'rejected';"
+echo -e "\n"
+
+# 6. The event is closed
+echo "6. Donations Page (should be 410 cancelled)..."
+curl -s -X POST "$BASE_URL/api/events/request" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": '"$EVENT_ID"'}' | jq .
+echo -e "\n"
+
+echo "7. New Donation (should be 410 cancelled)..."
+donate "Cancel Latecomer" 500 | jq .
+echo -e "\n"
+
+echo "8. Video Upload (should be 410 cancelled)..."
+curl -s -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=$EVENT_ID" \
+  -F "video=@$(dirname "$0")/../../docker/api/birthday_message.mp4" | jq .
+echo -e "\n"
+
+echo "9. Still Listed (is_cancelled true, cancel_reason kept, days_remaining 0)..."
+curl -s -X POST "$BASE_URL/api/events/list" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq '.events[] | select(.event_id == '"$EVENT_ID"') | {event_name, is_cancelled, cancelled_at, cancel_reason, days_remaining}'
+echo -e "\n"
+
+# 10. Cancelling again keeps the first cancellation
+echo "10. Cancel Again (should be 200, rejected_donations 0, same cancelled_at)..."
+cancel '{"event_id": '"$EVENT_ID"', "reason": "Changed my mind"}'
+echo -e "\n"
+
+# 11. Its name is free again
+echo "11. New Event With The Same Name (should be 201)..."
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "'"$NAME"'",
+    "expires_at": "'"$IN_A_MONTH"'"
+  }' | jq .
+echo -e "\n"
+
+echo "12. No Access Token (should be 401)..."
+curl -s -w "HTTP %{http_code}\n" -o /dev/null -X POST "$BASE_URL/api/events/cancel" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": '"$EVENT_ID"'}'
+echo -e "\n"
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,157 @@
+#!/bin/bash
+
+# Time Capsule Delivery Testing (emails land in mailpit)
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build
+
+echo "💌 Testing Time Capsule Delivery"
+echo "================================"
+
+BASE_URL="http://localhost:8080"
+MAILPIT="http://localhost:8025"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+OTHER_TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|capsuleother123")
+
+TODAY=$(date -u +%Y-%m-%d)
+EMAIL="capsule.$(date +%s)@example.com"
+
+sql() {
+  docker exec donations_db psql -U postgres -d donations -t -A -c "$1"
+}
+
+# Messages mailpit caught for $EMAIL
+mails() {
+  curl -s "$MAILPIT/api/v1/search?query=to:$EMAIL"
+}
+
+# The capsule link in the newest email to $EMAIL
+capsule_link() {
+  ID=$(mails | jq -r '.messages[0].ID')
+  curl -s "$MAILPIT/api/v1/message/$ID" | jq -r '.Text' | grep -o 'http://[^ ]*/api/capsules/[^ ]*' | head -1
+}
+
+# Setup: a second parent, and a child whose capsule is due today
+echo "🔧 Setting up..."
+curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "capsuleother@example.com",
+    "auth0_id": "auth0|capsuleother123"
+  }' > /dev/null
+echo ""
+
+# 1. A delivery date when the child is added
+echo "1. Create Child Delivered Today (should be 201)..."
+CHILD_ID=$(curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_name": "Capsule Test",
+    "dob": "2015-03-01",
+    "email": "'"$EMAIL"'",
+    "delivery_date": "'"$TODAY"'"
+  }' | tee /dev/stderr | jq -r '.child_id')
+echo -e "\n"
+
+# 2. Validation
+echo "2. Delivery Date In The Past (should be 400)..."
+curl -s -X POST "$BASE_URL/api/children/delivery" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": '"$CHILD_ID"', "delivery_date": "2000-01-01"}' | jq .
+echo -e "\n"
+
+echo "3. Another Parent's Child (should be 403)..."
+curl -s -X POST "$BASE_URL/api/children/delivery" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": '"$CHILD_ID"', "delivery_date": "'"$TODAY"'"}' | jq .
+echo -e "\n"
+
+echo "4. Children List Shows The Delivery Date (should be today, not delivered)..."
+curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq '.children[] | select(.child_id == '"$CHILD_ID"') | {child_name, delivery_date, capsule_delivered_at}'
+echo -e "\n"
+
+# 5. Nothing approved yet - nothing is sent
+echo "5. Deliver With No Approved Donations (should be empty, and no email)..."
+docker exec donations_api ./main capsules -child "$CHILD_ID"
+echo "emails: $(mails | jq '.messages_count')"
+echo -e "\n"
+
+echo "5b. Deliver Again With Nothing New Approved (should not list the child)..."
+docker exec donations_api ./main capsules -dry-run -child "$CHILD_ID"
+echo -e "\n"
+
+# 6. Gifts arrive: two approved (one with a video) and one the parent rejected
+echo "6. Dry Run After Donations Are Approved (should list the child, send nothing)..."
+EVENT_ID=$(sql "INSERT INTO events (child_id, event_name, expires_at, event_message, videos_enabled)
+  VALUES ($CHILD_ID, 'Capsule Test Birthday', NOW() + INTERVAL '30 days', 'Happy birthday!', true) RETURNING event_id;" | head -1)
+sql "INSERT INTO donations (message, donor_name, amount_pence, approved, approved_at, status, event_id, video_address) VALUES
+  ('Happy birthday! Open this when you are grown up.', 'Grandma', 5000, true, NOW(), 'approved', $EVENT_ID, NULL),
+  ('Remember the beach?', 'Uncle Bob', 1000, true, NOW(), 'captured', $EVENT_ID, '$BASE_URL/api/videos/capsule_test.mp4'),
+  ('Not for the capsule', 'Stranger', 100, false, NULL, 'rejected', $EVENT_ID, NULL);" > /dev/null
+docker exec donations_api ./main capsules -dry-run -child "$CHILD_ID"
+echo "emails: $(mails | jq '.messages_count')"
+echo -e "\n"
+
+echo "7. Deliver (should be sent: 2 gifts, 2 messages, 1 video)..."
+docker exec donations_api ./main capsules -child "$CHILD_ID"
+sleep 1
+mails | jq '{messages_count, subject: .messages[0].Subject, to: .messages[0].To[0].Address}'
+echo -e "\n"
+
+echo "8. Deliver Again (should send nothing - already delivered)..."
+docker exec donations_api ./main capsules -child "$CHILD_ID"
+sleep 1
+echo "emails: $(mails | jq '.messages_count') (should still be 1)"
+echo -e "\n"
+
+echo "9. Delivery Record (should be sent, 2 items, send_count 1)..."
+sql "SELECT status, item_count, message_count, video_count, send_count, sent_at IS NOT NULL AS sent FROM capsule_deliveries WHERE child_id = $CHILD_ID;"
+echo -e "\n"
+
+# 10. The link in the email
+LINK=$(capsule_link)
+echo "10. Open The Capsule From The Email (should be 200, two gifts oldest first, signed video link)..."
+echo "$LINK"
+curl -s -D /tmp/capsule_headers.txt "$LINK" | jq '{child_name, message_count, video_count, items: [.items[] | {donor_name, message, video_address}]}'
+grep -i "^Cache-Control" /tmp/capsule_headers.txt
+sql "SELECT opened_at IS NOT NULL AS opened FROM capsule_deliveries WHERE child_id = $CHILD_ID;"
+echo -e "\n"
+
+echo "11. Tampered, Unsigned And Other Capsule Links (should all be 403)..."
+DELIVERY_ID=$(sql "SELECT delivery_id FROM capsule_deliveries WHERE child_id = $CHILD_ID;")
+curl -s -w " HTTP %{http_code}\n" "${LINK%?}x"
+curl -s -w " HTTP %{http_code}\n" "$BASE_URL/api/capsules/$DELIVERY_ID"
+curl -s -w " HTTP %{http_code}\n" "$BASE_URL/api/capsules/$((DELIVERY_ID + 1))?${LINK#*\?}"
+echo -e "\n"
+
+echo "12. Move The Date After Delivery (should be 409)..."
+curl -s -X POST "$BASE_URL/api/children/delivery" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": '"$CHILD_ID"', "delivery_date": null}' | jq .
+echo -e "\n"
+
+# 13. A fresh link, with anything approved since
+echo "13. Resend (should be sent with 3 gifts, a second email, send_count 2)..."
+sql "INSERT INTO donations (message, donor_name, amount_pence, approved, status, event_id) VALUES
+  ('Sorry I was late!', 'Aunt Sarah', 2000, true, 'approved', $EVENT_ID);" > /dev/null
+docker exec donations_api ./main capsules -resend -child "$CHILD_ID"
+sleep 1
+echo "emails: $(mails | jq '.messages_count')"
+sql "SELECT status, item_count, send_count FROM capsule_deliveries WHERE child_id = $CHILD_ID;"
+echo -e "\n"
+
+echo "14. Old Link Still Opens The Updated Capsule (should list 3 gifts)..."
+curl -s "$LINK" | jq '[.items[].donor_name]'
+echo -e "\n"
+
+rm -f /tmp/capsule_headers.txt
+
+echo "✅ Testing Complete!"
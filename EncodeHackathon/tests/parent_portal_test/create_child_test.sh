@@ -1,16 +1,20 @@
 #!/bin/bash
 
 # Create Child API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "👶 Testing Create Child API"
 echo "============================"
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
 # 1. Valid child creation
 echo "1. Valid Child Creation..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -23,6 +27,7 @@ echo -e "\n"
 # 2. Another valid child
 echo "2. Another Valid Child..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -35,6 +40,7 @@ echo -e "\n"
 # 3. Missing required fields
 echo "3. Missing Required Fields..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -45,6 +51,7 @@ echo -e "\n"
 # 4. Invalid date format
 echo "4. Invalid Date Format..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -57,6 +64,7 @@ echo -e "\n"
 # 5. Future date of birth
 echo "5. Future Date of Birth..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -69,6 +77,7 @@ echo -e "\n"
 # 6. Child too old (over 18)
 echo "6. Child Too Old (Over 18)..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -81,6 +90,7 @@ echo -e "\n"
 # 7. Duplicate email
 echo "7. Duplicate Email..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -93,6 +103,7 @@ echo -e "\n"
 # 8. Non-existent parent
 echo "8. Non-existent Parent..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 999,
@@ -105,6 +116,7 @@ echo -e "\n"
 # 9. Invalid email format
 echo "9. Invalid Email Format..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -122,6 +134,7 @@ echo "curl -X POST $BASE_URL/api/children/list -H \"Content-Type: application/js
 # 10. Same child name (should be allowed - different families can have same names)
 echo "10. Same Child Name (Should Be Allowed)..."
 curl -s -X POST "$BASE_URL/api/children/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
@@ -1,16 +1,20 @@
 #!/bin/bash
 
 # Create Event API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "🎂 Testing Create Event API"
 echo "============================"
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
 # 1. Valid event creation
 echo "1. Valid Event Creation..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -25,6 +29,7 @@ echo -e "\n"
 # 2. Another valid event (minimal fields)
 echo "2. Minimal Valid Event..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -36,6 +41,7 @@ echo -e "\n"
 # 3. Missing required fields
 echo "3. Missing Required Fields..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -46,6 +52,7 @@ echo -e "\n"
 # 4. Invalid date format
 echo "4. Invalid Date Format..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -57,6 +64,7 @@ echo -e "\n"
 # 5. Past expiry date
 echo "5. Past Expiry Date..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -68,6 +76,7 @@ echo -e "\n"
 # 6. Too far in future (over 2 years)
 echo "6. Too Far in Future..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -79,6 +88,7 @@ echo -e "\n"
 # 7. Non-existent child
 echo "7. Non-existent Child..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 999,
@@ -90,6 +100,7 @@ echo -e "\n"
 # 8. Duplicate event name (same child, same name, active)
 echo "8. Duplicate Event Name..."
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -101,10 +112,11 @@ echo -e "\n"
 # 9. Event for different child (should work)
 echo "9. Event for Different Child..."
 # First get or create another child
-CHILD_COUNT=$(curl -s -X POST "$BASE_URL/api/children/list" -H "Content-Type: application/json" -d '{"parent_id": 1}' | jq '.count')
+CHILD_COUNT=$(curl -s -X POST "$BASE_URL/api/children/list" -H "Authorization: Bearer $TOKEN" -H "Content-Type: application/json" -d '{"parent_id": 1}' | jq '.count')
 if [ "$CHILD_COUNT" -lt 2 ]; then
   echo "Creating second child for test..."
   curl -s -X POST "$BASE_URL/api/children/create" \
+    -H "Authorization: Bearer $TOKEN" \
     -H "Content-Type: application/json" \
     -d '{
       "parent_id": 1,
@@ -115,9 +127,10 @@ if [ "$CHILD_COUNT" -lt 2 ]; then
 fi
 
 # Get child_id for second child
-SECOND_CHILD_ID=$(curl -s -X POST "$BASE_URL/api/children/list" -H "Content-Type: application/json" -d '{"parent_id": 1}' | jq '.children[1].child_id // .children[0].child_id')
+SECOND_CHILD_ID=$(curl -s -X POST "$BASE_URL/api/children/list" -H "Authorization: Bearer $TOKEN" -H "Content-Type: application/json" -d '{"parent_id": 1}' | jq '.children[1].child_id // .children[0].child_id')
 
 curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": '$SECOND_CHILD_ID',
@@ -1,16 +1,21 @@
 #!/bin/bash
 
 # Create Parent API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "👨‍👩‍👧‍👦 Testing Create Parent API"
 echo "================================"
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+token_for() { "$(dirname "$0")/../auth/token.sh" "$1"; }
+
 # 1. Valid parent creation
 echo "1. Valid Parent Creation..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|fresh123test456")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "jessica.smith@newdomain.com",
@@ -21,6 +26,7 @@ echo -e "\n"
 # 2. Another valid parent
 echo "2. Another Valid Parent..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|brand789new012")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "robert.jones@anotherdomain.com", 
@@ -31,6 +37,7 @@ echo -e "\n"
 # 3. Missing required fields
 echo "3. Missing Required Fields..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "incomplete@example.com"
@@ -40,6 +47,7 @@ echo -e "\n"
 # 4. Invalid email format
 echo "4. Invalid Email Format..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|valididformat")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "not-an-email",
@@ -50,6 +58,7 @@ echo -e "\n"
 # 5. Invalid auth0_id format (missing prefix)
 echo "5. Invalid Auth0 ID Format..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "justplaintext123")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "badauth@example.com",
@@ -60,6 +69,7 @@ echo -e "\n"
 # 6. Duplicate auth0_id
 echo "6. Duplicate Auth0 ID..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|fresh123test456")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "different.email@newdomain.com",
@@ -70,6 +80,7 @@ echo -e "\n"
 # 7. Duplicate email
 echo "7. Duplicate Email..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|totallydifferent999")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "jessica.smith@newdomain.com",
@@ -80,6 +91,7 @@ echo -e "\n"
 # 8. Empty auth0_id
 echo "8. Empty Auth0 ID..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "empty@example.com",
@@ -90,6 +102,7 @@ echo -e "\n"
 # 9. Valid auth0_id with different format variations
 echo "9. Valid Auth0 ID Variations..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|google-oauth2|9876543210")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "variation.test@uniquedomain.com",
@@ -100,6 +113,7 @@ echo -e "\n"
 # 10. Special characters in email (should be valid)
 echo "10. Special Characters in Email..."
 curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|specialemail456")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "test.email+tag@special-domain.co.uk",
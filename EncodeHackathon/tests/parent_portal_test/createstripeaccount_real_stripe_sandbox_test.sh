@@ -3,7 +3,7 @@
 # Real Stripe Integration Test
 # Prerequisites: 
 # 1. STRIPE_SECRET_KEY set in environment
-# 2. go get github.com/stripe/stripe-go/v79
+# 2. API running with the same key (no STRIPE_API_BASE)
 
 echo "🎯 Real Stripe Connect Account Test"
 echo "==================================="
@@ -21,9 +21,14 @@ echo ""
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+token_for() { "$(dirname "$0")/../auth/token.sh" "$1"; }
+
 # Step 1: Create a test parent
 echo "👤 Step 1: Creating test parent..."
 PARENT_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|realstripe789")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "realstripe@example.com",
@@ -31,48 +36,44 @@ PARENT_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
   }')
 
 PARENT_ID=$(echo $PARENT_RESPONSE | jq -r '.parent_id')
+PARENT_TOKEN=$(token_for "auth0|realstripe789")
 echo "Created parent ID: $PARENT_ID"
 echo ""
 
-# Step 2: Create REAL Stripe Connect Express account
-echo "💳 Step 2: Creating REAL Stripe Connect account..."
-STRIPE_RESPONSE=$(curl -s -X POST https://api.stripe.com/v1/accounts \
-  -H "Authorization: Bearer $STRIPE_SECRET_KEY" \
-  -H "Content-Type: application/x-www-form-urlencoded" \
-  -d "type=express&country=GB&email=realstripe@example.com")
-# Extract account ID from Stripe response
-STRIPE_ACCOUNT_ID=$(echo $STRIPE_RESPONSE | jq -r '.id')
+# Step 2: Have the API create a REAL Stripe Connect Express account
+# (the API must be running with this STRIPE_SECRET_KEY and without STRIPE_API_BASE)
+echo "💳 Step 2: Creating REAL Stripe Connect account via the API..."
+CREATE_RESPONSE=$(curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"parent_id\": $PARENT_ID}")
+
+echo "Create response:"
+echo "$CREATE_RESPONSE" | jq .
+echo ""
+
+STRIPE_ACCOUNT_ID=$(echo $CREATE_RESPONSE | jq -r '.stripe_connect_account_id')
+ONBOARDING_URL=$(echo $CREATE_RESPONSE | jq -r '.onboarding_url')
 
 if [ "$STRIPE_ACCOUNT_ID" = "null" ]; then
     echo "❌ Failed to create Stripe account!"
-    echo "Response: $STRIPE_RESPONSE"
     exit 1
 fi
 
 echo "✅ Created Stripe account: $STRIPE_ACCOUNT_ID"
 echo ""
 
-# Step 3: Save account to our database
-echo "💾 Step 3: Saving account to our database..."
-SAVE_RESPONSE=$(curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT_ID,
-    \"stripe_connect_account_id\": \"$STRIPE_ACCOUNT_ID\"
-  }")
-
-echo "Save response:"
-echo "$SAVE_RESPONSE" | jq .
+# Step 3: Check the account exists in Stripe
+echo "🔍 Step 3: Looking up account in Stripe..."
+curl -s "https://api.stripe.com/v1/accounts/$STRIPE_ACCOUNT_ID" \
+  -H "Authorization: Bearer $STRIPE_SECRET_KEY" | jq '{id, type, country, email}'
 echo ""
 
-# Step 4: Create account link for onboarding
-echo "🔗 Step 4: Creating onboarding link..."
-LINK_RESPONSE=$(curl -s -X POST https://api.stripe.com/v1/account_links \
-  -H "Authorization: Bearer $STRIPE_SECRET_KEY" \
-  -H "Content-Type: application/x-www-form-urlencoded" \
-  -d "account=$STRIPE_ACCOUNT_ID&refresh_url=http://localhost:3000/reauth&return_url=http://localhost:3000/return&type=account_onboarding")
-
-ONBOARDING_URL=$(echo $LINK_RESPONSE | jq -r '.url')
+# Step 4: Resume onboarding (fresh link)
+echo "🔗 Step 4: Creating a fresh onboarding link..."
+LINK_RESPONSE=$(curl -s -X POST "$BASE_URL/api/payments/onboarding-link" \
+  -H "Authorization: Bearer $PARENT_TOKEN")
+ONBOARDING_URL=$(echo $LINK_RESPONSE | jq -r '.onboarding_url')
 
 if [ "$ONBOARDING_URL" = "null" ]; then
     echo "❌ Failed to create onboarding link!"
@@ -99,12 +100,8 @@ echo ""
 
 # Step 6: Test duplicate prevention
 echo "🚫 Step 6: Testing duplicate prevention..."
-DUPLICATE_RESPONSE=$(curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT_ID,
-    \"stripe_connect_account_id\": \"$STRIPE_ACCOUNT_ID\"
-  }")
+DUPLICATE_RESPONSE=$(curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT_TOKEN")
 
 echo "Duplicate attempt response:"
 echo "$DUPLICATE_RESPONSE" | jq .
@@ -114,7 +111,7 @@ echo ""
 echo "🎉 REAL STRIPE TEST COMPLETE!"
 echo "=============================="
 echo "✅ Created real Stripe Express account: $STRIPE_ACCOUNT_ID"
-echo "✅ Saved to database successfully"
+echo "✅ Saved to database by the API"
 echo "✅ Generated onboarding URL"
 echo "✅ Verified duplicate prevention"
 echo ""
@@ -125,6 +122,6 @@ echo "2. Check Stripe dashboard: https://dashboard.stripe.com/test/connect/accou
 echo "3. Account will be able to receive test payments after onboarding"
 echo ""
 echo "💡 In production:"
-echo "   - Use webhooks to track onboarding completion"
-echo "   - Set real return/refresh URLs"
+echo "   - account.updated webhooks set onboarding_complete"
+echo "   - Set STRIPE_CONNECT_RETURN_URL / STRIPE_CONNECT_REFRESH_URL"
 echo "   - Handle country-specific requirements"
@@ -1,17 +1,21 @@
 #!/bin/bash
 
-# Save Stripe Account API Testing  
-# Run: docker compose up -d
-# Note: Tests client-side integration approach
+# Create Stripe Account API Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (accounts are created in stripe-mock)
 
-echo "💳 Testing Save Stripe Account API"
-echo "=================================="
+echo "💳 Testing Create Stripe Account API"
+echo "===================================="
 
 BASE_URL="http://localhost:8080"
 
-# Setup: Create test parents for Stripe account linking
-echo "🔧 Setting up test parents..."
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+token_for() { "$(dirname "$0")/../auth/token.sh" "$1"; }
+
+# Setup: Create a test parent without a Stripe account
+echo "🔧 Setting up test parent..."
 PARENT_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|stripesave123")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "stripesave1@example.com",
@@ -19,127 +23,81 @@ PARENT_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
   }')
 
 PARENT_ID=$(echo $PARENT_RESPONSE | jq -r '.parent_id')
+PARENT_TOKEN=$(token_for "auth0|stripesave123")
 echo "Created test parent with ID: $PARENT_ID"
-
-PARENT2_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
-  -H "Content-Type: application/json" \
-  -d '{
-    "parent_email": "stripesave2@example.com",
-    "auth0_id": "auth0|stripesave456"
-  }')
-
-PARENT2_ID=$(echo $PARENT2_RESPONSE | jq -r '.parent_id')
-echo "Created second test parent with ID: $PARENT2_ID"
 echo ""
 
-# 1. Valid Stripe account save
-echo "1. Valid Stripe Account Save..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT_ID,
-    \"stripe_connect_account_id\": \"acct_1ABCDEfghijklmno\"
-  }" | jq .
-echo -e "\n"
-
-# 2. Save account for sample parent
-echo "2. Account for Sample Parent..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d '{
-    "parent_id": 1,
-    "stripe_connect_account_id": "acct_1XYZ789sampletest"
-  }' | jq .
-echo -e "\n"
-
-# 3. Missing required fields
-echo "3. Missing Required Fields..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
+# 1. Create account (returns an onboarding link)
+echo "1. Create Stripe Account..."
+RESPONSE=$(curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT_TOKEN" \
   -H "Content-Type: application/json" \
-  -d '{
-    "parent_id": 1
-  }' | jq .
+  -d '{}')
+echo "$RESPONSE" | jq .
+
+if [[ "$(echo "$RESPONSE" | jq -r '.stripe_connect_account_id')" == acct_* ]]; then
+  echo "✅ Connect account created"
+else
+  echo "❌ No Connect account ID returned"
+fi
+if [[ "$(echo "$RESPONSE" | jq -r '.onboarding_url')" == http* ]]; then
+  echo "✅ Onboarding URL returned"
+else
+  echo "❌ No onboarding URL returned"
+fi
 echo -e "\n"
 
-# 4. Invalid Stripe account ID format
-echo "4. Invalid Stripe Account ID Format..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
+# 2. Duplicate account (should fail)
+echo "2. Duplicate Account (should fail with 409)..."
+curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT_TOKEN" \
   -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT2_ID,
-    \"stripe_connect_account_id\": \"invalid_format_123\"
-  }" | jq .
+  -d '{}' | jq .
 echo -e "\n"
 
-# 5. Non-existent parent
-echo "5. Non-existent Parent..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
+# 3. Empty body is fine (parent comes from the token)
+echo "3. No Request Body..."
+PARENT2_ID=$(curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|stripesave456")" \
   -H "Content-Type: application/json" \
   -d '{
-    "parent_id": 99999,
-    "stripe_connect_account_id": "acct_1ValidFormat123"
-  }' | jq .
-echo -e "\n"
-
-# 6. Duplicate parent account (should fail)
-echo "6. Duplicate Parent Account..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT_ID,
-    \"stripe_connect_account_id\": \"acct_1DifferentAccount\"
-  }" | jq .
-echo -e "\n"
-
-# 7. Duplicate Stripe account ID (should fail)
-echo "7. Duplicate Stripe Account ID..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT2_ID,
-    \"stripe_connect_account_id\": \"acct_1ABCDEfghijklmno\"
-  }" | jq .
+    "parent_email": "stripesave2@example.com",
+    "auth0_id": "auth0|stripesave456"
+  }' | jq -r '.parent_id')
+PARENT2_TOKEN=$(token_for "auth0|stripesave456")
+curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT2_TOKEN" | jq .
 echo -e "\n"
 
-# 8. Valid different Stripe account ID
-echo "8. Valid Different Account..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
+# 4. Another parent's ID (should fail with 403)
+echo "4. Another Parent's ID..."
+curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT_TOKEN" \
   -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT2_ID,
-    \"stripe_connect_account_id\": \"acct_1DEFGHijklmnopqr\"
-  }" | jq .
+  -d '{"parent_id": 1}' | jq .
 echo -e "\n"
 
-# 9. Empty Stripe account ID
-echo "9. Empty Stripe Account ID..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": 1,
-    \"stripe_connect_account_id\": \"\"
-  }" | jq .
+# 5. Account status is stored from Stripe, not from the client
+echo "5. Stored Account Status..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT parent_id, stripe_connect_account_id, onboarding_complete, charges_enabled, payouts_enabled, details_submitted FROM payment_accounts WHERE parent_id IN ($PARENT_ID, $PARENT2_ID);"
 echo -e "\n"
 
-# 10. Invalid JSON format
-echo "10. Invalid JSON Format..."
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
+# 6. Invalid JSON format
+echo "6. Invalid JSON Format..."
+curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1,
-    "stripe_connect_account_id": "acct_123",
     "extra": incomplete
   }' | jq .
 echo -e "\n"
 
 echo "✅ Testing Complete!"
 echo ""
-echo "🔍 Check saved payment accounts:"
-echo "docker exec donations_db psql -U postgres -d donations -c \"SELECT * FROM payment_accounts ORDER BY created_at DESC;\""
-echo ""
 echo "📝 Frontend Integration Notes:"
-echo "   1. Use Stripe.js to create Connect account client-side"
-echo "   2. Get account.id from Stripe response"
-echo "   3. Call this endpoint to save the account ID"
-echo "   4. Direct parent to Stripe onboarding flow"
-echo "   5. Use webhooks to update onboarding_complete status"
+echo "   1. Call this endpoint once the parent has signed up"
+echo "   2. Redirect the parent to onboarding_url"
+echo "   3. Stripe returns them to STRIPE_CONNECT_RETURN_URL (or STRIPE_CONNECT_REFRESH_URL if the link expired)"
+echo "   4. Call /api/payments/status to see if onboarding finished, /api/payments/onboarding-link to resume"
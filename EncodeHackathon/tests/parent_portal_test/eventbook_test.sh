@@ -0,0 +1,151 @@
+#!/bin/bash
+
+# Event Book (PDF) Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build
+
+echo "📖 Testing Event Book PDF"
+echo "========================="
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+OTHER_TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|bookother123")
+
+sql() {
+  docker exec donations_db psql -U postgres -d donations -t -A -c "$1"
+}
+
+# Prints the text drawn on each page of a PDF (the content streams are compressed)
+pdf_text() {
+  python3 - "$1" <<'EOF'
+import re, sys, zlib
+data = open(sys.argv[1], "rb").read()
+for page, m in enumerate(re.finditer(rb"/Filter /FlateDecode /Length (\d+) >>\nstream\n", data), 1):
+    content = zlib.decompress(data[m.end():m.end() + int(m.group(1))]).decode("cp1252")
+    for text in re.findall(r"\((.*?)\) Tj", content):
+        print(f"  page {page}: {text}")
+EOF
+}
+
+wait_for_video() {
+  for _ in $(seq 1 30); do
+    [ "$(sql "SELECT status FROM video_transcodes WHERE source_filename = '$1';")" = "ready" ] && return
+    sleep 2
+  done
+}
+
+# Setup: a second parent, an uploaded photo and message on event 1, and approved
+# donations - one with a transcoded video (so it has a thumbnail)
+echo "🔧 Setting up test event..."
+curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "bookother@example.com",
+    "auth0_id": "auth0|bookother123"
+  }' > /dev/null
+
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=size=640x480 -frames:v 1 /tmp/book_photo.jpg
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=2:size=320x240:rate=25 \
+  -c:v mpeg4 /tmp/book_video.mp4
+docker cp donations_api:/tmp/book_photo.jpg /tmp/book_photo.jpg
+docker cp donations_api:/tmp/book_video.mp4 /tmp/book_video.mp4
+
+PHOTO_ADDRESS=$(curl -s -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/book_photo.jpg" | jq -r '.photo_address')
+ORIGINAL_PHOTO=$(sql "SELECT photo_address FROM events WHERE event_id = 1;")
+
+sql "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true,
+  event_message = 'Book test: Emma turns 8 - send her a message (and a video!)',
+  photo_address = '$PHOTO_ADDRESS' WHERE event_id = 1;" > /dev/null
+VIDEO_ID=$(curl -s -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=1" -F "video=@/tmp/book_video.mp4" | jq -r '.video_id')
+FILENAME=$(sql "SELECT filename FROM videos WHERE video_id = $VIDEO_ID;")
+wait_for_video "$FILENAME"
+
+sql "UPDATE videos SET status = 'attached' WHERE video_id = $VIDEO_ID;
+INSERT INTO donations (message, donor_name, amount_pence, approved, status, event_id, video_id, video_address) VALUES
+  ('Book test: happy birthday Emma! 🎂 Watch my video – love, Gran', 'Book Gran', 2500, true, 'captured', 1, $VIDEO_ID, '$BASE_URL/api/videos/$FILENAME'),
+  ('Book test: have a wonderful day (from all of us)', 'Book Bob', 1000, true, 'approved', 1, NULL, NULL),
+  ('Book test: not approved', 'Book Stranger', 100, false, 'rejected', 1, NULL, NULL);" > /dev/null
+echo ""
+
+# 1. Download
+echo "1. Book For Event 1 (should be 200, application/pdf, attachment emmas-8th-birthday-book.pdf)..."
+curl -s -D - -o /tmp/event_book.pdf -X POST "$BASE_URL/api/events/book" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | grep -iE "^(HTTP|Content-Type|Content-Disposition|Content-Length|Cache-Control)"
+echo -e "\n"
+
+echo "2. Is A PDF (should start %PDF-1.4 and end %%EOF)..."
+head -c 8 /tmp/event_book.pdf; echo ""
+tail -c 6 /tmp/event_book.pdf
+echo "Pages: $(grep -a -o '/Type /Pages /Kids \[[^]]*\] /Count [0-9]*' /tmp/event_book.pdf | grep -o '[0-9]*$')"
+echo -e "\n"
+
+echo "3. Images (should be 2: the event photo and Book Gran's video thumbnail)..."
+grep -a -c "/Subtype /Image" /tmp/event_book.pdf
+echo -e "\n"
+
+echo "4. Text (cover with the event message; Book Gran and Book Bob with dates, the emoji left out; no Book Stranger)..."
+pdf_text /tmp/event_book.pdf | grep -E "page 1:|Book (test|Gran|Bob|Stranger)|Scan to watch|link works|Page [0-9]+ of"
+echo -e "\n"
+
+echo "5. QR Code Drawn Next To The Thumbnail (should print one grid of modules)..."
+python3 - /tmp/event_book.pdf <<'EOF'
+import re, sys, zlib
+data = open(sys.argv[1], "rb").read()
+for m in re.finditer(rb"/Filter /FlateDecode /Length (\d+) >>\nstream\n", data):
+    content = zlib.decompress(data[m.end():m.end() + int(m.group(1))]).decode("cp1252")
+    for grid in re.findall(r"cm\n((?:\d+ \d+ \d+ 1 re\n)+)f Q", content):
+        rows = {int(line.split()[1]) for line in grid.splitlines()}
+        print(f"  {len(rows)} x {len(rows)} modules")
+EOF
+echo -e "\n"
+
+# 6. Photos that weren't uploaded aren't fetched
+echo "6. Event With An Outside photo_address (should be 200, 1 image - just the thumbnail)..."
+sql "UPDATE events SET photo_address = 'https://example.com/emma-photo.jpg' WHERE event_id = 1;" > /dev/null
+curl -s -w "HTTP %{http_code}\n" -o /tmp/event_book_external.pdf -X POST "$BASE_URL/api/events/book" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}'
+grep -a -c "/Subtype /Image" /tmp/event_book_external.pdf
+echo -e "\n"
+
+# 7. Errors
+echo "7. Missing event_id (should be 400)..."
+curl -s -X POST "$BASE_URL/api/events/book" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq .
+echo -e "\n"
+
+echo "8. Another Parent's Event (should be 403)..."
+curl -s -X POST "$BASE_URL/api/events/book" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | jq .
+echo -e "\n"
+
+echo "9. Unknown Event (should be 404)..."
+curl -s -X POST "$BASE_URL/api/events/book" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 99999}' | jq .
+echo -e "\n"
+
+echo "10. No Access Token (should be 401)..."
+curl -s -w "HTTP %{http_code}\n" -o /dev/null -X POST "$BASE_URL/api/events/book" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}'
+echo -e "\n"
+
+sql "UPDATE events SET photo_address = '$ORIGINAL_PHOTO' WHERE event_id = 1;" > /dev/null
+docker exec donations_api rm -f /tmp/book_photo.jpg /tmp/book_video.mp4
+rm -f /tmp/book_photo.jpg /tmp/book_video.mp4 /tmp/event_book.pdf /tmp/event_book_external.pdf
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,132 @@
+#!/bin/bash
+
+# Keepsake Archive Export Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build
+
+echo "🎁 Testing Keepsake Archive Export"
+echo "=================================="
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+OTHER_TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|keepsakeother123")
+
+sql() {
+  docker exec donations_db psql -U postgres -d donations -t -A -c "$1"
+}
+
+# Setup: a second parent, and approved donations to event 1 - one with an uploaded video
+echo "🔧 Setting up test donations..."
+curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "keepsakeother@example.com",
+    "auth0_id": "auth0|keepsakeother123"
+  }' > /dev/null
+
+sql "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" > /dev/null
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=2:size=320x240:rate=25 \
+  -c:v mpeg4 /tmp/keepsake_video.mp4
+docker cp donations_api:/tmp/keepsake_video.mp4 /tmp/keepsake_video.mp4
+VIDEO_ID=$(curl -s -X POST "$BASE_URL/api/uploads/video" \
+  -F "event_id=1" -F "video=@/tmp/keepsake_video.mp4" | jq -r '.video_id')
+FILENAME=$(sql "SELECT filename FROM videos WHERE video_id = $VIDEO_ID;")
+
+sql "UPDATE videos SET status = 'attached' WHERE video_id = $VIDEO_ID;
+INSERT INTO donations (message, donor_name, amount_pence, approved, status, event_id, video_id, video_address) VALUES
+  ('Keepsake test: watch this when you are older <3', 'Keepsake Gran', 2500, true, 'captured', 1, $VIDEO_ID, '$BASE_URL/api/videos/$FILENAME'),
+  ('Keepsake test: happy birthday!', 'Keepsake Bob', 1000, true, 'approved', 1, NULL, NULL),
+  ('Keepsake test: not approved', 'Keepsake Stranger', 100, false, 'rejected', 1, NULL, NULL);" > /dev/null
+echo ""
+
+# 1. A child's keepsake
+echo "1. Export Child 1 (should be 200, application/zip, attachment emma-keepsake.zip)..."
+curl -s -D - -o /tmp/keepsake_child.zip -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1}' | grep -iE "^(HTTP|Content-Type|Content-Disposition|Cache-Control)"
+echo -e "\n"
+
+echo "2. Archive Contents (should be index.html, manifest.json and a video under emma-keepsake/)..."
+unzip -l /tmp/keepsake_child.zip
+unzip -t /tmp/keepsake_child.zip | tail -1
+echo -e "\n"
+
+echo "3. Manifest (test donations: Gran with a video, Bob without; Stranger left out)..."
+unzip -p /tmp/keepsake_child.zip emma-keepsake/manifest.json \
+  | jq '{format, version, child, totals, donations: [.donations[] | select(.donor_name | startswith("Keepsake")) | {donor_name, message, amount_pence, video}]}'
+echo -e "\n"
+
+echo "4. Video In The Archive Matches The Manifest (sizes should match)..."
+FILE=$(unzip -p /tmp/keepsake_child.zip emma-keepsake/manifest.json \
+  | jq -r '.donations[] | select(.donor_name == "Keepsake Gran") | .video.file')
+SIZE=$(unzip -p /tmp/keepsake_child.zip emma-keepsake/manifest.json \
+  | jq -r '.donations[] | select(.donor_name == "Keepsake Gran") | .video.size_bytes')
+echo "$FILE manifest: $SIZE bytes, archive: $(unzip -p /tmp/keepsake_child.zip "emma-keepsake/$FILE" | wc -c | tr -d ' ') bytes"
+echo -e "\n"
+
+echo "5. index.html (should show the gifts, escape the message, and play $FILE)..."
+unzip -p /tmp/keepsake_child.zip emma-keepsake/index.html | grep -E "Keepsake (Gran|Bob)|&lt;3|<video" | sed 's/^ *//'
+echo -e "\n"
+
+# 6. One event
+echo "6. Export Event 1 (should be 200, emmas-8th-birthday-keepsake.zip)..."
+curl -s -D - -o /tmp/keepsake_event.zip -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | grep -iE "^(HTTP|Content-Disposition)"
+unzip -p /tmp/keepsake_event.zip emmas-8th-birthday-keepsake/manifest.json | jq '{event, totals}'
+echo -e "\n"
+
+# 7. Errors
+echo "7. Neither child_id Nor event_id (should be 400)..."
+curl -s -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq .
+echo -e "\n"
+
+echo "8. Both child_id And event_id (should be 400)..."
+curl -s -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1, "event_id": 1}' | jq .
+echo -e "\n"
+
+echo "9. Another Parent's Child And Event (should be 403 twice)..."
+curl -s -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1}' | jq .
+curl -s -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | jq .
+echo -e "\n"
+
+echo "10. Unknown Child (should be 404)..."
+curl -s -X POST "$BASE_URL/api/children/export" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 99999}' | jq .
+echo -e "\n"
+
+echo "11. No Access Token (should be 401)..."
+curl -s -w "HTTP %{http_code}\n" -o /dev/null -X POST "$BASE_URL/api/children/export" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1}'
+echo -e "\n"
+
+# 12. The same archive from the CLI
+echo "12. CLI Export (should write the same files)..."
+docker exec donations_api ./main export -child 1 -o /tmp/keepsake_cli.zip
+docker cp donations_api:/tmp/keepsake_cli.zip /tmp/keepsake_cli.zip
+unzip -l /tmp/keepsake_cli.zip | tail -n +4 | awk '{print $4}' | grep -v '^$'
+echo -e "\n"
+
+docker exec donations_api rm -f /tmp/keepsake_video.mp4 /tmp/keepsake_cli.zip
+rm -f /tmp/keepsake_video.mp4 /tmp/keepsake_child.zip /tmp/keepsake_event.zip /tmp/keepsake_cli.zip
+
+echo "✅ Testing Complete!"
@@ -1,16 +1,20 @@
 #!/bin/bash
 
 # Get Children API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "👶 Testing Get Children API"
 echo "============================"
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
 # 1. Valid request (parent_id = 1 from sample data)
 echo "1. Valid Request (Parent ID: 1)..."
 curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"parent_id": 1}' | jq .
 echo -e "\n"
@@ -18,6 +22,7 @@ echo -e "\n"
 # 2. Non-existent parent
 echo "2. Non-existent Parent (ID: 999)..."
 curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"parent_id": 999}' | jq .
 echo -e "\n"
@@ -25,6 +30,7 @@ echo -e "\n"
 # 3. Missing parent_id
 echo "3. Missing parent_id..."
 curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"wrong_field": 123}' | jq .
 echo -e "\n"
@@ -32,6 +38,7 @@ echo -e "\n"
 # 4. Invalid JSON
 echo "4. Invalid JSON..."
 curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"invalid": json}' | jq .
 echo -e "\n"
@@ -46,6 +53,7 @@ INSERT INTO children (DOB, parent_id, email, isa_expiry, child_name) VALUES
 
 echo "6. Query after adding children..."
 curl -s -X POST "$BASE_URL/api/children/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"parent_id": 1}' | jq .
 echo -e "\n"
@@ -1,13 +1,16 @@
 #!/bin/bash
 
 # Get Events API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "🎉 Testing Get Events API"
 echo "=========================="
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
 # First, add some test events
 echo "Setting up test data..."
 docker exec -it donations_db psql -U postgres -d donations -c "
@@ -29,6 +32,7 @@ echo -e "\n"
 # 1. Valid request (parent_id = 1)
 echo "1. Valid Request (Parent ID: 1)..."
 curl -s -X POST "$BASE_URL/api/events/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"parent_id": 1}' | jq .
 echo -e "\n"
@@ -36,6 +40,7 @@ echo -e "\n"
 # 2. Non-existent parent
 echo "2. Non-existent Parent (ID: 999)..."
 curl -s -X POST "$BASE_URL/api/events/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"parent_id": 999}' | jq .
 echo -e "\n"
@@ -43,6 +48,7 @@ echo -e "\n"
 # 3. Missing parent_id
 echo "3. Missing parent_id..."
 curl -s -X POST "$BASE_URL/api/events/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"wrong_field": 123}' | jq .
 echo -e "\n"
@@ -50,6 +56,7 @@ echo -e "\n"
 # 4. Invalid JSON
 echo "4. Invalid JSON..."
 curl -s -X POST "$BASE_URL/api/events/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"invalid": json}' | jq .
 echo -e "\n"
@@ -63,6 +70,7 @@ INSERT INTO events (child_id, event_name, expires_at, event_message, videos_enab
 
 echo "6. Query including expired event..."
 curl -s -X POST "$BASE_URL/api/events/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"parent_id": 1}' | jq .
 echo -e "\n"
@@ -1,70 +1,71 @@
 #!/bin/bash
 
 # Get Payment Accounts Status API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "📊 Testing Get Payment Accounts Status API"
 echo "=========================================="
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+token_for() { "$(dirname "$0")/../auth/token.sh" "$1"; }
+
 # Setup: Create test parents in different payment states
 echo "🔧 Setting up test data..."
 
 # Parent 1: No payment account
 PARENT1_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|nopayment123")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "nopayment@example.com",
     "auth0_id": "auth0|nopayment123"
   }')
 PARENT1_ID=$(echo $PARENT1_RESPONSE | jq -r '.parent_id')
+PARENT1_TOKEN=$(token_for "auth0|nopayment123")
 echo "Created parent 1 (no payment): $PARENT1_ID"
 
 # Parent 2: Payment account but no onboarding
 PARENT2_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|pending123")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "pending@example.com",
     "auth0_id": "auth0|pending123"
   }')
 PARENT2_ID=$(echo $PARENT2_RESPONSE | jq -r '.parent_id')
+PARENT2_TOKEN=$(token_for "auth0|pending123")
 
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT2_ID,
-    \"stripe_connect_account_id\": \"acct_1PendingOnboarding\"
-  }" > /dev/null
+curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT2_TOKEN" > /dev/null
 echo "Created parent 2 (pending onboarding): $PARENT2_ID"
 
 # Parent 3: Complete payment setup
 PARENT3_RESPONSE=$(curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|complete123")" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_email": "complete@example.com",
     "auth0_id": "auth0|complete123"
   }')
 PARENT3_ID=$(echo $PARENT3_RESPONSE | jq -r '.parent_id')
+PARENT3_TOKEN=$(token_for "auth0|complete123")
 
-curl -s -X POST "$BASE_URL/api/payments/save-account" \
-  -H "Content-Type: application/json" \
-  -d "{
-    \"parent_id\": $PARENT3_ID,
-    \"stripe_connect_account_id\": \"acct_1CompleteSetup123\"
-  }" > /dev/null
+curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT3_TOKEN" > /dev/null
 
-curl -s -X POST "$BASE_URL/api/payments/onboarding-complete" \
-  -H "Content-Type: application/json" \
-  -d '{
-    "stripe_connect_account_id": "acct_1CompleteSetup123"
-  }' > /dev/null
+# Onboarding is completed by Stripe (account.updated webhook) - simulate its result
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE payment_accounts SET onboarding_complete = true, charges_enabled = true, payouts_enabled = true WHERE parent_id = $PARENT3_ID;" > /dev/null
 echo "Created parent 3 (complete setup): $PARENT3_ID"
 echo ""
 
 # 1. Parent with no payment account
 echo "1. Parent with No Payment Account..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $PARENT1_TOKEN" \
   -H "Content-Type: application/json" \
   -d "{
     \"parent_id\": $PARENT1_ID
@@ -74,6 +75,7 @@ echo -e "\n"
 # 2. Parent with pending onboarding
 echo "2. Parent with Pending Onboarding..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $PARENT2_TOKEN" \
   -H "Content-Type: application/json" \
   -d "{
     \"parent_id\": $PARENT2_ID
@@ -83,6 +85,7 @@ echo -e "\n"
 # 3. Parent with complete setup
 echo "3. Parent with Complete Setup..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $PARENT3_TOKEN" \
   -H "Content-Type: application/json" \
   -d "{
     \"parent_id\": $PARENT3_ID
@@ -92,6 +95,7 @@ echo -e "\n"
 # 4. Sample parent status (from initial data)
 echo "4. Sample Parent Status..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 1
@@ -101,6 +105,7 @@ echo -e "\n"
 # 5. Missing parent_id
 echo "5. Missing Parent ID..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{}' | jq .
 echo -e "\n"
@@ -108,6 +113,7 @@ echo -e "\n"
 # 6. Non-existent parent
 echo "6. Non-existent Parent..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 99999
@@ -117,6 +123,7 @@ echo -e "\n"
 # 7. Invalid parent_id type
 echo "7. Invalid Parent ID Type..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": "not_a_number"
@@ -126,6 +133,7 @@ echo -e "\n"
 # 8. Zero parent_id
 echo "8. Zero Parent ID..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": 0
@@ -135,6 +143,7 @@ echo -e "\n"
 # 9. Negative parent_id
 echo "9. Negative Parent ID..."
 curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "parent_id": -1
@@ -146,6 +155,7 @@ echo "10. Multiple Parent Status Check..."
 for id in $PARENT1_ID $PARENT2_ID $PARENT3_ID; do
   echo "Parent $id status:"
   curl -s -X POST "$BASE_URL/api/payments/status" \
+    -H "Authorization: Bearer $TOKEN" \
     -H "Content-Type: application/json" \
     -d "{\"parent_id\": $id}" | jq -r '.status'
 done
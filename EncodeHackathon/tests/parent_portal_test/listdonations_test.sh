@@ -1,13 +1,16 @@
 #!/bin/bash
 
 # List Donations API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "📝 Testing List Donations API"
 echo "=============================="
 
 BASE_URL="http://localhost:8080"
 
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
 # First, add some test donations for review
 echo "Setting up test donations..."
 docker exec -it donations_db psql -U postgres -d donations -c "
@@ -25,6 +28,7 @@ echo -e "\n"
 # 1. Valid request (event_id = 1)
 echo "1. Valid Request (Event ID: 1)..."
 curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"event_id": 1}' | jq .
 echo -e "\n"
@@ -32,6 +36,7 @@ echo -e "\n"
 # 2. Non-existent event
 echo "2. Non-existent Event (ID: 999)..."
 curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"event_id": 999}' | jq .
 echo -e "\n"
@@ -39,6 +44,7 @@ echo -e "\n"
 # 3. Missing event_id
 echo "3. Missing event_id..."
 curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"wrong_field": 123}' | jq .
 echo -e "\n"
@@ -46,6 +52,7 @@ echo -e "\n"
 # 4. Invalid JSON
 echo "4. Invalid JSON..."
 curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"invalid": json}' | jq .
 echo -e "\n"
@@ -54,6 +61,7 @@ echo -e "\n"
 echo "5. Creating event with no donations..."
 # First create a new event
 NEW_EVENT_RESPONSE=$(curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{
     "child_id": 1,
@@ -65,10 +73,11 @@ NEW_EVENT_ID=$(echo "$NEW_EVENT_RESPONSE" | jq -r '.event_id')
 
 echo "6. Query event with no donations (ID: $NEW_EVENT_ID)..."
 curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
   -H "Content-Type: application/json" \
   -d '{"event_id": '$NEW_EVENT_ID'}' | jq .
 echo -e "\n"
 
 echo "✅ Testing Complete!"
-echo "Check statistics: total_donations, approved_donations, pending_donations"
+echo "Check statistics: total_donations, approved_donations, pending_donations, status_breakdown"
 echo "Verify donations are ordered by created_at DESC (newest first)"
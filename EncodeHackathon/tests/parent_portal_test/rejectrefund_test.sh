@@ -0,0 +1,58 @@
+#!/bin/bash
+
+# Reject Donation Refund Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (payments go to stripe-mock)
+
+echo "💸 Testing Refund on Donation Rejection"
+echo "======================================="
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+# Setup: a donation with a PaymentIntent
+echo "🔧 Creating test donation..."
+DONATION_ID=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "Refund Tester", "amount_pence": 700, "message": "Not very nice"}' | jq -r '.donation_id')
+echo "Created donation: $DONATION_ID"
+echo ""
+
+# 1. Mark the payment as completed (as the webhook would)
+echo "1. Simulating Completed Payment..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE donations SET payment_status = 'succeeded' WHERE id = $DONATION_ID;"
+echo ""
+
+# 2. Reject the donation (should refund)
+echo "2. Reject Donation (should refund)..."
+curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"donation_id\": $DONATION_ID, \"approved\": false}" | jq .
+echo ""
+
+# 3. Refund details are stored
+echo "3. Refund Stored on Donation..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT id, approved, payment_status, refund_id, refunded_amount_pence, refunded_at FROM donations WHERE id = $DONATION_ID;"
+echo ""
+
+# 4. Rejecting again is a no-op (no second refund)
+echo "4. Reject Again (should be already rejected)..."
+curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"donation_id\": $DONATION_ID, \"approved\": false}" | jq .
+echo ""
+
+# 5. Approving a refunded donation (should fail with 409)
+echo "5. Approve Refunded Donation (should fail)..."
+curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"donation_id\": $DONATION_ID, \"approved\": true}" | jq .
+echo ""
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,91 @@
+#!/bin/bash
+
+# Resume Onboarding API Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (accounts are created in stripe-mock)
+
+echo "🔗 Testing Resume Onboarding API"
+echo "================================"
+
+BASE_URL="http://localhost:8080"
+WEBHOOK_SECRET="whsec_test_secret"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+token_for() { "$(dirname "$0")/../auth/token.sh" "$1"; }
+
+# Sign a payload the way Stripe does: HMAC-SHA256 of "timestamp.payload"
+send_event() {
+  local payload="$1"
+  local timestamp=$(date +%s)
+  local signature=$(printf "%s.%s" "$timestamp" "$payload" | openssl dgst -sha256 -hmac "$WEBHOOK_SECRET" | sed 's/^.* //')
+  curl -s -X POST "$BASE_URL/api/payments/webhook" \
+    -H "Content-Type: application/json" \
+    -H "Stripe-Signature: t=$timestamp,v1=$signature" \
+    -d "$payload"
+}
+
+# Setup: Create test parent and payment account for testing
+echo "🔧 Setting up test data..."
+PARENT_ID=$(curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $(token_for "auth0|onboarding123")" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "onboarding@example.com",
+    "auth0_id": "auth0|onboarding123"
+  }' | jq -r '.parent_id')
+PARENT_TOKEN=$(token_for "auth0|onboarding123")
+echo "Created test parent with ID: $PARENT_ID"
+echo ""
+
+# 1. No account yet (should fail with 404)
+echo "1. Resume Without Account..."
+curl -s -X POST "$BASE_URL/api/payments/onboarding-link" \
+  -H "Authorization: Bearer $PARENT_TOKEN" | jq .
+echo -e "\n"
+
+ACCOUNT_ID=$(curl -s -X POST "$BASE_URL/api/payments/create-account" \
+  -H "Authorization: Bearer $PARENT_TOKEN" | jq -r '.stripe_connect_account_id')
+echo "Created payment account $ACCOUNT_ID"
+echo ""
+
+# 2. Resume onboarding (fresh link)
+echo "2. Resume Onboarding..."
+curl -s -X POST "$BASE_URL/api/payments/onboarding-link" \
+  -H "Authorization: Bearer $PARENT_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{}' | jq .
+echo -e "\n"
+
+# 3. Status comes from Stripe (onboarding-complete is no longer a client call)
+echo "3. Removed Client Completion Endpoint (should be 404)..."
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -X POST "$BASE_URL/api/payments/onboarding-complete" \
+  -H "Authorization: Bearer $PARENT_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"stripe_connect_account_id\": \"$ACCOUNT_ID\"}"
+echo -e "\n"
+
+# 4. account.updated with charges and payouts enabled completes onboarding
+echo "4. account.updated Webhook..."
+send_event "{\"id\":\"evt_onboarded_$(date +%s)\",\"object\":\"event\",\"type\":\"account.updated\",\"data\":{\"object\":{\"id\":\"acct_sample123\",\"object\":\"account\",\"charges_enabled\":true,\"payouts_enabled\":true,\"details_submitted\":true}}}" | jq .
+curl -s -X POST "$BASE_URL/api/payments/status" \
+  -H "Authorization: Bearer $TOKEN" | jq '.payment_account'
+echo -e "\n"
+
+# 5. Resume for a completed account returns no link
+echo "5. Resume Completed Account..."
+curl -s -X POST "$BASE_URL/api/payments/onboarding-link" \
+  -H "Authorization: Bearer $TOKEN" | jq .
+echo -e "\n"
+
+# 6. Another parent's ID (should fail with 403)
+echo "6. Another Parent's ID..."
+curl -s -X POST "$BASE_URL/api/payments/onboarding-link" \
+  -H "Authorization: Bearer $PARENT_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"parent_id": 1}' | jq .
+echo -e "\n"
+
+echo "✅ Testing Complete!"
+echo ""
+echo "🔍 Check onboarding status in database:"
+echo "docker exec donations_db psql -U postgres -d donations -c \"SELECT account_id, parent_id, stripe_connect_account_id, onboarding_complete, charges_enabled, payouts_enabled FROM payment_accounts ORDER BY created_at DESC;\""
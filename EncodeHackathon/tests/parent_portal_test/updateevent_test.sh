@@ -0,0 +1,136 @@
+#!/bin/bash
+
+# Update Event API Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
+
+echo "✏️  Testing Update Event API"
+echo "============================"
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+OTHER_TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|updateother123")
+
+NAME="Update Test $(date +%s)"
+IN_A_MONTH=$(date -u -d '+1 month' +%Y-%m-%d)
+IN_TWO_MONTHS=$(date -u -d '+2 months' +%Y-%m-%d)
+IN_THREE_YEARS=$(date -u -d '+3 years' +%Y-%m-%d)
+
+update() {
+  curl -s -X POST "$BASE_URL/api/events/update" \
+    -H "Authorization: Bearer ${2:-$TOKEN}" \
+    -H "Content-Type: application/json" \
+    -d "$1" | jq .
+}
+
+# Setup: two events for Emma, and a second parent
+echo "🔧 Setting up..."
+curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "updateother@example.com",
+    "auth0_id": "auth0|updateother123"
+  }' > /dev/null
+EVENT_ID=$(curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "'"$NAME"'",
+    "expires_at": "'"$IN_A_MONTH"'",
+    "event_message": "Before the update"
+  }' | jq -r '.event_id')
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "'"$NAME"' (taken)",
+    "expires_at": "'"$IN_A_MONTH"'"
+  }' > /dev/null
+echo "Created event: $EVENT_ID"
+echo ""
+
+# 1. Change some fields, leave the rest
+echo "1. Rename, Move Expiry, Enable Videos (should be 200, event_message unchanged)..."
+update '{
+  "event_id": '"$EVENT_ID"',
+  "event_name": "'"$NAME"' (renamed)",
+  "expires_at": "'"$IN_TWO_MONTHS"'",
+  "videos_enabled": true
+}'
+echo -e "\n"
+
+# 2. Empty strings remove the message and photo
+echo "2. Remove Message, Set Policy (event_message null, policy capture)..."
+update '{
+  "event_id": '"$EVENT_ID"',
+  "event_message": "",
+  "expiring_authorization_policy": "capture"
+}' | jq '{event_message: .event.event_message, expiring_authorization_policy: .event.expiring_authorization_policy, message}'
+echo -e "\n"
+
+# 3. Validation (same rules as create)
+echo "3. Nothing To Change (should be 400)..."
+update '{"event_id": '"$EVENT_ID"'}'
+echo -e "\n"
+
+echo "4. Expiry In The Past (should be 400)..."
+update '{"event_id": '"$EVENT_ID"', "expires_at": "2020-01-01"}'
+echo -e "\n"
+
+echo "5. Expiry More Than 2 Years Away (should be 400)..."
+update '{"event_id": '"$EVENT_ID"', "expires_at": "'"$IN_THREE_YEARS"'"}'
+echo -e "\n"
+
+echo "6. Invalid Date Format (should be 400)..."
+update '{"event_id": '"$EVENT_ID"', "expires_at": "15-07-2026"}'
+echo -e "\n"
+
+echo "7. Unknown Policy (should be 400)..."
+update '{"event_id": '"$EVENT_ID"', "expiring_authorization_policy": "sometimes"}'
+echo -e "\n"
+
+echo "8. Empty Name (should be 400)..."
+update '{"event_id": '"$EVENT_ID"', "event_name": "  "}'
+echo -e "\n"
+
+echo "9. Name Of Another Active Event (should be 409)..."
+update '{"event_id": '"$EVENT_ID"', "event_name": "'"$NAME"' (taken)"}'
+echo -e "\n"
+
+echo "10. Keeping Its Own Name (should be 200)..."
+update '{"event_id": '"$EVENT_ID"', "event_name": "'"$NAME"' (renamed)"}' | jq '{event_name: .event.event_name, message}'
+echo -e "\n"
+
+echo "11. Photo That Wasn't Uploaded (should be 400)..."
+update '{"event_id": '"$EVENT_ID"', "photo_address": "'"$BASE_URL"'/api/photos/not-uploaded.jpg"}'
+echo -e "\n"
+
+# 12. Access
+echo "12. Another Parent's Event (should be 403)..."
+update '{"event_id": '"$EVENT_ID"', "event_name": "Hijacked"}' "$OTHER_TOKEN"
+echo -e "\n"
+
+echo "13. Unknown Event (should be 404)..."
+update '{"event_id": 99999, "event_name": "Nobody"}'
+echo -e "\n"
+
+echo "14. No Access Token (should be 401)..."
+curl -s -w "HTTP %{http_code}\n" -o /dev/null -X POST "$BASE_URL/api/events/update" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": '"$EVENT_ID"', "event_name": "No token"}'
+echo -e "\n"
+
+# 15. Cancelled events are frozen
+echo "15. Cancelled Event (should be 409)..."
+curl -s -X POST "$BASE_URL/api/events/cancel" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": '"$EVENT_ID"'}' > /dev/null
+update '{"event_id": '"$EVENT_ID"', "event_name": "Too late"}'
+echo -e "\n"
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,188 @@
+#!/bin/bash
+
+# Event Photo Upload API Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build
+
+echo "🖼️  Testing Event Photo Upload API"
+echo "=================================="
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+OTHER_TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|photoother123")
+
+# Setup: test images made with ffmpeg in the API container, and a second parent
+echo "🔧 Setting up test images..."
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=size=640x480 -frames:v 1 /tmp/photo_test.jpg
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=size=300x200 -frames:v 1 /tmp/photo_test.png
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=size=400x300 -frames:v 1 -c:v libwebp /tmp/photo_test.webp
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=size=200x20 -frames:v 1 /tmp/photo_wide.png
+for f in photo_test.jpg photo_test.png photo_test.webp photo_wide.png; do
+  docker cp "donations_api:/tmp/$f" "/tmp/$f"
+done
+
+# A phone photo: EXIF (big-endian TIFF) straight after the JPEG's SOI marker, with
+# Orientation=6 (taken on its side) and a GPS IFD holding GPSLatitudeRef=N
+EXIF='\xff\xe1\x00\x40Exif\x00\x00'
+EXIF+='MM\x00\x2a\x00\x00\x00\x08'
+EXIF+='\x00\x02'
+EXIF+='\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00'
+EXIF+='\x88\x25\x00\x04\x00\x00\x00\x01\x00\x00\x00\x26'
+EXIF+='\x00\x00\x00\x00'
+EXIF+='\x00\x01'
+EXIF+='\x00\x01\x00\x02\x00\x00\x00\x02N\x00\x00\x00'
+EXIF+='\x00\x00\x00\x00'
+{ head -c 2 /tmp/photo_test.jpg; printf "$EXIF"; tail -c +3 /tmp/photo_test.jpg; } > /tmp/photo_exif.jpg
+
+echo "This is not a photo" > /tmp/photo_fake.jpg
+
+curl -s -X POST "$BASE_URL/api/parents/create" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "parent_email": "photoother@example.com",
+    "auth0_id": "auth0|photoother123"
+  }' > /dev/null
+echo ""
+
+# 1. Phone photo with EXIF orientation and GPS
+echo "1. Upload JPEG With EXIF GPS + Orientation=6 (should be 201, display 480x640 - turned upright)..."
+RESPONSE=$(curl -s -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/photo_exif.jpg")
+echo "$RESPONSE" | jq .
+PHOTO_ADDRESS=$(echo "$RESPONSE" | jq -r '.photo_address')
+echo -e "\n"
+
+echo "2. Variants Carry No EXIF (should print ✅ for each, with their sizes)..."
+for variant in display card thumbnail; do
+  URL=$(echo "$RESPONSE" | jq -r ".variants.$variant")
+  curl -s -o "/tmp/photo_$variant.jpg" -w "$variant: HTTP %{http_code} %{content_type} " "$URL"
+  docker cp "/tmp/photo_$variant.jpg" "donations_api:/tmp/photo_$variant.jpg"
+  docker exec donations_api ffprobe -v error -select_streams v:0 -show_entries stream=width,height -of csv=p=0 "/tmp/photo_$variant.jpg" | tr -d '\n'
+  grep -qa "Exif" "/tmp/photo_$variant.jpg" && echo " ❌ still has EXIF" || echo " ✅ no EXIF"
+done
+echo -e "\n"
+
+echo "3. Original EXIF Was There (sanity check, should print ✅)..."
+grep -qa "Exif" /tmp/photo_exif.jpg && echo "✅ upload had EXIF"
+echo -e "\n"
+
+# 4. Other formats
+echo "4. Upload PNG (should be 201, 300x200 - small photos aren't enlarged)..."
+curl -s -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/photo_test.png" | jq '{photo_address, width, height}'
+echo -e "\n"
+
+echo "5. Upload WebP (should be 201)..."
+curl -s -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/photo_test.webp" | jq '{photo_address, width, height}'
+echo -e "\n"
+
+echo "6. Upload HEIC (should be 201; skipped if heif-enc can't encode in this image)..."
+if docker exec donations_api sh -c "heif-enc -o /tmp/photo_test.heic /tmp/photo_test.jpg" >/dev/null 2>&1; then
+  docker cp donations_api:/tmp/photo_test.heic /tmp/photo_test.heic
+  curl -s -X POST "$BASE_URL/api/uploads/photo" \
+    -H "Authorization: Bearer $TOKEN" \
+    -F "photo=@/tmp/photo_test.heic" | jq '{photo_address, width, height}'
+else
+  echo "⏭️  heif-enc unavailable, skipped"
+fi
+echo -e "\n"
+
+echo "7. Very Wide Photo (card and thumbnail are still cropped to their exact size)..."
+WIDE=$(curl -s -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/photo_wide.png")
+curl -s -o /tmp/photo_card.jpg "$(echo "$WIDE" | jq -r '.variants.card')"
+docker cp /tmp/photo_card.jpg donations_api:/tmp/photo_card.jpg
+docker exec donations_api ffprobe -v error -select_streams v:0 -show_entries stream=width,height -of csv=p=0 /tmp/photo_card.jpg
+echo -e "\n"
+
+# 8. Rejected uploads
+echo "8. Text File Named .jpg (should be 415)..."
+curl -s -w "HTTP %{http_code}\n" -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "photo=@/tmp/photo_fake.jpg"
+echo -e "\n"
+
+echo "9. No File (should be 400)..."
+curl -s -w "HTTP %{http_code}\n" -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $TOKEN" \
+  -F "event_id=1"
+echo -e "\n"
+
+echo "10. No Token (should be 401)..."
+curl -s -w "HTTP %{http_code}\n" -X POST "$BASE_URL/api/uploads/photo" \
+  -F "photo=@/tmp/photo_test.png"
+echo -e "\n"
+
+# 11. Serving photos
+echo "11. Serve Photo (should be 200 image/jpeg with an ETag, public)..."
+curl -s -I "$PHOTO_ADDRESS" | grep -iE "^(HTTP|Content-Type|ETag|Cache-Control)"
+echo -e "\n"
+
+echo "12. Unknown / Invalid Photo Filename (should be 404 / 400)..."
+curl -s -w "HTTP %{http_code}\n" "$BASE_URL/api/photos/00000000-0000-0000-0000-000000000000.jpg"
+curl -s -w "HTTP %{http_code}\n" "$BASE_URL/api/photos/..%2Fvideos%2Fsecret.mp4"
+echo -e "\n"
+
+# 13. Using the photo for an event
+echo "13. Create Event With The Uploaded Photo (should be 201)..."
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "Photo Test Party '"$(date +%s)"'",
+    "expires_at": "'"$(date -d '+30 days' +%Y-%m-%d)"'",
+    "photo_address": "'"$PHOTO_ADDRESS"'"
+  }' | jq .
+echo -e "\n"
+
+echo "14. Create Event With A Photo Address That Was Never Uploaded (should be 400)..."
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "Photo Test Missing '"$(date +%s)"'",
+    "expires_at": "'"$(date -d '+30 days' +%Y-%m-%d)"'",
+    "photo_address": "'"$BASE_URL"'/api/photos/00000000-0000-0000-0000-000000000000.jpg"
+  }' | jq .
+echo -e "\n"
+
+echo "15. Another Parent Uploads A Photo, Sample Parent Tries To Use It (should be 403)..."
+OTHER_PHOTO=$(curl -s -X POST "$BASE_URL/api/uploads/photo" \
+  -H "Authorization: Bearer $OTHER_TOKEN" \
+  -F "photo=@/tmp/photo_test.png" | jq -r '.photo_address')
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "Photo Test Other '"$(date +%s)"'",
+    "expires_at": "'"$(date -d '+30 days' +%Y-%m-%d)"'",
+    "photo_address": "'"$OTHER_PHOTO"'"
+  }' | jq .
+echo -e "\n"
+
+echo "16. Create Event With An Outside Photo URL (still allowed, should be 201)..."
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "child_id": 1,
+    "event_name": "Photo Test Outside '"$(date +%s)"'",
+    "expires_at": "'"$(date -d '+30 days' +%Y-%m-%d)"'",
+    "photo_address": "https://example.com/emma-9th.jpg"
+  }' | jq .
+echo -e "\n"
+
+docker exec donations_api sh -c "rm -f /tmp/photo_*"
+rm -f /tmp/photo_*
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,65 @@
+#!/bin/bash
+
+# Authorise-then-Capture Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d   (payments go to stripe-mock)
+
+echo "🔒 Testing Authorise-then-Capture Donations"
+echo "==========================================="
+
+BASE_URL="http://localhost:8080"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+# Setup: a donation whose card has been authorised
+echo "🔧 Creating test donation..."
+DONATION_ID=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "Capture Tester", "amount_pence": 1200, "message": "Hold this for me"}' | jq -r '.donation_id')
+echo "Created donation: $DONATION_ID"
+echo ""
+
+# 1. Mark the payment as authorised (as the amount_capturable_updated webhook would)
+echo "1. Simulating Card Authorisation..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE donations SET payment_status = 'authorized', authorized_at = NOW() WHERE id = $DONATION_ID;"
+echo ""
+
+# 2. Approving captures the money
+echo "2. Approve Donation (should capture)..."
+STATUS=$(curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"donation_id\": $DONATION_ID, \"approved\": true}" | tee /dev/stderr | jq -r '.payment_status')
+if [[ "$STATUS" == "succeeded" ]]; then
+  echo "✅ Donation captured"
+else
+  echo "❌ Expected payment_status succeeded, got $STATUS"
+fi
+echo ""
+
+# 3. Invalid expiring authorisation policy is rejected
+echo "3. Create Event with Invalid Policy (should fail)..."
+curl -s -X POST "$BASE_URL/api/events/create" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"child_id": 1, "event_name": "Policy Test", "expires_at": "'"$(date -d '+30 days' +%F)"'", "expiring_authorization_policy": "ignore"}' | jq .
+echo ""
+
+# 4. An unapproved donation authorised 6+ days ago gets the parent notified by the sweeper
+echo "4. Expiring Authorisation Notification..."
+PENDING_ID=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "Slow Parent Tester", "amount_pence": 900}' | jq -r '.donation_id')
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE donations SET payment_status = 'authorized', authorized_at = NOW() - INTERVAL '6 days 1 hour' WHERE id = $PENDING_ID;"
+echo "Restart the API (or wait for AUTHORIZATION_SWEEP_INTERVAL) so the sweeper runs..."
+docker restart donations_api > /dev/null
+sleep 5
+curl -s -X POST "$BASE_URL/api/notifications/list" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"unread_only": true}' | jq ".notifications[] | select(.donation_id == $PENDING_ID)"
+echo ""
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,103 @@
+#!/bin/bash
+
+# Stripe Webhook API Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
+# Events are signed locally with the same secret as docker-compose.yml
+
+echo "🔔 Testing Stripe Webhook API"
+echo "============================="
+
+BASE_URL="http://localhost:8080"
+WEBHOOK_SECRET="whsec_test_secret"
+
+# Sign a payload the way Stripe does: HMAC-SHA256 of "timestamp.payload"
+send_event() {
+  local payload="$1"
+  local timestamp=$(date +%s)
+  local signature=$(printf "%s.%s" "$timestamp" "$payload" | openssl dgst -sha256 -hmac "$WEBHOOK_SECRET" | sed 's/^.* //')
+  curl -s -X POST "$BASE_URL/api/payments/webhook" \
+    -H "Content-Type: application/json" \
+    -H "Stripe-Signature: t=$timestamp,v1=$signature" \
+    -d "$payload"
+}
+
+# Setup: create a donation so we have a PaymentIntent to update
+echo "🔧 Creating test donation..."
+DONATION=$(curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "Webhook Tester", "amount_pence": 500}')
+PAYMENT_INTENT_ID=$(echo "$DONATION" | jq -r '.payment_intent_id')
+echo "PaymentIntent: $PAYMENT_INTENT_ID"
+echo ""
+
+EVENT_SUFFIX=$(date +%s)
+
+# 1. The card is authorised, then the payment captured (should be captured)
+echo "1. payment_intent.amount_capturable_updated, then payment_intent.succeeded..."
+send_event "{\"id\":\"evt_authorized_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"payment_intent.amount_capturable_updated\",\"data\":{\"object\":{\"id\":\"$PAYMENT_INTENT_ID\",\"object\":\"payment_intent\"}}}" | jq .
+send_event "{\"id\":\"evt_succeeded_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"payment_intent.succeeded\",\"data\":{\"object\":{\"id\":\"$PAYMENT_INTENT_ID\",\"object\":\"payment_intent\"}}}" | jq .
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT id, payment_intent_id, payment_status, status FROM donations WHERE payment_intent_id = '$PAYMENT_INTENT_ID';"
+echo ""
+
+# 2. Same event delivered twice (should be a duplicate)
+echo "2. Duplicate Delivery..."
+send_event "{\"id\":\"evt_succeeded_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"payment_intent.succeeded\",\"data\":{\"object\":{\"id\":\"$PAYMENT_INTENT_ID\",\"object\":\"payment_intent\"}}}" | jq .
+echo ""
+
+# 2b. charge.refunded for a released hold that was never captured (should change nothing)
+echo "2b. Uncaptured charge.refunded..."
+send_event "{\"id\":\"evt_released_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"charge.refunded\",\"data\":{\"object\":{\"id\":\"ch_test\",\"object\":\"charge\",\"amount\":500,\"amount_refunded\":500,\"captured\":false,\"payment_intent\":\"$PAYMENT_INTENT_ID\"}}}" | jq .
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT id, payment_intent_id, payment_status, refunded_amount_pence, status FROM donations WHERE payment_intent_id = '$PAYMENT_INTENT_ID';"
+echo ""
+
+# 3. charge.refunded for part of the donation (should be partially_refunded, still captured)
+echo "3. Partial charge.refunded..."
+send_event "{\"id\":\"evt_partial_refund_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"charge.refunded\",\"data\":{\"object\":{\"id\":\"ch_test\",\"object\":\"charge\",\"amount\":500,\"amount_refunded\":200,\"captured\":true,\"payment_intent\":\"$PAYMENT_INTENT_ID\"}}}" | jq .
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT id, payment_intent_id, payment_status, refunded_amount_pence, status FROM donations WHERE payment_intent_id = '$PAYMENT_INTENT_ID';"
+echo ""
+
+# 3b. charge.refunded for the rest (should be refunded)
+echo "3b. Full charge.refunded..."
+send_event "{\"id\":\"evt_refunded_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"charge.refunded\",\"data\":{\"object\":{\"id\":\"ch_test\",\"object\":\"charge\",\"amount\":500,\"amount_refunded\":500,\"captured\":true,\"payment_intent\":\"$PAYMENT_INTENT_ID\"}}}" | jq .
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT id, payment_intent_id, payment_status, refunded_amount_pence, status FROM donations WHERE payment_intent_id = '$PAYMENT_INTENT_ID';"
+echo ""
+
+# 4. account.updated (charges and payouts enabled)
+echo "4. account.updated..."
+send_event "{\"id\":\"evt_account_$EVENT_SUFFIX\",\"object\":\"event\",\"type\":\"account.updated\",\"data\":{\"object\":{\"id\":\"acct_sample123\",\"object\":\"account\",\"charges_enabled\":true,\"payouts_enabled\":true}}}" | jq .
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT stripe_connect_account_id, onboarding_complete FROM payment_accounts WHERE stripe_connect_account_id = 'acct_sample123';"
+echo ""
+
+# 5. Bad signature (should fail)
+echo "5. Invalid Signature (should fail)..."
+curl -s -X POST "$BASE_URL/api/payments/webhook" \
+  -H "Content-Type: application/json" \
+  -H "Stripe-Signature: t=$(date +%s),v1=deadbeef" \
+  -d '{"id":"evt_forged","object":"event","type":"payment_intent.succeeded","data":{"object":{"id":"pi_forged"}}}' | jq .
+echo ""
+
+# 6. Missing signature (should fail)
+echo "6. Missing Signature (should fail)..."
+curl -s -X POST "$BASE_URL/api/payments/webhook" \
+  -H "Content-Type: application/json" \
+  -d '{"id":"evt_unsigned","object":"event","type":"payment_intent.succeeded"}' | jq .
+echo ""
+
+# 7. Replay events that were stored but never applied
+echo "7. Replay Unprocessed Events (should list and apply evt_account_$EVENT_SUFFIX)..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE stripe_events SET processed_at = NULL, processing_error = 'simulated failure' WHERE event_id = 'evt_account_$EVENT_SUFFIX';" >/dev/null
+docker exec donations_api ./main webhook-replay -dry-run
+docker exec donations_api ./main webhook-replay
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT event_id, processed_at IS NOT NULL AS processed, processing_error FROM stripe_events WHERE event_id = 'evt_account_$EVENT_SUFFIX';"
+echo ""
+
+echo "✅ Testing Complete!"
+echo "Check stored events with:"
+echo "docker exec -it donations_db psql -U postgres -d donations -c 'SELECT event_id, event_type, processed_at, processing_error FROM stripe_events;'"
@@ -0,0 +1,71 @@
+#!/usr/bin/env python3
+"""Stand-in for ClamAV's clamd, for the upload scanning tests.
+
+Speaks just enough of the clamd protocol (PING, INSTREAM) for the API: a stream
+containing the EICAR test string is reported as Eicar-Test-Signature FOUND,
+anything else as OK. Streams over --max-length get clamd's size limit error.
+
+    python3 fake_clamd.py [--port 3310] [--max-length 104857600]
+"""
+
+import argparse
+import socketserver
+import struct
+
+EICAR = b"EICAR-STANDARD-ANTIVIRUS-TEST-FILE"
+
+
+class Handler(socketserver.StreamRequestHandler):
+    def handle(self):
+        # Commands are prefixed with z (NUL-terminated) or n (newline-terminated)
+        prefix = self.rfile.read(1)
+        end = b"\0" if prefix == b"z" else b"\n"
+        command = b""
+        while not command.endswith(end):
+            byte = self.rfile.read(1)
+            if not byte:
+                return
+            command += byte
+        command = command[:-1]
+
+        if command == b"PING":
+            self.wfile.write(b"PONG" + end)
+            return
+        if command != b"INSTREAM":
+            self.wfile.write(b"UNKNOWN COMMAND" + end)
+            return
+
+        data = b""
+        while True:
+            header = self.rfile.read(4)
+            if len(header) < 4:
+                return
+            (length,) = struct.unpack(">I", header)
+            if length == 0:
+                break
+            data += self.rfile.read(length)
+            if len(data) > self.server.max_length:
+                self.wfile.write(b"INSTREAM size limit exceeded. ERROR" + end)
+                return
+
+        if EICAR in data:
+            self.wfile.write(b"stream: Eicar-Test-Signature FOUND" + end)
+        else:
+            self.wfile.write(b"stream: OK" + end)
+
+
+class Server(socketserver.ThreadingTCPServer):
+    allow_reuse_address = True
+    daemon_threads = True
+
+
+if __name__ == "__main__":
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--port", type=int, default=3310)
+    parser.add_argument("--max-length", type=int, default=100 * 1024 * 1024)
+    args = parser.parse_args()
+
+    with Server(("0.0.0.0", args.port), Handler) as server:
+        server.max_length = args.max_length
+        print(f"fake clamd listening on :{args.port}", flush=True)
+        server.serve_forever()
@@ -1,44 +1,58 @@
 #!/bin/bash
 
 # Get Video API Testing
-# Run: docker compose up -d
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d
 
 echo "🎬 Testing Get Video API"
 echo "========================"
 
 BASE_URL="http://localhost:8080"
 
+# Videos are only served from signed links (see tests/auth)
+VIDEO_LINK="$(dirname "$0")/../auth/video_link.sh"
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
 # Setup: Upload a test video first to have something to retrieve
 echo "🔧 Setting up test video..."
-echo "fake video content for testing getvideo endpoint" > /tmp/test_video_getvideo.mp4
+# Videos are only served once transcoded, so this needs to be a real video
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=1:size=320x240:rate=25 \
+  -c:v mpeg4 /tmp/test_video_getvideo.mp4
+docker cp donations_api:/tmp/test_video_getvideo.mp4 /tmp/test_video_getvideo.mp4
 
 UPLOAD_RESPONSE=$(curl -s -X POST "$BASE_URL/api/uploads/video" \
-  -F "video=@/tmp/test_video_getvideo.mp4")
+  -F "event_id=1" -F "video=@/tmp/test_video_getvideo.mp4")
 
 echo "Upload response: $UPLOAD_RESPONSE"
-VIDEO_URL=$(echo $UPLOAD_RESPONSE | jq -r '.video_url')
+VIDEO_URL=$(echo $UPLOAD_RESPONSE | jq -r '.video_url | split("?")[0]')
 FILENAME=$(basename "$VIDEO_URL")
 echo "📹 Test video filename: $FILENAME"
-echo ""
+SIGNED_URL=$("$VIDEO_LINK" "$VIDEO_URL")
 
-# Clean up temp file
-rm /tmp/test_video_getvideo.mp4
+# Wait for transcoding to finish (GetVideo answers 503 until then)
+for _ in $(seq 1 30); do
+  [ "$(curl -s -o /dev/null -w "%{http_code}" "$SIGNED_URL")" != "503" ] && break
+  sleep 2
+done
+echo ""
 
 # 1. Valid video retrieval
 echo "1. Valid Video Retrieval..."
-curl -s -I "$BASE_URL/api/videos/$FILENAME" | head -5
+curl -s -I "$SIGNED_URL" | head -5
 echo ""
 
 # 2. Download video content
 echo "2. Download Video Content..."
-CONTENT=$(curl -s "$BASE_URL/api/videos/$FILENAME")
+CONTENT=$(curl -s "$SIGNED_URL")
 echo "Content preview: ${CONTENT:0:50}..."
 echo "Content length: ${#CONTENT} characters"
 echo ""
 
 # 3. Non-existent video
 echo "3. Non-existent Video..."
-curl -s "$BASE_URL/api/videos/nonexistent_video.mp4" | jq .
+curl -s "$("$VIDEO_LINK" "$BASE_URL/api/videos/nonexistent_video.mp4")" | jq .
 echo ""
 
 # 4. Invalid filename with path traversal
@@ -63,47 +77,68 @@ echo ""
 
 # 8. Test video seeking (range requests)
 echo "8. Test Range Request (Video Seeking)..."
-curl -s -H "Range: bytes=0-100" -I "$BASE_URL/api/videos/$FILENAME" | grep -E "(HTTP|Content-Range|Accept-Ranges)"
+curl -s -H "Range: bytes=0-100" -I "$SIGNED_URL" | grep -E "(HTTP|Content-Range|Accept-Ranges)"
 echo ""
 
 # 9. Check cache headers
-echo "9. Check Cache Headers..."
-curl -s -I "$BASE_URL/api/videos/$FILENAME" | grep -E "(Cache-Control|Content-Type)"
+echo "9. Check Cache Headers (should be private, no-store)..."
+curl -s -I "$SIGNED_URL" | grep -E "(Cache-Control|Content-Type)"
 echo ""
 
 # 10. Test with different video extensions
-echo "10. Upload and Test Different Extensions..."
+echo "10. Upload and Test Different Extensions (503 until transcoded, then video/mp4)..."
 
 # Upload .mov file
-echo "fake mov content" > /tmp/test.mov
-MOV_UPLOAD=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "video=@/tmp/test.mov")
-MOV_URL=$(echo $MOV_UPLOAD | jq -r '.video_url')
+cp /tmp/test_video_getvideo.mp4 /tmp/test.mov
+MOV_UPLOAD=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/test.mov")
+MOV_URL=$(echo $MOV_UPLOAD | jq -r '.video_url | split("?")[0]')
 MOV_FILENAME=$(basename "$MOV_URL")
 echo "MOV upload: $MOV_URL"
-curl -s -I "$BASE_URL/api/videos/$MOV_FILENAME" | head -3
+curl -s -I "$("$VIDEO_LINK" "$MOV_URL")" | head -3
 rm /tmp/test.mov
 echo ""
 
 # Upload .webm file  
-echo "fake webm content" > /tmp/test.webm
-WEBM_UPLOAD=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "video=@/tmp/test.webm")
-WEBM_URL=$(echo $WEBM_UPLOAD | jq -r '.video_url')
+cp /tmp/test_video_getvideo.mp4 /tmp/test.webm
+WEBM_UPLOAD=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/test.webm")
+WEBM_URL=$(echo $WEBM_UPLOAD | jq -r '.video_url | split("?")[0]')
 WEBM_FILENAME=$(basename "$WEBM_URL")
 echo "WEBM upload: $WEBM_URL"
-curl -s -I "$BASE_URL/api/videos/$WEBM_FILENAME" | head -3
+curl -s -I "$("$VIDEO_LINK" "$WEBM_URL")" | head -3
 rm /tmp/test.webm
 echo ""
 
+# 11. Videos are private - links must be signed, unexpired and for this video
+echo "11. Unsigned Link (should be 403 Invalid video link)..."
+curl -s "$VIDEO_URL" | jq .
+echo ""
+
+echo "12. Expired Link (should be 403 Video link has expired)..."
+curl -s "$("$VIDEO_LINK" "$VIDEO_URL" -60)" | jq .
+echo ""
+
+echo "13. Link Signed For Another Video (should be 403 Invalid video link)..."
+OTHER_QUERY=$("$VIDEO_LINK" "$MOV_URL" | cut -d'?' -f2)
+curl -s "$VIDEO_URL?$OTHER_QUERY" | jq .
+echo ""
+
+echo "14. Link With A Longer Expiry Than Signed (should be 403 Invalid video link)..."
+curl -s "$(echo "$SIGNED_URL" | sed -E 's/expires=[0-9]+/expires=9999999999/')" | jq .
+echo ""
+
+# Clean up temp file
+rm /tmp/test_video_getvideo.mp4
+
 echo "✅ Testing Complete!"
 echo ""
 echo "🔍 Check uploaded videos:"
 echo "docker exec donations_api ls -la /var/uploads/videos/"
 echo ""
-echo "📺 Test in browser:"
-echo "Open: $VIDEO_URL"
+echo "📺 Test in browser (the link works for an hour):"
+echo "Open: $SIGNED_URL"
 echo "Should play/download the test video file"
 echo ""
 echo "🎬 Frontend Integration:"
 echo "<video controls>"
-echo "  <source src=\"$VIDEO_URL\" type=\"video/mp4\">"
+echo "  <source src=\"$SIGNED_URL\" type=\"video/mp4\">"
 echo "</video>"
@@ -0,0 +1,86 @@
+#!/bin/bash
+
+# S3 Video Storage Testing (MinIO)
+# Run: docker compose -f docker-compose.yml -f docker-compose.minio.yml up -d --build
+#      (STORAGE_DRIVER=s3, VIDEO_SERVE_MODE=presign)
+
+echo "🪣 Testing S3 Video Storage"
+echo "==========================="
+
+BASE_URL="http://localhost:8080"
+
+# Videos are only served from signed links (see tests/auth)
+VIDEO_LINK="$(dirname "$0")/../auth/video_link.sh"
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+# Videos are only served once transcoded, so this needs to be a real video
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=1:size=320x240:rate=25 \
+  -c:v mpeg4 /tmp/test_video_s3.mp4
+docker cp donations_api:/tmp/test_video_s3.mp4 /tmp/test_video_s3.mp4
+
+# 1. Upload goes to the bucket
+echo "1. Upload Video..."
+UPLOAD_RESPONSE=$(curl -s -X POST "$BASE_URL/api/uploads/video" \
+  -H "X-Forwarded-Proto: https" \
+  -H "X-Forwarded-Host: api.example.com" \
+  -F "event_id=1" -F "video=@/tmp/test_video_s3.mp4")
+echo "$UPLOAD_RESPONSE" | jq .
+FILENAME=$(basename "$(echo "$UPLOAD_RESPONSE" | jq -r '.video_url | split("?")[0]')")
+echo "(video_url should start with https://api.example.com/api/videos/)"
+VIDEO_URL=$("$VIDEO_LINK" "$VIDEO_URL")
+echo -e "\n"
+
+# 2. Not written to the API's disk
+echo "2. File Is Not On Local Disk..."
+docker exec donations_api ls "/var/uploads/videos/$FILENAME" 2>&1 || echo "not on disk (expected)"
+echo -e "\n"
+
+# 3. Object is in the bucket
+echo "3. Object In MinIO..."
+docker exec donations_minio sh -c \
+  "mc alias set local http://localhost:9000 minioadmin minioadmin >/dev/null && mc stat local/aletterahead-uploads/videos/$FILENAME"
+echo -e "\n"
+
+# Wait for transcoding (503 until then); the transcoded copy is stored in the bucket too
+for _ in $(seq 1 30); do
+  [ "$(curl -s -o /dev/null -w "%{http_code}" "$VIDEO_URL")" != "503" ] && break
+  sleep 2
+done
+docker exec donations_minio mc ls -r local/aletterahead-uploads/videos/transcoded/ | grep "$FILENAME"
+echo -e "\n"
+
+# 4. Presign mode redirects to a signed bucket URL
+echo "4. Redirect To Presigned URL (should be 302)..."
+curl -s -I "$VIDEO_URL" | grep -E "(HTTP|Location|Cache-Control)"
+echo -e "\n"
+
+# 5. Following the redirect returns the transcoded file
+echo "5. Download Through Presigned URL (should be 200 video/mp4)..."
+curl -s -L -o /dev/null -w "HTTP %{http_code} %{content_type} %{size_download} bytes\n" "$VIDEO_URL"
+echo -e "\n"
+
+# 6. Range request through the redirect (video seeking)
+echo "6. Range Request (should be 206)..."
+curl -s -L -o /dev/null -w "HTTP %{http_code}\n" -H "Range: bytes=0-9" "$VIDEO_URL"
+echo -e "\n"
+
+# 7. Tampered signature is refused by the bucket
+echo "7. Tampered Presigned URL (should be 403)..."
+LOCATION=$(curl -s -I "$VIDEO_URL" | grep -i '^Location:' | cut -d' ' -f2 | tr -d '\r')
+curl -s -o /dev/null -w "HTTP %{http_code}\n" "${LOCATION%?}x"
+echo -e "\n"
+
+# 8. Missing video
+echo "8. Non-existent Video (should be 404)..."
+curl -s "$("$VIDEO_LINK" "$BASE_URL/api/videos/nonexistent_video.mp4")" | jq .
+echo -e "\n"
+
+rm /tmp/test_video_s3.mp4
+
+echo "✅ Testing Complete!"
+echo ""
+echo "Set VIDEO_SERVE_MODE=stream to stream from the bucket through the API instead;"
+echo "tests/videoview_tests/getvideo_test.sh then passes against either storage driver."
@@ -0,0 +1,124 @@
+#!/bin/bash
+
+# Video Streaming Testing (ranges, ETags, conditional requests, content types)
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (STORAGE_DRIVER=local, VIDEO_SERVE_MODE=stream)
+
+echo "📡 Testing Video Streaming"
+echo "=========================="
+
+BASE_URL="http://localhost:8080"
+
+# Videos are only served from signed links (see tests/auth)
+VIDEO_LINK="$(dirname "$0")/../auth/video_link.sh"
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+header() {
+  grep -i "^$1:" | head -1 | cut -d' ' -f2- | tr -d '\r'
+}
+
+# Setup: a transcoded upload, and an old-style upload (no transcode job) whose name doesn't match its content
+echo "🔧 Setting up test videos..."
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=2:size=320x240:rate=25 \
+  -c:v mpeg4 /tmp/streaming_test.mp4
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=1:size=160x120:rate=25 \
+  -c:v libvpx /tmp/streaming_legacy.webm
+docker cp donations_api:/tmp/streaming_test.mp4 /tmp/streaming_test.mp4
+
+FILENAME=$(basename "$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/streaming_test.mp4" | jq -r '.video_url | split("?")[0]')")
+URL=$("$VIDEO_LINK" "$BASE_URL/api/videos/$FILENAME")
+for _ in $(seq 1 30); do
+  [ "$(curl -s -o /dev/null -w "%{http_code}" "$URL")" != "503" ] && break
+  sleep 2
+done
+
+LEGACY_FILE="streaming_legacy_$(date +%s).mp4"
+docker exec donations_api cp /tmp/streaming_legacy.webm "/var/uploads/videos/$LEGACY_FILE"
+
+HEADERS=$(curl -s -D - -o /tmp/streaming_full.mp4 "$URL")
+ETAG=$(echo "$HEADERS" | header ETag)
+LAST_MODIFIED=$(echo "$HEADERS" | header Last-Modified)
+SIZE=$(stat -c %s /tmp/streaming_full.mp4)
+echo ""
+
+# 1. Full response
+echo "1. GET (should be 200 video/mp4 with a quoted ETag and Last-Modified)..."
+echo "$HEADERS" | grep -iE "^(HTTP|Content-Type|Content-Length|Accept-Ranges|ETag|Last-Modified|Cache-Control)"
+echo -e "\n"
+
+echo "2. HEAD (same headers, no body)..."
+curl -s -I "$URL" | grep -iE "^(HTTP|Content-Type|Content-Length|ETag)"
+echo -e "\n"
+
+# 3. Ranges
+echo "3. Single Range bytes=0-99 (should be 206, Content-Range bytes 0-99/$SIZE, 100 bytes)..."
+curl -s -D - -o /tmp/streaming_range.bin -H "Range: bytes=0-99" "$URL" | grep -iE "^(HTTP|Content-Range|Content-Length)"
+cmp <(head -c 100 /tmp/streaming_full.mp4) /tmp/streaming_range.bin && echo "✅ bytes match"
+echo -e "\n"
+
+echo "4. Suffix Range bytes=-10 (should be 206, the last 10 bytes)..."
+curl -s -D - -o /tmp/streaming_range.bin -H "Range: bytes=-10" "$URL" | grep -iE "^(HTTP|Content-Range)"
+cmp <(tail -c 10 /tmp/streaming_full.mp4) /tmp/streaming_range.bin && echo "✅ bytes match"
+echo -e "\n"
+
+echo "5. Multiple Ranges bytes=0-9,100-109 (should be 206 multipart/byteranges with two parts)..."
+curl -s -D - -o /tmp/streaming_multi.bin -H "Range: bytes=0-9,100-109" "$URL" | grep -iE "^(HTTP|Content-Type)"
+grep -a "Content-Range" /tmp/streaming_multi.bin
+echo -e "\n"
+
+echo "6. Range Past The End (should be 416, Content-Range bytes */$SIZE)..."
+curl -s -D - -o /dev/null -H "Range: bytes=$((SIZE + 100))-" "$URL" | grep -iE "^(HTTP|Content-Range)"
+echo -e "\n"
+
+# 7. Conditional requests
+echo "7. If-None-Match With The ETag (should be 304, no body)..."
+curl -s -D - -o /dev/null -w "body: %{size_download} bytes\n" -H "If-None-Match: $ETAG" "$URL" | grep -iE "^(HTTP|ETag)|body"
+echo -e "\n"
+
+echo "8. If-None-Match Weak Form / Another ETag (should be 304 / 200)..."
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -H "If-None-Match: W/$ETAG" "$URL"
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -H 'If-None-Match: "something-else"' "$URL"
+echo -e "\n"
+
+echo "9. If-Modified-Since Last-Modified (should be 304)..."
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -H "If-Modified-Since: $LAST_MODIFIED" "$URL"
+echo -e "\n"
+
+echo "10. If-Match Another ETag (should be 412) / The ETag (should be 200)..."
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -H 'If-Match: "something-else"' "$URL"
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -H "If-Match: $ETAG" "$URL"
+echo -e "\n"
+
+echo "11. If-Range Current ETag (should be 206) / Old ETag (should be 200, whole file)..."
+curl -s -o /dev/null -w "HTTP %{http_code}\n" -H "Range: bytes=0-9" -H "If-Range: $ETAG" "$URL"
+curl -s -o /dev/null -w "HTTP %{http_code} %{size_download} bytes\n" -H "Range: bytes=0-9" -H 'If-Range: "something-else"' "$URL"
+echo -e "\n"
+
+# 12. The ETag follows the content
+echo "12. ETag Changes When The File Does..."
+TRANSCODED="/var/uploads/videos/transcoded/$FILENAME.mp4"
+docker exec donations_api sh -c "cp $TRANSCODED /tmp/streaming_backup.mp4 && printf 'x' >> $TRANSCODED"
+NEW_ETAG=$(curl -s -I "$URL" | header ETag)
+echo "before: $ETAG after: $NEW_ETAG"
+[ "$ETAG" != "$NEW_ETAG" ] && echo "✅ ETag changed" || echo "❌ ETag unchanged"
+curl -s -o /dev/null -w "If-None-Match old ETag: HTTP %{http_code} (should be 200)\n" -H "If-None-Match: $ETAG" "$URL"
+docker exec donations_api sh -c "cp /tmp/streaming_backup.mp4 $TRANSCODED && rm /tmp/streaming_backup.mp4"
+echo -e "\n"
+
+# 13. Content type comes from the file, not its name
+echo "13. WebM Stored As .mp4 (should be served as video/webm)..."
+curl -s -I "$("$VIDEO_LINK" "$BASE_URL/api/videos/$LEGACY_FILE")" | grep -iE "^(HTTP|Content-Type)"
+echo -e "\n"
+
+echo "14. Thumbnail (should be image/jpeg, with ETag and 304 handling)..."
+THUMB_URL=$("$VIDEO_LINK" "$BASE_URL/api/videos/$FILENAME/thumbnail")
+THUMB_ETAG=$(curl -s -I "$THUMB_URL" | tee /dev/stderr | header ETag)
+curl -s -o /dev/null -w "If-None-Match: HTTP %{http_code}\n" -H "If-None-Match: $THUMB_ETAG" "$THUMB_URL"
+echo -e "\n"
+
+docker exec donations_api rm -f "/var/uploads/videos/$LEGACY_FILE" /tmp/streaming_test.mp4 /tmp/streaming_legacy.webm
+rm -f /tmp/streaming_test.mp4 /tmp/streaming_full.mp4 /tmp/streaming_range.bin /tmp/streaming_multi.bin
+
+echo "✅ Testing Complete!"
@@ -0,0 +1,85 @@
+#!/bin/bash
+
+# Video Thumbnail Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (THUMBNAIL_OFFSET=1s)
+
+echo "🖼️  Testing Video Thumbnails"
+echo "==========================="
+
+BASE_URL="http://localhost:8080"
+
+# Videos are only served from signed links (see tests/auth)
+VIDEO_LINK="$(dirname "$0")/../auth/video_link.sh"
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+wait_for_video() {
+  for _ in $(seq 1 30); do
+    [ "$(curl -s -o /dev/null -w "%{http_code}" "$1")" != "503" ] && return
+    sleep 2
+  done
+}
+
+# 3 second clip, and one shorter than THUMBNAIL_OFFSET
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=3:size=1280x720:rate=25 \
+  -c:v mpeg4 /tmp/thumb_test.mp4
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=0.4:size=320x240:rate=25 \
+  -c:v mpeg4 /tmp/thumb_short.mp4
+docker cp donations_api:/tmp/thumb_test.mp4 /tmp/thumb_test.mp4
+docker cp donations_api:/tmp/thumb_short.mp4 /tmp/thumb_short.mp4
+
+UPLOAD=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/thumb_test.mp4")
+VIDEO_URL=$(echo "$UPLOAD" | jq -r '.video_url | split("?")[0]')
+VIDEO_ID=$(echo "$UPLOAD" | jq -r '.video_id')
+SHORT_URL=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/thumb_short.mp4" | jq -r '.video_url | split("?")[0]')
+
+# 1. Not available until transcoded
+echo "1. Thumbnail Before Transcoding (503 unless already done)..."
+curl -s -o /dev/null -w "HTTP %{http_code}\n" "$("$VIDEO_LINK" "$VIDEO_URL/thumbnail")"
+echo -e "\n"
+
+# 2. JPEG once ready, scaled down to 640px wide
+echo "2. Thumbnail After Transcoding (should be 200 image/jpeg, 640x360)..."
+wait_for_video "$("$VIDEO_LINK" "$VIDEO_URL")"
+curl -s -I "$("$VIDEO_LINK" "$VIDEO_URL/thumbnail")" | grep -E "(HTTP|Content-Type|Cache-Control)"
+curl -s "$("$VIDEO_LINK" "$VIDEO_URL/thumbnail")" -o /tmp/thumb.jpg
+docker cp /tmp/thumb.jpg donations_api:/tmp/thumb.jpg
+docker exec donations_api ffprobe -v error -show_entries stream=codec_name,width,height -of compact /tmp/thumb.jpg
+echo -e "\n"
+
+# 3. Shorter than THUMBNAIL_OFFSET - falls back to the first frame
+echo "3. Short Video Thumbnail (should be 200)..."
+wait_for_video "$("$VIDEO_LINK" "$SHORT_URL")"
+curl -s -o /dev/null -w "HTTP %{http_code} %{content_type}\n" "$("$VIDEO_LINK" "$SHORT_URL/thumbnail")"
+echo -e "\n"
+
+# 4. thumbnail_url on the donations list
+echo "4. thumbnail_url In List Donations (signed links, thumbnail should be 200)..."
+curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d "{\"event_id\": 1, \"donor_name\": \"Thumbnail Tester\", \"amount_pence\": 1000, \"video_id\": $VIDEO_ID}" > /dev/null
+LISTED=$(curl -s -X POST "$BASE_URL/api/donations/list" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1}' | jq '.donations[] | select(.donor_name == "Thumbnail Tester") | {video_address, thumbnail_url}')
+echo "$LISTED"
+curl -s -o /dev/null -w "HTTP %{http_code} %{content_type}\n" "$(echo "$LISTED" | jq -r '.thumbnail_url')"
+echo -e "\n"
+
+# 5. Errors
+echo "5. Unknown Video (should be 404) / Bad Filename (should be 400) / Unsigned (should be 403)..."
+curl -s "$("$VIDEO_LINK" "$BASE_URL/api/videos/nonexistent_video.mp4/thumbnail")" | jq .
+curl -s "$VIDEO_URL/thumbnail" | jq .
+echo "Video's own link on the thumbnail (should be 403):"
+curl -s "$VIDEO_URL/thumbnail?$("$VIDEO_LINK" "$VIDEO_URL" | cut -d'?' -f2)" | jq .
+curl -s "$BASE_URL/api/videos/notes.txt/thumbnail" | jq .
+echo -e "\n"
+
+rm -f /tmp/thumb_test.mp4 /tmp/thumb_short.mp4 /tmp/thumb.jpg
+
+echo "✅ Testing Complete!"
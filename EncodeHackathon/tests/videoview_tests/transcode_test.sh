@@ -0,0 +1,94 @@
+#!/bin/bash
+
+# Video Transcoding Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (the api image includes ffmpeg)
+
+echo "🎞️  Testing Video Transcoding"
+echo "============================"
+
+BASE_URL="http://localhost:8080"
+
+# Videos are only served from signed links (see tests/auth)
+VIDEO_LINK="$(dirname "$0")/../auth/video_link.sh"
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+# Make a small QuickTime clip (MPEG-4 video, PCM audio - neither plays in most browsers)
+docker exec donations_api ffmpeg -loglevel error -y \
+  -f lavfi -i testsrc=duration=2:size=321x241:rate=25 \
+  -f lavfi -i sine=duration=2 \
+  -c:v mpeg4 -c:a pcm_s16le /tmp/transcode_test.mov
+docker cp donations_api:/tmp/transcode_test.mov /tmp/transcode_test.mov
+
+# Wait for the video at $1 to finish processing, printing its final HTTP status
+wait_for_video() {
+  for _ in $(seq 1 30); do
+    CODE=$(curl -s -o /dev/null -w "%{http_code}" "$1")
+    if [ "$CODE" != "503" ]; then
+      echo "$CODE"
+      return
+    fi
+    sleep 2
+  done
+  echo "timeout"
+}
+
+# 1. Upload - queued for transcoding
+echo "1. Upload .mov (status should be queued)..."
+UPLOAD=$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/transcode_test.mov")
+echo "$UPLOAD" | jq .
+FILENAME=$(basename "$(echo "$UPLOAD" | jq -r '.video_url | split("?")[0]')")
+VIDEO_URL=$("$VIDEO_LINK" "$(echo "$UPLOAD" | jq -r '.video_url | split("?")[0]')")
+echo -e "\n"
+
+# 2. Not served until ready
+echo "2. Get Video Before Transcoding (503 unless already done)..."
+curl -s -i "$VIDEO_URL" | grep -E "(HTTP|Retry-After|status)"
+echo -e "\n"
+
+# 3. Job status in the database
+echo "3. Job Status..."
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT source_filename, status, attempts FROM video_transcodes WHERE source_filename = '$FILENAME';"
+echo -e "\n"
+
+# 4. Served once ready
+echo "4. Wait For Transcoding (should be 200)..."
+wait_for_video "$VIDEO_URL"
+curl -s -I "$VIDEO_URL" | grep -E "(HTTP|Content-Type|Content-Length)"
+echo -e "\n"
+
+# 5. Output is H.264/AAC with faststart
+echo "5. Check Output Codecs (h264, aac) And Faststart..."
+curl -s "$VIDEO_URL" -o /tmp/transcode_output.mp4
+docker cp /tmp/transcode_output.mp4 donations_api:/tmp/transcode_output.mp4
+docker exec donations_api ffprobe -v error -show_entries stream=codec_name,width,height \
+  -of compact /tmp/transcode_output.mp4
+FIRST_ATOM=$(grep -obUaE "moov|mdat" /tmp/transcode_output.mp4 | head -1 | cut -d: -f2)
+[ "$FIRST_ATOM" = "moov" ] && echo "✅ moov before mdat (faststart)" || echo "❌ moov after mdat"
+echo -e "\n"
+
+# 6. Seeking still works on the transcoded file
+echo "6. Range Request (should be 206)..."
+curl -s -I -H "Range: bytes=0-99" "$VIDEO_URL" | grep -E "(HTTP|Content-Range)"
+echo -e "\n"
+
+# 7. A stored video ffmpeg can't read ends up failed (uploads are checked with ffprobe,
+# so corrupt the stored copy of a good upload and requeue it)
+echo "7. Broken Source (should end 422 / failed)..."
+BROKEN_FILE=$(basename "$(curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/transcode_test.mov" | jq -r '.video_url | split("?")[0]')")
+BROKEN_URL=$("$VIDEO_LINK" "$BASE_URL/api/videos/$BROKEN_FILE")
+docker exec donations_api sh -c "head -c 4096 /dev/urandom > /var/uploads/videos/$BROKEN_FILE"
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE video_transcodes SET status = 'queued', attempts = 0, output_key = NULL, thumbnail_key = NULL WHERE source_filename = '$BROKEN_FILE';" >/dev/null
+wait_for_video "$BROKEN_URL"
+curl -s "$BROKEN_URL" | jq .
+docker exec donations_db psql -U postgres -d donations -c \
+  "SELECT status, attempts, LEFT(error, 80) AS error FROM video_transcodes WHERE source_filename = '$BROKEN_FILE';"
+echo -e "\n"
+
+rm -f /tmp/transcode_test.mov /tmp/transcode_output.mp4
+
+echo "✅ Testing Complete!"
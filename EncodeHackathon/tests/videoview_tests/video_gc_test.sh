@@ -0,0 +1,132 @@
+#!/bin/bash
+
+# Orphaned Video Collection Testing
+# Run: docker compose -f docker-compose.yml -f docker-compose.test.yml up -d --build   (STORAGE_DRIVER=local, VIDEO_GC_GRACE_PERIOD=72h)
+
+echo "🧹 Testing Orphaned Video Collection"
+echo "===================================="
+
+BASE_URL="http://localhost:8080"
+
+# Videos are only served from signed links (see tests/auth)
+VIDEO_LINK="$(dirname "$0")/../auth/video_link.sh"
+
+# Parent endpoints need an Auth0 access token (see tests/auth)
+TOKEN=$("$(dirname "$0")/../auth/token.sh" "auth0|sample123")
+
+# Videos are uploaded for an event, which must be open and taking videos
+docker exec donations_db psql -U postgres -d donations -c \
+  "UPDATE events SET expires_at = NOW() + INTERVAL '30 days', videos_enabled = true WHERE event_id = 1;" >/dev/null
+
+psql_donations() {
+  docker exec donations_db psql -U postgres -d donations -c "$1"
+}
+
+upload() {
+  curl -s -X POST "$BASE_URL/api/uploads/video" -F "event_id=1" -F "video=@/tmp/gc_test.mp4"
+}
+
+donate() {
+  curl -s -X POST "$BASE_URL/api/donations/create" \
+    -H "Content-Type: application/json" \
+    -d '{"event_id": 1, "donor_name": "'"$1"'", "amount_pence": 500, "video_id": '"$2"'}' | jq -r '.donation_id'
+}
+
+wait_for_video() {
+  for _ in $(seq 1 30); do
+    [ "$(curl -s -o /dev/null -w "%{http_code}" "$1")" != "503" ] && return
+    sleep 2
+  done
+}
+
+docker exec donations_api ffmpeg -loglevel error -y -f lavfi -i testsrc=duration=1:size=320x240:rate=25 /tmp/gc_test.mp4
+docker cp donations_api:/tmp/gc_test.mp4 /tmp/gc_test.mp4
+
+# Setup: a video nobody used, one whose donation was rejected, one whose donation expired,
+# one in a live donation, and a stray file with no record
+echo "🔧 Setting up videos..."
+UNUSED=$(upload)
+UNUSED_ID=$(echo "$UNUSED" | jq -r '.video_id')
+UNUSED_FILE=$(basename "$(echo "$UNUSED" | jq -r '.video_url | split("?")[0]')")
+
+REJECTED=$(upload)
+REJECTED_ID=$(echo "$REJECTED" | jq -r '.video_id')
+REJECTED_FILE=$(basename "$(echo "$REJECTED" | jq -r '.video_url | split("?")[0]')")
+REJECTED_DONATION=$(donate "GC Rejected" "$REJECTED_ID")
+curl -s -X POST "$BASE_URL/api/donations/approve" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d "{\"donation_id\": $REJECTED_DONATION, \"approved\": false}" > /dev/null
+
+EXPIRED=$(upload)
+EXPIRED_ID=$(echo "$EXPIRED" | jq -r '.video_id')
+EXPIRED_FILE=$(basename "$(echo "$EXPIRED" | jq -r '.video_url | split("?")[0]')")
+EXPIRED_DONATION=$(donate "GC Expired" "$EXPIRED_ID")
+psql_donations "UPDATE donations SET status = 'expired' WHERE id = $EXPIRED_DONATION;" >/dev/null
+
+KEPT=$(upload)
+KEPT_ID=$(echo "$KEPT" | jq -r '.video_id')
+KEPT_FILE=$(basename "$(echo "$KEPT" | jq -r '.video_url | split("?")[0]')")
+donate "GC Kept" "$KEPT_ID" > /dev/null
+
+docker exec donations_api sh -c "cp /tmp/gc_test.mp4 /var/uploads/videos/gc_stray.mp4 && touch -d '2020-01-01' /var/uploads/videos/gc_stray.mp4"
+
+# Transcoding videos are never collected, so let them finish
+for f in "$UNUSED_FILE" "$REJECTED_FILE" "$EXPIRED_FILE" "$KEPT_FILE"; do
+  wait_for_video "$("$VIDEO_LINK" "$BASE_URL/api/videos/$f")"
+done
+
+# Make everything older than the grace period
+psql_donations "UPDATE videos SET created_at = NOW() - INTERVAL '4 days' WHERE video_id IN ($UNUSED_ID, $REJECTED_ID, $EXPIRED_ID, $KEPT_ID);" >/dev/null
+psql_donations "UPDATE donations SET updated_at = NOW() - INTERVAL '4 days' WHERE id IN ($REJECTED_DONATION, $EXPIRED_DONATION);" >/dev/null
+echo ""
+
+# 1. Dry run reports, touches nothing
+echo "1. Dry Run (should list $UNUSED_FILE, $REJECTED_FILE, $EXPIRED_FILE and gc_stray.mp4, not $KEPT_FILE)..."
+docker exec donations_api ./main gc -dry-run
+docker exec donations_api ls /var/uploads/videos/$UNUSED_FILE /var/uploads/videos/gc_stray.mp4
+echo -e "\n"
+
+# 2. Nothing is old enough with a long grace period
+echo "2. Dry Run With -grace 720h (should find none of them)..."
+docker exec donations_api ./main gc -dry-run -grace 720h | grep -E "$UNUSED_FILE|$REJECTED_FILE|$EXPIRED_FILE|gc_stray" || echo "none found (expected)"
+echo -e "\n"
+
+# 3. Quarantine
+echo "3. Quarantine..."
+docker exec donations_api ./main gc -mode quarantine
+echo "In quarantine:"
+docker exec donations_api find /var/uploads/quarantine -type f | grep -E "$UNUSED_FILE|$REJECTED_FILE|$EXPIRED_FILE|gc_stray"
+echo "Still in videos/ (should only be $KEPT_FILE):"
+docker exec donations_api sh -c "ls /var/uploads/videos/ | grep -E '$UNUSED_FILE|$REJECTED_FILE|$EXPIRED_FILE|$KEPT_FILE|gc_stray'"
+echo -e "\n"
+
+# 4. Records are kept and marked
+echo "4. Video Records (quarantined / quarantined / quarantined / attached)..."
+psql_donations "SELECT video_id, status, removed_reason, removed_at IS NOT NULL AS removed FROM videos WHERE video_id IN ($UNUSED_ID, $REJECTED_ID, $EXPIRED_ID, $KEPT_ID) ORDER BY video_id;"
+echo -e "\n"
+
+# 5. A collected video can't be used any more
+echo "5. Donation With The Collected Video (should be 400)..."
+curl -s -X POST "$BASE_URL/api/donations/create" \
+  -H "Content-Type: application/json" \
+  -d '{"event_id": 1, "donor_name": "Too Late", "amount_pence": 500, "video_id": '"$UNUSED_ID"'}' | jq .
+curl -s -o /dev/null -w "GET collected video: HTTP %{http_code} (should be 404)\n" "$("$VIDEO_LINK" "$BASE_URL/api/videos/$UNUSED_FILE")"
+echo -e "\n"
+
+# 6. Collection only happens once
+echo "6. Run Again With -mode delete (should remove none of them)..."
+docker exec donations_api ./main gc -mode delete | grep -E "$UNUSED_FILE|$REJECTED_FILE|$EXPIRED_FILE|gc_stray" || echo "none found (expected)"
+echo -e "\n"
+
+# 7. Bad flags
+echo "7. Invalid Mode (should fail)..."
+docker exec donations_api ./main gc -mode shred
+echo -e "\n"
+
+docker exec donations_api sh -c "rm -rf /var/uploads/quarantine/videos/$UNUSED_FILE* /var/uploads/quarantine/videos/$REJECTED_FILE* /var/uploads/quarantine/videos/$EXPIRED_FILE* /var/uploads/quarantine/videos/gc_stray.mp4 \
+  /var/uploads/quarantine/videos/transcoded/$UNUSED_FILE* /var/uploads/quarantine/videos/transcoded/$REJECTED_FILE* /var/uploads/quarantine/videos/transcoded/$EXPIRED_FILE* \
+  /var/uploads/quarantine/videos/thumbnails/$UNUSED_FILE* /var/uploads/quarantine/videos/thumbnails/$REJECTED_FILE* /var/uploads/quarantine/videos/thumbnails/$EXPIRED_FILE*"
+rm -f /tmp/gc_test.mp4
+
+echo "✅ Testing Complete!"